@@ -1,6 +1,7 @@
 package aof
 
 import (
+	"bufio"
 	"go-redis/config"
 	databaseface "go-redis/interface/database"
 	"go-redis/lib/utils"
@@ -11,98 +12,330 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type CmdLine = [][]byte
 
 const (
 	aofQueueSize = 1 << 16
+	// rdbPreamble RDB文件的开头
+	rdbPreamble = "REDIS"
 )
 
+// appendfsync的取值
+const (
+	// FsyncAlways 每次写入后都fsync，fsync完成后才回复客户端
+	FsyncAlways = "always"
+	// FsyncEverySec 每秒fsync一次，最多丢失1秒的数据
+	FsyncEverySec = "everysec"
+	// FsyncNo 不主动fsync，由操作系统决定何时落盘
+	FsyncNo = "no"
+)
+
+// payload 中的多条命令会一次性写入文件
 type payload struct {
-	cmdLine CmdLine
+	cmdLines []CmdLine
 	dbIndex int
+	// rewriteStarted 不为nil时表示AOF重写从这里开始，之后的数据写入新的incr文件，打开incr文件的结果通过它返回
+	rewriteStarted chan error
+	// done 不为nil时写入(appendfsync always时包括fsync)完成后关闭
+	done chan struct{}
 }
 
 // AofHandler 作用是：
 // 1. 从管道中接收数据
-// 2. 写入Aof文件
+// 2. 写入Aof文件，文件布局见manifest.go
 type AofHandler struct {
-	db databaseface.Database
+	db databaseface.DBEngine
 	aofChan chan *payload
+	// aofFile 正在写入的incr文件
 	aofFile *os.File
+	aofDir string
+	// aofFilename 各个文件名的前缀
 	aofFilename string
+	manifest *aofManifest
+	// currentDB 为-1时下一次写入前需要先写入select
 	currentDB int
+	// pausingAof 写入文件时加锁，切换incr文件或更新manifest时暂停写入
+	pausingAof sync.Mutex
+	rewriting atomic.Bool
+	// rewriteIncrSeq 重写开始时打开的incr文件，重写完成后它和之后的incr文件保留在manifest中
+	rewriteIncrSeq int64
+	// rewriteStartSize 重写开始时所有文件的大小
+	rewriteStartSize int64
+	// aofSize 所有文件的大小，baseSize 上次重写后的大小，用于判断是否需要自动重写
+	aofSize int64
+	baseSize int64
+	rewritePercentage int
+	rewriteMinSize int64
+	// useRdbPreamble 重写时以RDB格式写入快照
+	useRdbPreamble bool
+	// fsyncPolicy appendfsync配置
+	fsyncPolicy string
+	// needFsync 上次fsync之后有新的写入
+	needFsync bool
+	// lastWriteErr 最近一次写入或fsync的错误，成功时为nil
+	lastWriteErr error
+	closing chan struct{}
+	closeOnce sync.Once
+	// closeMu 保护closed，关闭aofChan之后不能再发送
+	closeMu sync.RWMutex
+	closed bool
+	// aofFinished handleAof处理完aofChan中的所有数据后关闭
+	aofFinished chan struct{}
+	// shutdown 已经关闭文件，之后完成的重写直接放弃
+	shutdown atomic.Bool
 }
 
 // NewAofHandler 构造函数
-func NewAofHandler(db databaseface.Database) (*AofHandler, error) {
+func NewAofHandler(db databaseface.DBEngine) (*AofHandler, error) {
 	handler := &AofHandler{
 		db:db,
-		aofFilename: config.Properties.AppendFilename,
+		aofDir: aofDirPath(),
+		aofFilename: aofBaseFilename(),
+		rewritePercentage: config.Properties.AutoAofRewritePercentage,
+		rewriteMinSize: config.Properties.AofRewriteMinSize(),
+		useRdbPreamble: config.Properties.AofUseRdbPreamble,
+		fsyncPolicy: parseFsyncPolicy(config.Properties.AppendFsync),
+		closing: make(chan struct{}),
+		aofFinished: make(chan struct{}),
+	}
+	if err := os.MkdirAll(handler.aofDir, 0755); err != nil {
+		return nil, err
+	}
+	handler.removeTempFiles()
+	manifest, err := handler.loadManifest()
+	if err != nil {
+		return nil, err
 	}
+	handler.manifest = manifest
 	// LoadAof
-	err := handler.LoadAof()
+	err = handler.LoadAof()
 	if err != nil {
 		return nil, err
 	}
+	handler.deleteHistoryFiles()
 
-	aofFile, err := os.OpenFile(handler.aofFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	// 继续写入最后一个incr文件，没有incr文件时创建一个
+	if len(manifest.incrs) > 0 {
+		handler.aofFile, err = os.OpenFile(handler.filePath(manifest.incrs[len(manifest.incrs)-1]), os.O_APPEND|os.O_RDWR, 0600)
+	} else {
+		handler.aofFile, err = handler.openNewIncrFile()
+	}
 	if err != nil {
 		return nil, err
 	}
-	handler.aofFile = aofFile
+	// 文件结尾所在的DB未知，先写入select
+	handler.currentDB = -1
+	handler.aofSize = handler.manifestFilesSize()
+	handler.baseSize = handler.aofSize
 	// channel
 	handler.aofChan = make(chan *payload, aofQueueSize)
 	go func() {
 		handler.handleAof()
 	}()
+	if handler.rewritePercentage > 0 {
+		go handler.rewriteCron()
+	}
+	if handler.fsyncPolicy == FsyncEverySec {
+		go handler.fsyncCron()
+	}
 
 	return handler, nil
 }
 
 
+func parseFsyncPolicy(value string) string {
+	switch policy := strings.ToLower(value); policy {
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return policy
+	case "":
+	default:
+		logger.Error("invalid appendfsync: " + value + ", use everysec")
+	}
+	return FsyncEverySec
+}
+
 // AddAof：用户的指令包装成payload放入管道
-func (handler *AofHandler) AddAof(dbIndex int, cmd CmdLine) {
-	if config.Properties.AppendOnly && handler.aofChan != nil {
-		handler.aofChan <- &payload{
-			cmdLine: cmd,
+// 一次传入多条指令时(如事务)，它们会作为一个整体写入，不会被其他指令穿插
+// appendfsync always时等待数据fsync到磁盘后才返回
+func (handler *AofHandler) AddAof(dbIndex int, cmds ...CmdLine) {
+	if config.Properties.AppendOnly && handler.aofChan != nil && len(cmds) > 0 {
+		p := &payload{
+			cmdLines: cmds,
 			dbIndex: dbIndex,
 		}
+		if handler.fsyncPolicy == FsyncAlways {
+			p.done = make(chan struct{})
+		}
+		if handler.send(p) && p.done != nil {
+			<-p.done
+		}
 	}
 }
 
+// send 关闭之后不再接收新的数据
+func (handler *AofHandler) send(p *payload) bool {
+	handler.closeMu.RLock()
+	defer handler.closeMu.RUnlock()
+	if handler.closed {
+		return false
+	}
+	handler.aofChan <- p
+	return true
+}
+
 
 // handleAof 将管道中的payload写入磁盘
 func (handler *AofHandler) handleAof() {
-	handler.currentDB = 0
 	for p := range handler.aofChan {
-		if p.dbIndex != handler.currentDB {
-			data := reply.NewMultiBulkReply(utils.ToCmdLine("select", strconv.Itoa(p.dbIndex))).ToBytes()
-			_, err := handler.aofFile.Write(data)
-			if err != nil {
-				logger.Error(err)
-				continue
+		handler.pausingAof.Lock()
+		if p.rewriteStarted != nil {
+			p.rewriteStarted <- handler.switchIncrFile()
+		} else {
+			handler.writePayload(p)
+			if handler.fsyncPolicy == FsyncAlways {
+				handler.fsync()
 			}
-			handler.currentDB = p.dbIndex
 		}
-		data := reply.NewMultiBulkReply(p.cmdLine).ToBytes()
-		_, err := handler.aofFile.Write(data)
-		if err != nil {
-			logger.Error(err)
-			continue
+		handler.pausingAof.Unlock()
+		if p.done != nil {
+			close(p.done)
+		}
+	}
+	close(handler.aofFinished)
+}
+
+// writePayload 调用方需要持有pausingAof
+func (handler *AofHandler) writePayload(p *payload) {
+	if p.dbIndex != handler.currentDB {
+		data := reply.NewMultiBulkReply(utils.ToCmdLine("select", strconv.Itoa(p.dbIndex))).ToBytes()
+		if !handler.write(data) {
+			return
+		}
+		handler.currentDB = p.dbIndex
+	}
+	var data []byte
+	for _, cmdLine := range p.cmdLines {
+		data = append(data, reply.NewMultiBulkReply(cmdLine).ToBytes()...)
+	}
+	handler.write(data)
+}
+
+func (handler *AofHandler) write(data []byte) bool {
+	_, err := handler.aofFile.Write(data)
+	if err != nil {
+		logger.Error("aof write failed: " + err.Error())
+		handler.lastWriteErr = err
+		return false
+	}
+	handler.needFsync = true
+	handler.aofSize += int64(len(data))
+	return true
+}
+
+// switchIncrFile 重写开始时切换到新的incr文件，调用方需要持有pausingAof
+func (handler *AofHandler) switchIncrFile() error {
+	handler.fsync()
+	file, err := handler.openNewIncrFile()
+	if err != nil {
+		return err
+	}
+	_ = handler.aofFile.Close()
+	handler.aofFile = file
+	handler.currentDB = -1
+	handler.rewriteIncrSeq = handler.manifest.incrSeq
+	handler.rewriteStartSize = handler.aofSize
+	return nil
+}
+
+// fsync 调用方需要持有pausingAof
+func (handler *AofHandler) fsync() {
+	if !handler.needFsync {
+		return
+	}
+	if err := handler.aofFile.Sync(); err != nil {
+		logger.Error("aof fsync failed: " + err.Error())
+		handler.lastWriteErr = err
+		return
+	}
+	handler.needFsync = false
+	handler.lastWriteErr = nil
+}
+
+// fsyncCron appendfsync everysec时每秒fsync一次
+func (handler *AofHandler) fsyncCron() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			handler.pausingAof.Lock()
+			handler.fsync()
+			handler.pausingAof.Unlock()
+		case <-handler.closing:
+			return
 		}
 	}
 }
 
-// LoadAof 重启Redis后加载aof文件
+// LastWriteError 返回最近一次写入或fsync的错误，成功时返回nil
+func (handler *AofHandler) LastWriteError() error {
+	handler.pausingAof.Lock()
+	defer handler.pausingAof.Unlock()
+	return handler.lastWriteErr
+}
+
+// Close 停止自动重写，把管道中剩余的数据写入文件并fsync后关闭文件
+// 未完成的重写会被放弃
+func (handler *AofHandler) Close() {
+	handler.closeOnce.Do(func() {
+		close(handler.closing)
+		handler.closeMu.Lock()
+		handler.closed = true
+		close(handler.aofChan)
+		handler.closeMu.Unlock()
+		<-handler.aofFinished
+
+		handler.pausingAof.Lock()
+		defer handler.pausingAof.Unlock()
+		handler.shutdown.Store(true)
+		handler.needFsync = true
+		handler.fsync()
+		if err := handler.aofFile.Close(); err != nil {
+			logger.Error("close aof file failed: " + err.Error())
+		}
+	})
+}
+
+// LoadAof 重启Redis后按manifest依次加载base文件和incr文件
 func (handler *AofHandler) LoadAof() error {
-	file, err := os.Open(handler.aofFilename)
+	for _, info := range handler.manifest.files() {
+		if err := handler.loadFile(handler.filePath(info)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFile 文件以"REDIS"开头时先加载RDB前缀，再执行之后的命令
+func (handler *AofHandler) loadFile(filename string) error {
+	file, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	ch := parser.ParseStream(file)
+	reader := bufio.NewReader(file)
+	if header, err := reader.Peek(len(rdbPreamble)); err == nil && string(header) == rdbPreamble {
+		if err := handler.db.LoadRDB(reader); err != nil {
+			return err
+		}
+	}
+	ch := parser.ParseStream(reader)
 	fakeConn := &connection.Connection{}
 	for p := range ch {
 		if p.Err != nil {
@@ -0,0 +1,310 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"go-redis/config"
+	"go-redis/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+	multi part aof，与redis 7的布局相同，dir/appenddirname目录下包含：
+	- base文件：<appendfilename>.<seq>.base.rdb 或 .base.aof，重写生成的快照，最多一个
+	- incr文件：<appendfilename>.<seq>.incr.aof，重写开始时打开新的incr文件，之后的写命令追加到新文件
+	- manifest：<appendfilename>.manifest，按顺序记录base和incr文件，每行形如
+	  file appendonly.aof.1.base.rdb seq 1 type b
+	加载时按manifest依次读取base和incr文件。manifest先写临时文件再改名，
+	重写中途崩溃时manifest仍指向原来的文件，不会丢失数据
+*/
+
+const (
+	defaultAppendFilename = "appendonly.aof"
+	defaultAppendDirname  = "appendonlydir"
+
+	aofTypeBase    = "b"
+	aofTypeIncr    = "i"
+	aofTypeHistory = "h" // 重写完成后不再需要，等待删除的文件
+
+	baseSuffix     = ".base"
+	incrSuffix     = ".incr"
+	rdbFormatExt   = ".rdb"
+	aofFormatExt   = ".aof"
+	manifestSuffix = ".manifest"
+	// tempFilePrefix 重写时的临时文件，启动时清理
+	tempFilePrefix = "temp-"
+)
+
+var errInvalidManifest = errors.New("invalid aof manifest")
+
+type aofInfo struct {
+	name     string
+	seq      int64
+	fileType string
+}
+
+type aofManifest struct {
+	base    *aofInfo
+	incrs   []*aofInfo
+	history []*aofInfo
+	// baseSeq incrSeq 最近使用的序号，新文件的序号在此基础上加1
+	baseSeq int64
+	incrSeq int64
+}
+
+// aofDirPath 由dir和appenddirname决定
+func aofDirPath() string {
+	dirname := config.Properties.AppendDirname
+	if dirname == "" {
+		dirname = defaultAppendDirname
+	}
+	return filepath.Join(config.Properties.Dir, dirname)
+}
+
+// aofBaseFilename 文件名的前缀，只使用appendfilename的文件名部分
+func aofBaseFilename() string {
+	filename := filepath.Base(config.Properties.AppendFilename)
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
+		filename = defaultAppendFilename
+	}
+	return filename
+}
+
+func parseManifest(r io.Reader) (*aofManifest, error) {
+	m := &aofManifest{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields)%2 != 0 {
+			return nil, errInvalidManifest
+		}
+		info := &aofInfo{}
+		for i := 0; i < len(fields); i += 2 {
+			switch fields[i] {
+			case "file":
+				info.name = fields[i+1]
+			case "seq":
+				seq, err := strconv.ParseInt(fields[i+1], 10, 64)
+				if err != nil {
+					return nil, errInvalidManifest
+				}
+				info.seq = seq
+			case "type":
+				info.fileType = fields[i+1]
+			}
+		}
+		// 文件名不能包含路径
+		if info.name == "" || info.name != filepath.Base(info.name) {
+			return nil, errInvalidManifest
+		}
+		switch info.fileType {
+		case aofTypeBase:
+			if m.base != nil {
+				return nil, errInvalidManifest
+			}
+			m.base = info
+			m.baseSeq = info.seq
+		case aofTypeIncr:
+			m.incrs = append(m.incrs, info)
+			if info.seq > m.incrSeq {
+				m.incrSeq = info.seq
+			}
+		case aofTypeHistory:
+			m.history = append(m.history, info)
+		default:
+			return nil, errInvalidManifest
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *aofManifest) marshal() []byte {
+	var sb strings.Builder
+	writeInfo := func(info *aofInfo) {
+		sb.WriteString("file " + info.name + " seq " + strconv.FormatInt(info.seq, 10) + " type " + info.fileType + "\n")
+	}
+	if m.base != nil {
+		writeInfo(m.base)
+	}
+	for _, info := range m.history {
+		writeInfo(info)
+	}
+	for _, info := range m.incrs {
+		writeInfo(info)
+	}
+	return []byte(sb.String())
+}
+
+// files 需要按顺序加载的文件
+func (m *aofManifest) files() []*aofInfo {
+	files := make([]*aofInfo, 0, len(m.incrs)+1)
+	if m.base != nil {
+		files = append(files, m.base)
+	}
+	return append(files, m.incrs...)
+}
+
+func (m *aofManifest) clone() *aofManifest {
+	cloned := *m
+	cloned.incrs = append([]*aofInfo(nil), m.incrs...)
+	cloned.history = append([]*aofInfo(nil), m.history...)
+	return &cloned
+}
+
+func (handler *AofHandler) manifestPath() string {
+	return filepath.Join(handler.aofDir, handler.aofFilename+manifestSuffix)
+}
+
+func (handler *AofHandler) filePath(info *aofInfo) string {
+	return filepath.Join(handler.aofDir, info.name)
+}
+
+// loadManifest 读取manifest，没有manifest时把旧版本的单个aof文件作为base文件
+func (handler *AofHandler) loadManifest() (*aofManifest, error) {
+	file, err := os.Open(handler.manifestPath())
+	if err == nil {
+		defer file.Close()
+		return parseManifest(file)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	m := &aofManifest{}
+	legacyPath := filepath.Join(config.Properties.Dir, handler.aofFilename)
+	legacy, err := os.Open(legacyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	header := make([]byte, len(rdbPreamble))
+	n, _ := io.ReadFull(legacy, header)
+	_ = legacy.Close()
+	ext := aofFormatExt
+	if string(header[:n]) == rdbPreamble {
+		ext = rdbFormatExt
+	}
+	m.baseSeq = 1
+	m.base = &aofInfo{
+		name:     handler.aofFilename + "." + strconv.FormatInt(m.baseSeq, 10) + baseSuffix + ext,
+		seq:      m.baseSeq,
+		fileType: aofTypeBase,
+	}
+	if err := os.Rename(legacyPath, handler.filePath(m.base)); err != nil {
+		return nil, err
+	}
+	if err := handler.persistManifest(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// persistManifest 先写入临时文件再改名，替换manifest是原子的
+func (handler *AofHandler) persistManifest(m *aofManifest) error {
+	tmpFile, err := os.CreateTemp(handler.aofDir, tempFilePrefix+"*"+manifestSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(m.marshal()); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), handler.manifestPath()); err != nil {
+		return err
+	}
+	return syncDir(handler.aofDir)
+}
+
+// openNewIncrFile 创建下一个incr文件并写入manifest，调用方需要持有pausingAof
+func (handler *AofHandler) openNewIncrFile() (*os.File, error) {
+	m := handler.manifest.clone()
+	m.incrSeq++
+	info := &aofInfo{
+		name:     handler.aofFilename + "." + strconv.FormatInt(m.incrSeq, 10) + incrSuffix + aofFormatExt,
+		seq:      m.incrSeq,
+		fileType: aofTypeIncr,
+	}
+	m.incrs = append(m.incrs, info)
+	// 之前崩溃时可能留下同名文件，它不在manifest中，可以直接清空
+	file, err := os.OpenFile(handler.filePath(info), os.O_APPEND|os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := handler.persistManifest(m); err != nil {
+		_ = file.Close()
+		_ = os.Remove(handler.filePath(info))
+		return nil, err
+	}
+	handler.manifest = m
+	return file, nil
+}
+
+// deleteHistoryFiles 删除重写完成后不再需要的文件，调用方需要持有pausingAof
+func (handler *AofHandler) deleteHistoryFiles() {
+	if len(handler.manifest.history) == 0 {
+		return
+	}
+	for _, info := range handler.manifest.history {
+		if err := os.Remove(handler.filePath(info)); err != nil && !os.IsNotExist(err) {
+			logger.Error("remove aof history file failed: " + err.Error())
+		}
+	}
+	m := handler.manifest.clone()
+	m.history = nil
+	if err := handler.persistManifest(m); err != nil {
+		logger.Error("persist aof manifest failed: " + err.Error())
+		return
+	}
+	handler.manifest = m
+}
+
+// removeTempFiles 清理上次重写中途退出时留下的临时文件
+func (handler *AofHandler) removeTempFiles() {
+	matches, err := filepath.Glob(filepath.Join(handler.aofDir, tempFilePrefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		_ = os.Remove(match)
+	}
+}
+
+func (handler *AofHandler) manifestFilesSize() int64 {
+	var size int64
+	for _, info := range handler.manifest.files() {
+		if stat, err := os.Stat(handler.filePath(info)); err == nil {
+			size += stat.Size()
+		}
+	}
+	return size
+}
+
+// syncDir 改名之后fsync目录，保证改名已经落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
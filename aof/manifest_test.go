@@ -0,0 +1,46 @@
+package aof
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	content := "file appendonly.aof.2.base.rdb seq 2 type b\n" +
+		"file appendonly.aof.1.base.aof seq 1 type h\n" +
+		"file appendonly.aof.3.incr.aof seq 3 type i\n" +
+		"file appendonly.aof.4.incr.aof seq 4 type i\n"
+	m, err := parseManifest(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.baseSeq != 2 || m.incrSeq != 4 || len(m.history) != 1 {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+	files := m.files()
+	if len(files) != 3 || files[0].name != "appendonly.aof.2.base.rdb" || files[2].name != "appendonly.aof.4.incr.aof" {
+		t.Errorf("unexpected files %v", files)
+	}
+	if marshaled := string(m.marshal()); marshaled != content {
+		t.Errorf("unexpected marshaled manifest %q", marshaled)
+	}
+
+	// 字段顺序不影响解析
+	m, err = parseManifest(strings.NewReader("type i seq 7 file a.7.incr.aof\n"))
+	if err != nil || m.incrs[0].name != "a.7.incr.aof" || m.incrSeq != 7 {
+		t.Errorf("unexpected manifest %+v %v", m, err)
+	}
+
+	invalid := []string{
+		"file a seq 1\n",
+		"file a seq x type i\n",
+		"file a seq 1 type x\n",
+		"file ../a seq 1 type i\n",
+		"file a seq 1 type b\nfile b seq 2 type b\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseManifest(strings.NewReader(content)); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package aof
+
+import (
+	"go-redis/datastruct/dict"
+	List "go-redis/datastruct/list"
+	HashSet "go-redis/datastruct/set"
+	SortedSet "go-redis/datastruct/sortedset"
+	"go-redis/datastruct/stream"
+	databaseface "go-redis/interface/database"
+	"go-redis/lib/utils"
+	"strconv"
+	"time"
+)
+
+// rewriteItemsPerCmd 重写时集合类型每条命令最多包含的元素数，与redis的AOF_REWRITE_ITEMS_PER_CMD相同
+const rewriteItemsPerCmd = 64
+
+// appendItems 把元素按rewriteItemsPerCmd分批，每批生成一条 cmd key item...
+func appendItems(cmds []CmdLine, cmd string, key string, items [][]byte, itemsPerElement int) []CmdLine {
+	batch := rewriteItemsPerCmd * itemsPerElement
+	for start := 0; start < len(items); start += batch {
+		end := start + batch
+		if end > len(items) {
+			end = len(items)
+		}
+		cmdLine := make(CmdLine, 0, 2+end-start)
+		cmdLine = append(cmdLine, []byte(cmd), []byte(key))
+		cmdLine = append(cmdLine, items[start:end]...)
+		cmds = append(cmds, cmdLine)
+	}
+	return cmds
+}
+
+// EntityToCmds 生成重建key需要的命令，不能识别的类型返回nil
+func EntityToCmds(key string, entity *databaseface.DataEntity) []CmdLine {
+	switch val := entity.Data.(type) {
+	case []byte:
+		return []CmdLine{utils.ToCmdLine3("set", []byte(key), val)}
+	case List.List:
+		items := make([][]byte, 0, val.Len())
+		val.ForEach(func(i int, v interface{}) bool {
+			items = append(items, v.([]byte))
+			return true
+		})
+		return appendItems(nil, "rpush", key, items, 1)
+	case *HashSet.Set:
+		items := make([][]byte, 0, val.Len())
+		val.ForEach(func(member string) bool {
+			items = append(items, []byte(member))
+			return true
+		})
+		return appendItems(nil, "sadd", key, items, 1)
+	case dict.Dict:
+		items := make([][]byte, 0, 2*val.Len())
+		val.ForEach(func(field string, value interface{}) bool {
+			items = append(items, []byte(field), value.([]byte))
+			return true
+		})
+		return appendItems(nil, "hset", key, items, 2)
+	case *SortedSet.SortedSet:
+		items := make([][]byte, 0, 2*val.Len())
+		if size := val.Len(); size > 0 {
+			val.ForEachByRank(0, size, false, func(element *SortedSet.Element) bool {
+				score := strconv.FormatFloat(element.Score, 'f', -1, 64)
+				items = append(items, []byte(score), []byte(element.Member))
+				return true
+			})
+		}
+		return appendItems(nil, "zadd", key, items, 2)
+	case *stream.Stream:
+		return streamToCmds(key, val)
+	}
+	return nil
+}
+
+// streamToCmds stream的消息用XADD重建，lastID大于最后一条消息时先添加再删除一条占位消息，
+// 消费者组的投递状态与XREADGROUP写入aof的方式相同，记录为 XCLAIM ... FORCE JUSTID LASTID
+func streamToCmds(key string, s *stream.Stream) []CmdLine {
+	cmds := make([]CmdLine, 0)
+	entries := s.Range(stream.MinID, stream.MaxID, 0)
+	for _, entry := range entries {
+		cmdLine := utils.ToCmdLine("xadd", key, entry.ID.String())
+		cmds = append(cmds, append(cmdLine, entry.Fields...))
+	}
+	lastID := s.LastID().String()
+	switch {
+	case len(entries) > 0 && entries[len(entries)-1].ID != s.LastID():
+		cmds = append(cmds, utils.ToCmdLine("xadd", key, lastID, "x", "y"), utils.ToCmdLine("xdel", key, lastID))
+	case len(entries) == 0 && s.LastID() != stream.MinID:
+		cmds = append(cmds, utils.ToCmdLine("xadd", key, "maxlen", "0", lastID, "x", "y"))
+	case len(entries) == 0 && len(s.Groups()) == 0:
+		// 没有消息也没有消费者组的空stream只能由XGROUP CREATE MKSTREAM创建
+		cmds = append(cmds, utils.ToCmdLine("xgroup", "create", key, "__rewrite__", "0", "mkstream"),
+			utils.ToCmdLine("xgroup", "destroy", key, "__rewrite__"))
+	}
+
+	for _, group := range s.Groups() {
+		cmds = append(cmds, utils.ToCmdLine("xgroup", "create", key, group.Name, group.LastID.String(), "mkstream"))
+		for _, consumer := range group.Consumers() {
+			cmds = append(cmds, utils.ToCmdLine("xgroup", "createconsumer", key, group.Name, consumer.Name))
+		}
+		pending := group.PendingRange(stream.MinID, stream.MaxID, 0, func(*stream.PendingEntry) bool {
+			return true
+		})
+		for _, entry := range pending {
+			cmds = append(cmds, XClaimCmdLine(key, group, entry))
+		}
+	}
+	return cmds
+}
+
+// XClaimCmdLine 把消息的投递状态记录为一条可以重放的XCLAIM命令
+func XClaimCmdLine(key string, group *stream.Group, pending *stream.PendingEntry) CmdLine {
+	return utils.ToCmdLine("xclaim", key, group.Name, pending.Consumer.Name, "0", pending.ID.String(),
+		"TIME", strconv.FormatInt(pending.DeliveryTime.UnixMilli(), 10),
+		"RETRYCOUNT", strconv.FormatUint(pending.DeliveryCount, 10),
+		"FORCE", "JUSTID", "LASTID", group.LastID.String())
+}
+
+// makeExpireCmd 过期时间记录为绝对时间，重放时不受加载耗时影响
+func makeExpireCmd(key string, expireAt time.Time) CmdLine {
+	return utils.ToCmdLine("pexpireat", key, strconv.FormatInt(expireAt.UnixMilli(), 10))
+}
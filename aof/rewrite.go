@@ -0,0 +1,208 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	databaseface "go-redis/interface/database"
+	"go-redis/lib/utils"
+	"go-redis/logger"
+	"go-redis/resp/reply"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+	AOF重写：
+	1. 暂停写命令，给所有DB装上快照，同时向aofChan放入一个标记，标记之后的数据写入新的incr文件
+	2. 把快照中的每个key转换为命令写入临时文件，期间客户端的写命令照常执行
+	3. 临时文件改名为新的base文件，manifest中只保留新的base文件和标记之后的incr文件
+	开启aof-use-rdb-preamble时，第2步写入RDB格式的快照，base文件为.rdb
+*/
+
+var ErrRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+
+var errAofClosed = errors.New("aof handler is closed")
+
+// Rewrite 在后台重写aof文件，同一时间只能有一个重写
+func (handler *AofHandler) Rewrite() error {
+	if !handler.rewriting.CompareAndSwap(false, true) {
+		return ErrRewriteInProgress
+	}
+	go func() {
+		defer handler.rewriting.Store(false)
+		if err := handler.rewrite(); err != nil {
+			logger.Error("aof rewrite failed: " + err.Error())
+			return
+		}
+		logger.Info("aof rewrite finished")
+	}()
+	return nil
+}
+
+func (handler *AofHandler) IsRewriting() bool {
+	return handler.rewriting.Load()
+}
+
+func (handler *AofHandler) rewrite() error {
+	tmpFile, err := os.CreateTemp(handler.aofDir, tempFilePrefix+"rewriteaof-*"+aofFormatExt)
+	if err != nil {
+		return err
+	}
+	// 替换成功后临时文件已不存在，删除失败不影响
+	defer os.Remove(tmpFile.Name())
+
+	var started chan error
+	err = handler.writeSnapshot(tmpFile, func() {
+		marker := &payload{rewriteStarted: make(chan error, 1)}
+		if handler.send(marker) {
+			started = marker.rewriteStarted
+		}
+	})
+	if started != nil {
+		// 标记之前的数据都已写入原来的incr文件
+		if startErr := <-started; err == nil {
+			err = startErr
+		}
+	} else if err == nil {
+		err = errAofClosed
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return handler.finishRewrite(tmpFile.Name())
+}
+
+// writeSnapshot 把快照写入w，onStart在快照开始时调用
+// 开启aof-use-rdb-preamble时快照以RDB格式写入，否则转换为命令
+func (handler *AofHandler) writeSnapshot(w io.Writer, onStart func()) error {
+	writer := bufio.NewWriter(w)
+	if handler.useRdbPreamble {
+		if err := handler.db.WriteRDB(writer, onStart); err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+	currentDB := 0
+	writeCmd := func(cmdLine CmdLine) error {
+		_, err := writer.Write(reply.NewMultiBulkReply(cmdLine).ToBytes())
+		return err
+	}
+	err := handler.db.ForEachSnapshot(onStart, func(dbIndex int, key string, entity *databaseface.DataEntity, expireAt time.Time) error {
+		if dbIndex != currentDB {
+			if err := writeCmd(utils.ToCmdLine("select", strconv.Itoa(dbIndex))); err != nil {
+				return err
+			}
+			currentDB = dbIndex
+		}
+		cmds := EntityToCmds(key, entity)
+		if cmds == nil {
+			logger.Error("aof rewrite: unknown type of key " + key)
+			return nil
+		}
+		for _, cmdLine := range cmds {
+			if err := writeCmd(cmdLine); err != nil {
+				return err
+			}
+		}
+		if !expireAt.IsZero() {
+			return writeCmd(makeExpireCmd(key, expireAt))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// finishRewrite 临时文件改名为新的base文件，重写开始之前的base和incr文件在manifest中标记为history后删除
+func (handler *AofHandler) finishRewrite(tmpFilename string) error {
+	handler.pausingAof.Lock()
+	defer handler.pausingAof.Unlock()
+	if handler.shutdown.Load() {
+		return errAofClosed
+	}
+
+	m := handler.manifest.clone()
+	m.baseSeq++
+	ext := aofFormatExt
+	if handler.useRdbPreamble {
+		ext = rdbFormatExt
+	}
+	base := &aofInfo{
+		name:     handler.aofFilename + "." + strconv.FormatInt(m.baseSeq, 10) + baseSuffix + ext,
+		seq:      m.baseSeq,
+		fileType: aofTypeBase,
+	}
+	if m.base != nil {
+		m.history = append(m.history, &aofInfo{name: m.base.name, seq: m.base.seq, fileType: aofTypeHistory})
+	}
+	m.base = base
+	incrs := m.incrs[:0:0]
+	for _, info := range m.incrs {
+		if info.seq < handler.rewriteIncrSeq {
+			m.history = append(m.history, &aofInfo{name: info.name, seq: info.seq, fileType: aofTypeHistory})
+		} else {
+			incrs = append(incrs, info)
+		}
+	}
+	m.incrs = incrs
+
+	if err := os.Rename(tmpFilename, handler.filePath(base)); err != nil {
+		return err
+	}
+	if err := handler.persistManifest(m); err != nil {
+		_ = os.Remove(handler.filePath(base))
+		return err
+	}
+	handler.manifest = m
+	handler.deleteHistoryFiles()
+
+	if info, err := os.Stat(handler.filePath(base)); err == nil {
+		handler.aofSize = info.Size() + handler.aofSize - handler.rewriteStartSize
+		handler.baseSize = handler.aofSize
+	}
+	return nil
+}
+
+// rewriteCron 每秒检查一次文件是否增长到需要重写
+func (handler *AofHandler) rewriteCron() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if handler.shouldRewrite() {
+				_ = handler.Rewrite()
+			}
+		case <-handler.closing:
+			return
+		}
+	}
+}
+
+// shouldRewrite 文件超过auto-aof-rewrite-min-size，且比上次重写后增长了auto-aof-rewrite-percentage%
+func (handler *AofHandler) shouldRewrite() bool {
+	if handler.rewritePercentage <= 0 || handler.IsRewriting() {
+		return false
+	}
+	handler.pausingAof.Lock()
+	defer handler.pausingAof.Unlock()
+	if handler.aofSize <= handler.rewriteMinSize {
+		return false
+	}
+	base := handler.baseSize
+	if base == 0 {
+		base = 1
+	}
+	growth := (handler.aofSize - base) * 100 / base
+	return growth >= int64(handler.rewritePercentage)
+}
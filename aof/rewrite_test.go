@@ -0,0 +1,30 @@
+package aof
+
+import "testing"
+
+func TestShouldRewrite(t *testing.T) {
+	handler := &AofHandler{
+		rewritePercentage: 100,
+		rewriteMinSize:    1000,
+		baseSize:          600,
+		aofSize:           1100,
+	}
+	if handler.shouldRewrite() {
+		t.Error("should not rewrite before growing 100%")
+	}
+	handler.aofSize = 1200
+	if !handler.shouldRewrite() {
+		t.Error("should rewrite after growing 100%")
+	}
+	// 文件小于auto-aof-rewrite-min-size时不重写
+	handler.baseSize = 0
+	handler.aofSize = 1000
+	if handler.shouldRewrite() {
+		t.Error("should not rewrite small file")
+	}
+	handler.rewriting.Store(true)
+	handler.aofSize = 2000
+	if handler.shouldRewrite() {
+		t.Error("should not rewrite during rewriting")
+	}
+}
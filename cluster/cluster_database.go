@@ -9,8 +9,10 @@ import (
 	"go-redis/interface/resp"
 	"go-redis/lib/consistenthash"
 	"go-redis/logger"
+	"go-redis/pubsub"
 	"go-redis/resp/reply"
 	"strings"
+	"sync"
 )
 
 type ClusterDatabase struct {
@@ -20,15 +22,22 @@ type ClusterDatabase struct {
 	nodes []string
 	peerPicker *consistenthash.NodeMap // 节点选择器
 	peerConnection map[string] *pool.ObjectPool
+
+	hub *pubsub.Hub // 本地的发布订阅，接收其他节点转来的分片消息
+	relayMu sync.Mutex
+	shardRelays map[string]*shardRelay // 节点 -> 分片频道的中转连接
 }
 
 
 func NewClusterDatabase() *ClusterDatabase {
+	db := database.NewStandaloneDatabase()
 	clusterDatabase := &ClusterDatabase{
 		self: config.Properties.Self,
-		db: database.NewStandaloneDatabase(),
+		db: db,
 		peerPicker:consistenthash.NewNodeMap(nil),
 		peerConnection: make(map[string]*pool.ObjectPool),
+		hub: db.GetHub(),
+		shardRelays: make(map[string]*shardRelay),
 	}
 	n := make([]string, 0, len(config.Properties.Peers) + 1)
 	for _, node := range config.Properties.Peers {
@@ -52,6 +61,10 @@ func (cluster *ClusterDatabase) Exec(client resp.Connection, args databaseface.C
 	}()
 	router := makeRouter()
 	cmdName := strings.ToLower(string(args[0]))
+	// 订阅状态下的命令在转发到其他节点之前就要拦截
+	if errReply := pubsub.CheckSubscribedMode(client, cmdName); errReply != nil {
+		return errReply
+	}
 	cmdFunc, ok := router[cmdName]
 	if !ok {
 		return reply.NewErrReply("not supported cmd" + cmdName)
@@ -60,12 +73,20 @@ func (cluster *ClusterDatabase) Exec(client resp.Connection, args databaseface.C
 }
 
 func (cluster *ClusterDatabase) Close() error {
+	cluster.relayMu.Lock()
+	for _, r := range cluster.shardRelays {
+		r.close()
+	}
+	cluster.relayMu.Unlock()
 	return cluster.db.Close()
 }
 
 
 func (cluster *ClusterDatabase) AfterClientClose(c resp.Connection) error {
-	return cluster.db.AfterClientClose(c)
+	shardChannels := c.GetShardChannels()
+	err := cluster.db.AfterClientClose(c)
+	cluster.syncShardChannels(shardChannels)
+	return err
 }
 
 
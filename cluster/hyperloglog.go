@@ -0,0 +1,38 @@
+package cluster
+
+import (
+	"go-redis/datastruct/hyperloglog"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+)
+
+// pfcount PFCOUNT key [key ...]
+// key都在同一节点时直接转发，否则从各节点GET出HLL后在本地合并计数
+func pfcount(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) < 2 {
+		return reply.NewArgNumErrReply("pfcount")
+	}
+	keys := cmdArg[1:]
+	if node, ok := cluster.pickSameNode(keys...); ok {
+		return cluster.relay(node, c, cmdArg)
+	}
+	hlls := make([]*hyperloglog.HLL, 0, len(keys))
+	for _, key := range keys {
+		node := cluster.peerPicker.PickNode(string(key))
+		rep := cluster.relay(node, c, utils.ToCmdLine3("get", key))
+		if reply.IsErrReply(rep) {
+			return rep
+		}
+		bulk, ok := rep.(*reply.BulkReply)
+		if !ok {
+			continue // key不存在
+		}
+		h, ok := hyperloglog.FromBytes(bulk.Arg)
+		if !ok {
+			return reply.NewErrReply("WRONGTYPE Key is not a valid HyperLogLog string value.")
+		}
+		hlls = append(hlls, h)
+	}
+	return reply.NewIntReply(int64(hyperloglog.Count(hlls...)))
+}
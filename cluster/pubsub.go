@@ -0,0 +1,42 @@
+package cluster
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+)
+
+// relayPublish 转发给其他节点的PUBLISH，收到的节点只发给本地的订阅者，不再继续转发
+const relayPublish = "_publish"
+
+// execLocal 订阅关系保存在客户端连接所在的节点，直接在本地执行
+func execLocal(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	return cluster.db.Exec(c, cmdArg)
+}
+
+// publish PUBLISH channel message，发送给所有节点上的订阅者，返回订阅者总数
+func publish(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) != 3 {
+		return reply.NewArgNumErrReply("publish")
+	}
+	var count int64
+	for _, node := range cluster.nodes {
+		var rep resp.Reply
+		if node == cluster.self {
+			rep = cluster.db.Exec(c, cmdArg)
+		} else {
+			rep = cluster.relay(node, c, utils.ToCmdLine3(relayPublish, cmdArg[1:]...))
+		}
+		if reply.IsErrReply(rep) {
+			return rep
+		}
+		if intReply, ok := rep.(*reply.IntReply); ok {
+			count += intReply.Code
+		}
+	}
+	return reply.NewIntReply(count)
+}
+
+func execRelayedPublish(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	return cluster.db.Exec(c, utils.ToCmdLine3("publish", cmdArg[1:]...))
+}
@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"go-redis/interface/resp"
+	"go-redis/resp/reply"
 )
 
 type CmdFunc func(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply
@@ -16,12 +17,131 @@ func makeRouter() map[string]CmdFunc {
 	m["rename"] = rename
 	m["renamenx"] = rename
 	m["keys"] = defaultFunc
+	m["expire"] = defaultFunc
+	m["pexpire"] = defaultFunc
+	m["expireat"] = defaultFunc
+	m["pexpireat"] = defaultFunc
+	m["ttl"] = defaultFunc
+	m["pttl"] = defaultFunc
+	m["persist"] = defaultFunc
 	m["get"] = defaultFunc
 	m["set"] = defaultFunc
 	m["setnx"] = defaultFunc
+	m["setex"] = defaultFunc
+	m["psetex"] = defaultFunc
 	m["getset"] = defaultFunc
+	m["getex"] = defaultFunc
+	m["getdel"] = defaultFunc
 	m["getstrlen"] = defaultFunc
+	m["strlen"] = defaultFunc
+	m["append"] = defaultFunc
+	m["setrange"] = defaultFunc
+	m["getrange"] = defaultFunc
+	m["mget"] = mget
+	m["mset"] = mset
+	m["msetnx"] = msetnx
+	m["setbit"] = defaultFunc
+	m["getbit"] = defaultFunc
+	m["bitcount"] = defaultFunc
+	m["bitpos"] = defaultFunc
+	m["bitop"] = sameNodeFunc(2, -1)
+	m["bitfield"] = defaultFunc
+	m["pfadd"] = defaultFunc
+	m["pfcount"] = pfcount
+	m["pfmerge"] = sameNodeFunc(1, -1)
+	m["xadd"] = defaultFunc
+	m["xlen"] = defaultFunc
+	m["xrange"] = defaultFunc
+	m["xrevrange"] = defaultFunc
+	m["xdel"] = defaultFunc
+	m["xtrim"] = defaultFunc
+	m["xread"] = xread
+	m["xgroup"] = xgroup
+	m["xreadgroup"] = xread
+	m["xack"] = defaultFunc
+	m["xpending"] = defaultFunc
+	m["xclaim"] = defaultFunc
+	m["incr"] = defaultFunc
+	m["incrby"] = defaultFunc
+	m["decr"] = defaultFunc
+	m["decrby"] = defaultFunc
+	m["incrbyfloat"] = defaultFunc
+	m["hset"] = defaultFunc
+	m["hmset"] = defaultFunc
+	m["hsetnx"] = defaultFunc
+	m["hget"] = defaultFunc
+	m["hmget"] = defaultFunc
+	m["hdel"] = defaultFunc
+	m["hexists"] = defaultFunc
+	m["hlen"] = defaultFunc
+	m["hstrlen"] = defaultFunc
+	m["hkeys"] = defaultFunc
+	m["hvals"] = defaultFunc
+	m["hgetall"] = defaultFunc
+	m["hincrby"] = defaultFunc
+	m["hincrbyfloat"] = defaultFunc
+	m["hrandfield"] = defaultFunc
+	m["hscan"] = defaultFunc
+	m["sadd"] = defaultFunc
+	m["srem"] = defaultFunc
+	m["scard"] = defaultFunc
+	m["sismember"] = defaultFunc
+	m["smismember"] = defaultFunc
+	m["smembers"] = defaultFunc
+	m["spop"] = defaultFunc
+	m["srandmember"] = defaultFunc
+	m["smove"] = sameNodeFunc(1, 3)
+	m["sinter"] = setAlgebra
+	m["sunion"] = setAlgebra
+	m["sdiff"] = setAlgebra
+	m["sinterstore"] = sameNodeFunc(1, -1)
+	m["sunionstore"] = sameNodeFunc(1, -1)
+	m["sdiffstore"] = sameNodeFunc(1, -1)
+	m["zadd"] = defaultFunc
+	m["zrem"] = defaultFunc
+	m["zscore"] = defaultFunc
+	m["zmscore"] = defaultFunc
+	m["zincrby"] = defaultFunc
+	m["zcard"] = defaultFunc
+	m["zcount"] = defaultFunc
+	m["zrank"] = defaultFunc
+	m["zrevrank"] = defaultFunc
+	m["zrange"] = defaultFunc
+	m["zrevrange"] = defaultFunc
+	m["zrangebyscore"] = defaultFunc
+	m["zrevrangebyscore"] = defaultFunc
+	m["zremrangebyrank"] = defaultFunc
+	m["zremrangebyscore"] = defaultFunc
+	m["zpopmin"] = defaultFunc
+	m["zpopmax"] = defaultFunc
+	m["geoadd"] = defaultFunc
+	m["geopos"] = defaultFunc
+	m["geodist"] = defaultFunc
+	m["geohash"] = defaultFunc
+	m["geosearch"] = defaultFunc
+	m["geosearchstore"] = sameNodeFunc(1, 3)
+	m["zunionstore"] = zsetAlgebraStore
+	m["zinterstore"] = zsetAlgebraStore
 	m["select"] = execSelect
+	m["ping"] = execLocal
+	m["subscribe"] = execLocal
+	m["unsubscribe"] = execLocal
+	m["psubscribe"] = execLocal
+	m["punsubscribe"] = execLocal
+	m["pubsub"] = execLocal
+	// CLIENT TRACKING只能跟踪在本节点执行的命令，转发到其他节点的读取不会被记录
+	m["client"] = execLocal
+	// 每个节点只保存自己的数据
+	m["save"] = execLocal
+	m["bgsave"] = execLocal
+	m["lastsave"] = execLocal
+	m["bgrewriteaof"] = execLocal
+	m["info"] = execLocal
+	m["publish"] = publish
+	m[relayPublish] = execRelayedPublish
+	m["ssubscribe"] = ssubscribe
+	m["sunsubscribe"] = sunsubscribe
+	m["spublish"] = spublish
 	return m
 }
 
@@ -31,4 +151,33 @@ func defaultFunc(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) r
 	return cluster.relay(node, c, cmdArg)
 }
 
+// pickSameNode 返回所有key共同所在的节点，key分布在不同节点时ok为false
+func (cluster *ClusterDatabase) pickSameNode(keys ...[]byte) (node string, ok bool) {
+	for i, key := range keys {
+		n := cluster.peerPicker.PickNode(string(key))
+		if i > 0 && n != node {
+			return "", false
+		}
+		node = n
+	}
+	return node, true
+}
 
+// sameNodeFunc 用于多key命令：cmdArg[begin:end]是命令涉及的key（end为-1表示直到末尾），
+// 所有key在同一节点时转发过去，否则拒绝执行
+func sameNodeFunc(begin, end int) CmdFunc {
+	return func(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+		stop := end
+		if stop < 0 || stop > len(cmdArg) {
+			stop = len(cmdArg)
+		}
+		if begin >= stop {
+			return reply.NewArgNumErrReply(string(cmdArg[0]))
+		}
+		node, ok := cluster.pickSameNode(cmdArg[begin:stop]...)
+		if !ok {
+			return reply.NewErrReply("ERR " + string(cmdArg[0]) + " keys must within one peer")
+		}
+		return cluster.relay(node, c, cmdArg)
+	}
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"go-redis/datastruct/set"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strings"
+)
+
+// setAlgebra SINTER/SUNION/SDIFF key1 key2 ...
+// key都在同一节点时直接转发，否则从各节点取出集合后在本地计算
+func setAlgebra(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) < 2 {
+		return reply.NewArgNumErrReply(string(cmdArg[0]))
+	}
+	keys := cmdArg[1:]
+	if node, ok := cluster.pickSameNode(keys...); ok {
+		return cluster.relay(node, c, cmdArg)
+	}
+
+	var result *set.Set
+	cmdName := strings.ToLower(string(cmdArg[0]))
+	for i, key := range keys {
+		node := cluster.peerPicker.PickNode(string(key))
+		rep := cluster.relay(node, c, utils.ToCmdLine3("smembers", key))
+		if reply.IsErrReply(rep) {
+			return rep
+		}
+		members := set.New()
+		if multiBulk, ok := rep.(*reply.MultiBulkReply); ok {
+			for _, member := range multiBulk.Args {
+				members.Add(string(member))
+			}
+		}
+		if i == 0 {
+			result = members
+			continue
+		}
+		switch cmdName {
+		case "sinter":
+			result = result.Intersect(members)
+		case "sunion":
+			result = result.Union(members)
+		case "sdiff":
+			result = result.Diff(members)
+		}
+	}
+	if result.Len() == 0 {
+		return reply.NewNullMultiBulkReply()
+	}
+	return reply.NewMultiBulkReply(utils.ToCmdLine(result.ToSlice()...))
+}
@@ -0,0 +1,189 @@
+package cluster
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/logger"
+	"go-redis/pubsub"
+	"go-redis/resp/parser"
+	"go-redis/resp/reply"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	分片发布订阅：分片频道由 peerPicker.PickNode 决定所属的节点，SPUBLISH只发送到该节点
+	客户端在任意节点SSUBSCRIBE，订阅关系记录在本地；频道属于其他节点时，
+	本节点通过一条到所属节点的中转连接订阅该频道，收到消息后再发给本地的订阅者，
+	这样每条消息只在所属节点与有订阅者的节点之间传输一次
+ */
+
+// shardRelay 到某个节点的中转连接，订阅本节点上有订阅者但属于该节点的分片频道
+type shardRelay struct {
+	peer string
+	hub  *pubsub.Hub
+
+	mu       sync.Mutex
+	conn     net.Conn
+	channels map[string]struct{} // 已经在对端订阅的频道
+	closed   bool
+}
+
+func newShardRelay(peer string, hub *pubsub.Hub) *shardRelay {
+	return &shardRelay{
+		peer:     peer,
+		hub:      hub,
+		channels: make(map[string]struct{}),
+	}
+}
+
+// sync 根据本地是否还有订阅者，在对端订阅或退订channel
+func (r *shardRelay) sync(channel string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	want := r.hub.ShardNumSub(channel) > 0
+	_, have := r.channels[channel]
+	if want == have {
+		return
+	}
+	if want {
+		r.channels[channel] = struct{}{}
+		r.send(utils.ToCmdLine("ssubscribe", channel))
+	} else {
+		delete(r.channels, channel)
+		r.send(utils.ToCmdLine("sunsubscribe", channel))
+	}
+}
+
+// send 调用方需要持有r.mu；连接断开时重新连接并恢复全部订阅
+func (r *shardRelay) send(cmdLine [][]byte) {
+	if r.conn != nil {
+		_, err := r.conn.Write(reply.NewMultiBulkReply(cmdLine).ToBytes())
+		if err == nil {
+			return
+		}
+		logger.Error("shard relay to " + r.peer + " write error: " + err.Error())
+		_ = r.conn.Close()
+		r.conn = nil
+	}
+	r.connect()
+}
+
+// connect 调用方需要持有r.mu
+func (r *shardRelay) connect() {
+	conn, err := net.Dial("tcp", r.peer)
+	if err != nil {
+		logger.Error("shard relay connect to " + r.peer + " error: " + err.Error())
+		return
+	}
+	r.conn = conn
+	go r.receive(conn)
+	if len(r.channels) == 0 {
+		return
+	}
+	cmdLine := make([][]byte, 0, len(r.channels)+1)
+	cmdLine = append(cmdLine, []byte("ssubscribe"))
+	for channel := range r.channels {
+		cmdLine = append(cmdLine, []byte(channel))
+	}
+	if _, err := conn.Write(reply.NewMultiBulkReply(cmdLine).ToBytes()); err != nil {
+		logger.Error("shard relay to " + r.peer + " write error: " + err.Error())
+	}
+}
+
+// receive 把对端推送的分片消息发给本地订阅者，连接断开后重新连接
+func (r *shardRelay) receive(conn net.Conn) {
+	for payload := range parser.ParseStream(conn) {
+		if payload.Err != nil {
+			break
+		}
+		msg, ok := payload.Data.(*reply.MultiBulkReply)
+		if !ok || len(msg.Args) != 3 || strings.ToLower(string(msg.Args[0])) != "smessage" {
+			continue // 订阅和退订的确认
+		}
+		r.hub.PublishShard(string(msg.Args[1]), msg.Args[2])
+	}
+	_ = conn.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.conn != conn {
+		return
+	}
+	r.conn = nil
+	for i := 0; i < 3 && r.conn == nil && !r.closed; i++ {
+		// 重连时不释放锁，期间的订阅变化会在重连后一并处理
+		time.Sleep(time.Second)
+		r.connect()
+	}
+}
+
+func (r *shardRelay) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.conn != nil {
+		_ = r.conn.Close()
+	}
+}
+
+func (cluster *ClusterDatabase) getShardRelay(peer string) *shardRelay {
+	cluster.relayMu.Lock()
+	defer cluster.relayMu.Unlock()
+	r, ok := cluster.shardRelays[peer]
+	if !ok {
+		r = newShardRelay(peer, cluster.hub)
+		cluster.shardRelays[peer] = r
+	}
+	return r
+}
+
+// syncShardChannels 本地订阅变化后，更新属于其他节点的频道在中转连接上的订阅
+func (cluster *ClusterDatabase) syncShardChannels(channels []string) {
+	for _, channel := range channels {
+		owner := cluster.peerPicker.PickNode(channel)
+		if owner == cluster.self {
+			continue
+		}
+		cluster.getShardRelay(owner).sync(channel)
+	}
+}
+
+// ssubscribe SSUBSCRIBE shardchannel [shardchannel ...]
+func ssubscribe(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	result := cluster.db.Exec(c, cmdArg)
+	cluster.syncShardChannels(pubsubArgs(cmdArg[1:]))
+	return result
+}
+
+// sunsubscribe SUNSUBSCRIBE [shardchannel ...]
+func sunsubscribe(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	channels := pubsubArgs(cmdArg[1:])
+	if len(channels) == 0 {
+		channels = c.GetShardChannels()
+	}
+	result := cluster.db.Exec(c, cmdArg)
+	cluster.syncShardChannels(channels)
+	return result
+}
+
+func pubsubArgs(args [][]byte) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = string(arg)
+	}
+	return result
+}
+
+// spublish SPUBLISH shardchannel message，只发送到频道所属的节点
+func spublish(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) != 3 {
+		return reply.NewArgNumErrReply("spublish")
+	}
+	return defaultFunc(cluster, c, cmdArg)
+}
@@ -0,0 +1,28 @@
+package cluster
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/resp/reply"
+	"strconv"
+)
+
+// zsetAlgebraStore ZUNIONSTORE/ZINTERSTORE destination numkeys key [key ...] ...
+// destination和所有源key必须位于同一节点
+func zsetAlgebraStore(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) < 4 {
+		return reply.NewArgNumErrReply(string(cmdArg[0]))
+	}
+	numKeys, err := strconv.Atoi(string(cmdArg[2]))
+	// numKeys很大时3+numKeys会溢出，先用参数个数计算可用的key数量
+	if err != nil || numKeys <= 0 || numKeys > len(cmdArg)-3 {
+		return reply.NewSyntaxErrReply()
+	}
+	keys := make([][]byte, 0, numKeys+1)
+	keys = append(keys, cmdArg[1])
+	keys = append(keys, cmdArg[3:3+numKeys]...)
+	node, ok := cluster.pickSameNode(keys...)
+	if !ok {
+		return reply.NewErrReply("ERR " + string(cmdArg[0]) + " keys must within one peer")
+	}
+	return cluster.relay(node, c, cmdArg)
+}
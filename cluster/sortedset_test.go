@@ -0,0 +1,21 @@
+package cluster
+
+import (
+	"fmt"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"testing"
+)
+
+// TestZSetAlgebraStoreNumKeys numkeys过大时不能溢出，在选择节点之前就返回错误
+func TestZSetAlgebraStoreNumKeys(t *testing.T) {
+	cluster := &ClusterDatabase{}
+	for _, numKeys := range []string{"9223372036854775807", "9223372036854775806", "3"} {
+		for _, cmdName := range []string{"zunionstore", "zinterstore"} {
+			result := zsetAlgebraStore(cluster, nil, utils.ToCmdLine(cmdName, "dest", numKeys, "k1", "k2"))
+			if !utils.BytesEquals(result.ToBytes(), reply.NewSyntaxErrReply().ToBytes()) {
+				t.Error(fmt.Sprintf("expected syntax error, actually %s", string(result.ToBytes())))
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package cluster
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/resp/reply"
+	"strings"
+)
+
+// xgroup XGROUP subcommand key ...，按第二个参数的key路由
+func xgroup(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) < 3 {
+		return reply.NewArgNumErrReply("xgroup")
+	}
+	node := cluster.peerPicker.PickNode(string(cmdArg[2]))
+	return cluster.relay(node, c, cmdArg)
+}
+
+// xread XREAD/XREADGROUP ... STREAMS key [key ...] id [id ...]
+// 所有key需要在同一节点，BLOCK时由该节点负责阻塞
+func xread(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	for i := 1; i < len(cmdArg); i++ {
+		if strings.ToUpper(string(cmdArg[i])) != "STREAMS" {
+			continue
+		}
+		rest := cmdArg[i+1:]
+		if len(rest) == 0 || len(rest)%2 != 0 {
+			break
+		}
+		node, ok := cluster.pickSameNode(rest[:len(rest)/2]...)
+		if !ok {
+			return reply.NewErrReply("ERR " + string(cmdArg[0]) + " keys must within one peer")
+		}
+		return cluster.relay(node, c, cmdArg)
+	}
+	return reply.NewSyntaxErrReply()
+}
@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/resp/reply"
+)
+
+// mget MGET key [key ...]
+// 按节点把key分组，每个节点执行一次MGET，再按原来的顺序拼接结果
+func mget(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	if len(cmdArg) < 2 {
+		return reply.NewArgNumErrReply("mget")
+	}
+	keys := cmdArg[1:]
+	groups := make(map[string][]int) // node -> 在keys中的下标
+	for i, key := range keys {
+		node := cluster.peerPicker.PickNode(string(key))
+		groups[node] = append(groups[node], i)
+	}
+
+	result := make([][]byte, len(keys))
+	for node, indices := range groups {
+		args := make([][]byte, 0, len(indices)+1)
+		args = append(args, []byte("mget"))
+		for _, i := range indices {
+			args = append(args, keys[i])
+		}
+		rep := cluster.relay(node, c, args)
+		if reply.IsErrReply(rep) {
+			return rep
+		}
+		multiBulk, ok := rep.(*reply.MultiBulkReply)
+		if !ok || len(multiBulk.Args) != len(indices) {
+			return reply.NewErrReply("ERR unexpected reply from " + node)
+		}
+		for j, i := range indices {
+			result[i] = multiBulk.Args[j]
+		}
+	}
+	return reply.NewMultiBulkReply(result)
+}
+
+// mset MSET key value [key value ...]
+// 按节点把键值对分组后分别执行，不保证跨节点的原子性
+func mset(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	args := cmdArg[1:]
+	if len(args) == 0 || len(args)%2 != 0 {
+		return reply.NewArgNumErrReply("mset")
+	}
+	groups := make(map[string][][]byte) // node -> 该节点的mset命令
+	for i := 0; i < len(args); i += 2 {
+		node := cluster.peerPicker.PickNode(string(args[i]))
+		if _, ok := groups[node]; !ok {
+			groups[node] = [][]byte{[]byte("mset")}
+		}
+		groups[node] = append(groups[node], args[i], args[i+1])
+	}
+	for node, nodeArgs := range groups {
+		rep := cluster.relay(node, c, nodeArgs)
+		if reply.IsErrReply(rep) {
+			return rep
+		}
+	}
+	return reply.NewOkReply()
+}
+
+// msetnx MSETNX key value [key value ...]
+// 需要全部成功或全部失败，因此要求所有key在同一个节点
+func msetnx(cluster *ClusterDatabase, c resp.Connection, cmdArg [][]byte) resp.Reply {
+	args := cmdArg[1:]
+	if len(args) == 0 || len(args)%2 != 0 {
+		return reply.NewArgNumErrReply("msetnx")
+	}
+	keys := make([][]byte, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+	}
+	node, ok := cluster.pickSameNode(keys...)
+	if !ok {
+		return reply.NewErrReply("ERR msetnx keys must within one peer")
+	}
+	return cluster.relay(node, c, cmdArg)
+}
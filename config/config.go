@@ -29,12 +29,18 @@ type ServerProperties struct {
 	AnnounceHost      string `cfg:"announce-host"`
 	AppendOnly        bool   `cfg:"appendonly"`
 	AppendFilename    string `cfg:"appendfilename"`
+	AppendDirname     string `cfg:"appenddirname"` // aof文件所在的目录，位于dir下，默认appendonlydir
 	AppendFsync       string `cfg:"appendfsync"`
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"`
 	AofUseRdbPreamble bool   `cfg:"aof-use-rdb-preamble"`
+	// aof文件比上次重写后增长超过该百分比时自动重写，为0时不自动重写
+	AutoAofRewritePercentage int    `cfg:"auto-aof-rewrite-percentage"`
+	AutoAofRewriteMinSize    string `cfg:"auto-aof-rewrite-min-size"` // 如64mb，文件小于该值时不自动重写
 	MaxClients        int    `cfg:"maxclients"`
 	RequirePass       string `cfg:"requirepass"`
 	Databases         int    `cfg:"databases"`
 	RDBFilename       string `cfg:"dbfilename"`
+	Save              string `cfg:"save"` // 自动保存RDB的条件："<seconds> <changes> ..."，为空时不自动保存
 	MasterAuth        string `cfg:"masterauth"`
 	SlaveAnnouncePort int    `cfg:"slave-announce-port"`
 	SlaveAnnounceIP   string `cfg:"slave-announce-ip"`
@@ -180,6 +186,35 @@ func SetupConfig(configFilename string) {
 	}
 }
 
+// AofRewriteMinSize 解析auto-aof-rewrite-min-size，支持k/kb/m/mb/g/gb单位，默认64mb
+func (p *ServerProperties) AofRewriteMinSize() int64 {
+	value := strings.ToLower(strings.TrimSpace(p.AutoAofRewriteMinSize))
+	if value == "" {
+		return 64 << 20
+	}
+	units := []struct {
+		suffix string
+		unit   int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	}
+	multiple := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSuffix(value, u.suffix)
+			multiple = u.unit
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		logger.Error("invalid auto-aof-rewrite-min-size: " + p.AutoAofRewriteMinSize)
+		return 64 << 20
+	}
+	return size * multiple
+}
+
 func GetTmpDir() string {
 	return Properties.Dir + "/tmp"
 }
\ No newline at end of file
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"go-redis/config"
+	"go-redis/lib/utils"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeAofTestDatabase 使用临时目录中的aof文件，测试结束后恢复配置
+func makeAofTestDatabase(t *testing.T) *StandaloneDatabase {
+	properties := *config.Properties
+	t.Cleanup(func() {
+		*config.Properties = properties
+	})
+	config.Properties.Dir = t.TempDir()
+	config.Properties.AppendOnly = true
+	config.Properties.AppendFilename = "appendonly.aof"
+	config.Properties.AppendDirname = "appendonlydir"
+	config.Properties.AutoAofRewritePercentage = 0
+	config.Properties.Databases = 4
+	return NewStandaloneDatabase()
+}
+
+// aofFiles 返回aof目录中的文件名
+func aofFiles(t *testing.T) []string {
+	entries, err := os.ReadDir(filepath.Join(config.Properties.Dir, config.Properties.AppendDirname))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func readAofFile(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join(config.Properties.Dir, config.Properties.AppendDirname, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// waitRewrite 等待重写完成，重写开始前的命令都已经在新文件中
+func waitRewrite(t *testing.T, d *StandaloneDatabase) {
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if !d.aofHandler.IsRewriting() {
+			return
+		}
+	}
+	t.Fatal("aof rewrite timeout")
+}
+
+func TestBgRewriteAof(t *testing.T) {
+	t.Run("commands", func(t *testing.T) {
+		testBgRewriteAof(t, false)
+	})
+	t.Run("rdb preamble", func(t *testing.T) {
+		testBgRewriteAof(t, true)
+	})
+}
+
+func testBgRewriteAof(t *testing.T, useRdbPreamble bool) {
+	d := makeAofTestDatabase(t)
+	config.Properties.AofUseRdbPreamble = useRdbPreamble
+	d.Close()
+	d = NewStandaloneDatabase()
+	conn := connection.NewFakeConn()
+	for i := 0; i < 100; i++ {
+		d.Exec(conn, utils.ToCmdLine("set", "str", strconv.Itoa(i)))
+		d.Exec(conn, utils.ToCmdLine("rpush", "list", strconv.Itoa(i)))
+	}
+	cmds := [][]string{
+		{"set", "ttl", "v", "ex", "100"},
+		{"sadd", "set", "x", "y"},
+		{"zadd", "zset", "1", "m1", "2.5", "m2"},
+		{"hset", "hash", "f", "v"},
+		{"xadd", "stream", "1-1", "f", "v"},
+		{"xadd", "stream", "1-2", "f", "v"},
+		{"xgroup", "create", "stream", "g", "0"},
+		{"xreadgroup", "group", "g", "alice", "count", "1", "streams", "stream", ">"},
+		{"xdel", "stream", "1-2"},
+		{"select", "2"},
+		{"set", "db2", "v2"},
+	}
+	for _, cmd := range cmds {
+		if result := d.Exec(conn, utils.ToCmdLine(cmd...)); reply.IsErrReply(result) {
+			t.Fatalf("%v: %s", cmd, result.ToBytes())
+		}
+	}
+
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgrewriteaof")),
+		reply.NewStatusReply("Background append only file rewriting started"))
+	// 重写期间的写入追加到新文件末尾
+	d.Exec(conn, utils.ToCmdLine("set", "during", "v"))
+	conn.SelectDB(0)
+	d.Exec(conn, utils.ToCmdLine("lpop", "list"))
+	waitRewrite(t, d)
+	d.Close()
+	// 重写之前的incr文件已经删除，只剩新的base文件和重写开始时打开的incr文件
+	baseName := "appendonly.aof.1.base.aof"
+	if useRdbPreamble {
+		baseName = "appendonly.aof.1.base.rdb"
+	}
+	expectedFiles := []string{baseName, "appendonly.aof.2.incr.aof", "appendonly.aof.manifest"}
+	if files := aofFiles(t); strings.Join(files, ",") != strings.Join(expectedFiles, ",") {
+		t.Fatalf("expected files %v, actually %v", expectedFiles, files)
+	}
+	expectedManifest := "file " + baseName + " seq 1 type b\nfile appendonly.aof.2.incr.aof seq 2 type i\n"
+	if manifest := string(readAofFile(t, "appendonly.aof.manifest")); manifest != expectedManifest {
+		t.Errorf("unexpected manifest %q", manifest)
+	}
+	base := readAofFile(t, baseName)
+	if len(base) > 4096 {
+		t.Errorf("aof file should be compacted, actually %d bytes", len(base))
+	}
+	if strings.HasPrefix(string(base), "REDIS") != useRdbPreamble {
+		t.Errorf("unexpected base file header %q", base[:5])
+	}
+
+	loaded := NewStandaloneDatabase()
+	conn = connection.NewFakeConn()
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "str")), reply.NewBulkReply([]byte("99")))
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("llen", "list")), 99)
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("lindex", "list", "0")), reply.NewBulkReply([]byte("1")))
+	if ttl := loaded.Exec(conn, utils.ToCmdLine("ttl", "ttl")).(*reply.IntReply).Code; ttl < 99 || ttl > 100 {
+		t.Errorf("expected ttl about 100, actually %d", ttl)
+	}
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("scard", "set")), 2)
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("zscore", "zset", "m2")), reply.NewBulkReply([]byte("2.5")))
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("hget", "hash", "f")), reply.NewBulkReply([]byte("v")))
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("xlen", "stream")), 1)
+	// 删除的消息不影响stream的last id
+	if result := loaded.Exec(conn, utils.ToCmdLine("xadd", "stream", "1-2", "f", "v")); !reply.IsErrReply(result) {
+		t.Error("expected error when adding id not greater than last id")
+	}
+	pending := loaded.Exec(conn, utils.ToCmdLine("xpending", "stream", "g"))
+	if result, ok := pending.(*reply.MultiRawReply); !ok || result.Replies[0].(*reply.IntReply).Code != 1 {
+		t.Errorf("expected 1 pending entry, actually %s", pending.ToBytes())
+	}
+	conn.SelectDB(2)
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "db2")), reply.NewBulkReply([]byte("v2")))
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "during")), reply.NewBulkReply([]byte("v")))
+	loaded.Close()
+}
+
+func TestBgRewriteAofWithSave(t *testing.T) {
+	d := makeAofTestDatabase(t)
+	conn := connection.NewFakeConn()
+	d.Exec(conn, utils.ToCmdLine("set", "k", "v"))
+
+	// 保存期间的重写会等待快照结束
+	d.snapshotMu.Lock()
+	dirty, _ := d.startSave()
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgrewriteaof")),
+		reply.NewStatusReply("Background append only file rewriting scheduled"))
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgrewriteaof")), reply.NewErrReply("ERR Background append only file rewriting already in progress"))
+	d.finishSave(dirty, nil)
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgsave")), reply.NewErrReply("ERR Another child process is active (AOF?): "+
+		"can't BGSAVE right now. Use BGSAVE SCHEDULE in order to schedule a BGSAVE whenever possible"))
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgsave", "schedule")), reply.NewStatusReply("Background saving scheduled"))
+	d.snapshotMu.Unlock()
+	waitRewrite(t, d)
+	d.Close()
+}
+
+func TestAppendFsyncAlways(t *testing.T) {
+	d := makeAofTestDatabase(t)
+	config.Properties.AppendFsync = "always"
+	d.Close()
+	d = NewStandaloneDatabase()
+	conn := connection.NewFakeConn()
+	// 回复客户端时命令已经写入文件
+	d.Exec(conn, utils.ToCmdLine("set", "k", "v"))
+	data := readAofFile(t, "appendonly.aof.1.incr.aof")
+	expected := reply.NewMultiBulkReply(utils.ToCmdLine("set", "k", "v")).ToBytes()
+	if !strings.HasSuffix(string(data), string(expected)) {
+		t.Errorf("expected aof ends with set command, actually %q", data)
+	}
+	info := string(d.Exec(conn, utils.ToCmdLine("info", "persistence")).(*reply.BulkReply).Arg)
+	if !strings.Contains(info, "aof_enabled:1\r\n") || !strings.Contains(info, "aof_last_write_status:ok\r\n") {
+		t.Errorf("unexpected info %q", info)
+	}
+	d.Close()
+}
+
+// TestAofFlushOnClose 关闭时把管道中剩余的命令写入文件
+func TestAofFlushOnClose(t *testing.T) {
+	d := makeAofTestDatabase(t)
+	conn := connection.NewFakeConn()
+	for i := 0; i < 1000; i++ {
+		d.Exec(conn, utils.ToCmdLine("incr", "counter"))
+	}
+	d.Close()
+	// 关闭后的写入不再记录
+	d.Exec(conn, utils.ToCmdLine("incr", "counter"))
+
+	loaded := NewStandaloneDatabase()
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "counter")), reply.NewBulkReply([]byte("1000")))
+	loaded.Close()
+}
+
+// TestLegacyAof 旧版本的单个aof文件作为base文件移入aof目录
+func TestLegacyAof(t *testing.T) {
+	d := makeAofTestDatabase(t)
+	d.Close()
+	config.Properties.Dir = t.TempDir()
+	legacy := reply.NewMultiBulkReply(utils.ToCmdLine("set", "k", "v")).ToBytes()
+	if err := os.WriteFile(filepath.Join(config.Properties.Dir, "appendonly.aof"), legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d = NewStandaloneDatabase()
+	conn := connection.NewFakeConn()
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("get", "k")), reply.NewBulkReply([]byte("v")))
+	d.Exec(conn, utils.ToCmdLine("set", "k2", "v2"))
+	d.Close()
+	if _, err := os.Stat(filepath.Join(config.Properties.Dir, "appendonly.aof")); !os.IsNotExist(err) {
+		t.Error("legacy aof file should be moved")
+	}
+	expectedFiles := []string{"appendonly.aof.1.base.aof", "appendonly.aof.1.incr.aof", "appendonly.aof.manifest"}
+	if files := aofFiles(t); strings.Join(files, ",") != strings.Join(expectedFiles, ",") {
+		t.Fatalf("expected files %v, actually %v", expectedFiles, files)
+	}
+
+	loaded := NewStandaloneDatabase()
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "k")), reply.NewBulkReply([]byte("v")))
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "k2")), reply.NewBulkReply([]byte("v2")))
+	loaded.Close()
+}
+
+// TestAofSelectInMulti 事务中切换了DB时，重放后数据仍在各自的DB中
+func TestAofSelectInMulti(t *testing.T) {
+	d := makeAofTestDatabase(t)
+	conn := connection.NewFakeConn()
+	for _, cmd := range [][]string{
+		{"multi"},
+		{"set", "k", "v0"},
+		{"select", "1"},
+		{"set", "k", "v1"},
+		{"select", "2"},
+		{"exec"},
+		// 事务开始时所在DB中的写入不能被重放到事务最后选择的DB
+		{"select", "0"},
+		{"set", "after", "v"},
+		{"select", "2"},
+		{"set", "k", "v2"},
+	} {
+		d.Exec(conn, utils.ToCmdLine(cmd...))
+	}
+	d.Close()
+
+	loaded := NewStandaloneDatabase()
+	conn = connection.NewFakeConn()
+	for dbIndex, expected := range []string{"v0", "v1", "v2"} {
+		conn.SelectDB(dbIndex)
+		assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "k")), reply.NewBulkReply([]byte(expected)))
+	}
+	conn.SelectDB(0)
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "after")), reply.NewBulkReply([]byte("v")))
+	conn.SelectDB(1)
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("exists", "after")), 0)
+	loaded.Close()
+}
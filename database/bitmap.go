@@ -0,0 +1,524 @@
+package database
+
+import (
+	"go-redis/datastruct/bitmap"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterCommand("setbit", execSetBit, writeFirstKey, 4)
+	RegisterCommand("getbit", execGetBit, readFirstKey, 3)
+	RegisterCommand("bitcount", execBitCount, readFirstKey, -2)
+	RegisterCommand("bitpos", execBitPos, readFirstKey, -3)
+	RegisterCommand("bitop", execBitOp, prepareBitOp, -4)
+	RegisterCommand("bitfield", execBitField, writeFirstKey, -2)
+}
+
+// maxBitOffset 位偏移不能超过字符串的最大长度
+const maxBitOffset = maxStringLength * 8
+
+func parseBitOffset(raw []byte) (int64, reply.ErrorReply) {
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || offset < 0 || offset >= maxBitOffset {
+		return 0, reply.NewErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// getBitMapForWrite 返回字符串的副本，修改不能直接写在原切片上：
+// GET返回的切片和尚未写入aof的命令可能仍在使用原来的字节
+func (db *DB) getBitMapForWrite(key string) (*bitmap.BitMap, reply.ErrorReply) {
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	return bitmap.FromBytes(append([]byte(nil), bytes...)), nil
+}
+
+// putBitMap 位图扩展后底层切片可能变化，需要重新写回
+func (db *DB) putBitMap(key string, bm *bitmap.BitMap) {
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: bm.ToBytes(),
+	})
+}
+
+// SETBIT key offset value
+func execSetBit(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	val := string(args[2])
+	if val != "0" && val != "1" {
+		return reply.NewErrReply("ERR bit is not an integer or out of range")
+	}
+	bm, errReply := db.getBitMapForWrite(key)
+	if errReply != nil {
+		return errReply
+	}
+	old := bm.GetBit(offset)
+	bm.SetBit(offset, val[0]-'0')
+	db.putBitMap(key, bm)
+	db.addAof(utils.ToCmdLine3("setbit", args...))
+	return reply.NewIntReply(int64(old))
+}
+
+// GETBIT key offset
+func execGetBit(db *DB, args [][]byte) resp.Reply {
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.NewIntReply(int64(bitmap.FromBytes(bytes).GetBit(offset)))
+}
+
+// parseBitRange 解析 [start [end [BYTE|BIT]]]，返回的区间是位偏移
+// 下标规则与GETRANGE相同，支持负数；区间为空时ok为false
+func parseBitRange(args [][]byte, byteSize int64) (start int64, end int64, ok bool, errReply reply.ErrorReply) {
+	if len(args) > 3 {
+		return 0, 0, false, reply.NewSyntaxErrReply()
+	}
+	size := byteSize
+	byBit := false
+	if len(args) == 3 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BYTE":
+		case "BIT":
+			byBit = true
+			size = byteSize * 8
+		default:
+			return 0, 0, false, reply.NewSyntaxErrReply()
+		}
+	}
+	start, end = 0, size-1
+	var err error
+	if len(args) >= 1 {
+		start, err = strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil {
+			return 0, 0, false, reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if len(args) >= 2 {
+		end, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return 0, 0, false, reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if size == 0 || start > end {
+		return 0, 0, false, nil
+	}
+	if !byBit {
+		start, end = start*8, end*8+7
+	}
+	return start, end, true, nil
+}
+
+// BITCOUNT key [start end [BYTE|BIT]]
+func execBitCount(db *DB, args [][]byte) resp.Reply {
+	// start和end必须同时出现
+	if len(args) == 2 {
+		return reply.NewSyntaxErrReply()
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	start, end, ok, errReply := parseBitRange(args[1:], int64(len(bytes)))
+	if errReply != nil {
+		return errReply
+	}
+	if !ok {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(bitmap.FromBytes(bytes).BitCount(start, end))
+}
+
+// BITPOS key bit [start [end [BYTE|BIT]]]
+func execBitPos(db *DB, args [][]byte) resp.Reply {
+	var bit byte
+	switch string(args[1]) {
+	case "0":
+		bit = 0
+	case "1":
+		bit = 1
+	default:
+		return reply.NewErrReply("ERR The bit argument must be 1 or 0.")
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	start, end, ok, errReply := parseBitRange(args[2:], int64(len(bytes)))
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		// 不存在的key视为全0的空字符串
+		if bit == 0 {
+			return reply.NewIntReply(0)
+		}
+		return reply.NewIntReply(-1)
+	}
+	if !ok {
+		return reply.NewIntReply(-1)
+	}
+	bm := bitmap.FromBytes(bytes)
+	pos := bm.BitPos(bit, start, end)
+	// 查找0且没有指定end时，认为字符串右侧有无限个0
+	if pos < 0 && bit == 0 && len(args) < 4 {
+		return reply.NewIntReply(bm.BitSize())
+	}
+	return reply.NewIntReply(pos)
+}
+
+func prepareBitOp(args [][]byte) ([]string, []string) {
+	_, readKeys := readAllKeys(args[2:])
+	return []string{string(args[1])}, readKeys
+}
+
+// BITOP AND|OR|XOR|NOT destkey key [key ...]
+// 长度不足的字符串用0补齐，结果长度等于最长的输入
+func execBitOp(db *DB, args [][]byte) resp.Reply {
+	op := strings.ToUpper(string(args[0]))
+	destKey := string(args[1])
+	srcKeys := args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(srcKeys) != 1 {
+			return reply.NewErrReply("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return reply.NewSyntaxErrReply()
+	}
+
+	srcs := make([][]byte, len(srcKeys))
+	maxLen := 0
+	for i, key := range srcKeys {
+		bytes, errReply := db.getAsString(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		srcs[i] = bytes
+		if len(bytes) > maxLen {
+			maxLen = len(bytes)
+		}
+	}
+
+	result := make([]byte, maxLen)
+	for i := 0; i < maxLen; i++ {
+		var b byte
+		for j, src := range srcs {
+			var cur byte
+			if i < len(src) {
+				cur = src[i]
+			}
+			if j == 0 {
+				b = cur
+				continue
+			}
+			switch op {
+			case "AND":
+				b &= cur
+			case "OR":
+				b |= cur
+			case "XOR":
+				b ^= cur
+			}
+		}
+		if op == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	db.RemoveEntity(destKey)
+	if maxLen > 0 {
+		db.PutEntity(destKey, &databaseface.DataEntity{
+			Data: result,
+		})
+	}
+	db.addAof(utils.ToCmdLine3("bitop", args...))
+	return reply.NewIntReply(int64(maxLen))
+}
+
+/* ---- BITFIELD ---- */
+
+const (
+	overflowWrap = iota
+	overflowSat
+	overflowFail
+)
+
+type bitFieldOp struct {
+	op       string // GET SET INCRBY
+	signed   bool
+	width    int
+	offset   int64
+	value    int64 // SET的值或INCRBY的增量
+	overflow int
+}
+
+// parseBitFieldType 解析 i<bits> 或 u<bits>，有符号最多64位，无符号最多63位
+func parseBitFieldType(raw []byte) (signed bool, width int, errReply reply.ErrorReply) {
+	errReply = reply.NewErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	str := strings.ToLower(string(raw))
+	if len(str) < 2 || str[0] != 'i' && str[0] != 'u' {
+		return false, 0, errReply
+	}
+	signed = str[0] == 'i'
+	width, err := strconv.Atoi(str[1:])
+	if err != nil || width < 1 || signed && width > 64 || !signed && width > 63 {
+		return false, 0, errReply
+	}
+	return signed, width, nil
+}
+
+// parseBitFieldOffset 以#开头的偏移量需要乘以类型的位数
+func parseBitFieldOffset(raw []byte, width int) (int64, reply.ErrorReply) {
+	errReply := reply.NewErrReply("ERR bit offset is not an integer or out of range")
+	str := string(raw)
+	multiply := strings.HasPrefix(str, "#")
+	if multiply {
+		str = str[1:]
+	}
+	offset, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errReply
+	}
+	if multiply {
+		if offset > maxBitOffset/int64(width) {
+			return 0, errReply
+		}
+		offset *= int64(width)
+	}
+	if offset+int64(width) > maxBitOffset {
+		return 0, errReply
+	}
+	return offset, nil
+}
+
+func parseBitFieldOps(args [][]byte) ([]*bitFieldOp, reply.ErrorReply) {
+	ops := make([]*bitFieldOp, 0)
+	overflow := overflowWrap
+	for i := 0; i < len(args); {
+		subCmd := strings.ToUpper(string(args[i]))
+		switch subCmd {
+		case "OVERFLOW":
+			if i+1 >= len(args) {
+				return nil, reply.NewSyntaxErrReply()
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return nil, reply.NewErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+		case "GET", "SET", "INCRBY":
+			argNum := 3
+			if subCmd != "GET" {
+				argNum = 4
+			}
+			if i+argNum > len(args) {
+				return nil, reply.NewSyntaxErrReply()
+			}
+			signed, width, errReply := parseBitFieldType(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			offset, errReply := parseBitFieldOffset(args[i+2], width)
+			if errReply != nil {
+				return nil, errReply
+			}
+			op := &bitFieldOp{
+				op:       subCmd,
+				signed:   signed,
+				width:    width,
+				offset:   offset,
+				overflow: overflow,
+			}
+			if subCmd != "GET" {
+				value, err := strconv.ParseInt(string(args[i+3]), 10, 64)
+				if err != nil {
+					return nil, reply.NewErrReply("ERR value is not an integer or out of range")
+				}
+				op.value = value
+			}
+			ops = append(ops, op)
+			i += argNum
+		default:
+			return nil, reply.NewSyntaxErrReply()
+		}
+	}
+	return ops, nil
+}
+
+// toSigned 把width位的值按补码解释为有符号数
+func toSigned(value uint64, width int) int64 {
+	shift := 64 - width
+	return int64(value<<shift) >> shift
+}
+
+func fieldMask(width int) uint64 {
+	if width == 64 {
+		return ^uint64(0)
+	}
+	return uint64(1)<<width - 1
+}
+
+// incrSigned 计算width位有符号数value+incr，ok为false表示FAIL模式下溢出
+func incrSigned(value, incr int64, width int, overflow int) (result int64, ok bool) {
+	max := int64(uint64(1)<<(width-1) - 1)
+	min := -max - 1
+	if incr > 0 && value > max-incr || incr < 0 && value < min-incr {
+		switch overflow {
+		case overflowFail:
+			return 0, false
+		case overflowSat:
+			if incr > 0 {
+				return max, true
+			}
+			return min, true
+		}
+	}
+	return toSigned((uint64(value)+uint64(incr))&fieldMask(width), width), true
+}
+
+// incrUnsigned 计算width位无符号数value+incr，ok为false表示FAIL模式下溢出
+func incrUnsigned(value uint64, incr int64, width int, overflow int) (result uint64, ok bool) {
+	max := fieldMask(width)
+	var overflowed bool
+	if incr >= 0 {
+		overflowed = uint64(incr) > max-value
+	} else {
+		overflowed = uint64(-incr) > value
+	}
+	if overflowed {
+		switch overflow {
+		case overflowFail:
+			return 0, false
+		case overflowSat:
+			if incr > 0 {
+				return max, true
+			}
+			return 0, true
+		}
+	}
+	return (value + uint64(incr)) & max, true
+}
+
+// execBitFieldOp 执行单个子命令，FAIL模式下溢出时返回nil回复且不修改
+func execBitFieldOp(bm *bitmap.BitMap, op *bitFieldOp) resp.Reply {
+	raw := bm.GetField(op.offset, op.width)
+	if op.op == "GET" {
+		if op.signed {
+			return reply.NewIntReply(toSigned(raw, op.width))
+		}
+		return reply.NewIntReply(int64(raw))
+	}
+
+	// SET 相当于从0开始增加value，溢出规则与INCRBY相同
+	var result uint64
+	var resultReply int64
+	if op.signed {
+		base := toSigned(raw, op.width)
+		if op.op == "SET" {
+			base = 0
+		}
+		value, ok := incrSigned(base, op.value, op.width, op.overflow)
+		if !ok {
+			return reply.NewNullBulkReply()
+		}
+		result, resultReply = uint64(value), value
+	} else {
+		base := raw
+		if op.op == "SET" {
+			base = 0
+		}
+		value, ok := incrUnsigned(base, op.value, op.width, op.overflow)
+		if !ok {
+			return reply.NewNullBulkReply()
+		}
+		result, resultReply = value, int64(value)
+	}
+	bm.SetField(op.offset, op.width, result&fieldMask(op.width))
+	if op.op == "SET" {
+		// SET返回旧值
+		if op.signed {
+			return reply.NewIntReply(toSigned(raw, op.width))
+		}
+		return reply.NewIntReply(int64(raw))
+	}
+	return reply.NewIntReply(resultReply)
+}
+
+// BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL] ...
+func execBitField(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	ops, errReply := parseBitFieldOps(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	readOnly := true
+	for _, op := range ops {
+		if op.op != "GET" {
+			readOnly = false
+			break
+		}
+	}
+	var bm *bitmap.BitMap
+	if readOnly {
+		bytes, errReply := db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		bm = bitmap.FromBytes(bytes)
+	} else {
+		bm, errReply = db.getBitMapForWrite(key)
+		if errReply != nil {
+			return errReply
+		}
+	}
+	written := false
+	results := make([]resp.Reply, len(ops))
+	for i, op := range ops {
+		results[i] = execBitFieldOp(bm, op)
+		if op.op != "GET" {
+			if _, failed := results[i].(*reply.NullBulkReply); !failed {
+				written = true
+			}
+		}
+	}
+	if written {
+		db.putBitMap(key, bm)
+		db.addAof(utils.ToCmdLine3("bitfield", args...))
+	}
+	return reply.NewMultiRawReply(results)
+}
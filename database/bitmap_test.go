@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"testing"
+)
+
+func assertIntReply(t *testing.T, result resp.Reply, expected int64) {
+	t.Helper()
+	intResult, ok := result.(*reply.IntReply)
+	if !ok || intResult.Code != expected {
+		t.Error(fmt.Sprintf("expected %d, actually %s", expected, string(result.ToBytes())))
+	}
+}
+
+func TestSetBitAndGetBit(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	assertIntReply(t, execSetBit(testDB, utils.ToCmdLine(key, "7", "1")), 0)
+	assertIntReply(t, execSetBit(testDB, utils.ToCmdLine(key, "7", "0")), 1)
+	assertIntReply(t, execSetBit(testDB, utils.ToCmdLine(key, "1", "1")), 0)
+	assertIntReply(t, execGetBit(testDB, utils.ToCmdLine(key, "1")), 1)
+	assertIntReply(t, execGetBit(testDB, utils.ToCmdLine(key, "100")), 0)
+	// GET返回底层字符串
+	result := execGet(testDB, utils.ToCmdLine(key))
+	expected := reply.NewBulkReply([]byte{0x40})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %q, actually %q", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	for _, args := range [][]string{
+		{key, "-1", "1"},
+		{key, "4294967296", "1"},
+		{key, "1", "2"},
+	} {
+		result = execSetBit(testDB, utils.ToCmdLine(args...))
+		if !reply.IsErrReply(result) {
+			t.Error(fmt.Sprintf("expected error, actually %s", string(result.ToBytes())))
+		}
+	}
+}
+
+func TestBitCountAndBitPos(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(key, "foobar"))
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(key)), 26)
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(key, "0", "0")), 4)
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(key, "1", "1")), 6)
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(key, "1", "1", "BYTE")), 6)
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(key, "5", "30", "BIT")), 17)
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(key, "-2", "-1")), 7)
+	assertIntReply(t, execBitCount(testDB, utils.ToCmdLine(utils.RandString(10))), 0)
+	if result := execBitCount(testDB, utils.ToCmdLine(key, "1")); !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected syntax error, actually %s", string(result.ToBytes())))
+	}
+
+	execSet(testDB, utils.ToCmdLine(key, "\xff\xf0\x00"))
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "0")), 12)
+	execSet(testDB, utils.ToCmdLine(key, "\x00\xff\xf0"))
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "1", "0")), 8)
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "1", "2")), 16)
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "1", "2", "-1", "BYTE")), 16)
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "1", "7", "15", "BIT")), 8)
+	// 全1时查找0：没有end参数返回字符串之后的位置，指定end返回-1
+	execSet(testDB, utils.ToCmdLine(key, "\xff\xff"))
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "0")), 16)
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(key, "0", "0", "-1")), -1)
+	missing := utils.RandString(10)
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(missing, "0")), 0)
+	assertIntReply(t, execBitPos(testDB, utils.ToCmdLine(missing, "1")), -1)
+}
+
+func TestBitOp(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1, key2, dest := utils.RandString(10), utils.RandString(10), utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(key1, "\xf0\x0f"))
+	execSet(testDB, utils.ToCmdLine(key2, "\xff"))
+
+	for _, c := range []struct {
+		op       string
+		keys     []string
+		expected []byte
+	}{
+		{"AND", []string{key1, key2}, []byte{0xf0, 0x00}},
+		{"OR", []string{key1, key2}, []byte{0xff, 0x0f}},
+		{"XOR", []string{key1, key2}, []byte{0x0f, 0x0f}},
+		{"NOT", []string{key1}, []byte{0x0f, 0xf0}},
+	} {
+		args := append([]string{c.op, dest}, c.keys...)
+		assertIntReply(t, execBitOp(testDB, utils.ToCmdLine(args...)), int64(len(c.expected)))
+		result := execGet(testDB, utils.ToCmdLine(dest))
+		expected := reply.NewBulkReply(c.expected)
+		if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+			t.Error(fmt.Sprintf("%s: expected %q, actually %q", c.op, string(expected.ToBytes()), string(result.ToBytes())))
+		}
+	}
+
+	// 结果为空时删除dest
+	assertIntReply(t, execBitOp(testDB, utils.ToCmdLine("AND", dest, utils.RandString(10))), 0)
+	if _, exists := testDB.GetEntity(dest); exists {
+		t.Error("empty result should remove dest")
+	}
+	if result := execBitOp(testDB, utils.ToCmdLine("NOT", dest, key1, key2)); !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected error, actually %s", string(result.ToBytes())))
+	}
+}
+
+func TestBitField(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+
+	result := execBitField(testDB, utils.ToCmdLine(key, "SET", "i8", "0", "100", "GET", "u4", "0", "INCRBY", "i8", "#1", "1"))
+	expected := reply.NewMultiRawReply([]resp.Reply{reply.NewIntReply(0), reply.NewIntReply(6), reply.NewIntReply(1)})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	for _, c := range []struct {
+		args     []string
+		expected []resp.Reply
+	}{
+		// 默认WRAP
+		{[]string{"SET", "u8", "16", "255", "INCRBY", "u8", "16", "10"}, []resp.Reply{reply.NewIntReply(0), reply.NewIntReply(9)}},
+		{[]string{"SET", "i8", "16", "127", "INCRBY", "i8", "16", "1"}, []resp.Reply{reply.NewIntReply(9), reply.NewIntReply(-128)}},
+		{[]string{"OVERFLOW", "SAT", "INCRBY", "i8", "16", "-10", "INCRBY", "u8", "16", "-1000"}, []resp.Reply{reply.NewIntReply(-128), reply.NewIntReply(0)}},
+		{[]string{"OVERFLOW", "SAT", "SET", "u8", "16", "1000", "GET", "u8", "16"}, []resp.Reply{reply.NewIntReply(0), reply.NewIntReply(255)}},
+		{[]string{"OVERFLOW", "FAIL", "INCRBY", "u8", "16", "1", "GET", "u8", "16"}, []resp.Reply{reply.NewNullBulkReply(), reply.NewIntReply(255)}},
+		{[]string{"SET", "i64", "32", "9223372036854775807", "INCRBY", "i64", "32", "1"}, []resp.Reply{reply.NewIntReply(0), reply.NewIntReply(-9223372036854775808)}},
+	} {
+		result = execBitField(testDB, utils.ToCmdLine(append([]string{key}, c.args...)...))
+		expected = reply.NewMultiRawReply(c.expected)
+		if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+			t.Error(fmt.Sprintf("%v: expected %s, actually %s", c.args, string(expected.ToBytes()), string(result.ToBytes())))
+		}
+	}
+
+	// 只有GET时不会创建key
+	missing := utils.RandString(10)
+	execBitField(testDB, utils.ToCmdLine(missing, "GET", "u8", "0"))
+	if _, exists := testDB.GetEntity(missing); exists {
+		t.Error("bitfield GET should not create key")
+	}
+
+	for _, args := range [][]string{
+		{key, "GET", "u64", "0"},
+		{key, "GET", "i65", "0"},
+		{key, "GET", "x8", "0"},
+		{key, "GET", "u8", "-1"},
+		{key, "SET", "u8", "0"},
+		{key, "OVERFLOW", "FOO"},
+		{key, "FOO"},
+	} {
+		result = execBitField(testDB, utils.ToCmdLine(args...))
+		if !reply.IsErrReply(result) {
+			t.Error(fmt.Sprintf("%v: expected error, actually %s", args, string(result.ToBytes())))
+		}
+	}
+}
+
+// TestBitMapCopyOnWrite 修改位图不能影响SET的参数和GET返回的切片
+func TestBitMapCopyOnWrite(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	args := utils.ToCmdLine(key, "\x00")
+	execSet(testDB, args)
+	got := execGet(testDB, utils.ToCmdLine(key)).(*reply.BulkReply)
+	execSetBit(testDB, utils.ToCmdLine(key, "0", "1"))
+	execBitField(testDB, utils.ToCmdLine(key, "INCRBY", "u8", "0", "1"))
+	if args[1][0] != 0 || got.Arg[0] != 0 {
+		t.Errorf("stored bytes should not be modified in place, args %x, get %x", args[1], got.Arg)
+	}
+	assertReply(t, execGet(testDB, utils.ToCmdLine(key)), reply.NewBulkReply([]byte{0x81}))
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"go-redis/interface/resp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	阻塞命令(如 XREAD BLOCK)在没有数据时不能持有key锁等待，否则会挡住写入数据的命令
+	因此阻塞命令在锁外等待：先在waiterTable中登记关心的key，尝试执行失败后等待唤醒，
+	写命令执行后唤醒等待它所写key的客户端，被唤醒的客户端重新加锁尝试
+	弹出元素的命令按阻塞的先后顺序取得数据；在事务中阻塞命令按非阻塞的方式执行
+ */
+
+// BlockingFunc 阻塞版本的命令实现，cmdLine包含命令名；连接关闭时需要放弃等待
+type BlockingFunc func(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply
+
+var blockingTable = make(map[string]BlockingFunc)
+
+// RegisterBlockingCommand 为已经注册的命令提供阻塞版本的实现
+func RegisterBlockingCommand(name string, fn BlockingFunc) {
+	blockingTable[strings.ToLower(name)] = fn
+}
+
+// waiter 一个阻塞中的客户端
+type waiter struct {
+	ch chan struct{}
+}
+
+// waiterTable 记录每个key上阻塞的客户端，按阻塞的先后顺序排列
+type waiterTable struct {
+	mu      sync.Mutex
+	waiters map[string][]*waiter
+}
+
+func newWaiterTable() *waiterTable {
+	return &waiterTable{
+		waiters: make(map[string][]*waiter),
+	}
+}
+
+func (t *waiterTable) add(keys []string, w *waiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		t.waiters[key] = append(t.waiters[key], w)
+	}
+}
+
+// remove 移除w，并唤醒这些key上剩下的客户端：w可能是因为超时离开的，数据需要交给排在后面的客户端
+func (t *waiterTable) remove(keys []string, w *waiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		queue := t.waiters[key]
+		for i, other := range queue {
+			if other == w {
+				queue = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(t.waiters, key)
+			continue
+		}
+		t.waiters[key] = queue
+		t.wakeLocked(key)
+	}
+}
+
+// isFirst w是否是key上最早阻塞的客户端，弹出元素的命令只有排在最前面时才能取走数据
+func (t *waiterTable) isFirst(key string, w *waiter) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	queue := t.waiters[key]
+	return len(queue) > 0 && queue[0] == w
+}
+
+// wake 通知等待这些key的客户端重试，通道带缓冲，不会阻塞写命令
+func (t *waiterTable) wake(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		t.wakeLocked(key)
+	}
+}
+
+func (t *waiterTable) wakeLocked(key string) {
+	for _, w := range t.waiters[key] {
+		select {
+		case w.ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// execBlocking 反复调用try直到它返回非nil的结果；超时或者连接关闭时返回nil
+// try需要自己对key加锁；timeout为0表示一直等待
+func (db *DB) execBlocking(c resp.Connection, keys []string, timeout time.Duration, try func(w *waiter) resp.Reply) resp.Reply {
+	keys = uniqueKeys(keys)
+	// 先登记再尝试，避免在两者之间写入的数据被错过
+	w := &waiter{
+		ch: make(chan struct{}, 1),
+	}
+	db.waiters.add(keys, w)
+	defer db.waiters.remove(keys, w)
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	var done <-chan struct{}
+	if c != nil {
+		done = c.Done()
+	}
+	for {
+		if result := try(w); result != nil {
+			return result
+		}
+		select {
+		case <-w.ch:
+		case <-timeoutCh:
+			return nil
+		case <-done:
+			return nil
+		}
+	}
+}
+
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, key)
+	}
+	return result
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/resp/reply"
+	"go-redis/tracking"
+	"strings"
+)
+
+// execClient CLIENT ID | TRACKING | CACHING | GETREDIR
+func execClient(d *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.NewArgNumErrReply("client")
+	}
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "id":
+		if len(args) != 1 {
+			return reply.NewArgNumErrReply("client|id")
+		}
+		return reply.NewIntReply(int64(c.GetID()))
+	case "tracking":
+		return tracking.Tracking(d.tracking, c, args[1:])
+	case "caching":
+		return tracking.Caching(d.tracking, c, args[1:])
+	case "getredir":
+		if len(args) != 1 {
+			return reply.NewArgNumErrReply("client|getredir")
+		}
+		return tracking.GetRedir(d.tracking, c)
+	}
+	return reply.NewErrReply("ERR unknown subcommand '" + subCmd + "'. Try CLIENT HELP.")
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"go-redis/lib/utils"
+	"go-redis/pubsub"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"go-redis/tracking"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientTracking(t *testing.T) {
+	hub := pubsub.MakeHub()
+	d := &StandaloneDatabase{
+		dbSet:    []*DB{makeTestDB()},
+		hub:      hub,
+		tracking: tracking.MakeTable(hub),
+	}
+	d.dbSet[0].tracking = d.tracking
+	redirect, conn, writer := connection.NewFakeConn(), connection.NewFakeConn(), connection.NewFakeConn()
+	assertReply(t, d.Exec(redirect, utils.ToCmdLine("client", "id")), reply.NewIntReply(int64(redirect.GetID())))
+	d.Exec(redirect, utils.ToCmdLine("subscribe", tracking.InvalidateChannel))
+	time.Sleep(5 * time.Millisecond)
+	redirect.Bytes()
+
+	id := strconv.FormatUint(redirect.GetID(), 10)
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("client", "tracking", "on", "redirect", id)), reply.NewOkReply())
+
+	expectInvalidation := func(keys ...string) {
+		t.Helper()
+		expected := append([]byte("*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n"),
+			reply.NewMultiBulkReply(utils.ToCmdLine(keys...)).ToBytes()...)
+		var actual []byte
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && len(actual) < len(expected); {
+			time.Sleep(5 * time.Millisecond)
+			actual = append(actual, redirect.Bytes()...)
+		}
+		if string(actual) != string(expected) {
+			t.Errorf("expected %q, actually %q", string(expected), string(actual))
+		}
+	}
+
+	// 只读命令记录key，写命令使其失效
+	d.Exec(conn, utils.ToCmdLine("get", "k"))
+	d.Exec(writer, utils.ToCmdLine("set", "k", "v"))
+	expectInvalidation("k")
+
+	// 事务中的读取同样会被记录
+	d.Exec(conn, utils.ToCmdLine("multi"))
+	d.Exec(conn, utils.ToCmdLine("get", "k"))
+	d.Exec(conn, utils.ToCmdLine("exec"))
+	d.Exec(writer, utils.ToCmdLine("pexpire", "k", "100"))
+	expectInvalidation("k")
+
+	// 过期删除也会通知
+	d.Exec(conn, utils.ToCmdLine("get", "k"))
+	time.Sleep(150 * time.Millisecond)
+	d.Exec(writer, utils.ToCmdLine("exists", "k"))
+	expectInvalidation("k")
+}
@@ -7,12 +7,68 @@ import (
 var cmdTable = make(map[string]*command)
 type command struct {
 	exector ExecFunc
+	prepare PreFunc // 执行前分析出命令会写入和读取的key
 	arity int // number of args
+	lockMode int
 }
 
-func RegisterCommand(name string, exector ExecFunc, arity int) {
+// 命令执行时的加锁方式
+const (
+	lockKeys = iota // 只锁prepare声明的key
+	lockAllRead // 遍历整个DB的命令(如KEYS)，对所有分段加读锁
+	lockAllWrite // 修改整个DB的命令(如FLUSHDB)，对所有分段加写锁
+)
+
+func RegisterCommand(name string, exector ExecFunc, prepare PreFunc, arity int) {
+	cmdTable[strings.ToLower(name)] = &command{
+		exector: exector,
+		prepare: prepare,
+		arity: arity,
+	}
+}
+
+// registerLockAllCommand 注册访问整个DB的命令，执行时按lockMode锁住所有分段
+func registerLockAllCommand(name string, exector ExecFunc, lockMode int, arity int) {
 	cmdTable[strings.ToLower(name)] = &command{
 		exector: exector,
+		prepare: noPrepare,
 		arity: arity,
+		lockMode: lockMode,
 	}
 }
+
+/* ---- 常用的 PreFunc ---- */
+
+func noPrepare(args [][]byte) ([]string, []string) {
+	return nil, nil
+}
+
+func writeFirstKey(args [][]byte) ([]string, []string) {
+	return []string{string(args[0])}, nil
+}
+
+func readFirstKey(args [][]byte) ([]string, []string) {
+	return nil, []string{string(args[0])}
+}
+
+func writeAllKeys(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys, nil
+}
+
+func readAllKeys(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return nil, keys
+}
+
+// writeFirstKeyReadOthers 用于 XXXSTORE destination key [key ...] 形式的命令
+func writeFirstKeyReadOthers(args [][]byte) ([]string, []string) {
+	_, readKeys := readAllKeys(args[1:])
+	return []string{string(args[0])}, readKeys
+}
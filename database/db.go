@@ -4,29 +4,100 @@ import (
 	"go-redis/datastruct/dict"
 	"go-redis/interface/database"
 	"go-redis/interface/resp"
+	"go-redis/lib/timewheel"
 	"go-redis/resp/reply"
+	"go-redis/tracking"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type DB struct {
 	index int
 	data dict.Dict
-	addAof func(CmdLine)
+	ttlMap dict.Dict // key -> expire time (time.Time)
+	versionMap dict.Dict // key -> version (uint32)，供WATCH判断key是否被修改
+	// 命令执行前对它声明的key加锁，保证多步操作的原子性
+	// 使用指针是为了让事务中复制出的DB共享同一张锁表
+	locker *lockTable
+	// 阻塞命令在这里登记等待的key，写命令执行后唤醒它们
+	waiters *waiterTable
+	// 多条命令一起传入时作为一个整体写入aof
+	addAof func(...CmdLine)
+	// 键空间通知，publish为nil时不发布
+	notifyFlags int
+	publish func(channel string, message []byte)
+	// 客户端缓存的跟踪表，所有DB共享，为nil时不跟踪
+	tracking *tracking.Table
+	// 正在进行的RDB快照，写命令修改key前需要保存旧值
+	snapshot *atomic.Pointer[rdbSnapshot]
+	// 按序号查找同一个服务器中的DB，EXEC需要检查WATCH时所在DB中的key
+	getDB func(index int) *DB
 }
 
 
 type ExecFunc func(db *DB, args [][]byte) resp.Reply
 
+// PreFunc 分析命令参数，返回命令会写入和读取的key
+type PreFunc func(args [][]byte) (writeKeys []string, readKeys []string)
+
 type CmdLine = [][]byte
 
 func newDB() *DB {
 	return &DB{
 		data: dict.NewSyncDict(),
-		addAof: func(CmdLine) {},
+		ttlMap: dict.NewSyncDict(),
+		versionMap: dict.NewSyncDict(),
+		locker: newLockTable(defaultLockCount),
+		waiters: newWaiterTable(),
+		addAof: func(...CmdLine) {},
+		snapshot: &atomic.Pointer[rdbSnapshot]{},
 	}
 }
 
 func (db *DB) Exec(c resp.Connection, cmdLine CmdLine) resp.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	switch cmdName {
+	case "multi":
+		if len(cmdLine) != 1 {
+			return reply.NewArgNumErrReply(cmdName)
+		}
+		return StartMulti(c)
+	case "discard":
+		if len(cmdLine) != 1 {
+			return reply.NewArgNumErrReply(cmdName)
+		}
+		return DiscardMulti(c)
+	case "exec":
+		if len(cmdLine) != 1 {
+			return reply.NewArgNumErrReply(cmdName)
+		}
+		return db.execMulti(c)
+	case "watch":
+		if len(cmdLine) < 2 {
+			return reply.NewArgNumErrReply(cmdName)
+		}
+		return Watch(db, c, cmdLine[1:])
+	case "unwatch":
+		if len(cmdLine) != 1 {
+			return reply.NewArgNumErrReply(cmdName)
+		}
+		return Unwatch(c)
+	}
+	if c != nil && c.InMultiState() {
+		return EnqueueCmd(c, cmdLine)
+	}
+	if blocking, ok := blockingTable[cmdName]; ok {
+		if cmd, ok := cmdTable[cmdName]; ok && !validateArity(cmd.arity, cmdLine) {
+			return reply.NewArgNumErrReply(cmdName)
+		}
+		return blocking(db, c, cmdLine)
+	}
+	return db.execNormalCommand(c, cmdLine)
+}
+
+func (db *DB) execNormalCommand(c resp.Connection, cmdLine CmdLine) resp.Reply {
 	cmdName := strings.ToLower(string(cmdLine[0]))
 	cmd, ok := cmdTable[cmdName]
 	if !ok {
@@ -35,18 +106,77 @@ func (db *DB) Exec(c resp.Connection, cmdLine CmdLine) resp.Reply {
 	if !validateArity(cmd.arity, cmdLine) {
 		return reply.NewArgNumErrReply(cmdName)
 	}
+	if cmd.lockMode != lockKeys {
+		return db.execLockAllCommand(cmd, cmdLine)
+	}
+	writeKeys, readKeys := cmd.prepare(cmdLine[1:])
+	// 先解锁再唤醒，被唤醒的阻塞命令需要重新加锁
+	defer db.waiters.wake(writeKeys...)
+	db.locker.RWLocks(writeKeys, readKeys)
+	defer db.locker.RWUnLocks(writeKeys, readKeys)
+	db.beforeWrite(writeKeys...)
+	result := db.execWrite(cmd, cmdLine[1:], writeKeys)
+	db.trackKeys(c, writeKeys, readKeys)
+	return result
+}
+
+// execWrite 执行命令，命令写入了aof才说明修改了数据，此时才增加写入key的版本号
+// 出错或没有修改数据的写命令不会让WATCH这些key的事务失败
+func (db *DB) execWrite(cmd *command, args [][]byte, writeKeys []string) resp.Reply {
+	if len(writeKeys) == 0 {
+		return cmd.exector(db, args)
+	}
+	written := false
+	// 与事务中的txDB相同，复制出的DB共享数据，只是记录命令是否写入了aof
+	cmdDB := *db
+	cmdDB.addAof = func(lines ...CmdLine) {
+		written = true
+		db.addAof(lines...)
+	}
+	result := cmd.exector(&cmdDB, args)
+	if written {
+		db.addVersion(writeKeys...)
+	}
+	return result
+}
+
+// execLockAllCommand 对所有分段加锁后执行访问整个DB的命令，版本号和快照由命令自己处理
+func (db *DB) execLockAllCommand(cmd *command, cmdLine CmdLine) resp.Reply {
+	if cmd.lockMode == lockAllWrite {
+		db.locker.LockAll()
+		defer db.locker.UnLockAll()
+	} else {
+		db.locker.RLockAll()
+		defer db.locker.RUnLockAll()
+	}
 	return cmd.exector(db, cmdLine[1:])
 }
 
+// trackKeys 只读命令记录客户端读取的key，写命令通知缓存了这些key的客户端
+// 调用方需要持有key的锁，保证读取与记录之间key不会被修改
+func (db *DB) trackKeys(c resp.Connection, writeKeys []string, readKeys []string) {
+	if db.tracking == nil {
+		return
+	}
+	if len(writeKeys) > 0 {
+		db.tracking.Invalidate(c, writeKeys)
+	} else {
+		db.tracking.Remember(c, readKeys)
+	}
+}
+
 func validateArity(arity int, cmdArgs [][]byte) bool {
 	argNum := len(cmdArgs)
 	if arity >= 0 {
 		return arity == argNum
 	}
-	return argNum >= -argNum
+	return argNum >= -arity
 }
 
 func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
+	if db.IsExpired(key) {
+		return nil, false
+	}
 	value, exit := db.data.Get(key)
 	if !exit {
 		return nil, false
@@ -61,24 +191,117 @@ func (db *DB) PutEntity(key string, value *database.DataEntity) int {
 }
 
 func (db *DB) PutEntityIfExist(key string, value *database.DataEntity) int {
+	db.IsExpired(key)
 	return db.data.PutIfExist(key, value.Data)
 }
 
 func (db *DB) PutEntityIfAbsent(key string, value *database.DataEntity) int {
+	db.IsExpired(key)
 	return db.data.PutIfAbsent(key, value.Data)
 }
+
+// RemoveEntity 删除key，同时清除它的过期时间
 func (db *DB) RemoveEntity(key string) int {
+	db.Persist(key)
 	return db.data.Remove(key)
 }
 
 func (db *DB) Removes(keys ...string) int {
 	deleted := 0
 	for _, key := range keys {
-		deleted += db.data.Remove(key)
+		deleted += db.RemoveEntity(key)
 	}
 	return deleted
 }
 
 func (db *DB) Flush() {
+	db.beforeWrite(db.data.Keys()...)
 	db.data.Clear()
+	db.ttlMap.Clear()
+	// 所有key都被删除，WATCH了任意key的事务都应该失败
+	db.versionMap.ForEach(func(key string, val interface{}) bool {
+		db.addVersion(key)
+		return true
+	})
+	if db.tracking != nil {
+		db.tracking.InvalidateAll()
+	}
+}
+
+/* ---- version ---- */
+
+// addVersion 写命令执行时增加key的版本号
+func (db *DB) addVersion(keys ...string) {
+	for _, key := range keys {
+		db.versionMap.Put(key, db.GetVersion(key)+1)
+	}
+}
+
+// GetVersion 返回key当前的版本号，从未被写过的key版本号为0
+func (db *DB) GetVersion(key string) uint32 {
+	raw, ok := db.versionMap.Get(key)
+	if !ok {
+		return 0
+	}
+	return raw.(uint32)
+}
+
+/* ---- TTL ---- */
+
+func genExpireTask(index int, key string) string {
+	return "expire:" + strconv.Itoa(index) + ":" + key
+}
+
+// Expire 设置key的过期时间，到期后由时间轮删除
+func (db *DB) Expire(key string, expireTime time.Time) {
+	db.ttlMap.Put(key, expireTime)
+	timewheel.At(expireTime, genExpireTask(db.index, key), func() {
+		keys := []string{key}
+		db.locker.RWLocks(keys, nil)
+		defer db.locker.RWUnLocks(keys, nil)
+		// 等待期间过期时间可能已被修改，需要再检查一次
+		rawExpireTime, ok := db.ttlMap.Get(key)
+		if !ok {
+			return
+		}
+		expireTime, _ := rawExpireTime.(time.Time)
+		if time.Now().After(expireTime) {
+			db.RemoveEntity(key)
+			db.addVersion(key)
+			db.trackKeys(nil, keys, nil)
+			db.notify(notifyExpired, "expired", key)
+		}
+	})
+}
+
+// Persist 取消key的过期时间
+func (db *DB) Persist(key string) {
+	if db.ttlMap.Remove(key) > 0 {
+		timewheel.Cancel(genExpireTask(db.index, key))
+	}
+}
+
+// TTLOf 返回key的过期时间，没有设置过期时间时ok为false
+func (db *DB) TTLOf(key string) (expireTime time.Time, ok bool) {
+	rawExpireTime, ok := db.ttlMap.Get(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	return rawExpireTime.(time.Time), true
+}
+
+// IsExpired 惰性检查：key已过期则立即删除，保证读不到过期数据
+func (db *DB) IsExpired(key string) bool {
+	expireTime, ok := db.TTLOf(key)
+	if !ok {
+		return false
+	}
+	expired := time.Now().After(expireTime)
+	if expired {
+		db.RemoveEntity(key)
+		db.addVersion(key)
+		db.trackKeys(nil, []string{key}, nil)
+		db.notify(notifyExpired, "expired", key)
+	}
+	return expired
 }
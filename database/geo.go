@@ -0,0 +1,507 @@
+package database
+
+import (
+	"fmt"
+	SortedSet "go-redis/datastruct/sortedset"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/geohash"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	地理位置保存在有序集合中，score是经纬度的52位geohash
+	因此GEO命令添加的key也可以使用ZRANGE、ZREM等有序集合命令
+ */
+
+func init() {
+	RegisterCommand("geoadd", execGeoAdd, writeFirstKey, -5)
+	RegisterCommand("geopos", execGeoPos, readFirstKey, -2)
+	RegisterCommand("geodist", execGeoDist, readFirstKey, -4)
+	RegisterCommand("geohash", execGeoHash, readFirstKey, -2)
+	RegisterCommand("geosearch", execGeoSearch, readFirstKey, -7)
+	RegisterCommand("geosearchstore", execGeoSearchStore, prepareGeoSearchStore, -8)
+}
+
+// parseGeoUnit 返回一个单位对应的米数
+func parseGeoUnit(raw []byte) (float64, reply.ErrorReply) {
+	switch strings.ToLower(string(raw)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, reply.NewErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+func parseGeoFloat(raw []byte) (float64, reply.ErrorReply) {
+	value, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return 0, reply.NewErrReply("ERR value is not a valid float")
+	}
+	return value, nil
+}
+
+func parseLonLat(rawLon, rawLat []byte) (longitude, latitude float64, errReply reply.ErrorReply) {
+	longitude, errReply = parseGeoFloat(rawLon)
+	if errReply != nil {
+		return 0, 0, errReply
+	}
+	latitude, errReply = parseGeoFloat(rawLat)
+	if errReply != nil {
+		return 0, 0, errReply
+	}
+	if !geohash.Valid(longitude, latitude) {
+		return 0, 0, reply.NewErrReply(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", longitude, latitude))
+	}
+	return longitude, latitude, nil
+}
+
+func formatCoordinate(value float64) []byte {
+	return []byte(strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func formatDistance(meters float64, unit float64) []byte {
+	return []byte(strconv.FormatFloat(meters/unit, 'f', 4, 64))
+}
+
+// getGeoPosition 返回成员的经纬度，key或成员不存在时ok为false
+func getGeoPosition(sortedSet *SortedSet.SortedSet, member string) (longitude, latitude float64, ok bool) {
+	if sortedSet == nil {
+		return 0, 0, false
+	}
+	element, ok := sortedSet.Get(member)
+	if !ok {
+		return 0, 0, false
+	}
+	longitude, latitude = geohash.Decode(uint64(element.Score))
+	return longitude, latitude, true
+}
+
+// GEOADD key [NX|XX] [CH] longitude latitude member [longitude latitude member ...]
+func execGeoAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var nx, xx, ch bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "CH":
+			ch = true
+		default:
+			break options
+		}
+	}
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return reply.NewSyntaxErrReply()
+	}
+	if nx && xx {
+		return reply.NewErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	elements := make([]*SortedSet.Element, len(triples)/3)
+	for j := 0; j < len(triples); j += 3 {
+		longitude, latitude, errReply := parseLonLat(triples[j], triples[j+1])
+		if errReply != nil {
+			return errReply
+		}
+		elements[j/3] = &SortedSet.Element{
+			Member: string(triples[j+2]),
+			Score:  float64(geohash.Encode(longitude, latitude)),
+		}
+	}
+
+	sortedSet, _, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added, changed := 0, 0
+	for _, element := range elements {
+		current, exists := sortedSet.Get(element.Member)
+		if exists && nx || !exists && xx {
+			continue
+		}
+		if exists {
+			if current.Score != element.Score {
+				changed++
+			}
+		} else {
+			added++
+		}
+		sortedSet.Add(element.Member, element.Score)
+	}
+	if sortedSet.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if added+changed > 0 {
+		db.addAof(utils.ToCmdLine3("geoadd", args...))
+	}
+	if ch {
+		return reply.NewIntReply(int64(added + changed))
+	}
+	return reply.NewIntReply(int64(added))
+}
+
+// GEOPOS key member [member ...]
+func execGeoPos(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, 0, len(args)-1)
+	for _, member := range args[1:] {
+		longitude, latitude, ok := getGeoPosition(sortedSet, string(member))
+		if !ok {
+			result = append(result, reply.NewNullArrayReply())
+			continue
+		}
+		result = append(result, reply.NewMultiBulkReply([][]byte{
+			formatCoordinate(longitude),
+			formatCoordinate(latitude),
+		}))
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// GEODIST key member1 member2 [M|KM|FT|MI]
+func execGeoDist(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 4 {
+		return reply.NewSyntaxErrReply()
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var errReply reply.ErrorReply
+		unit, errReply = parseGeoUnit(args[3])
+		if errReply != nil {
+			return errReply
+		}
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	lon1, lat1, ok1 := getGeoPosition(sortedSet, string(args[1]))
+	lon2, lat2, ok2 := getGeoPosition(sortedSet, string(args[2]))
+	if !ok1 || !ok2 {
+		return reply.NewNullBulkReply()
+	}
+	return reply.NewBulkReply(formatDistance(geohash.Distance(lon1, lat1, lon2, lat2), unit))
+}
+
+// GEOHASH key member [member ...]
+func execGeoHash(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, 0, len(args)-1)
+	for _, member := range args[1:] {
+		if sortedSet == nil {
+			result = append(result, reply.NewNullBulkReply())
+			continue
+		}
+		element, ok := sortedSet.Get(string(member))
+		if !ok {
+			result = append(result, reply.NewNullBulkReply())
+			continue
+		}
+		result = append(result, reply.NewBulkReply([]byte(geohash.ToString(uint64(element.Score)))))
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+/* ---- GEOSEARCH ---- */
+
+type geoSearchOptions struct {
+	fromMember bool
+	fromLonLat bool
+	member     string
+	longitude  float64
+	latitude   float64
+
+	byRadius bool
+	byBox    bool
+	radius   float64 // 米
+	width    float64
+	height   float64
+	unit     float64 // 返回距离时使用的单位
+
+	sort      int // 0表示不排序，1为ASC，-1为DESC
+	count     int // 0表示不限制
+	any       bool
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+// parseGeoSearchArgs 解析GEOSEARCH的参数(不含key)，store为true时解析GEOSEARCHSTORE
+func parseGeoSearchArgs(args [][]byte, store bool) (*geoSearchOptions, reply.ErrorReply) {
+	opts := &geoSearchOptions{}
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "FROMMEMBER" && i+1 < len(args):
+			opts.fromMember = true
+			opts.member = string(args[i+1])
+			i++
+		case option == "FROMLONLAT" && i+2 < len(args):
+			longitude, latitude, errReply := parseLonLat(args[i+1], args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.fromLonLat = true
+			opts.longitude, opts.latitude = longitude, latitude
+			i += 2
+		case option == "BYRADIUS" && i+2 < len(args):
+			radius, errReply := parseGeoFloat(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			if radius < 0 {
+				return nil, reply.NewErrReply("ERR radius cannot be negative")
+			}
+			unit, errReply := parseGeoUnit(args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.byRadius = true
+			opts.radius, opts.unit = radius*unit, unit
+			i += 2
+		case option == "BYBOX" && i+3 < len(args):
+			width, errReply := parseGeoFloat(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			height, errReply := parseGeoFloat(args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			if width < 0 || height < 0 {
+				return nil, reply.NewErrReply("ERR height or width cannot be negative")
+			}
+			unit, errReply := parseGeoUnit(args[i+3])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.byBox = true
+			opts.width, opts.height, opts.unit = width*unit, height*unit, unit
+			i += 3
+		case option == "ASC":
+			opts.sort = 1
+		case option == "DESC":
+			opts.sort = -1
+		case option == "COUNT" && i+1 < len(args):
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			if count <= 0 {
+				return nil, reply.NewErrReply("ERR COUNT must be > 0")
+			}
+			opts.count = int(count)
+			i++
+			if i+1 < len(args) && strings.ToUpper(string(args[i+1])) == "ANY" {
+				opts.any = true
+				i++
+			}
+		case option == "ANY":
+			return nil, reply.NewErrReply("ERR the ANY argument requires COUNT argument")
+		case option == "WITHCOORD" && !store:
+			opts.withCoord = true
+		case option == "WITHDIST" && !store:
+			opts.withDist = true
+		case option == "WITHHASH" && !store:
+			opts.withHash = true
+		case option == "STOREDIST" && store:
+			opts.storeDist = true
+		default:
+			return nil, reply.NewSyntaxErrReply()
+		}
+	}
+	if opts.fromMember == opts.fromLonLat {
+		return nil, reply.NewErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+	}
+	if opts.byRadius == opts.byBox {
+		return nil, reply.NewErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+	}
+	// 限制了数量又不是ANY时，需要返回最近的count个
+	if opts.count > 0 && !opts.any && opts.sort == 0 {
+		opts.sort = 1
+	}
+	return opts, nil
+}
+
+type geoPoint struct {
+	member    string
+	hash      uint64
+	longitude float64
+	latitude  float64
+	dist      float64 // 与中心的距离，单位为米
+}
+
+// distanceIfInArea 返回点与中心的距离，点不在搜索范围内时ok为false
+func (opts *geoSearchOptions) distanceIfInArea(longitude, latitude float64) (dist float64, ok bool) {
+	if opts.byRadius {
+		dist = geohash.Distance(opts.longitude, opts.latitude, longitude, latitude)
+		return dist, dist <= opts.radius
+	}
+	// 矩形的宽沿纬线方向，在点所在的纬度上计算经度方向的距离
+	if geohash.LatDistance(opts.latitude, latitude) > opts.height/2 {
+		return 0, false
+	}
+	if geohash.Distance(opts.longitude, latitude, longitude, latitude) > opts.width/2 {
+		return 0, false
+	}
+	return geohash.Distance(opts.longitude, opts.latitude, longitude, latitude), true
+}
+
+// geoSearch 返回有序集合中在搜索范围内的点
+func geoSearch(db *DB, key string, opts *geoSearchOptions) ([]*geoPoint, reply.ErrorReply) {
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	points := make([]*geoPoint, 0)
+	if sortedSet == nil {
+		return points, nil
+	}
+	if opts.fromMember {
+		longitude, latitude, ok := getGeoPosition(sortedSet, opts.member)
+		if !ok {
+			return nil, reply.NewErrReply("ERR could not decode requested zset member")
+		}
+		opts.longitude, opts.latitude = longitude, latitude
+	}
+
+	var ranges []geohash.Range
+	if opts.byRadius {
+		ranges = geohash.RadiusRanges(opts.longitude, opts.latitude, opts.radius)
+	} else {
+		ranges = geohash.BoxRanges(opts.longitude, opts.latitude, opts.width, opts.height)
+	}
+	for _, r := range ranges {
+		min := &SortedSet.ScoreBorder{Value: float64(r.Min)}
+		max := &SortedSet.ScoreBorder{Value: float64(r.Max), Exclude: true}
+		sortedSet.ForEach(min, max, 0, -1, false, func(element *SortedSet.Element) bool {
+			hash := uint64(element.Score)
+			longitude, latitude := geohash.Decode(hash)
+			dist, ok := opts.distanceIfInArea(longitude, latitude)
+			if !ok {
+				return true
+			}
+			points = append(points, &geoPoint{
+				member:    element.Member,
+				hash:      hash,
+				longitude: longitude,
+				latitude:  latitude,
+				dist:      dist,
+			})
+			// ANY只要找到足够数量的点就可以返回
+			return !opts.any || len(points) < opts.count
+		})
+		if opts.any && len(points) >= opts.count {
+			break
+		}
+	}
+
+	if opts.sort != 0 {
+		sort.SliceStable(points, func(i, j int) bool {
+			if opts.sort > 0 {
+				return points[i].dist < points[j].dist
+			}
+			return points[i].dist > points[j].dist
+		})
+	}
+	if opts.count > 0 && len(points) > opts.count {
+		points = points[:opts.count]
+	}
+	return points, nil
+}
+
+// GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude
+// BYRADIUS radius M|KM|FT|MI|BYBOX width height M|KM|FT|MI
+// [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func execGeoSearch(db *DB, args [][]byte) resp.Reply {
+	opts, errReply := parseGeoSearchArgs(args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	points, errReply := geoSearch(db, string(args[0]), opts)
+	if errReply != nil {
+		return errReply
+	}
+	if !opts.withCoord && !opts.withDist && !opts.withHash {
+		members := make([][]byte, len(points))
+		for i, point := range points {
+			members[i] = []byte(point.member)
+		}
+		return reply.NewMultiBulkReply(members)
+	}
+	result := make([]resp.Reply, len(points))
+	for i, point := range points {
+		item := []resp.Reply{reply.NewBulkReply([]byte(point.member))}
+		if opts.withDist {
+			item = append(item, reply.NewBulkReply(formatDistance(point.dist, opts.unit)))
+		}
+		if opts.withHash {
+			item = append(item, reply.NewIntReply(int64(point.hash)))
+		}
+		if opts.withCoord {
+			item = append(item, reply.NewMultiBulkReply([][]byte{
+				formatCoordinate(point.longitude),
+				formatCoordinate(point.latitude),
+			}))
+		}
+		result[i] = reply.NewMultiRawReply(item)
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+func prepareGeoSearchStore(args [][]byte) ([]string, []string) {
+	return []string{string(args[0])}, []string{string(args[1])}
+}
+
+// GEOSEARCHSTORE destination source FROMMEMBER member|FROMLONLAT longitude latitude
+// BYRADIUS radius M|KM|FT|MI|BYBOX width height M|KM|FT|MI
+// [ASC|DESC] [COUNT count [ANY]] [STOREDIST]
+// 结果保存为有序集合，score为geohash，STOREDIST时为距离
+func execGeoSearchStore(db *DB, args [][]byte) resp.Reply {
+	dest := string(args[0])
+	opts, errReply := parseGeoSearchArgs(args[2:], true)
+	if errReply != nil {
+		return errReply
+	}
+	points, errReply := geoSearch(db, string(args[1]), opts)
+	if errReply != nil {
+		return errReply
+	}
+	db.RemoveEntity(dest)
+	if len(points) > 0 {
+		sortedSet := SortedSet.Make()
+		for _, point := range points {
+			score := float64(point.hash)
+			if opts.storeDist {
+				score = point.dist / opts.unit
+			}
+			sortedSet.Add(point.member, score)
+		}
+		db.PutEntity(dest, &databaseface.DataEntity{
+			Data: sortedSet,
+		})
+	}
+	db.addAof(utils.ToCmdLine3("geosearchstore", args...))
+	return reply.NewIntReply(int64(len(points)))
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"testing"
+)
+
+func TestGeoAddAndQuery(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	assertIntReply(t, execGeoAdd(testDB, utils.ToCmdLine(key,
+		"13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania")), 2)
+	assertIntReply(t, execGeoAdd(testDB, utils.ToCmdLine(key, "NX", "13", "38", "Palermo")), 0)
+	assertIntReply(t, execGeoAdd(testDB, utils.ToCmdLine(key, "XX", "CH", "13", "38", "Rome")), 0)
+	assertReply(t, execGeoAdd(testDB, utils.ToCmdLine(key, "181", "0", "x")),
+		reply.NewErrReply("ERR invalid longitude,latitude pair 181.000000,0.000000"))
+
+	assertReply(t, execGeoDist(testDB, utils.ToCmdLine(key, "Palermo", "Catania")), reply.NewBulkReply([]byte("166274.1516")))
+	assertReply(t, execGeoDist(testDB, utils.ToCmdLine(key, "Palermo", "Catania", "km")), reply.NewBulkReply([]byte("166.2742")))
+	assertReply(t, execGeoDist(testDB, utils.ToCmdLine(key, "Palermo", "Rome")), reply.NewNullBulkReply())
+	assertReply(t, execGeoHash(testDB, utils.ToCmdLine(key, "Palermo", "Catania", "Rome")), reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte("sqc8b49rny0")),
+		reply.NewBulkReply([]byte("sqdtr74hyu0")),
+		reply.NewNullBulkReply(),
+	}))
+	pos := execGeoPos(testDB, utils.ToCmdLine(key, "Palermo", "Rome")).(*reply.MultiRawReply)
+	if len(pos.Replies) != 2 {
+		t.Fatal("expected 2 positions")
+	}
+	assertReply(t, pos.Replies[0], reply.NewMultiBulkReply(utils.ToCmdLine("13.361389338970184", "38.1155563954963")))
+	assertReply(t, pos.Replies[1], reply.NewNullArrayReply())
+	// 可以使用有序集合命令
+	assertReply(t, execType(testDB, utils.ToCmdLine(key)), reply.NewStatusReply("zset"))
+}
+
+func TestGeoSearch(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execGeoAdd(testDB, utils.ToCmdLine(key,
+		"13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania",
+		"12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"))
+
+	result := execGeoSearch(testDB, utils.ToCmdLine(key, "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"))
+	assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine("Catania", "Palermo")))
+	result = execGeoSearch(testDB, utils.ToCmdLine(key, "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "DESC", "WITHDIST"))
+	assertReply(t, result, reply.NewMultiRawReply([]resp.Reply{
+		reply.NewMultiBulkReply(utils.ToCmdLine("Palermo", "190.4424")),
+		reply.NewMultiBulkReply(utils.ToCmdLine("Catania", "56.4413")),
+	}))
+	result = execGeoSearch(testDB, utils.ToCmdLine(key, "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC"))
+	assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine("Catania", "Palermo", "edge2", "edge1")))
+	result = execGeoSearch(testDB, utils.ToCmdLine(key, "FROMMEMBER", "Palermo", "BYRADIUS", "1000", "km", "COUNT", "1"))
+	assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine("Palermo")))
+	result = execGeoSearch(testDB, utils.ToCmdLine(key, "FROMMEMBER", "Rome", "BYRADIUS", "1000", "km"))
+	assertReply(t, result, reply.NewErrReply("ERR could not decode requested zset member"))
+	result = execGeoSearch(testDB, utils.ToCmdLine(key, "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "BYBOX", "1", "1", "km"))
+	assertReply(t, result, reply.NewErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH"))
+
+	dest := utils.RandString(10)
+	assertIntReply(t, execGeoSearchStore(testDB, utils.ToCmdLine(dest, key,
+		"FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "STOREDIST")), 2)
+	assertReply(t, execZScore(testDB, utils.ToCmdLine(dest, "Catania")), reply.NewBulkReply([]byte("56.4412578701568")))
+	assertIntReply(t, execGeoSearchStore(testDB, utils.ToCmdLine(dest, key,
+		"FROMLONLAT", "0", "0", "BYRADIUS", "1", "km")), 0)
+	assertIntReply(t, execExist(testDB, utils.ToCmdLine(dest)), 0)
+}
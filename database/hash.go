@@ -0,0 +1,455 @@
+package database
+
+import (
+	"go-redis/datastruct/dict"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/lib/wildcard"
+	"go-redis/resp/reply"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterCommand("hset", execHSet, writeFirstKey, -4)
+	RegisterCommand("hmset", execHMSet, writeFirstKey, -4)
+	RegisterCommand("hsetnx", execHSetNX, writeFirstKey, 4)
+	RegisterCommand("hget", execHGet, readFirstKey, 3)
+	RegisterCommand("hmget", execHMGet, readFirstKey, -3)
+	RegisterCommand("hdel", execHDel, writeFirstKey, -3)
+	RegisterCommand("hexists", execHExists, readFirstKey, 3)
+	RegisterCommand("hlen", execHLen, readFirstKey, 2)
+	RegisterCommand("hstrlen", execHStrlen, readFirstKey, 3)
+	RegisterCommand("hkeys", execHKeys, readFirstKey, 2)
+	RegisterCommand("hvals", execHVals, readFirstKey, 2)
+	RegisterCommand("hgetall", execHGetAll, readFirstKey, 2)
+	RegisterCommand("hincrby", execHIncrBy, writeFirstKey, 4)
+	RegisterCommand("hincrbyfloat", execHIncrByFloat, writeFirstKey, 4)
+	RegisterCommand("hrandfield", execHRandField, readFirstKey, -2)
+	RegisterCommand("hscan", execHScan, readFirstKey, -3)
+}
+
+func (db *DB) getAsDict(key string) (dict.Dict, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	d, ok := entity.Data.(dict.Dict)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return d, nil
+}
+
+func (db *DB) getOrInitDict(key string) (d dict.Dict, inited bool, errReply reply.ErrorReply) {
+	d, errReply = db.getAsDict(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if d == nil {
+		d = dict.NewSimpleDict()
+		db.PutEntity(key, &databaseface.DataEntity{
+			Data: d,
+		})
+		inited = true
+	}
+	return d, inited, nil
+}
+
+// HSET key field value [field value ...]
+func execHSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 1 {
+		return reply.NewArgNumErrReply("hset")
+	}
+	key := string(args[0])
+	d, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		added += d.Put(string(args[i]), args[i+1])
+	}
+	db.addAof(utils.ToCmdLine3("hset", args...))
+	return reply.NewIntReply(int64(added))
+}
+
+// HMSET key field value [field value ...]
+func execHMSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 1 {
+		return reply.NewArgNumErrReply("hmset")
+	}
+	if r := execHSet(db, args); reply.IsErrReply(r) {
+		return r
+	}
+	return reply.NewOkReply()
+}
+
+// HSETNX key field value
+func execHSetNX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	d, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := d.PutIfAbsent(string(args[1]), args[2])
+	if result > 0 {
+		db.addAof(utils.ToCmdLine3("hsetnx", args...))
+	}
+	return reply.NewIntReply(int64(result))
+}
+
+// HGET key field
+func execHGet(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewNullBulkReply()
+	}
+	raw, exists := d.Get(string(args[1]))
+	if !exists {
+		return reply.NewNullBulkReply()
+	}
+	return reply.NewBulkReply(raw.([]byte))
+}
+
+// HMGET key field [field ...]
+func execHMGet(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	fields := args[1:]
+	result := make([]resp.Reply, len(fields))
+	for i, field := range fields {
+		result[i] = reply.NewNullBulkReply()
+		if d == nil {
+			continue
+		}
+		if raw, exists := d.Get(string(field)); exists {
+			result[i] = reply.NewBulkReply(raw.([]byte))
+		}
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// HDEL key field [field ...]
+func execHDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	d, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewIntReply(0)
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		deleted += d.Remove(string(field))
+	}
+	if d.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine3("hdel", args...))
+	}
+	return reply.NewIntReply(int64(deleted))
+}
+
+// HEXISTS key field
+func execHExists(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewIntReply(0)
+	}
+	if _, exists := d.Get(string(args[1])); exists {
+		return reply.NewIntReply(1)
+	}
+	return reply.NewIntReply(0)
+}
+
+// HLEN key
+func execHLen(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(int64(d.Len()))
+}
+
+// HSTRLEN key field
+func execHStrlen(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewIntReply(0)
+	}
+	raw, exists := d.Get(string(args[1]))
+	if !exists {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(int64(len(raw.([]byte))))
+}
+
+// HKEYS key
+func execHKeys(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewNullMultiBulkReply()
+	}
+	fields := make([][]byte, 0, d.Len())
+	d.ForEach(func(field string, val interface{}) bool {
+		fields = append(fields, []byte(field))
+		return true
+	})
+	return reply.NewMultiBulkReply(fields)
+}
+
+// HVALS key
+func execHVals(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewNullMultiBulkReply()
+	}
+	values := make([][]byte, 0, d.Len())
+	d.ForEach(func(field string, val interface{}) bool {
+		values = append(values, val.([]byte))
+		return true
+	})
+	return reply.NewMultiBulkReply(values)
+}
+
+// HGETALL key
+func execHGetAll(db *DB, args [][]byte) resp.Reply {
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewNullMultiBulkReply()
+	}
+	result := make([][]byte, 0, d.Len()*2)
+	d.ForEach(func(field string, val interface{}) bool {
+		result = append(result, []byte(field), val.([]byte))
+		return true
+	})
+	return reply.NewMultiBulkReply(result)
+}
+
+// HINCRBY key field increment
+func execHIncrBy(db *DB, args [][]byte) resp.Reply {
+	key, field := string(args[0]), string(args[1])
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	d, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current int64
+	if raw, exists := d.Get(field); exists {
+		current, err = strconv.ParseInt(string(raw.([]byte)), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR hash value is not an integer")
+		}
+	}
+	if delta > 0 && current > math.MaxInt64-delta || delta < 0 && current < math.MinInt64-delta {
+		return reply.NewErrReply("ERR increment or decrement would overflow")
+	}
+	result := current + delta
+	d.Put(field, []byte(strconv.FormatInt(result, 10)))
+	db.addAof(utils.ToCmdLine3("hincrby", args...))
+	return reply.NewIntReply(result)
+}
+
+// HINCRBYFLOAT key field increment
+func execHIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key, field := string(args[0]), string(args[1])
+	delta, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return reply.NewErrReply("ERR value is not a valid float")
+	}
+	d, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current float64
+	if raw, exists := d.Get(field); exists {
+		current, err = strconv.ParseFloat(string(raw.([]byte)), 64)
+		if err != nil {
+			return reply.NewErrReply("ERR hash value is not a float")
+		}
+	}
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return reply.NewErrReply("ERR increment would produce NaN or Infinity")
+	}
+	value := []byte(strconv.FormatFloat(result, 'f', -1, 64))
+	d.Put(field, value)
+	// 浮点运算结果可能因平台而异，aof中直接记录最终值
+	db.addAof(utils.ToCmdLine3("hset", args[0], args[1], value))
+	return reply.NewBulkReply(value)
+}
+
+// maxRandomCount count为负数时允许重复，返回的个数不受key大小的限制，需要限制分配的内存
+const maxRandomCount = 1 << 24
+
+// checkRandomCount 负数count的绝对值不能超过maxRandomCount，同时避免对MinInt64取反溢出
+func checkRandomCount(count int64) reply.ErrorReply {
+	if count < -maxRandomCount {
+		return reply.NewErrReply("ERR value is out of range")
+	}
+	return nil
+}
+
+// HRANDFIELD key [count [WITHVALUES]]
+func execHRandField(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 3 {
+		return reply.NewSyntaxErrReply()
+	}
+	var count int64
+	withValues := false
+	if len(args) >= 2 {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if errReply := checkRandomCount(count); errReply != nil {
+		return errReply
+	}
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHVALUES" {
+			return reply.NewSyntaxErrReply()
+		}
+		withValues = true
+	}
+
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if d == nil {
+			return reply.NewNullBulkReply()
+		}
+		return reply.NewBulkReply([]byte(d.RandomKeys(1)[0]))
+	}
+	if d == nil || count == 0 {
+		return reply.NewNullMultiBulkReply()
+	}
+
+	// count为正数时返回不重复的field，为负数时允许重复
+	var fields []string
+	if count > 0 {
+		fields = d.RandomDistinctKeys(int(count))
+	} else {
+		fields = d.RandomKeys(int(-count))
+	}
+	result := make([][]byte, 0, len(fields)*2)
+	for _, field := range fields {
+		result = append(result, []byte(field))
+		if withValues {
+			raw, _ := d.Get(field)
+			result = append(result, raw.([]byte))
+		}
+	}
+	return reply.NewMultiBulkReply(result)
+}
+
+// HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+// 游标是按字典序排列后的field下标
+func execHScan(db *DB, args [][]byte) resp.Reply {
+	cursor, err := strconv.Atoi(string(args[1]))
+	if err != nil || cursor < 0 {
+		return reply.NewErrReply("ERR invalid cursor")
+	}
+	count := 10
+	noValues := false
+	var pattern *wildcard.Pattern
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			if i+1 >= len(args) {
+				return reply.NewSyntaxErrReply()
+			}
+			pattern, err = wildcard.CompilePattern(string(args[i+1]))
+			if err != nil {
+				return reply.NewErrReply("ERR invalid pattern")
+			}
+			i++
+		case "COUNT":
+			if i+1 >= len(args) {
+				return reply.NewSyntaxErrReply()
+			}
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return reply.NewSyntaxErrReply()
+			}
+			i++
+		case "NOVALUES":
+			noValues = true
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	d, errReply := db.getAsDict(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if d == nil {
+		return reply.NewMultiRawReply([]resp.Reply{
+			reply.NewBulkReply([]byte("0")),
+			reply.NewNullMultiBulkReply(),
+		})
+	}
+
+	fields := d.Keys()
+	sort.Strings(fields)
+	result := make([][]byte, 0)
+	next := cursor
+	for ; next < len(fields) && next < cursor+count; next++ {
+		field := fields[next]
+		if pattern != nil && !pattern.IsMatch(field) {
+			continue
+		}
+		result = append(result, []byte(field))
+		if !noValues {
+			raw, _ := d.Get(field)
+			result = append(result, raw.([]byte))
+		}
+	}
+	if next >= len(fields) {
+		next = 0
+	}
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(strconv.Itoa(next))),
+		reply.NewMultiBulkReply(result),
+	})
+}
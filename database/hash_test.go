@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strconv"
+	"testing"
+)
+
+func TestHSet(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	size := 100
+	key := utils.RandString(10)
+	values := make(map[string][]byte, size)
+	for i := 0; i < size; i++ {
+		field := strconv.Itoa(i)
+		value := utils.RandString(10)
+		values[field] = []byte(value)
+		result := execHSet(testDB, utils.ToCmdLine(key, field, value))
+		if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+			t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+		}
+	}
+	for field, value := range values {
+		result := execHGet(testDB, utils.ToCmdLine(key, field))
+		expected := reply.NewBulkReply(value)
+		if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+			t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+		}
+	}
+	result := execHLen(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(size) {
+		t.Error(fmt.Sprintf("expected %d, actually %d", size, intResult.Code))
+	}
+	result = execType(testDB, utils.ToCmdLine(key))
+	if status, _ := result.(*reply.StatusReply); status.Status != "hash" {
+		t.Error(fmt.Sprintf("expected hash, actually %s", status.Status))
+	}
+
+	// update existing field
+	result = execHSet(testDB, utils.ToCmdLine(key, "0", "a", "new", "b"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execHSetNX(testDB, utils.ToCmdLine(key, "0", "c"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+
+	// wrong type
+	strKey := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(strKey, "v"))
+	result = execHGet(testDB, utils.ToCmdLine(strKey, "f"))
+	if _, ok := result.(*reply.WrongTypeErrReply); !ok {
+		t.Error(fmt.Sprintf("expected wrong type error, actually %s", string(result.ToBytes())))
+	}
+}
+
+func TestHDel(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execHSet(testDB, utils.ToCmdLine(key, "a", "1", "b", "2"))
+
+	result := execHDel(testDB, utils.ToCmdLine(key, "a", "c"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execHMGet(testDB, utils.ToCmdLine(key, "a", "b"))
+	expected := reply.NewMultiRawReply([]resp.Reply{reply.NewNullBulkReply(), reply.NewBulkReply([]byte("2"))})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	// empty hash is removed
+	execHDel(testDB, utils.ToCmdLine(key, "b"))
+	result = execExist(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+}
+
+func TestHIncrBy(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	for i := 0; i < 10; i++ {
+		result := execHIncrBy(testDB, utils.ToCmdLine(key, "a", "1"))
+		if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(i+1) {
+			t.Error(fmt.Sprintf("expected %d, actually %d", i+1, intResult.Code))
+		}
+	}
+	result := execHIncrByFloat(testDB, utils.ToCmdLine(key, "a", "0.5"))
+	expected := reply.NewBulkReply([]byte("10.5"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execHIncrBy(testDB, utils.ToCmdLine(key, "a", "1"))
+	if !reply.IsErrReply(result) {
+		t.Error("expected not an integer error")
+	}
+	execHSet(testDB, utils.ToCmdLine(key, "max", "9223372036854775807"))
+	result = execHIncrBy(testDB, utils.ToCmdLine(key, "max", "1"))
+	if !reply.IsErrReply(result) {
+		t.Error("expected overflow error")
+	}
+}
+
+func TestHScan(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	size := 25
+	for i := 0; i < size; i++ {
+		execHSet(testDB, utils.ToCmdLine(key, "f"+strconv.Itoa(i), "v"))
+	}
+	seen := 0
+	cursor := "0"
+	for {
+		result := execHScan(testDB, utils.ToCmdLine(key, cursor, "COUNT", "10", "NOVALUES"))
+		multiRaw, _ := result.(*reply.MultiRawReply)
+		cursor = string(multiRaw.Replies[0].(*reply.BulkReply).Arg)
+		seen += len(multiRaw.Replies[1].(*reply.MultiBulkReply).Args)
+		if cursor == "0" {
+			break
+		}
+	}
+	if seen != size {
+		t.Error(fmt.Sprintf("expected %d, actually %d", size, seen))
+	}
+
+	result := execHRandField(testDB, utils.ToCmdLine(key, "5", "WITHVALUES"))
+	if multiBulk, _ := result.(*reply.MultiBulkReply); len(multiBulk.Args) != 10 {
+		t.Error(fmt.Sprintf("expected 10, actually %d", len(multiBulk.Args)))
+	}
+	result = execHRandField(testDB, utils.ToCmdLine(key, "-30"))
+	if multiBulk, _ := result.(*reply.MultiBulkReply); len(multiBulk.Args) != 30 {
+		t.Error(fmt.Sprintf("expected 30, actually %d", len(multiBulk.Args)))
+	}
+	for _, count := range []string{"-9223372036854775808", "-4294967296"} {
+		result = execHRandField(testDB, utils.ToCmdLine(key, count))
+		assertReply(t, result, reply.NewErrReply("ERR value is out of range"))
+	}
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"go-redis/datastruct/hyperloglog"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+)
+
+func init() {
+	RegisterCommand("pfadd", execPFAdd, writeFirstKey, -2)
+	RegisterCommand("pfcount", execPFCount, preparePFCount, -2)
+	RegisterCommand("pfmerge", execPFMerge, writeFirstKeyReadOthers, -2)
+}
+
+func newInvalidHLLErrReply() reply.ErrorReply {
+	return reply.NewErrReply("WRONGTYPE Key is not a valid HyperLogLog string value.")
+}
+
+// getAsHLL HLL以字符串形式存储，内容不是合法的HLL时返回错误
+func (db *DB) getAsHLL(key string) (*hyperloglog.HLL, reply.ErrorReply) {
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+	h, ok := hyperloglog.FromBytes(bytes)
+	if !ok {
+		return nil, newInvalidHLLErrReply()
+	}
+	return h, nil
+}
+
+// getHLLForWrite 返回HLL的副本，修改不能直接写在原来的字节上：
+// GET返回的切片和尚未写入aof的命令可能仍在使用它们
+func (db *DB) getHLLForWrite(key string) (*hyperloglog.HLL, reply.ErrorReply) {
+	h, errReply := db.getAsHLL(key)
+	if h == nil || errReply != nil {
+		return nil, errReply
+	}
+	h, _ = hyperloglog.FromBytes(append([]byte(nil), h.ToBytes()...))
+	return h, nil
+}
+
+func (db *DB) putHLL(key string, h *hyperloglog.HLL) {
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: h.ToBytes(),
+	})
+}
+
+// PFADD key [element [element ...]]
+func execPFAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	h, errReply := db.getHLLForWrite(key)
+	if errReply != nil {
+		return errReply
+	}
+	created := false
+	if h == nil {
+		h = hyperloglog.New()
+		created = true
+	}
+	changed := h.Add(args[1:]...)
+	if !created && !changed {
+		return reply.NewIntReply(0)
+	}
+	db.putHLL(key, h)
+	db.addAof(utils.ToCmdLine3("pfadd", args...))
+	return reply.NewIntReply(1)
+}
+
+// preparePFCount 单个key时PFCOUNT会更新HLL中缓存的基数，需要加写锁
+func preparePFCount(args [][]byte) ([]string, []string) {
+	if len(args) == 1 {
+		return writeFirstKey(args)
+	}
+	return readAllKeys(args)
+}
+
+// PFCOUNT key [key ...]
+// 多个key时返回它们并集的基数，不会修改任何key
+func execPFCount(db *DB, args [][]byte) resp.Reply {
+	hlls := make([]*hyperloglog.HLL, 0, len(args))
+	for _, arg := range args {
+		h, errReply := db.getAsHLL(string(arg))
+		if errReply != nil {
+			return errReply
+		}
+		if h != nil {
+			hlls = append(hlls, h)
+		}
+	}
+	if len(hlls) == 0 {
+		return reply.NewIntReply(0)
+	}
+	if len(args) == 1 {
+		if count, ok := hlls[0].CachedCount(); ok {
+			return reply.NewIntReply(int64(count))
+		}
+		// 缓存失效时在副本上重新计算，写回带有新缓存的副本
+		key := string(args[0])
+		h, _ := db.getHLLForWrite(key)
+		count := h.Count()
+		db.putHLL(key, h)
+		return reply.NewIntReply(int64(count))
+	}
+	return reply.NewIntReply(int64(hyperloglog.Count(hlls...)))
+}
+
+// PFMERGE destkey [sourcekey [sourcekey ...]]
+func execPFMerge(db *DB, args [][]byte) resp.Reply {
+	destKey := string(args[0])
+	dest, errReply := db.getHLLForWrite(destKey)
+	if errReply != nil {
+		return errReply
+	}
+	sources := make([]*hyperloglog.HLL, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		h, errReply := db.getAsHLL(string(arg))
+		if errReply != nil {
+			return errReply
+		}
+		if h != nil {
+			sources = append(sources, h)
+		}
+	}
+	if dest == nil {
+		dest = hyperloglog.New()
+	}
+	dest.Merge(sources...)
+	db.putHLL(destKey, dest)
+	db.addAof(utils.ToCmdLine3("pfmerge", args...))
+	return reply.NewOkReply()
+}
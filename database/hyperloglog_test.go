@@ -0,0 +1,95 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strconv"
+	"testing"
+)
+
+func TestPFAddAndCount(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+
+	// 不带元素时只创建key
+	assertIntReply(t, execPFAdd(testDB, utils.ToCmdLine(key)), 1)
+	assertIntReply(t, execPFAdd(testDB, utils.ToCmdLine(key)), 0)
+	assertIntReply(t, execPFAdd(testDB, utils.ToCmdLine(key, "a", "b", "c", "d", "e", "f", "g")), 1)
+	assertIntReply(t, execPFAdd(testDB, utils.ToCmdLine(key, "a", "b")), 0)
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(key)), 7)
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(utils.RandString(10))), 0)
+
+	// 以字符串形式存储，可以GET/SET
+	raw := execGet(testDB, utils.ToCmdLine(key)).(*reply.BulkReply).Arg
+	copied := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine3(copied, raw))
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(copied)), 7)
+	result := execType(testDB, utils.ToCmdLine(copied))
+	if !utils.BytesEquals(result.ToBytes(), reply.NewStatusReply("string").ToBytes()) {
+		t.Error(fmt.Sprintf("expected string, actually %s", string(result.ToBytes())))
+	}
+
+	str := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(str, "not a hll"))
+	expected := reply.NewErrReply("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	result = execPFAdd(testDB, utils.ToCmdLine(str, "a"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execPFCount(testDB, utils.ToCmdLine(key, str))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+}
+
+func TestPFMerge(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1, key2, dest := utils.RandString(10), utils.RandString(10), utils.RandString(10)
+	for i := 0; i < 100; i++ {
+		execPFAdd(testDB, utils.ToCmdLine(key1, strconv.Itoa(i)))
+		execPFAdd(testDB, utils.ToCmdLine(key2, strconv.Itoa(i+50)))
+	}
+	union := execPFCount(testDB, utils.ToCmdLine(key1, key2, utils.RandString(10))).(*reply.IntReply).Code
+	if union < 145 || union > 155 {
+		t.Error(fmt.Sprintf("expected about 150, actually %d", union))
+	}
+
+	result := execPFMerge(testDB, utils.ToCmdLine(dest, key1, key2))
+	if _, ok := result.(*reply.OkReply); !ok {
+		t.Error(fmt.Sprintf("expected OK, actually %s", string(result.ToBytes())))
+	}
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(dest)), union)
+
+	// 没有源key时也会创建dest
+	empty := utils.RandString(10)
+	execPFMerge(testDB, utils.ToCmdLine(empty))
+	if _, exists := testDB.GetEntity(empty); !exists {
+		t.Error("pfmerge should create dest")
+	}
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(empty)), 0)
+}
+
+// TestHLLCopyOnWrite PFADD和PFCOUNT更新缓存时不能修改GET返回的切片
+func TestHLLCopyOnWrite(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execPFAdd(testDB, utils.ToCmdLine(key, "a"))
+	got := execGet(testDB, utils.ToCmdLine(key)).(*reply.BulkReply)
+	before := string(got.Arg)
+	execPFAdd(testDB, utils.ToCmdLine(key, "b", "c"))
+	if string(got.Arg) != before {
+		t.Error("PFADD should not modify bytes returned by GET")
+	}
+	got = execGet(testDB, utils.ToCmdLine(key)).(*reply.BulkReply)
+	before = string(got.Arg)
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(key)), 3)
+	if string(got.Arg) != before {
+		t.Error("PFCOUNT should not modify bytes returned by GET")
+	}
+	// 缓存写回到新的值中
+	if result := execGet(testDB, utils.ToCmdLine(key)).(*reply.BulkReply); string(result.Arg) == before {
+		t.Error("expected cached count written back")
+	}
+	assertIntReply(t, execPFCount(testDB, utils.ToCmdLine(key)), 3)
+}
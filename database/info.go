@@ -0,0 +1,63 @@
+package database
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+// execInfo INFO [section]，目前只提供persistence部分
+func execInfo(d *StandaloneDatabase, args [][]byte) resp.Reply {
+	if len(args) > 1 {
+		return reply.NewArgNumErrReply("info")
+	}
+	section := "default"
+	if len(args) == 1 {
+		section = strings.ToLower(string(args[0]))
+	}
+	switch section {
+	case "default", "all", "everything", "persistence":
+		return reply.NewBulkReply([]byte(persistenceInfo(d)))
+	}
+	return reply.NewBulkReply([]byte{})
+}
+
+func persistenceInfo(d *StandaloneDatabase) string {
+	d.saveMu.Lock()
+	saving := d.saving
+	lastSave := d.lastSave
+	lastSaveOK := d.lastSaveOK
+	d.saveMu.Unlock()
+
+	aofEnabled := d.aofHandler != nil
+	var aofWriteErr error
+	if aofEnabled {
+		aofWriteErr = d.aofHandler.LastWriteError()
+	}
+	lines := []string{
+		"# Persistence",
+		"rdb_changes_since_last_save:" + strconv.FormatInt(d.dirty.Load(), 10),
+		"rdb_bgsave_in_progress:" + boolToInfo(saving),
+		"rdb_last_save_time:" + strconv.FormatInt(lastSave.Unix(), 10),
+		"rdb_last_bgsave_status:" + statusToInfo(lastSaveOK),
+		"aof_enabled:" + boolToInfo(aofEnabled),
+		"aof_rewrite_in_progress:" + boolToInfo(d.aofRewriting()),
+		"aof_last_write_status:" + statusToInfo(aofWriteErr == nil),
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func boolToInfo(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func statusToInfo(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "err"
+}
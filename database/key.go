@@ -1,29 +1,49 @@
 package database
 
 import (
+	"go-redis/datastruct/dict"
+	List "go-redis/datastruct/list"
+	HashSet "go-redis/datastruct/set"
+	SortedSet "go-redis/datastruct/sortedset"
+	"go-redis/datastruct/stream"
+	databaseface "go-redis/interface/database"
 	"go-redis/interface/resp"
 	"go-redis/lib/utils"
 	"go-redis/lib/wildcard"
 	"go-redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func init() {
-	RegisterCommand("del", execDel, -2)
-	RegisterCommand("exists", execExist, -2)
-	RegisterCommand("flushdb", execFlushDB, -1)
-	RegisterCommand("type", execType, 2)
-	RegisterCommand("rename", execRename, 3)
-	RegisterCommand("renamenx", execRenameNX, 3)
-	RegisterCommand("keys", execKeys, 2)
+	RegisterCommand("del", execDel, writeAllKeys, -2)
+	RegisterCommand("exists", execExist, readAllKeys, -2)
+	registerLockAllCommand("flushdb", execFlushDB, lockAllWrite, -1)
+	RegisterCommand("type", execType, readFirstKey, 2)
+	RegisterCommand("rename", execRename, writeAllKeys, 3)
+	RegisterCommand("renamenx", execRenameNX, writeAllKeys, 3)
+	registerLockAllCommand("keys", execKeys, lockAllRead, 2)
+	RegisterCommand("expire", execExpire, writeFirstKey, -3)
+	RegisterCommand("pexpire", execPExpire, writeFirstKey, -3)
+	RegisterCommand("expireat", execExpireAt, writeFirstKey, -3)
+	RegisterCommand("pexpireat", execPExpireAt, writeFirstKey, -3)
+	RegisterCommand("ttl", execTTL, readFirstKey, 2)
+	RegisterCommand("pttl", execPTTL, readFirstKey, 2)
+	RegisterCommand("persist", execPersist, writeFirstKey, 2)
 }
 
 // DEL k1 k2 k3
 func execDel(db *DB, args [][]byte) resp.Reply {
-	keys := make([]string, len(args))
-	for i := range args {
-		keys[i] = string(args[i])
+	var deleted int64
+	for _, arg := range args {
+		key := string(arg)
+		if db.RemoveEntity(key) > 0 {
+			deleted++
+			db.notify(notifyGeneric, "del", key)
+		}
 	}
-	deleted := int64(db.Removes(keys...))
 
 	if deleted > 0 {
 		db.addAof(utils.ToCmdLine3("del", args...))
@@ -62,6 +82,16 @@ func execType(db *DB, args[][]byte) resp.Reply {
 		return reply.NewStatusReply("int")
 	case []byte:
 		return reply.NewStatusReply("string")
+	case List.List:
+		return reply.NewStatusReply("list")
+	case dict.Dict:
+		return reply.NewStatusReply("hash")
+	case *HashSet.Set:
+		return reply.NewStatusReply("set")
+	case *SortedSet.SortedSet:
+		return reply.NewStatusReply("zset")
+	case *stream.Stream:
+		return reply.NewStatusReply("stream")
 	}
 	// TODO: 别的数据结构
 	return reply.NewUnknownErrReply()
@@ -74,9 +104,9 @@ func execRename (db *DB, args[][]byte) resp.Reply {
 	if !exist {
 		return reply.NewStatusReply("no such key")
 	}
-	db.PutEntity(newName, entity)
-	db.RemoveEntity(oldName)
+	moveKey(db, oldName, newName, entity)
 	db.addAof(utils.ToCmdLine3("rename", args...))
+	notifyRename(db, oldName, newName)
 	return reply.NewOkReply()
 }
 
@@ -91,9 +121,9 @@ func execRenameNX (db *DB, args[][]byte) resp.Reply {
 	if !exist {
 		return reply.NewStatusReply("no such key")
 	}
-	db.PutEntity(newName, entity)
-	db.RemoveEntity(oldName)
+	moveKey(db, oldName, newName, entity)
 	db.addAof(utils.ToCmdLine3("renamenx", args...))
+	notifyRename(db, oldName, newName)
 	return reply.NewOkReply()
 }
 
@@ -105,7 +135,12 @@ func execKeys(db *DB, args[][]byte) resp.Reply {
 	}
 
 	res := make([][]byte, 0)
+	now := time.Now()
 	db.data.ForEach(func(key string, value interface{}) bool {
+		// 只持有读锁，跳过已过期的key而不删除它们
+		if expireTime, ok := db.TTLOf(key); ok && now.After(expireTime) {
+			return true
+		}
 		if p.IsMatch(key) {
 			res = append(res, []byte(key))
 		}
@@ -114,5 +149,172 @@ func execKeys(db *DB, args[][]byte) resp.Reply {
 	return reply.NewMultiBulkReply(res)
 }
 
+// moveKey 把oldName的数据连同过期时间一起转移到newName
+func moveKey(db *DB, oldName, newName string, entity *databaseface.DataEntity) {
+	expireTime, hasTTL := db.TTLOf(oldName)
+	db.RemoveEntity(oldName)
+	db.RemoveEntity(newName)
+	db.PutEntity(newName, entity)
+	if hasTTL {
+		db.Expire(newName, expireTime)
+	}
+}
+
+func notifyRename(db *DB, oldName, newName string) {
+	db.notify(notifyGeneric, "rename_from", oldName)
+	db.notify(notifyGeneric, "rename_to", newName)
+}
+
+// makeExpireCmd 过期时间统一以绝对时间 PEXPIREAT 写入aof，重启后不会复活已过期的key
+func makeExpireCmd(key string, expireTime time.Time) CmdLine {
+	return utils.ToCmdLine("pexpireat", key, strconv.FormatInt(expireTime.UnixMilli(), 10))
+}
+
+const (
+	expireNX = 1 << iota
+	expireXX
+	expireGT
+	expireLT
+)
+
+var maxExpireMillis = int64(math.MaxInt64 / int64(time.Millisecond))
+
+// parseExpireTime 把 EXPIRE 系列命令的参数转换成绝对过期时间
+func parseExpireTime(cmdName string, raw []byte, unit time.Duration, absolute bool) (time.Time, reply.ErrorReply) {
+	val, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	perMilli := int64(unit / time.Millisecond)
+	if val > maxExpireMillis/perMilli || val < -maxExpireMillis/perMilli {
+		return time.Time{}, reply.NewErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	millis := val * perMilli
+	if absolute {
+		return time.UnixMilli(millis), nil
+	}
+	return time.Now().Add(time.Duration(millis) * time.Millisecond), nil
+}
+
+func parseExpireFlags(args [][]byte) (int, reply.ErrorReply) {
+	flags := 0
+	for _, arg := range args {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			flags |= expireNX
+		case "XX":
+			flags |= expireXX
+		case "GT":
+			flags |= expireGT
+		case "LT":
+			flags |= expireLT
+		default:
+			return 0, reply.NewErrReply("ERR Unsupported option " + string(arg))
+		}
+	}
+	if flags&expireNX > 0 && flags&(expireXX|expireGT|expireLT) > 0 {
+		return 0, reply.NewErrReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if flags&expireGT > 0 && flags&expireLT > 0 {
+		return 0, reply.NewErrReply("ERR GT and LT options at the same time are not compatible")
+	}
+	return flags, nil
+}
+
+// expireKey 是 EXPIRE/PEXPIRE/EXPIREAT/PEXPIREAT 的公共逻辑
+func expireKey(db *DB, cmdName string, args [][]byte, unit time.Duration, absolute bool) resp.Reply {
+	key := string(args[0])
+	expireTime, errReply := parseExpireTime(cmdName, args[1], unit, absolute)
+	if errReply != nil {
+		return errReply
+	}
+	flags, errReply := parseExpireFlags(args[2:])
+	if errReply != nil {
+		return errReply
+	}
+	if _, exists := db.GetEntity(key); !exists {
+		return reply.NewIntReply(0)
+	}
+
+	// 没有过期时间视为无穷大
+	current, hasTTL := db.TTLOf(key)
+	if flags&expireNX > 0 && hasTTL ||
+		flags&expireXX > 0 && !hasTTL ||
+		flags&expireGT > 0 && (!hasTTL || !expireTime.After(current)) ||
+		flags&expireLT > 0 && hasTTL && !expireTime.Before(current) {
+		return reply.NewIntReply(0)
+	}
+
+	if !expireTime.After(time.Now()) {
+		db.RemoveEntity(key)
+		db.addAof(utils.ToCmdLine("del", key))
+		db.notify(notifyGeneric, "del", key)
+		return reply.NewIntReply(1)
+	}
+	db.Expire(key, expireTime)
+	db.addAof(makeExpireCmd(key, expireTime))
+	db.notify(notifyGeneric, "expire", key)
+	return reply.NewIntReply(1)
+}
+
+// EXPIRE key seconds [NX|XX|GT|LT]
+func execExpire(db *DB, args [][]byte) resp.Reply {
+	return expireKey(db, "expire", args, time.Second, false)
+}
 
+// PEXPIRE key milliseconds [NX|XX|GT|LT]
+func execPExpire(db *DB, args [][]byte) resp.Reply {
+	return expireKey(db, "pexpire", args, time.Millisecond, false)
+}
+
+// EXPIREAT key unix-time-seconds [NX|XX|GT|LT]
+func execExpireAt(db *DB, args [][]byte) resp.Reply {
+	return expireKey(db, "expireat", args, time.Second, true)
+}
 
+// PEXPIREAT key unix-time-milliseconds [NX|XX|GT|LT]
+func execPExpireAt(db *DB, args [][]byte) resp.Reply {
+	return expireKey(db, "pexpireat", args, time.Millisecond, true)
+}
+
+// TTL key
+func execTTL(db *DB, args [][]byte) resp.Reply {
+	return ttlOf(db, string(args[0]), time.Second)
+}
+
+// PTTL key
+func execPTTL(db *DB, args [][]byte) resp.Reply {
+	return ttlOf(db, string(args[0]), time.Millisecond)
+}
+
+// ttlOf key不存在返回-2，没有过期时间返回-1
+func ttlOf(db *DB, key string, unit time.Duration) resp.Reply {
+	if _, exists := db.GetEntity(key); !exists {
+		return reply.NewIntReply(-2)
+	}
+	expireTime, hasTTL := db.TTLOf(key)
+	if !hasTTL {
+		return reply.NewIntReply(-1)
+	}
+	ttl := time.Until(expireTime)
+	if ttl < 0 {
+		ttl = 0
+	}
+	// 与redis一样四舍五入
+	return reply.NewIntReply(int64((ttl + unit/2) / unit))
+}
+
+// PERSIST key
+func execPersist(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); !exists {
+		return reply.NewIntReply(0)
+	}
+	if _, hasTTL := db.TTLOf(key); !hasTTL {
+		return reply.NewIntReply(0)
+	}
+	db.Persist(key)
+	db.addAof(utils.ToCmdLine3("persist", args...))
+	db.notify(notifyGeneric, "persist", key)
+	return reply.NewIntReply(1)
+}
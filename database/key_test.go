@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(key, "v"))
+
+	result := execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -1 {
+		t.Error(fmt.Sprintf("expected -1, actually %d", intResult.Code))
+	}
+	result = execExpire(testDB, utils.ToCmdLine(key, "100"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 100 {
+		t.Error(fmt.Sprintf("expected 100, actually %d", intResult.Code))
+	}
+
+	// NX fails on a key with ttl, GT only accepts a later time
+	result = execExpire(testDB, utils.ToCmdLine(key, "200", "NX"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+	result = execExpire(testDB, utils.ToCmdLine(key, "50", "GT"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+	result = execExpire(testDB, utils.ToCmdLine(key, "10", "NX", "XX"))
+	if !reply.IsErrReply(result) {
+		t.Error("expected error for NX and XX")
+	}
+
+	// set clears ttl
+	execSet(testDB, utils.ToCmdLine(key, "v"))
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -1 {
+		t.Error(fmt.Sprintf("expected -1, actually %d", intResult.Code))
+	}
+
+	result = execTTL(testDB, utils.ToCmdLine(utils.RandString(10)))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -2 {
+		t.Error(fmt.Sprintf("expected -2, actually %d", intResult.Code))
+	}
+}
+
+func TestLazyExpire(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(key, "v"))
+	execPExpire(testDB, utils.ToCmdLine(key, "50"))
+	time.Sleep(100 * time.Millisecond)
+
+	result := execGet(testDB, utils.ToCmdLine(key))
+	if _, ok := result.(*reply.NullBulkReply); !ok {
+		t.Error(fmt.Sprintf("expected null, actually %s", string(result.ToBytes())))
+	}
+	result = execKeys(testDB, utils.ToCmdLine("*"))
+	if multiBulk, _ := result.(*reply.MultiBulkReply); len(multiBulk.Args) != 0 {
+		t.Error("expired key should not be listed")
+	}
+
+	// expire in the past deletes the key immediately
+	execSet(testDB, utils.ToCmdLine(key, "v"))
+	past := strconv.FormatInt(time.Now().Add(-time.Second).UnixMilli(), 10)
+	result = execPExpireAt(testDB, utils.ToCmdLine(key, past))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execExist(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+}
+
+func TestPersist(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(key, "v"))
+	execPExpire(testDB, utils.ToCmdLine(key, "50"))
+	result := execPersist(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	time.Sleep(100 * time.Millisecond)
+	result = execGet(testDB, utils.ToCmdLine(key))
+	expected := reply.NewBulkReply([]byte("v"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execPersist(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+}
+
+func TestRenameWithTTL(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(src, "v"))
+	execExpire(testDB, utils.ToCmdLine(src, "100"))
+	execSet(testDB, utils.ToCmdLine(dest, "old"))
+
+	execRename(testDB, utils.ToCmdLine(src, dest))
+	result := execTTL(testDB, utils.ToCmdLine(dest))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 100 {
+		t.Error(fmt.Sprintf("expected 100, actually %d", intResult.Code))
+	}
+	result = execTTL(testDB, utils.ToCmdLine(src))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -2 {
+		t.Error(fmt.Sprintf("expected -2, actually %d", intResult.Code))
+	}
+
+	// del clears ttl
+	execDel(testDB, utils.ToCmdLine(dest))
+	execSet(testDB, utils.ToCmdLine(dest, "v"))
+	result = execTTL(testDB, utils.ToCmdLine(dest))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -1 {
+		t.Error(fmt.Sprintf("expected -1, actually %d", intResult.Code))
+	}
+}
@@ -6,45 +6,63 @@ import (
 	"go-redis/interface/resp"
 	"go-redis/lib/utils"
 	"go-redis/resp/reply"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func init() {
-	RegisterCommand("LPush", LPush, -3)
-	RegisterCommand("LPushX", LPushX, -3)
-	RegisterCommand("RPush", RPush, -3)
-	RegisterCommand("RPushX", RPushX, -3)
-	RegisterCommand("LPop", LPop, -2)
-	RegisterCommand("RPop", RPop, -2)
-	RegisterCommand("RPopLPush", RPopLPush, 3)
-	RegisterCommand("LRem", LRem, 4)
-	RegisterCommand("LLen", LLen, 2)
-	RegisterCommand("LIndex", LIndex, 3)
-	RegisterCommand("LSet", LSet, 4)
-	RegisterCommand("LRange", LRange, 4)
-}
-
-
-func (db *DB) getAsList(key string) (*List.LinkList, reply.ErrorReply) {
+	RegisterCommand("LPush", LPush, writeFirstKey, -3)
+	RegisterCommand("LPushX", LPushX, writeFirstKey, -3)
+	RegisterCommand("RPush", RPush, writeFirstKey, -3)
+	RegisterCommand("RPushX", RPushX, writeFirstKey, -3)
+	RegisterCommand("LPop", LPop, writeFirstKey, -2)
+	RegisterCommand("RPop", RPop, writeFirstKey, -2)
+	RegisterCommand("RPopLPush", RPopLPush, writeAllKeys, 3)
+	RegisterCommand("LRem", LRem, writeFirstKey, 4)
+	RegisterCommand("LLen", LLen, readFirstKey, 2)
+	RegisterCommand("LIndex", LIndex, readFirstKey, 3)
+	RegisterCommand("LSet", LSet, writeFirstKey, 4)
+	RegisterCommand("LRange", LRange, readFirstKey, 4)
+	RegisterCommand("LInsert", LInsert, writeFirstKey, 5)
+	RegisterCommand("LTrim", LTrim, writeFirstKey, 4)
+	RegisterCommand("LPos", LPos, readFirstKey, -3)
+	RegisterCommand("LMove", LMove, prepareBMove, 5)
+	RegisterCommand("LMPop", LMPop, prepareLMPop, -4)
+	RegisterCommand("BLPop", BLPop, prepareBPop, -3)
+	RegisterCommand("BRPop", BRPop, prepareBPop, -3)
+	RegisterCommand("BRPopLPush", BRPopLPush, prepareBMove, 4)
+	RegisterCommand("BLMove", BLMove, prepareBMove, 6)
+	RegisterCommand("BLMPop", BLMPop, prepareBLMPop, -5)
+	RegisterBlockingCommand("BLPop", blockingBLPop)
+	RegisterBlockingCommand("BRPop", blockingBRPop)
+	RegisterBlockingCommand("BRPopLPush", blockingBRPopLPush)
+	RegisterBlockingCommand("BLMove", blockingBLMove)
+	RegisterBlockingCommand("BLMPop", blockingBLMPop)
+}
+
+
+func (db *DB) getAsList(key string) (List.List, reply.ErrorReply) {
 	entity, ok := db.GetEntity(key)
 	if !ok {
 		return nil, nil
 	}
-	bytes, ok := entity.Data.(*List.LinkList)
+	bytes, ok := entity.Data.(List.List)
 	if !ok {
 		return nil, &reply.WrongTypeErrReply{}
 	}
 	return bytes, nil
 }
 
-func (db *DB) getOrInitList(key string) (list *List.LinkList, isNew bool, errReply reply.ErrorReply) {
+func (db *DB) getOrInitList(key string) (list List.List, isNew bool, errReply reply.ErrorReply) {
 	list, errReply = db.getAsList(key)
 	if errReply != nil {
 		return nil, false, errReply
 	}
 	isNew = false
 	if list == nil {
-		list = &List.LinkList{}
+		list = List.NewQuickList()
 		db.PutEntity(key, &databaseface.DataEntity{
 			Data: list,
 		})
@@ -128,9 +146,8 @@ func LPop(db *DB, args [][]byte) resp.Reply {
 	}
 
 	val, _ := list.Remove(0).([]byte)
-	if list.Len() == 0 {
-		db.RemoveEntity(key)
-	}
+	db.notify(notifyList, "lpop", key)
+	removeEmptyList(db, key, list)
 	db.addAof(utils.ToCmdLine3("lpop", args...))
 
 	return reply.NewBulkReply(val)
@@ -158,6 +175,7 @@ func LPush(db *DB, args [][]byte) resp.Reply {
 	}
 
 	db.addAof(utils.ToCmdLine3("lpush", args...))
+	db.notify(notifyList, "lpush", key)
 	return reply.NewIntReply(int64(list.Len()))
 }
 
@@ -185,6 +203,7 @@ func LPushX(db *DB, args [][]byte) resp.Reply {
 		list.Insert(0, value)
 	}
 	db.addAof(utils.ToCmdLine3("lpushx", args...))
+	db.notify(notifyList, "lpush", key)
 	return reply.NewIntReply(int64(list.Len()))
 }
 
@@ -283,12 +302,11 @@ func LRem(db *DB, args [][]byte) resp.Reply {
 		removed = list.ReverseRemoveByVal(value, -count)
 	}
 
-	if list.Len() == 0 {
-		db.RemoveEntity(key)
-	}
 	if removed > 0 {
 		db.addAof(utils.ToCmdLine3("lrem", args...))
+		db.notify(notifyList, "lrem", key)
 	}
+	removeEmptyList(db, key, list)
 
 	return reply.NewIntReply(int64(removed))
 }
@@ -329,6 +347,7 @@ func LSet(db *DB, args [][]byte) resp.Reply {
 
 	list.Set(index, value)
 	db.addAof(utils.ToCmdLine3("lset", args...))
+	db.notify(notifyList, "lset", key)
 	return &reply.OkReply{}
 }
 
@@ -352,9 +371,8 @@ func RPop(db *DB, args [][]byte) resp.Reply {
 	}
 
 	val, _ := list.RemoveLast().([]byte)
-	if list.Len() == 0 {
-		db.RemoveEntity(key)
-	}
+	db.notify(notifyList, "rpop", key)
+	removeEmptyList(db, key, list)
 	db.addAof(utils.ToCmdLine3("rpop", args...))
 	return reply.NewBulkReply(val)
 }
@@ -387,10 +405,9 @@ func RPopLPush(db *DB, args [][]byte) resp.Reply {
 	// pop and push
 	val, _ := sourceList.RemoveLast().([]byte)
 	destList.Insert(0, val)
-
-	if sourceList.Len() == 0 {
-		db.RemoveEntity(sourceKey)
-	}
+	db.notify(notifyList, "rpop", sourceKey)
+	db.notify(notifyList, "lpush", destKey)
+	removeEmptyList(db, sourceKey, sourceList)
 
 	db.addAof(utils.ToCmdLine3("rpoplpush", args...))
 	return reply.NewBulkReply(val)
@@ -418,6 +435,7 @@ func RPush(db *DB, args [][]byte) resp.Reply {
 		list.Add(value)
 	}
 	db.addAof(utils.ToCmdLine3("rpush", args...))
+	db.notify(notifyList, "rpush", key)
 	return reply.NewIntReply(int64(list.Len()))
 }
 
@@ -445,6 +463,566 @@ func RPushX(db *DB, args [][]byte) resp.Reply {
 		list.Add(value)
 	}
 	db.addAof(utils.ToCmdLine3("rpushx", args...))
+	db.notify(notifyList, "rpush", key)
 
 	return reply.NewIntReply(int64(list.Len()))
 }
+
+// LInsert inserts element before or after pivot
+func LInsert(db *DB, args [][]byte) resp.Reply {
+	if len(args) != 4 {
+		return reply.NewErrReply("ERR wrong number of arguments for 'linsert' command")
+	}
+	key := string(args[0])
+	var before bool
+	switch strings.ToUpper(string(args[1])) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return reply.NewSyntaxErrReply()
+	}
+	pivot := args[2]
+	value := args[3]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return reply.NewIntReply(0)
+	}
+
+	index := -1
+	list.ForEach(func(i int, v interface{}) bool {
+		if utils.Equals(v, pivot) {
+			index = i
+			return false
+		}
+		return true
+	})
+	if index < 0 {
+		return reply.NewIntReply(-1)
+	}
+	if !before {
+		index++
+	}
+	list.Insert(index, value)
+	db.addAof(utils.ToCmdLine3("linsert", args...))
+	db.notify(notifyList, "linsert", key)
+	return reply.NewIntReply(int64(list.Len()))
+}
+
+// LTrim keeps only elements in given range
+func LTrim(db *DB, args [][]byte) resp.Reply {
+	if len(args) != 3 {
+		return reply.NewErrReply("ERR wrong number of arguments for 'ltrim' command")
+	}
+	key := string(args[0])
+	start64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	stop64, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return &reply.OkReply{}
+	}
+
+	// compute index, keep [start, stop]
+	size := int64(list.Len())
+	start, stop := start64, stop64
+	if start < 0 {
+		start = size + start
+	}
+	if stop < 0 {
+		stop = size + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || start >= size {
+		db.RemoveEntity(key)
+		db.addAof(utils.ToCmdLine3("ltrim", args...))
+		db.notify(notifyList, "ltrim", key)
+		db.notify(notifyGeneric, "del", key)
+		return &reply.OkReply{}
+	}
+	for i := int64(0); i < start; i++ {
+		list.Remove(0)
+	}
+	for i := stop + 1; i < size; i++ {
+		list.RemoveLast()
+	}
+	db.addAof(utils.ToCmdLine3("ltrim", args...))
+	db.notify(notifyList, "ltrim", key)
+	return &reply.OkReply{}
+}
+
+// LPos returns index of matching elements
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func LPos(db *DB, args [][]byte) resp.Reply {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return reply.NewSyntaxErrReply()
+	}
+	key := string(args[0])
+	value := args[1]
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i < len(args); i += 2 {
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "RANK":
+			if n == 0 || n == math.MinInt64 {
+				return reply.NewErrReply("ERR RANK can't be zero: use 1 to start from the first match, " +
+					"2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return reply.NewErrReply("ERR COUNT can't be negative")
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return reply.NewErrReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	// 没有COUNT时返回单个下标，否则返回数组；COUNT 0 表示返回所有匹配
+	limit := count
+	if count < 0 {
+		limit = 1
+	}
+	matches := make([]int64, 0)
+	if list != nil {
+		skip := rank - 1
+		forEach := list.ForEach
+		if rank < 0 {
+			skip = -rank - 1
+			forEach = list.ReverseForEach
+		}
+		scanned := int64(0)
+		forEach(func(i int, v interface{}) bool {
+			if maxLen > 0 && scanned >= maxLen {
+				return false
+			}
+			scanned++
+			if !utils.Equals(v, value) {
+				return true
+			}
+			if skip > 0 {
+				skip--
+				return true
+			}
+			matches = append(matches, int64(i))
+			return limit == 0 || int64(len(matches)) < limit
+		})
+	}
+	if count < 0 {
+		if len(matches) == 0 {
+			return &reply.NullBulkReply{}
+		}
+		return reply.NewIntReply(matches[0])
+	}
+	result := make([]resp.Reply, len(matches))
+	for i, index := range matches {
+		result[i] = reply.NewIntReply(index)
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// LMove pops element from one side of source and pushes it to one side of destination
+// LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func LMove(db *DB, args [][]byte) resp.Reply {
+	if len(args) != 4 {
+		return reply.NewErrReply("ERR wrong number of arguments for 'lmove' command")
+	}
+	fromLeft, errReply := parseDirection(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	toLeft, errReply := parseDirection(args[3])
+	if errReply != nil {
+		return errReply
+	}
+	result := makeListMove(string(args[0]), string(args[1]), fromLeft, toLeft)(db, popAny)
+	if result == nil {
+		return &reply.NullBulkReply{}
+	}
+	return result
+}
+
+func prepareLMPop(args [][]byte) ([]string, []string) {
+	keys, _, _, errReply := parseMPop(args)
+	if errReply != nil {
+		return nil, nil
+	}
+	return keys, nil
+}
+
+// LMPop pops elements from the first non-empty list
+// LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func LMPop(db *DB, args [][]byte) resp.Reply {
+	keys, left, count, errReply := parseMPop(args)
+	if errReply != nil {
+		return errReply
+	}
+	result := makeMPop(keys, left, count)(db, popAny)
+	if result == nil {
+		return reply.NewNullArrayReply()
+	}
+	return result
+}
+
+/* ---- blocking pop ---- */
+
+// removeEmptyList 列表中最后一个元素被移除后删除key
+func removeEmptyList(db *DB, key string, list List.List) {
+	if list.Len() == 0 {
+		db.RemoveEntity(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// listPop 从列表头部(left)或尾部弹出一个元素，列表为空后删除key
+func listPop(db *DB, key string, list List.List, left bool) []byte {
+	var val []byte
+	if left {
+		val, _ = list.Remove(0).([]byte)
+	} else {
+		val, _ = list.RemoveLast().([]byte)
+	}
+	db.notify(notifyList, popCmdName(left), key)
+	removeEmptyList(db, key, list)
+	return val
+}
+
+func listPush(list List.List, val []byte, left bool) {
+	if left {
+		list.Insert(0, val)
+	} else {
+		list.Add(val)
+	}
+}
+
+// popCmdName 记录aof时使用的非阻塞弹出/插入命令
+func popCmdName(left bool) string {
+	if left {
+		return "lpop"
+	}
+	return "rpop"
+}
+
+func pushCmdName(left bool) string {
+	if left {
+		return "lpush"
+	}
+	return "rpush"
+}
+
+// parseDirection 解析 LEFT|RIGHT
+func parseDirection(raw []byte) (left bool, errReply reply.ErrorReply) {
+	switch strings.ToUpper(string(raw)) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	}
+	return false, reply.NewSyntaxErrReply()
+}
+
+// parseBlockingTimeout 解析以秒为单位的超时时间，0表示一直等待
+func parseBlockingTimeout(raw []byte) (time.Duration, reply.ErrorReply) {
+	timeout, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return 0, reply.NewErrReply("ERR timeout is not a float or out of range")
+	}
+	if timeout < 0 {
+		return 0, reply.NewErrReply("ERR timeout is negative")
+	}
+	return time.Duration(timeout * float64(time.Second)), nil
+}
+
+// listPopFunc 在持有锁的情况下尝试弹出一次，没有数据时返回nil
+// canPop判断当前客户端能否从key中取走数据，保证先阻塞的客户端先得到数据
+type listPopFunc func(db *DB, canPop func(key string) bool) resp.Reply
+
+func popAny(string) bool {
+	return true
+}
+
+// execListPop 阻塞列表命令在事务中按非阻塞方式执行，没有数据时返回nil数组
+func execListPop(db *DB, pop listPopFunc) resp.Reply {
+	if result := pop(db, popAny); result != nil {
+		return result
+	}
+	return reply.NewNullArrayReply()
+}
+
+// blockingListPop 没有数据时等待waitKeys上的写入，直到超时或连接关闭
+func blockingListPop(db *DB, c resp.Connection, writeKeys []string, waitKeys []string, timeout time.Duration, pop listPopFunc) resp.Reply {
+	result := db.execBlocking(c, waitKeys, timeout, func(w *waiter) resp.Reply {
+		db.locker.RWLocks(writeKeys, nil)
+		defer db.locker.RWUnLocks(writeKeys, nil)
+		db.beforeWrite(writeKeys...)
+		result := pop(db, func(key string) bool {
+			return db.waiters.isFirst(key, w)
+		})
+		if result != nil && !reply.IsErrReply(result) {
+			db.addVersion(writeKeys...)
+			db.trackKeys(c, writeKeys, nil)
+		}
+		return result
+	})
+	if result == nil {
+		return reply.NewNullArrayReply()
+	}
+	// BLMOVE等命令会写入目标列表，唤醒等待它的客户端
+	db.waiters.wake(writeKeys...)
+	return result
+}
+
+// makeBPop BLPOP/BRPOP key [key ...] timeout，从第一个非空列表中弹出元素
+func makeBPop(keys []string, left bool) listPopFunc {
+	return func(db *DB, canPop func(key string) bool) resp.Reply {
+		for _, key := range keys {
+			list, errReply := db.getAsList(key)
+			if errReply != nil {
+				return errReply
+			}
+			if list == nil || !canPop(key) {
+				continue
+			}
+			val := listPop(db, key, list, left)
+			db.addAof(utils.ToCmdLine(popCmdName(left), key))
+			return reply.NewMultiBulkReply([][]byte{[]byte(key), val})
+		}
+		return nil
+	}
+}
+
+// prepareBPop 最后一个参数是超时时间
+func prepareBPop(args [][]byte) ([]string, []string) {
+	return writeAllKeys(args[:len(args)-1])
+}
+
+func bPop(db *DB, c resp.Connection, block bool, args [][]byte, left bool) resp.Reply {
+	timeout, errReply := parseBlockingTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	keys, _ := prepareBPop(args)
+	pop := makeBPop(keys, left)
+	if !block {
+		return execListPop(db, pop)
+	}
+	return blockingListPop(db, c, keys, keys, timeout, pop)
+}
+
+// BLPop BLPOP key [key ...] timeout
+func BLPop(db *DB, args [][]byte) resp.Reply {
+	return bPop(db, nil, false, args, true)
+}
+
+// BRPop BRPOP key [key ...] timeout
+func BRPop(db *DB, args [][]byte) resp.Reply {
+	return bPop(db, nil, false, args, false)
+}
+
+func blockingBLPop(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	return bPop(db, c, true, cmdLine[1:], true)
+}
+
+func blockingBRPop(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	return bPop(db, c, true, cmdLine[1:], false)
+}
+
+// makeListMove 从source的一端弹出元素，推入destination的一端
+func makeListMove(source, destination string, fromLeft, toLeft bool) listPopFunc {
+	return func(db *DB, canPop func(key string) bool) resp.Reply {
+		sourceList, errReply := db.getAsList(source)
+		if errReply != nil {
+			return errReply
+		}
+		if sourceList == nil || !canPop(source) {
+			return nil
+		}
+		// 先检查目标的类型，避免弹出后无法推入
+		if _, errReply := db.getAsList(destination); errReply != nil {
+			return errReply
+		}
+		val := listPop(db, source, sourceList, fromLeft)
+		destList, _, _ := db.getOrInitList(destination)
+		listPush(destList, val, toLeft)
+		db.notify(notifyList, pushCmdName(toLeft), destination)
+		db.addAof(
+			utils.ToCmdLine(popCmdName(fromLeft), source),
+			utils.ToCmdLine3(pushCmdName(toLeft), []byte(destination), val),
+		)
+		return reply.NewBulkReply(val)
+	}
+}
+
+func bMove(db *DB, c resp.Connection, block bool, source, destination string, fromLeft, toLeft bool, rawTimeout []byte) resp.Reply {
+	timeout, errReply := parseBlockingTimeout(rawTimeout)
+	if errReply != nil {
+		return errReply
+	}
+	move := makeListMove(source, destination, fromLeft, toLeft)
+	if !block {
+		return execListPop(db, move)
+	}
+	return blockingListPop(db, c, []string{source, destination}, []string{source}, timeout, move)
+}
+
+func prepareBMove(args [][]byte) ([]string, []string) {
+	return []string{string(args[0]), string(args[1])}, nil
+}
+
+// BRPopLPush BRPOPLPUSH source destination timeout
+func BRPopLPush(db *DB, args [][]byte) resp.Reply {
+	return bMove(db, nil, false, string(args[0]), string(args[1]), false, true, args[2])
+}
+
+func blockingBRPopLPush(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	args := cmdLine[1:]
+	return bMove(db, c, true, string(args[0]), string(args[1]), false, true, args[2])
+}
+
+func bLMove(db *DB, c resp.Connection, block bool, args [][]byte) resp.Reply {
+	fromLeft, errReply := parseDirection(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	toLeft, errReply := parseDirection(args[3])
+	if errReply != nil {
+		return errReply
+	}
+	return bMove(db, c, block, string(args[0]), string(args[1]), fromLeft, toLeft, args[4])
+}
+
+// BLMove BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func BLMove(db *DB, args [][]byte) resp.Reply {
+	return bLMove(db, nil, false, args)
+}
+
+func blockingBLMove(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	return bLMove(db, c, true, cmdLine[1:])
+}
+
+// parseMPop 解析 numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func parseMPop(args [][]byte) (keys []string, left bool, count int, errReply reply.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, false, 0, reply.NewErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys <= 0 {
+		return nil, false, 0, reply.NewErrReply("ERR numkeys should be greater than 0")
+	}
+	// numKeys很大时numKeys+2会溢出，先用参数个数计算可用的key数量
+	if numKeys > int64(len(args)-2) {
+		return nil, false, 0, reply.NewSyntaxErrReply()
+	}
+	for _, arg := range args[1 : numKeys+1] {
+		keys = append(keys, string(arg))
+	}
+	left, errReply = parseDirection(args[numKeys+1])
+	if errReply != nil {
+		return nil, false, 0, errReply
+	}
+	count = 1
+	rest := args[numKeys+2:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "COUNT" {
+			return nil, false, 0, reply.NewSyntaxErrReply()
+		}
+		n, err := strconv.ParseInt(string(rest[1]), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, false, 0, reply.NewErrReply("ERR count should be greater than 0")
+		}
+		count = int(n)
+	}
+	return keys, left, count, nil
+}
+
+// makeMPop 从第一个非空列表中弹出最多count个元素，返回 [key, [element ...]]
+func makeMPop(keys []string, left bool, count int) listPopFunc {
+	return func(db *DB, canPop func(key string) bool) resp.Reply {
+		for _, key := range keys {
+			list, errReply := db.getAsList(key)
+			if errReply != nil {
+				return errReply
+			}
+			if list == nil || !canPop(key) {
+				continue
+			}
+			n := count
+			if n > list.Len() {
+				n = list.Len()
+			}
+			vals := make([][]byte, 0, n)
+			aofLines := make([]CmdLine, 0, n)
+			for i := 0; i < n; i++ {
+				vals = append(vals, listPop(db, key, list, left))
+				aofLines = append(aofLines, utils.ToCmdLine(popCmdName(left), key))
+			}
+			db.addAof(aofLines...)
+			return reply.NewMultiRawReply([]resp.Reply{
+				reply.NewBulkReply([]byte(key)),
+				reply.NewMultiBulkReply(vals),
+			})
+		}
+		return nil
+	}
+}
+
+func prepareBLMPop(args [][]byte) ([]string, []string) {
+	return prepareLMPop(args[1:])
+}
+
+func bLMPop(db *DB, c resp.Connection, block bool, args [][]byte) resp.Reply {
+	timeout, errReply := parseBlockingTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	keys, left, count, errReply := parseMPop(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	pop := makeMPop(keys, left, count)
+	if !block {
+		return execListPop(db, pop)
+	}
+	return blockingListPop(db, c, keys, keys, timeout, pop)
+}
+
+// BLMPop BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func BLMPop(db *DB, args [][]byte) resp.Reply {
+	return bLMPop(db, nil, false, args)
+}
+
+func blockingBLMPop(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	return bLMPop(db, c, true, cmdLine[1:])
+}
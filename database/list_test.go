@@ -2,10 +2,14 @@ package database
 
 import (
 	"fmt"
+	"go-redis/interface/resp"
 	"go-redis/lib/utils"
+	"go-redis/resp/connection"
 	"go-redis/resp/reply"
+	"net"
 	"strconv"
 	"testing"
+	"time"
 )
 
 var testDB = makeTestDB()
@@ -384,3 +388,198 @@ func TestLPushX(t *testing.T) {
 	}
 
 }
+
+func TestBLPop(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1, key2 := utils.RandString(10), utils.RandString(10)
+	RPush(testDB, utils.ToCmdLine(key2, "a", "b"))
+	result := testDB.Exec(nil, utils.ToCmdLine("blpop", key1, key2, "0"))
+	assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine(key2, "a")))
+	result = testDB.Exec(nil, utils.ToCmdLine("brpop", key1, key2, "0"))
+	assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine(key2, "b")))
+	result = testDB.Exec(nil, utils.ToCmdLine("blpop", key1, "0.01"))
+	assertReply(t, result, reply.NewNullArrayReply())
+	result = testDB.Exec(nil, utils.ToCmdLine("blpop", key1, "-1"))
+	assertReply(t, result, reply.NewErrReply("ERR timeout is negative"))
+
+	// 阻塞直到其他客户端写入
+	done := make(chan resp.Reply)
+	go func() {
+		done <- testDB.Exec(nil, utils.ToCmdLine("blpop", key1, key2, "0"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key1, "c"))
+	select {
+	case result = <-done:
+		assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine(key1, "c")))
+	case <-time.After(time.Second):
+		t.Error("blocking blpop was not woken up")
+	}
+	assertIntReply(t, execExist(testDB, utils.ToCmdLine(key1)), 0)
+}
+
+func TestBLPopFairness(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	first, second := make(chan resp.Reply), make(chan resp.Reply)
+	go func() {
+		first <- testDB.Exec(nil, utils.ToCmdLine("blpop", key, "0"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		second <- testDB.Exec(nil, utils.ToCmdLine("blpop", key, "0"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// 先阻塞的客户端先得到数据
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "a", "b"))
+	for _, ch := range []chan resp.Reply{first, second} {
+		select {
+		case result := <-ch:
+			expected := "a"
+			if ch == second {
+				expected = "b"
+			}
+			assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine(key, expected)))
+		case <-time.After(time.Second):
+			t.Error("blocking blpop was not woken up")
+		}
+	}
+}
+
+func TestBLPopConnClosed(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := connection.NewConnection(server)
+	done := make(chan resp.Reply)
+	go func() {
+		done <- testDB.Exec(conn, utils.ToCmdLine("blpop", key, "0"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_ = conn.Close()
+	select {
+	case result := <-done:
+		assertReply(t, result, reply.NewNullArrayReply())
+	case <-time.After(time.Second):
+		t.Error("blocking blpop was not cancelled")
+	}
+	// 已经放弃等待的客户端不会取走数据
+	RPush(testDB, utils.ToCmdLine(key, "a"))
+	assertIntReply(t, LLen(testDB, utils.ToCmdLine(key)), 1)
+}
+
+func TestBLMoveAndBLMPop(t *testing.T) {
+	db := makeTestDB()
+	aofLines := make([]CmdLine, 0)
+	db.addAof = func(lines ...CmdLine) {
+		aofLines = append(aofLines, lines...)
+	}
+	src, dest := utils.RandString(10), utils.RandString(10)
+	db.Exec(nil, utils.ToCmdLine("rpush", src, "a", "b", "c", "d"))
+	result := db.Exec(nil, utils.ToCmdLine("blmove", src, dest, "LEFT", "RIGHT", "0"))
+	assertReply(t, result, reply.NewBulkReply([]byte("a")))
+	result = db.Exec(nil, utils.ToCmdLine("brpoplpush", src, dest, "0"))
+	assertReply(t, result, reply.NewBulkReply([]byte("d")))
+	result = db.Exec(nil, utils.ToCmdLine("blmpop", "0", "2", utils.RandString(10), src, "LEFT", "COUNT", "5"))
+	assertReply(t, result, reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(src)),
+		reply.NewMultiBulkReply(utils.ToCmdLine("b", "c")),
+	}))
+	result = db.Exec(nil, utils.ToCmdLine("blmpop", "0", "0", src, "LEFT"))
+	assertReply(t, result, reply.NewErrReply("ERR numkeys should be greater than 0"))
+	result = db.Exec(nil, utils.ToCmdLine("blmpop", "0", "9223372036854775807", src, "LEFT"))
+	assertReply(t, result, reply.NewSyntaxErrReply())
+	result = db.Exec(nil, utils.ToCmdLine("blmove", src, dest, "UP", "RIGHT", "0"))
+	assertReply(t, result, reply.NewSyntaxErrReply())
+
+	// aof中记录的是实际执行的非阻塞命令
+	replayed := makeTestDB()
+	for _, line := range aofLines {
+		replayed.Exec(nil, line)
+	}
+	for _, key := range []string{src, dest} {
+		cmdLine := utils.ToCmdLine("lrange", key, "0", "-1")
+		assertReply(t, replayed.Exec(nil, cmdLine), db.Exec(nil, cmdLine))
+	}
+	assertReply(t, db.Exec(nil, utils.ToCmdLine("lrange", dest, "0", "-1")),
+		reply.NewMultiBulkReply(utils.ToCmdLine("d", "a")))
+}
+
+func TestLInsert(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	assertIntReply(t, LInsert(testDB, utils.ToCmdLine(key, "BEFORE", "a", "b")), 0)
+	RPush(testDB, utils.ToCmdLine(key, "a", "c"))
+	assertIntReply(t, LInsert(testDB, utils.ToCmdLine(key, "AFTER", "a", "b")), 3)
+	assertIntReply(t, LInsert(testDB, utils.ToCmdLine(key, "BEFORE", "a", "z")), 4)
+	assertIntReply(t, LInsert(testDB, utils.ToCmdLine(key, "AFTER", "c", "d")), 5)
+	assertIntReply(t, LInsert(testDB, utils.ToCmdLine(key, "AFTER", "x", "y")), -1)
+	assertReply(t, LInsert(testDB, utils.ToCmdLine(key, "UP", "a", "y")), reply.NewSyntaxErrReply())
+	assertReply(t, LRange(testDB, utils.ToCmdLine(key, "0", "-1")),
+		reply.NewMultiBulkReply(utils.ToCmdLine("z", "a", "b", "c", "d")))
+	assertReply(t, testDB.Exec(nil, utils.ToCmdLine("type", key)), reply.NewStatusReply("list"))
+}
+
+func TestLTrim(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	RPush(testDB, utils.ToCmdLine(key, "a", "b", "c", "d", "e"))
+	assertReply(t, LTrim(testDB, utils.ToCmdLine(key, "1", "-2")), &reply.OkReply{})
+	assertReply(t, LRange(testDB, utils.ToCmdLine(key, "0", "-1")),
+		reply.NewMultiBulkReply(utils.ToCmdLine("b", "c", "d")))
+	assertReply(t, LTrim(testDB, utils.ToCmdLine(key, "-100", "100")), &reply.OkReply{})
+	assertIntReply(t, LLen(testDB, utils.ToCmdLine(key)), 3)
+	// start大于stop时删除整个列表
+	assertReply(t, LTrim(testDB, utils.ToCmdLine(key, "2", "1")), &reply.OkReply{})
+	assertIntReply(t, execExist(testDB, utils.ToCmdLine(key)), 0)
+}
+
+func TestLPos(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	RPush(testDB, utils.ToCmdLine(key, "a", "b", "c", "1", "2", "3", "c", "c"))
+	assertIntReply(t, LPos(testDB, utils.ToCmdLine(key, "c")), 2)
+	assertIntReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "RANK", "2")), 6)
+	assertIntReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "RANK", "-1")), 7)
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "COUNT", "2")),
+		reply.NewMultiRawReply([]resp.Reply{reply.NewIntReply(2), reply.NewIntReply(6)}))
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "RANK", "-1", "COUNT", "0")),
+		reply.NewMultiRawReply([]resp.Reply{reply.NewIntReply(7), reply.NewIntReply(6), reply.NewIntReply(2)}))
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "COUNT", "0", "MAXLEN", "7")),
+		reply.NewMultiRawReply([]resp.Reply{reply.NewIntReply(2), reply.NewIntReply(6)}))
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "x")), &reply.NullBulkReply{})
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "x", "COUNT", "1")), reply.NewMultiRawReply([]resp.Reply{}))
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "RANK", "0")),
+		reply.NewErrReply("ERR RANK can't be zero: use 1 to start from the first match, "+
+			"2 from the second ... or use negative to start from the end of the list"))
+	assertReply(t, LPos(testDB, utils.ToCmdLine(key, "c", "COUNT", "-1")), reply.NewErrReply("ERR COUNT can't be negative"))
+}
+
+func TestLMoveAndLMPop(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	src, dest := utils.RandString(10), utils.RandString(10)
+	RPush(testDB, utils.ToCmdLine(src, "a", "b", "c"))
+	assertReply(t, LMove(testDB, utils.ToCmdLine(src, dest, "RIGHT", "LEFT")), reply.NewBulkReply([]byte("c")))
+	assertReply(t, LMove(testDB, utils.ToCmdLine(src, dest, "LEFT", "LEFT")), reply.NewBulkReply([]byte("a")))
+	// source和destination相同时旋转列表
+	assertReply(t, LMove(testDB, utils.ToCmdLine(dest, dest, "LEFT", "RIGHT")), reply.NewBulkReply([]byte("a")))
+	assertReply(t, LRange(testDB, utils.ToCmdLine(dest, "0", "-1")), reply.NewMultiBulkReply(utils.ToCmdLine("c", "a")))
+	assertReply(t, LMove(testDB, utils.ToCmdLine(utils.RandString(10), dest, "LEFT", "LEFT")), &reply.NullBulkReply{})
+
+	assertReply(t, LMPop(testDB, utils.ToCmdLine("2", src, dest, "RIGHT")), reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(src)),
+		reply.NewMultiBulkReply(utils.ToCmdLine("b")),
+	}))
+	assertReply(t, LMPop(testDB, utils.ToCmdLine("2", src, dest, "LEFT", "COUNT", "10")), reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(dest)),
+		reply.NewMultiBulkReply(utils.ToCmdLine("c", "a")),
+	}))
+	assertReply(t, LMPop(testDB, utils.ToCmdLine("2", src, dest, "LEFT")), reply.NewNullArrayReply())
+	assertReply(t, LMPop(testDB, utils.ToCmdLine("1", src, "LEFT", "COUNT", "0")),
+		reply.NewErrReply("ERR count should be greater than 0"))
+	// numkeys过大时不能溢出
+	for _, numKeys := range []string{"9223372036854775807", "9223372036854775806"} {
+		assertReply(t, testDB.Exec(nil, utils.ToCmdLine("lmpop", numKeys, src, "LEFT")), reply.NewSyntaxErrReply())
+	}
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	prime32          = uint32(16777619)
+	defaultLockCount = 1024
+)
+
+// lockTable 分段锁：key经过哈希映射到固定数量的读写锁上
+// 多个key总是按锁下标从小到大的顺序加锁，避免不同命令之间死锁
+type lockTable struct {
+	table []*sync.RWMutex
+}
+
+func newLockTable(size int) *lockTable {
+	table := make([]*sync.RWMutex, size)
+	for i := range table {
+		table[i] = &sync.RWMutex{}
+	}
+	return &lockTable{
+		table: table,
+	}
+}
+
+// fnv32 哈希
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash *= prime32
+		hash ^= uint32(key[i])
+	}
+	return hash
+}
+
+func (t *lockTable) spread(key string) int {
+	return int(fnv32(key) % uint32(len(t.table)))
+}
+
+// toLockIndices 计算key对应的锁下标，去重后升序排列
+// 同一个锁下标既被读又被写时只加写锁
+func (t *lockTable) toLockIndices(writeKeys []string, readKeys []string) (indices []int, writeIndices map[int]bool) {
+	writeIndices = make(map[int]bool)
+	for _, key := range writeKeys {
+		writeIndices[t.spread(key)] = true
+	}
+	indexSet := make(map[int]bool)
+	for index := range writeIndices {
+		indexSet[index] = true
+	}
+	for _, key := range readKeys {
+		indexSet[t.spread(key)] = true
+	}
+	indices = make([]int, 0, len(indexSet))
+	for index := range indexSet {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices, writeIndices
+}
+
+// RWLocks 对写入的key加写锁，只读的key加读锁
+func (t *lockTable) RWLocks(writeKeys []string, readKeys []string) {
+	indices, writeIndices := t.toLockIndices(writeKeys, readKeys)
+	for _, index := range indices {
+		if writeIndices[index] {
+			t.table[index].Lock()
+		} else {
+			t.table[index].RLock()
+		}
+	}
+}
+
+// RWUnLocks 按加锁的相反顺序释放
+func (t *lockTable) RWUnLocks(writeKeys []string, readKeys []string) {
+	indices, writeIndices := t.toLockIndices(writeKeys, readKeys)
+	for i := len(indices) - 1; i >= 0; i-- {
+		index := indices[i]
+		if writeIndices[index] {
+			t.table[index].Unlock()
+		} else {
+			t.table[index].RUnlock()
+		}
+	}
+}
+
+// LockAll 按顺序对所有锁加写锁，用于FLUSHDB等修改整个DB的命令
+func (t *lockTable) LockAll() {
+	for _, lock := range t.table {
+		lock.Lock()
+	}
+}
+
+func (t *lockTable) UnLockAll() {
+	for i := len(t.table) - 1; i >= 0; i-- {
+		t.table[i].Unlock()
+	}
+}
+
+// RLockAll 按顺序对所有锁加读锁，阻止所有写命令执行，用于在两条命令之间开始快照
+func (t *lockTable) RLockAll() {
+	for _, lock := range t.table {
+		lock.RLock()
+	}
+}
+
+func (t *lockTable) RUnLockAll() {
+	for i := len(t.table) - 1; i >= 0; i-- {
+		t.table[i].RUnlock()
+	}
+}
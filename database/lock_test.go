@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+	databaseface "go-redis/interface/database"
+	"go-redis/lib/utils"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockIndices(t *testing.T) {
+	locks := newLockTable(16)
+	indices, writeIndices := locks.toLockIndices([]string{"a", "b", "a"}, []string{"b", "c"})
+	for i := 1; i < len(indices); i++ {
+		if indices[i-1] >= indices[i] {
+			t.Error(fmt.Sprintf("indices should be sorted and distinct, actually %v", indices))
+		}
+	}
+	if !writeIndices[locks.spread("a")] || !writeIndices[locks.spread("b")] {
+		t.Error("written keys should hold write locks")
+	}
+	// 同时被读写的key只加一次写锁，解锁后可以再次加锁
+	locks.RWLocks([]string{"a", "b"}, []string{"b", "c"})
+	locks.RWUnLocks([]string{"a", "b"}, []string{"b", "c"})
+	locks.RWLocks([]string{"a", "b", "c"}, nil)
+	locks.RWUnLocks([]string{"a", "b", "c"}, nil)
+}
+
+func TestConcurrentExec(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	size := 100
+	for i := 0; i < size; i++ {
+		testDB.Exec(nil, utils.ToCmdLine("rpush", src, strconv.Itoa(i)))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn := &connection.Connection{}
+			testDB.Exec(conn, utils.ToCmdLine("hincrby", key, "field", "1"))
+			testDB.Exec(conn, utils.ToCmdLine("rpoplpush", src, dest))
+		}()
+	}
+	wg.Wait()
+
+	result := testDB.Exec(nil, utils.ToCmdLine("hget", key, "field"))
+	expected := reply.NewBulkReply([]byte(strconv.Itoa(size)))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("llen", dest))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(size) {
+		t.Error(fmt.Sprintf("expected %d, actually %s", size, string(result.ToBytes())))
+	}
+}
+
+// TestFlushDBLocksAll FLUSHDB需要等待正在执行的写命令释放锁
+func TestFlushDBLocksAll(t *testing.T) {
+	db := makeTestDB()
+	key := utils.RandString(10)
+	db.Exec(nil, utils.ToCmdLine("set", key, "v"))
+	db.locker.RWLocks([]string{key}, nil)
+	done := make(chan struct{})
+	go func() {
+		db.Exec(nil, utils.ToCmdLine("flushdb"))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("flushdb should wait for locked key")
+	case <-time.After(50 * time.Millisecond):
+	}
+	db.locker.RWUnLocks([]string{key}, nil)
+	<-done
+	assertIntReply(t, db.Exec(nil, utils.ToCmdLine("exists", key)), 0)
+
+	// 事务中的FLUSHDB同样锁住整个DB
+	db.Exec(nil, utils.ToCmdLine("set", key, "v"))
+	db.ExecMulti(nil, nil, []CmdLine{utils.ToCmdLine("flushdb"), utils.ToCmdLine("set", "k2", "v")})
+	assertIntReply(t, db.Exec(nil, utils.ToCmdLine("exists", key, "k2")), 1)
+}
+
+// TestKeysSkipExpired KEYS只持有读锁，不删除过期的key
+func TestKeysSkipExpired(t *testing.T) {
+	db := makeTestDB()
+	db.Exec(nil, utils.ToCmdLine("set", "a", "v"))
+	db.PutEntity("b", &databaseface.DataEntity{Data: []byte("v")})
+	db.ttlMap.Put("b", time.Now().Add(-time.Second))
+	assertReply(t, db.Exec(nil, utils.ToCmdLine("keys", "*")), reply.NewMultiBulkReply(utils.ToCmdLine("a")))
+	if _, ok := db.data.Get("b"); !ok {
+		t.Error("keys should not delete expired key")
+	}
+}
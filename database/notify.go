@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+)
+
+/*
+	键空间通知：key被修改时向 __keyspace@<db>__:<key> 发布事件名，
+	向 __keyevent@<db>__:<event> 发布key；配置项 notify-keyspace-events 决定发布哪些类别，
+	与redis相同，K/E 决定发布到哪种频道，其余字母决定哪些类别的事件需要发布
+ */
+
+const (
+	notifyKeyspace = 1 << iota // K
+	notifyKeyevent             // E
+	notifyGeneric              // g
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x
+	notifyEvicted              // e
+	notifyStream               // t
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash |
+		notifyZSet | notifyExpired | notifyEvicted | notifyStream // A
+)
+
+// parseNotifyFlags 解析 notify-keyspace-events 的取值，空字符串表示关闭通知
+func parseNotifyFlags(classes string) (int, error) {
+	flags := 0
+	for _, c := range classes {
+		switch c {
+		case 'A':
+			flags |= notifyAll
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'l':
+			flags |= notifyList
+		case 's':
+			flags |= notifySet
+		case 'h':
+			flags |= notifyHash
+		case 'z':
+			flags |= notifyZSet
+		case 'x':
+			flags |= notifyExpired
+		case 'e':
+			flags |= notifyEvicted
+		case 't':
+			flags |= notifyStream
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		default:
+			return 0, errors.New("invalid notify-keyspace-events class: " + string(c))
+		}
+	}
+	// 只指定类别而没有指定K或E时不发布任何通知
+	if flags&(notifyKeyspace|notifyKeyevent) == 0 {
+		return 0, nil
+	}
+	return flags, nil
+}
+
+// notify 发布key上发生的事件，class是事件所属的类别
+func (db *DB) notify(class int, event string, key string) {
+	if db.notifyFlags&class == 0 || db.publish == nil {
+		return
+	}
+	index := strconv.Itoa(db.index)
+	if db.notifyFlags&notifyKeyspace != 0 {
+		db.publish("__keyspace@"+index+"__:"+key, []byte(event))
+	}
+	if db.notifyFlags&notifyKeyevent != 0 {
+		db.publish("__keyevent@"+index+"__:"+event, []byte(key))
+	}
+}
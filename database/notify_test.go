@@ -0,0 +1,98 @@
+package database
+
+import (
+	"go-redis/lib/utils"
+	"testing"
+	"time"
+)
+
+func TestParseNotifyFlags(t *testing.T) {
+	flags, err := parseNotifyFlags("")
+	if err != nil || flags != 0 {
+		t.Errorf("expected notification disabled, actually %d", flags)
+	}
+	// 没有K和E时不发布
+	flags, err = parseNotifyFlags("g$")
+	if err != nil || flags != 0 {
+		t.Errorf("expected notification disabled, actually %d", flags)
+	}
+	flags, err = parseNotifyFlags("KEA")
+	if err != nil || flags != notifyKeyspace|notifyKeyevent|notifyAll {
+		t.Errorf("unexpected flags %d", flags)
+	}
+	if _, err = parseNotifyFlags("Kq"); err == nil {
+		t.Error("expected error for unknown class")
+	}
+}
+
+type notification struct {
+	channel string
+	message string
+}
+
+func makeNotifyTestDB(classes string) (*DB, *[]notification) {
+	db := makeTestDB()
+	db.notifyFlags, _ = parseNotifyFlags(classes)
+	var published []notification
+	db.publish = func(channel string, message []byte) {
+		published = append(published, notification{channel, string(message)})
+	}
+	return db, &published
+}
+
+func assertNotifications(t *testing.T, actual *[]notification, expected ...notification) {
+	t.Helper()
+	if len(*actual) != len(expected) {
+		t.Fatalf("expected %v, actually %v", expected, *actual)
+	}
+	for i := range expected {
+		if (*actual)[i] != expected[i] {
+			t.Errorf("expected %v, actually %v", expected, *actual)
+			break
+		}
+	}
+	*actual = nil
+}
+
+func TestNotify(t *testing.T) {
+	db, published := makeNotifyTestDB("KEA")
+	db.Exec(nil, utils.ToCmdLine("set", "k", "v"))
+	assertNotifications(t, published,
+		notification{"__keyspace@0__:k", "set"},
+		notification{"__keyevent@0__:set", "k"},
+	)
+
+	db.Exec(nil, utils.ToCmdLine("del", "k", "missing"))
+	assertNotifications(t, published,
+		notification{"__keyspace@0__:k", "del"},
+		notification{"__keyevent@0__:del", "k"},
+	)
+
+	db.Exec(nil, utils.ToCmdLine("rpush", "list", "a"))
+	assertNotifications(t, published,
+		notification{"__keyspace@0__:list", "rpush"},
+		notification{"__keyevent@0__:rpush", "list"},
+	)
+	// 列表被弹空后同时发布del
+	db.Exec(nil, utils.ToCmdLine("lpop", "list"))
+	assertNotifications(t, published,
+		notification{"__keyspace@0__:list", "lpop"},
+		notification{"__keyevent@0__:lpop", "list"},
+		notification{"__keyspace@0__:list", "del"},
+		notification{"__keyevent@0__:del", "list"},
+	)
+}
+
+func TestNotifyClasses(t *testing.T) {
+	// 只发布keyevent频道上的通用命令和过期事件
+	db, published := makeNotifyTestDB("Egx")
+	db.Exec(nil, utils.ToCmdLine("set", "k", "v"))
+	assertNotifications(t, published)
+
+	db.Exec(nil, utils.ToCmdLine("pexpire", "k", "1"))
+	assertNotifications(t, published, notification{"__keyevent@0__:expire", "k"})
+
+	time.Sleep(5 * time.Millisecond)
+	db.Exec(nil, utils.ToCmdLine("get", "k"))
+	assertNotifications(t, published, notification{"__keyevent@0__:expired", "k"})
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func init() {
-	RegisterCommand("ping", Ping, 1)
+	RegisterCommand("ping", Ping, noPrepare, 1)
 }
 
 func Ping (db *DB, args [][]byte) resp.Reply {
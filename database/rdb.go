@@ -0,0 +1,474 @@
+package database
+
+import (
+	"errors"
+	"go-redis/config"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/logger"
+	"go-redis/rdb"
+	"go-redis/resp/reply"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	RDB快照不复制整个数据库，而是在快照开始时给每个DB装上一个rdbSnapshot：
+	写命令修改key之前先检查它是否已经写入快照，没有的话把它当前的值编码后保存下来(写时复制)，
+	保存线程逐个key加读锁写入文件，跳过已经处理过的key，最后写入保存下来的旧值。
+	这样文件中的内容是快照开始时的数据，而客户端只在访问正在写入的那一个key时才需要等待
+*/
+
+const (
+	defaultRDBFilename = "dump.rdb"
+	// saveRetryDelay 自动保存失败后，至少间隔这么久才再次尝试
+	saveRetryDelay = 5 * time.Second
+)
+
+var errSaveInProgress = errors.New("ERR Background save already in progress")
+
+type rdbSnapshot struct {
+	mu sync.Mutex
+	// done 已经写入文件或已经保存旧值的key
+	done map[string]struct{}
+	// preserved 写命令修改前编码好的旧值
+	preserved [][]byte
+	err       error
+}
+
+func newRDBSnapshot() *rdbSnapshot {
+	return &rdbSnapshot{
+		done: make(map[string]struct{}),
+	}
+}
+
+// markDone 返回key是否是第一次被标记，只有第一次需要保存它的值
+func (s *rdbSnapshot) markDone(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.done[key]; ok {
+		return false
+	}
+	s.done[key] = struct{}{}
+	return true
+}
+
+func (s *rdbSnapshot) preserve(data []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.preserved = append(s.preserved, data)
+}
+
+func (s *rdbSnapshot) takePreserved() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.preserved, s.err
+}
+
+// beforeWrite 写入key之前调用，快照期间保存key在快照时刻的值
+// 调用方需要持有key的写锁
+func (db *DB) beforeWrite(keys ...string) {
+	snap := db.snapshot.Load()
+	if snap == nil {
+		return
+	}
+	for _, key := range keys {
+		if !snap.markDone(key) {
+			continue
+		}
+		value, expireAt, ok := db.snapshotValue(key)
+		if !ok {
+			continue
+		}
+		snap.preserve(rdb.EncodeObject(key, value, expireAt))
+	}
+}
+
+// snapshotValue 读取key的值和过期时间，已过期的key视为不存在，但不会删除它
+func (db *DB) snapshotValue(key string) (value interface{}, expireAt time.Time, ok bool) {
+	value, ok = db.data.Get(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	expireAt, hasTTL := db.TTLOf(key)
+	if hasTTL && time.Now().After(expireAt) {
+		return nil, time.Time{}, false
+	}
+	return value, expireAt, true
+}
+
+// dumpSnapshot 把快照时刻db中的key依次交给emit，结束后卸下快照
+func (db *DB) dumpSnapshot(snap *rdbSnapshot, emit snapshotConsumer) error {
+	for _, key := range db.data.Keys() {
+		if err := db.dumpKey(snap, key, emit); err != nil {
+			return err
+		}
+	}
+	// 快照开始后才创建的key在写入时已被标记，之后不会再保存旧值
+	db.snapshot.Store(nil)
+	preserved, err := snap.takePreserved()
+	if err != nil {
+		return err
+	}
+	for _, data := range preserved {
+		obj, err := rdb.DecodeObject(data)
+		if err != nil {
+			return err
+		}
+		if err := emit(obj.Key, obj.Value, obj.ExpireAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) dumpKey(snap *rdbSnapshot, key string, emit snapshotConsumer) error {
+	keys := []string{key}
+	db.locker.RWLocks(nil, keys)
+	defer db.locker.RWUnLocks(nil, keys)
+	if !snap.markDone(key) {
+		return nil
+	}
+	value, expireAt, ok := db.snapshotValue(key)
+	if !ok {
+		return nil
+	}
+	return emit(key, value, expireAt)
+}
+
+// snapshotConsumer 接收快照中的一个key，expireAt为零值表示没有过期时间
+type snapshotConsumer func(key string, value interface{}, expireAt time.Time) error
+
+/* ---- StandaloneDatabase ---- */
+
+func rdbFilePath() string {
+	filename := config.Properties.RDBFilename
+	if filename == "" {
+		filename = defaultRDBFilename
+	}
+	return filepath.Join(config.Properties.Dir, filename)
+}
+
+// forEachSnapshot 给所有DB装上快照后调用onStart，然后按DB顺序遍历快照中的key
+// 同一时间只能有一个快照，后来的调用会等待前一个结束
+func (d *StandaloneDatabase) forEachSnapshot(onStart func(), consumer func(dbIndex int) snapshotConsumer) error {
+	d.snapshotMu.Lock()
+	defer d.snapshotMu.Unlock()
+	snaps := make([]*rdbSnapshot, len(d.dbSet))
+	// 暂停所有写命令，保证onStart(如AOF重写开始缓冲命令)与快照在同一时刻
+	for _, db := range d.dbSet {
+		db.locker.RLockAll()
+	}
+	for i, db := range d.dbSet {
+		snaps[i] = newRDBSnapshot()
+		db.snapshot.Store(snaps[i])
+	}
+	if onStart != nil {
+		onStart()
+	}
+	for _, db := range d.dbSet {
+		db.locker.RUnLockAll()
+	}
+	// 出错时也要卸下剩余DB的快照
+	defer func() {
+		for _, db := range d.dbSet {
+			db.snapshot.Store(nil)
+		}
+	}()
+	for i, db := range d.dbSet {
+		if err := db.dumpSnapshot(snaps[i], consumer(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachSnapshot 遍历所有DB在同一时刻的快照，onStart在快照开始时调用，此时写命令被暂停
+func (d *StandaloneDatabase) ForEachSnapshot(onStart func(), cb func(dbIndex int, key string, data *databaseface.DataEntity, expireAt time.Time) error) error {
+	return d.forEachSnapshot(onStart, func(dbIndex int) snapshotConsumer {
+		return func(key string, value interface{}, expireAt time.Time) error {
+			return cb(dbIndex, key, &databaseface.DataEntity{Data: value}, expireAt)
+		}
+	})
+}
+
+// WriteRDB 将所有DB当前的数据以RDB格式写入w，onStart在快照开始时调用
+func (d *StandaloneDatabase) WriteRDB(w io.Writer, onStart func()) error {
+	enc := rdb.NewEncoder(w)
+	if err := enc.WriteHeader(); err != nil {
+		return err
+	}
+	err := d.forEachSnapshot(onStart, func(dbIndex int) snapshotConsumer {
+		db := d.dbSet[dbIndex]
+		headerWritten := false
+		return func(key string, value interface{}, expireAt time.Time) error {
+			// 空的DB不写入
+			if !headerWritten {
+				headerWritten = true
+				if err := enc.WriteDBHeader(dbIndex, db.data.Len(), db.ttlMap.Len()); err != nil {
+					return err
+				}
+			}
+			return enc.WriteObject(key, value, expireAt)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return enc.WriteEnd()
+}
+
+// saveRDB 先写入临时文件，写完后再替换原文件，保存失败不会破坏原有的RDB文件
+func (d *StandaloneDatabase) saveRDB() error {
+	filename := rdbFilePath()
+	tmpFile, err := os.CreateTemp(filepath.Dir(filename), "temp-*.rdb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := d.WriteRDB(tmpFile, nil); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filename)
+}
+
+// startSave 同一时间只能有一个保存任务，返回开始时的修改次数
+func (d *StandaloneDatabase) startSave() (dirty int64, err error) {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+	if d.saving {
+		return 0, errSaveInProgress
+	}
+	d.saving = true
+	d.lastSaveTry = time.Now()
+	return d.dirty.Load(), nil
+}
+
+// finishSave 保存成功后减去保存开始前的修改次数，保存期间的修改留给下一次保存
+func (d *StandaloneDatabase) finishSave(dirty int64, err error) {
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+	d.saving = false
+	d.lastSaveOK = err == nil
+	if err != nil {
+		logger.Error("rdb save failed: " + err.Error())
+		return
+	}
+	d.dirty.Add(-dirty)
+	d.lastSave = time.Now()
+	logger.Info("rdb saved on disk")
+}
+
+func (d *StandaloneDatabase) save() error {
+	dirty, err := d.startSave()
+	if err != nil {
+		return err
+	}
+	err = d.saveRDB()
+	d.finishSave(dirty, err)
+	return err
+}
+
+func (d *StandaloneDatabase) bgSave() error {
+	dirty, err := d.startSave()
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error(err)
+				d.finishSave(dirty, errors.New("rdb save panicked"))
+			}
+		}()
+		d.finishSave(dirty, d.saveRDB())
+	}()
+	return nil
+}
+
+// SAVE
+func execSave(d *StandaloneDatabase, args [][]byte) resp.Reply {
+	if len(args) != 0 {
+		return reply.NewArgNumErrReply("save")
+	}
+	if err := d.save(); err != nil {
+		if err == errSaveInProgress {
+			return reply.NewErrReply(err.Error())
+		}
+		return reply.NewErrReply("ERR " + err.Error())
+	}
+	return reply.NewOkReply()
+}
+
+// BGSAVE [SCHEDULE]
+func execBgSave(d *StandaloneDatabase, args [][]byte) resp.Reply {
+	if len(args) > 1 {
+		return reply.NewArgNumErrReply("bgsave")
+	}
+	schedule := len(args) == 1
+	if schedule && strings.ToLower(string(args[0])) != "schedule" {
+		return reply.NewSyntaxErrReply()
+	}
+	// aof重写期间只有指定SCHEDULE才保存，快照会等待重写结束后开始
+	rewriting := d.aofRewriting()
+	if rewriting && !schedule {
+		return reply.NewErrReply("ERR Another child process is active (AOF?): can't BGSAVE right now. " +
+			"Use BGSAVE SCHEDULE in order to schedule a BGSAVE whenever possible")
+	}
+	if err := d.bgSave(); err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	if rewriting {
+		return reply.NewStatusReply("Background saving scheduled")
+	}
+	return reply.NewStatusReply("Background saving started")
+}
+
+// BGREWRITEAOF
+func execBgRewriteAof(d *StandaloneDatabase, args [][]byte) resp.Reply {
+	if len(args) != 0 {
+		return reply.NewArgNumErrReply("bgrewriteaof")
+	}
+	if d.aofHandler == nil {
+		return reply.NewErrReply("ERR Append only file is not enabled")
+	}
+	d.saveMu.Lock()
+	saving := d.saving
+	d.saveMu.Unlock()
+	if err := d.aofHandler.Rewrite(); err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	if saving {
+		return reply.NewStatusReply("Background append only file rewriting scheduled")
+	}
+	return reply.NewStatusReply("Background append only file rewriting started")
+}
+
+func (d *StandaloneDatabase) aofRewriting() bool {
+	return d.aofHandler != nil && d.aofHandler.IsRewriting()
+}
+
+// LASTSAVE
+func execLastSave(d *StandaloneDatabase, args [][]byte) resp.Reply {
+	if len(args) != 0 {
+		return reply.NewArgNumErrReply("lastsave")
+	}
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+	return reply.NewIntReply(d.lastSave.Unix())
+}
+
+/* ---- save policy ---- */
+
+// savePolicy 距离上次保存超过seconds秒且至少有changes次修改时自动保存
+type savePolicy struct {
+	seconds int64
+	changes int64
+}
+
+// parseSavePolicies 解析 "<seconds> <changes> [<seconds> <changes> ...]"，空字符串表示不自动保存
+func parseSavePolicies(value string) ([]savePolicy, error) {
+	fields := strings.Fields(strings.Trim(value, "\""))
+	if len(fields)%2 != 0 {
+		return nil, errors.New("invalid save parameters: " + value)
+	}
+	policies := make([]savePolicy, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err1 := strconv.ParseInt(fields[i], 10, 64)
+		changes, err2 := strconv.ParseInt(fields[i+1], 10, 64)
+		if err1 != nil || err2 != nil || seconds < 1 || changes < 0 {
+			return nil, errors.New("invalid save parameters: " + value)
+		}
+		policies = append(policies, savePolicy{seconds, changes})
+	}
+	return policies, nil
+}
+
+// saveCron 每秒检查一次是否满足自动保存的条件
+func (d *StandaloneDatabase) saveCron(policies []savePolicy) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if d.shouldSave(policies, time.Now()) {
+				_ = d.bgSave()
+			}
+		case <-d.closing:
+			return
+		}
+	}
+}
+
+func (d *StandaloneDatabase) shouldSave(policies []savePolicy, now time.Time) bool {
+	dirty := d.dirty.Load()
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+	if d.saving || d.aofRewriting() {
+		return false
+	}
+	// 上次保存失败时不立即重试，避免磁盘出问题时不停地写
+	if !d.lastSaveOK && now.Sub(d.lastSaveTry) < saveRetryDelay {
+		return false
+	}
+	for _, policy := range policies {
+		if dirty >= policy.changes && now.Sub(d.lastSave) >= time.Duration(policy.seconds)*time.Second {
+			return true
+		}
+	}
+	return false
+}
+
+/* ---- load ---- */
+
+// loadRDB 启动时加载RDB文件，文件不存在时直接返回
+func (d *StandaloneDatabase) loadRDB() error {
+	file, err := os.Open(rdbFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	return d.LoadRDB(file)
+}
+
+// LoadRDB 从r中读取一个RDB文件的数据，r为*bufio.Reader时读取到RDB结尾为止，之后的数据可以继续读取
+func (d *StandaloneDatabase) LoadRDB(r io.Reader) error {
+	now := time.Now()
+	return rdb.NewDecoder(r).Parse(func(obj *rdb.Object) bool {
+		if obj.DB < 0 || obj.DB >= len(d.dbSet) {
+			logger.Error("rdb: db index out of range: " + strconv.Itoa(obj.DB))
+			return true
+		}
+		if !obj.ExpireAt.IsZero() && obj.ExpireAt.Before(now) {
+			return true
+		}
+		db := d.dbSet[obj.DB]
+		db.PutEntity(obj.Key, &databaseface.DataEntity{
+			Data: obj.Value,
+		})
+		if !obj.ExpireAt.IsZero() {
+			db.Expire(obj.Key, obj.ExpireAt)
+		}
+		return true
+	})
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"go-redis/config"
+	List "go-redis/datastruct/list"
+	"go-redis/lib/utils"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"testing"
+	"time"
+)
+
+// makeRDBTestDatabase 使用临时目录保存RDB文件，测试结束后恢复配置
+func makeRDBTestDatabase(t *testing.T) *StandaloneDatabase {
+	properties := *config.Properties
+	t.Cleanup(func() {
+		*config.Properties = properties
+	})
+	config.Properties.Dir = t.TempDir()
+	config.Properties.AppendOnly = false
+	config.Properties.Databases = 4
+	return NewStandaloneDatabase()
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	d := makeRDBTestDatabase(t)
+	conn := connection.NewFakeConn()
+	cmds := [][]string{
+		{"set", "str", "hello"},
+		{"set", "ttl", "v", "ex", "100"},
+		{"set", "gone", "v", "px", "20"},
+		{"rpush", "list", "a", "b", "c"},
+		{"sadd", "set", "x", "y"},
+		{"zadd", "zset", "1", "m1", "2.5", "m2"},
+		{"hset", "hash", "f", "v"},
+		{"xadd", "stream", "1-1", "f", "v"},
+		{"select", "2"},
+		{"set", "db2", "v2"},
+	}
+	for _, cmd := range cmds {
+		if result := d.Exec(conn, utils.ToCmdLine(cmd...)); reply.IsErrReply(result) {
+			t.Fatalf("%v: %s", cmd, result.ToBytes())
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("save")), reply.NewOkReply())
+
+	loaded := NewStandaloneDatabase()
+	conn = connection.NewFakeConn()
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "str")), reply.NewBulkReply([]byte("hello")))
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("exists", "gone")), 0)
+	if ttl := loaded.Exec(conn, utils.ToCmdLine("ttl", "ttl")).(*reply.IntReply).Code; ttl < 99 || ttl > 100 {
+		t.Errorf("expected ttl about 100, actually %d", ttl)
+	}
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("lrange", "list", "0", "-1")),
+		reply.NewMultiBulkReply(utils.ToCmdLine("a", "b", "c")))
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("sismember", "set", "y")), 1)
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("zscore", "zset", "m2")), reply.NewBulkReply([]byte("2.5")))
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("hget", "hash", "f")), reply.NewBulkReply([]byte("v")))
+	assertIntReply(t, loaded.Exec(conn, utils.ToCmdLine("xlen", "stream")), 1)
+	conn.SelectDB(2)
+	assertReply(t, loaded.Exec(conn, utils.ToCmdLine("get", "db2")), reply.NewBulkReply([]byte("v2")))
+}
+
+// TestSnapshotCopyOnWrite 快照开始后的写入不影响快照的内容
+func TestSnapshotCopyOnWrite(t *testing.T) {
+	db := makeTestDB()
+	db.Exec(nil, utils.ToCmdLine("set", "k1", "old"))
+	db.Exec(nil, utils.ToCmdLine("set", "k2", "old"))
+	db.Exec(nil, utils.ToCmdLine("rpush", "list", "a"))
+	snap := newRDBSnapshot()
+	db.snapshot.Store(snap)
+
+	db.Exec(nil, utils.ToCmdLine("set", "k1", "new"))
+	db.Exec(nil, utils.ToCmdLine("del", "k2"))
+	db.Exec(nil, utils.ToCmdLine("set", "k3", "new"))
+	db.ExecMulti(nil, nil, []CmdLine{
+		utils.ToCmdLine("rpush", "list", "b"),
+		utils.ToCmdLine("rpush", "list", "c"),
+	})
+
+	values := make(map[string]interface{})
+	err := db.dumpSnapshot(snap, func(key string, value interface{}, expireAt time.Time) error {
+		values[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.snapshot.Load() != nil {
+		t.Error("snapshot should be removed after dump")
+	}
+	// 快照结束后的写入不再保存旧值
+	db.Exec(nil, utils.ToCmdLine("set", "k3", "newer"))
+	if preserved, _ := snap.takePreserved(); len(preserved) != 3 {
+		t.Errorf("expected 3 preserved keys, actually %d", len(preserved))
+	}
+
+	if len(values) != 3 {
+		t.Errorf("expected 3 keys, actually %d", len(values))
+	}
+	for _, key := range []string{"k1", "k2"} {
+		if value, ok := values[key].([]byte); !ok || string(value) != "old" {
+			t.Errorf("expected old value of %s", key)
+		}
+	}
+	if _, ok := values["k3"]; ok {
+		t.Error("k3 is created after snapshot")
+	}
+	if l, ok := values["list"].(List.List); !ok {
+		t.Error("expected list")
+	} else if l.Len() != 1 {
+		t.Errorf("expected list len 1, actually %d", l.Len())
+	}
+}
+
+func TestBgSave(t *testing.T) {
+	d := makeRDBTestDatabase(t)
+	conn := connection.NewFakeConn()
+	d.Exec(conn, utils.ToCmdLine("set", "k", "v"))
+	d.saveMu.Lock()
+	d.lastSave = time.Unix(100, 0)
+	d.saveMu.Unlock()
+	assertIntReply(t, d.Exec(conn, utils.ToCmdLine("lastsave")), 100)
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgsave", "now")), reply.NewSyntaxErrReply())
+
+	// 保存中不能再开始新的保存
+	dirty, err := d.startSave()
+	if err != nil || dirty != 1 {
+		t.Fatalf("unexpected start save result %d %v", dirty, err)
+	}
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgsave")), reply.NewErrReply("ERR Background save already in progress"))
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("save")), reply.NewErrReply("ERR Background save already in progress"))
+	d.finishSave(0, errSaveInProgress)
+
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("bgsave")), reply.NewStatusReply("Background saving started"))
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if d.Exec(conn, utils.ToCmdLine("lastsave")).(*reply.IntReply).Code != 100 {
+			break
+		}
+	}
+	if lastSave := d.Exec(conn, utils.ToCmdLine("lastsave")).(*reply.IntReply).Code; lastSave < time.Now().Unix()-1 {
+		t.Errorf("lastsave should be updated, actually %d", lastSave)
+	}
+	if d.dirty.Load() != 0 {
+		t.Errorf("expected dirty 0, actually %d", d.dirty.Load())
+	}
+}
+
+func TestSavePolicy(t *testing.T) {
+	if _, err := parseSavePolicies("900 1 300"); err == nil {
+		t.Error("expected error for odd parameters")
+	}
+	if policies, err := parseSavePolicies(`""`); err != nil || len(policies) != 0 {
+		t.Error("empty string disables save")
+	}
+	policies, err := parseSavePolicies("900 1 60 100")
+	if err != nil || len(policies) != 2 {
+		t.Fatalf("unexpected policies %v %v", policies, err)
+	}
+
+	d := &StandaloneDatabase{
+		lastSave:   time.Now(),
+		lastSaveOK: true,
+	}
+	now := d.lastSave
+	d.dirty.Store(100)
+	if d.shouldSave(policies, now.Add(59*time.Second)) {
+		t.Error("should not save before 60 seconds")
+	}
+	if !d.shouldSave(policies, now.Add(60*time.Second)) {
+		t.Error("should save after 60 seconds with 100 changes")
+	}
+	d.dirty.Store(1)
+	if d.shouldSave(policies, now.Add(600*time.Second)) || !d.shouldSave(policies, now.Add(900*time.Second)) {
+		t.Error("1 change should be saved after 900 seconds")
+	}
+	// 保存失败后需要等待一段时间才能重试
+	d.lastSaveOK = false
+	d.lastSaveTry = now.Add(899 * time.Second)
+	if d.shouldSave(policies, now.Add(900*time.Second)) {
+		t.Error("should wait before retry")
+	}
+	if !d.shouldSave(policies, now.Add(905*time.Second)) {
+		t.Error("should retry after delay")
+	}
+}
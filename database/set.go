@@ -0,0 +1,356 @@
+package database
+
+import (
+	HashSet "go-redis/datastruct/set"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strconv"
+)
+
+func init() {
+	RegisterCommand("sadd", execSAdd, writeFirstKey, -3)
+	RegisterCommand("srem", execSRem, writeFirstKey, -3)
+	RegisterCommand("scard", execSCard, readFirstKey, 2)
+	RegisterCommand("sismember", execSIsMember, readFirstKey, 3)
+	RegisterCommand("smismember", execSMIsMember, readFirstKey, -3)
+	RegisterCommand("smembers", execSMembers, readFirstKey, 2)
+	RegisterCommand("spop", execSPop, writeFirstKey, -2)
+	RegisterCommand("srandmember", execSRandMember, readFirstKey, -2)
+	RegisterCommand("smove", execSMove, prepareSMove, 4)
+	RegisterCommand("sinter", execSInter, readAllKeys, -2)
+	RegisterCommand("sinterstore", execSInterStore, writeFirstKeyReadOthers, -3)
+	RegisterCommand("sunion", execSUnion, readAllKeys, -2)
+	RegisterCommand("sunionstore", execSUnionStore, writeFirstKeyReadOthers, -3)
+	RegisterCommand("sdiff", execSDiff, readAllKeys, -2)
+	RegisterCommand("sdiffstore", execSDiffStore, writeFirstKeyReadOthers, -3)
+}
+
+func (db *DB) getAsSet(key string) (*HashSet.Set, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	set, ok := entity.Data.(*HashSet.Set)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return set, nil
+}
+
+func (db *DB) getOrInitSet(key string) (set *HashSet.Set, inited bool, errReply reply.ErrorReply) {
+	set, errReply = db.getAsSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if set == nil {
+		set = HashSet.New()
+		db.PutEntity(key, &databaseface.DataEntity{
+			Data: set,
+		})
+		inited = true
+	}
+	return set, inited, nil
+}
+
+func membersToReply(members []string) *reply.MultiBulkReply {
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		result[i] = []byte(member)
+	}
+	return reply.NewMultiBulkReply(result)
+}
+
+// SADD key member [member ...]
+func execSAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	set, _, errReply := db.getOrInitSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for _, member := range args[1:] {
+		added += set.Add(string(member))
+	}
+	db.addAof(utils.ToCmdLine3("sadd", args...))
+	return reply.NewIntReply(int64(added))
+}
+
+// SREM key member [member ...]
+func execSRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.NewIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		removed += set.Remove(string(member))
+	}
+	if set.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("srem", args...))
+	}
+	return reply.NewIntReply(int64(removed))
+}
+
+// SCARD key
+func execSCard(db *DB, args [][]byte) resp.Reply {
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(int64(set.Len()))
+}
+
+// SISMEMBER key member
+func execSIsMember(db *DB, args [][]byte) resp.Reply {
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if set != nil && set.Has(string(args[1])) {
+		return reply.NewIntReply(1)
+	}
+	return reply.NewIntReply(0)
+}
+
+// SMISMEMBER key member [member ...]
+func execSMIsMember(db *DB, args [][]byte) resp.Reply {
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	members := args[1:]
+	result := make([]resp.Reply, len(members))
+	for i, member := range members {
+		if set != nil && set.Has(string(member)) {
+			result[i] = reply.NewIntReply(1)
+		} else {
+			result[i] = reply.NewIntReply(0)
+		}
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// SMEMBERS key
+func execSMembers(db *DB, args [][]byte) resp.Reply {
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.NewNullMultiBulkReply()
+	}
+	return membersToReply(set.ToSlice())
+}
+
+// SPOP key [count]
+func execSPop(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return reply.NewSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := 1
+	if len(args) == 2 {
+		count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || count64 < 0 {
+			return reply.NewErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(count64)
+	}
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil || count == 0 {
+		if len(args) == 1 {
+			return reply.NewNullBulkReply()
+		}
+		return reply.NewNullMultiBulkReply()
+	}
+
+	members := set.RandomDistinctMembers(count)
+	for _, member := range members {
+		set.Remove(member)
+	}
+	if set.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	// 随机结果不可重放，aof中记录实际删除的成员
+	db.addAof(utils.ToCmdLine2("srem", append([]string{key}, members...)...))
+	if len(args) == 1 {
+		return reply.NewBulkReply([]byte(members[0]))
+	}
+	return membersToReply(members)
+}
+
+// SRANDMEMBER key [count]
+func execSRandMember(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return reply.NewSyntaxErrReply()
+	}
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if set == nil {
+			return reply.NewNullBulkReply()
+		}
+		return reply.NewBulkReply([]byte(set.RandomMembers(1)[0]))
+	}
+	count, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if errReply := checkRandomCount(count); errReply != nil {
+		return errReply
+	}
+	if set == nil || count == 0 {
+		return reply.NewNullMultiBulkReply()
+	}
+	// count为正数时返回不重复的成员，为负数时允许重复
+	if count > 0 {
+		return membersToReply(set.RandomDistinctMembers(int(count)))
+	}
+	return membersToReply(set.RandomMembers(int(-count)))
+}
+
+func prepareSMove(args [][]byte) ([]string, []string) {
+	return []string{string(args[0]), string(args[1])}, nil
+}
+
+// SMOVE source destination member
+func execSMove(db *DB, args [][]byte) resp.Reply {
+	srcKey, destKey, member := string(args[0]), string(args[1]), string(args[2])
+	src, errReply := db.getAsSet(srcKey)
+	if errReply != nil {
+		return errReply
+	}
+	if _, errReply = db.getAsSet(destKey); errReply != nil {
+		return errReply
+	}
+	if src == nil || !src.Has(member) {
+		return reply.NewIntReply(0)
+	}
+	dest, _, _ := db.getOrInitSet(destKey)
+	src.Remove(member)
+	dest.Add(member)
+	if src.Len() == 0 {
+		db.RemoveEntity(srcKey)
+	}
+	db.addAof(utils.ToCmdLine3("smove", args...))
+	return reply.NewIntReply(1)
+}
+
+const (
+	interOp = iota
+	unionOp
+	diffOp
+)
+
+// setAlgebra 依次对各个key上的集合做交/并/差运算
+// 返回nil表示结果为空集
+func setAlgebra(db *DB, keys [][]byte, op int) (*HashSet.Set, reply.ErrorReply) {
+	var result *HashSet.Set
+	for i, key := range keys {
+		set, errReply := db.getAsSet(string(key))
+		if errReply != nil {
+			return nil, errReply
+		}
+		if set == nil {
+			// 交集遇到空集，或差集的第一个集合为空，结果一定为空
+			if op == interOp || op == diffOp && i == 0 {
+				return nil, nil
+			}
+			continue
+		}
+		if result == nil {
+			result = HashSet.New(set.ToSlice()...)
+			continue
+		}
+		switch op {
+		case interOp:
+			result = result.Intersect(set)
+			if result.Len() == 0 {
+				return nil, nil
+			}
+		case unionOp:
+			result = result.Union(set)
+		case diffOp:
+			result = result.Diff(set)
+		}
+	}
+	return result, nil
+}
+
+func setAlgebraReply(db *DB, keys [][]byte, op int) resp.Reply {
+	result, errReply := setAlgebra(db, keys, op)
+	if errReply != nil {
+		return errReply
+	}
+	if result == nil {
+		return reply.NewNullMultiBulkReply()
+	}
+	return membersToReply(result.ToSlice())
+}
+
+// setAlgebraStore 把运算结果存到dest中，结果为空时删除dest
+func setAlgebraStore(db *DB, cmdName string, args [][]byte, op int) resp.Reply {
+	dest := string(args[0])
+	result, errReply := setAlgebra(db, args[1:], op)
+	if errReply != nil {
+		return errReply
+	}
+	db.RemoveEntity(dest)
+	size := 0
+	if result != nil && result.Len() > 0 {
+		db.PutEntity(dest, &databaseface.DataEntity{
+			Data: result,
+		})
+		size = result.Len()
+	}
+	db.addAof(utils.ToCmdLine3(cmdName, args...))
+	return reply.NewIntReply(int64(size))
+}
+
+// SINTER key [key ...]
+func execSInter(db *DB, args [][]byte) resp.Reply {
+	return setAlgebraReply(db, args, interOp)
+}
+
+// SINTERSTORE destination key [key ...]
+func execSInterStore(db *DB, args [][]byte) resp.Reply {
+	return setAlgebraStore(db, "sinterstore", args, interOp)
+}
+
+// SUNION key [key ...]
+func execSUnion(db *DB, args [][]byte) resp.Reply {
+	return setAlgebraReply(db, args, unionOp)
+}
+
+// SUNIONSTORE destination key [key ...]
+func execSUnionStore(db *DB, args [][]byte) resp.Reply {
+	return setAlgebraStore(db, "sunionstore", args, unionOp)
+}
+
+// SDIFF key [key ...]
+func execSDiff(db *DB, args [][]byte) resp.Reply {
+	return setAlgebraReply(db, args, diffOp)
+}
+
+// SDIFFSTORE destination key [key ...]
+func execSDiffStore(db *DB, args [][]byte) resp.Reply {
+	return setAlgebraStore(db, "sdiffstore", args, diffOp)
+}
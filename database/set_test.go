@@ -0,0 +1,118 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func sortedMembers(r interface{}) []string {
+	members := make([]string, 0)
+	if multiBulk, ok := r.(*reply.MultiBulkReply); ok {
+		for _, arg := range multiBulk.Args {
+			members = append(members, string(arg))
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+func TestSAdd(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	size := 100
+	key := utils.RandString(10)
+	for i := 0; i < size; i++ {
+		member := strconv.Itoa(i)
+		result := execSAdd(testDB, utils.ToCmdLine(key, member, member))
+		if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+			t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+		}
+	}
+	result := execSCard(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(size) {
+		t.Error(fmt.Sprintf("expected %d, actually %d", size, intResult.Code))
+	}
+	result = execSIsMember(testDB, utils.ToCmdLine(key, "10"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execType(testDB, utils.ToCmdLine(key))
+	if status, _ := result.(*reply.StatusReply); status.Status != "set" {
+		t.Error(fmt.Sprintf("expected set, actually %s", status.Status))
+	}
+
+	result = execSRem(testDB, utils.ToCmdLine(key, "10", "1000"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execSPop(testDB, utils.ToCmdLine(key, strconv.Itoa(size)))
+	if members := sortedMembers(result); len(members) != size-1 {
+		t.Error(fmt.Sprintf("expected %d, actually %d", size-1, len(members)))
+	}
+	result = execExist(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	dest := utils.RandString(10)
+	missing := utils.RandString(10)
+	execSAdd(testDB, utils.ToCmdLine(key1, "a", "b", "c"))
+	execSAdd(testDB, utils.ToCmdLine(key2, "b", "c", "d"))
+
+	if got := fmt.Sprint(sortedMembers(execSInter(testDB, utils.ToCmdLine(key1, key2)))); got != "[b c]" {
+		t.Error("sinter error: " + got)
+	}
+	if got := fmt.Sprint(sortedMembers(execSInter(testDB, utils.ToCmdLine(key1, missing)))); got != "[]" {
+		t.Error("sinter error: " + got)
+	}
+	if got := fmt.Sprint(sortedMembers(execSUnion(testDB, utils.ToCmdLine(missing, key1, key2)))); got != "[a b c d]" {
+		t.Error("sunion error: " + got)
+	}
+	if got := fmt.Sprint(sortedMembers(execSDiff(testDB, utils.ToCmdLine(key1, key2, missing)))); got != "[a]" {
+		t.Error("sdiff error: " + got)
+	}
+
+	result := execSUnionStore(testDB, utils.ToCmdLine(dest, key1, key2))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 4 {
+		t.Error(fmt.Sprintf("expected 4, actually %d", intResult.Code))
+	}
+	result = execSInterStore(testDB, utils.ToCmdLine(dest, key1, missing))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+	result = execExist(testDB, utils.ToCmdLine(dest))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+
+	result = execSMove(testDB, utils.ToCmdLine(key1, key2, "a"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	if got := fmt.Sprint(sortedMembers(execSMembers(testDB, utils.ToCmdLine(key2)))); got != "[a b c d]" {
+		t.Error("smove error: " + got)
+	}
+}
+
+func TestSRandMember(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSAdd(testDB, utils.ToCmdLine(key, "a", "b", "c"))
+	if multiBulk, _ := execSRandMember(testDB, utils.ToCmdLine(key, "5")).(*reply.MultiBulkReply); len(multiBulk.Args) != 3 {
+		t.Error(fmt.Sprintf("expected 3, actually %d", len(multiBulk.Args)))
+	}
+	if multiBulk, _ := execSRandMember(testDB, utils.ToCmdLine(key, "-5")).(*reply.MultiBulkReply); len(multiBulk.Args) != 5 {
+		t.Error(fmt.Sprintf("expected 5, actually %d", len(multiBulk.Args)))
+	}
+	for _, count := range []string{"-9223372036854775808", "-4294967296"} {
+		assertReply(t, execSRandMember(testDB, utils.ToCmdLine(key, count)), reply.NewErrReply("ERR value is out of range"))
+	}
+}
@@ -0,0 +1,790 @@
+package database
+
+import (
+	HashSet "go-redis/datastruct/set"
+	SortedSet "go-redis/datastruct/sortedset"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterCommand("zadd", execZAdd, writeFirstKey, -4)
+	RegisterCommand("zrem", execZRem, writeFirstKey, -3)
+	RegisterCommand("zscore", execZScore, readFirstKey, 3)
+	RegisterCommand("zmscore", execZMScore, readFirstKey, -3)
+	RegisterCommand("zincrby", execZIncrBy, writeFirstKey, 4)
+	RegisterCommand("zcard", execZCard, readFirstKey, 2)
+	RegisterCommand("zcount", execZCount, readFirstKey, 4)
+	RegisterCommand("zrank", execZRank, readFirstKey, -3)
+	RegisterCommand("zrevrank", execZRevRank, readFirstKey, -3)
+	RegisterCommand("zrange", execZRange, readFirstKey, -4)
+	RegisterCommand("zrevrange", execZRevRange, readFirstKey, -4)
+	RegisterCommand("zrangebyscore", execZRangeByScore, readFirstKey, -4)
+	RegisterCommand("zrevrangebyscore", execZRevRangeByScore, readFirstKey, -4)
+	RegisterCommand("zremrangebyrank", execZRemRangeByRank, writeFirstKey, 4)
+	RegisterCommand("zremrangebyscore", execZRemRangeByScore, writeFirstKey, 4)
+	RegisterCommand("zpopmin", execZPopMin, writeFirstKey, -2)
+	RegisterCommand("zpopmax", execZPopMax, writeFirstKey, -2)
+	RegisterCommand("zunionstore", execZUnionStore, prepareZSetStore, -4)
+	RegisterCommand("zinterstore", execZInterStore, prepareZSetStore, -4)
+}
+
+func (db *DB) getAsSortedSet(key string) (*SortedSet.SortedSet, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	sortedSet, ok := entity.Data.(*SortedSet.SortedSet)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return sortedSet, nil
+}
+
+func (db *DB) getOrInitSortedSet(key string) (sortedSet *SortedSet.SortedSet, inited bool, errReply reply.ErrorReply) {
+	sortedSet, errReply = db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if sortedSet == nil {
+		sortedSet = SortedSet.Make()
+		db.PutEntity(key, &databaseface.DataEntity{
+			Data: sortedSet,
+		})
+		inited = true
+	}
+	return sortedSet, inited, nil
+}
+
+func parseScore(raw []byte) (float64, reply.ErrorReply) {
+	score, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, reply.NewErrReply("ERR value is not a valid float")
+	}
+	return score, nil
+}
+
+// formatScore 与redis一样把无穷大输出为 inf/-inf
+func formatScore(score float64) []byte {
+	if math.IsInf(score, 1) {
+		return []byte("inf")
+	} else if math.IsInf(score, -1) {
+		return []byte("-inf")
+	}
+	return []byte(strconv.FormatFloat(score, 'f', -1, 64))
+}
+
+func elementsToReply(elements []*SortedSet.Element, withScores bool) *reply.MultiBulkReply {
+	size := len(elements)
+	if withScores {
+		size *= 2
+	}
+	result := make([][]byte, 0, size)
+	for _, element := range elements {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, formatScore(element.Score))
+		}
+	}
+	return reply.NewMultiBulkReply(result)
+}
+
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func execZAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return reply.NewSyntaxErrReply()
+	}
+	if nx && xx {
+		return reply.NewErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if gt && lt || nx && (gt || lt) {
+		return reply.NewErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) > 2 {
+		return reply.NewErrReply("ERR INCR option supports a single increment-element pair")
+	}
+	elements := make([]*SortedSet.Element, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, errReply := parseScore(pairs[j])
+		if errReply != nil {
+			return errReply
+		}
+		elements[j/2] = &SortedSet.Element{
+			Member: string(pairs[j+1]),
+			Score:  score,
+		}
+	}
+
+	sortedSet, _, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added, changed := 0, 0
+	var incrResult resp.Reply = reply.NewNullBulkReply()
+	for _, element := range elements {
+		current, exists := sortedSet.Get(element.Member)
+		if exists && nx || !exists && xx {
+			continue
+		}
+		score := element.Score
+		if incr && exists {
+			score += current.Score
+			if math.IsNaN(score) {
+				return reply.NewErrReply("ERR resulting score is not a number (NaN)")
+			}
+		}
+		if exists {
+			if gt && score <= current.Score || lt && score >= current.Score {
+				continue
+			}
+			if score != current.Score {
+				changed++
+			}
+		} else {
+			added++
+		}
+		sortedSet.Add(element.Member, score)
+		incrResult = reply.NewBulkReply(formatScore(score))
+	}
+	if sortedSet.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if added+changed > 0 {
+		db.addAof(utils.ToCmdLine3("zadd", args...))
+	}
+
+	if incr {
+		return incrResult
+	}
+	if ch {
+		return reply.NewIntReply(int64(added + changed))
+	}
+	return reply.NewIntReply(int64(added))
+}
+
+// ZREM key member [member ...]
+func execZRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		if sortedSet.Remove(string(member)) {
+			removed++
+		}
+	}
+	if sortedSet.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("zrem", args...))
+	}
+	return reply.NewIntReply(int64(removed))
+}
+
+// ZSCORE key member
+func execZScore(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewNullBulkReply()
+	}
+	element, exists := sortedSet.Get(string(args[1]))
+	if !exists {
+		return reply.NewNullBulkReply()
+	}
+	return reply.NewBulkReply(formatScore(element.Score))
+}
+
+// ZMSCORE key member [member ...]
+func execZMScore(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	members := args[1:]
+	result := make([]resp.Reply, len(members))
+	for i, member := range members {
+		result[i] = reply.NewNullBulkReply()
+		if sortedSet == nil {
+			continue
+		}
+		if element, exists := sortedSet.Get(string(member)); exists {
+			result[i] = reply.NewBulkReply(formatScore(element.Score))
+		}
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// ZINCRBY key increment member
+func execZIncrBy(db *DB, args [][]byte) resp.Reply {
+	key, member := string(args[0]), string(args[2])
+	delta, errReply := parseScore(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	sortedSet, _, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	score := delta
+	if element, exists := sortedSet.Get(member); exists {
+		score += element.Score
+		if math.IsNaN(score) {
+			return reply.NewErrReply("ERR resulting score is not a number (NaN)")
+		}
+	}
+	sortedSet.Add(member, score)
+	db.addAof(utils.ToCmdLine3("zincrby", args...))
+	return reply.NewBulkReply(formatScore(score))
+}
+
+// ZCARD key
+func execZCard(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(sortedSet.Len())
+}
+
+// ZCOUNT key min max
+func execZCount(db *DB, args [][]byte) resp.Reply {
+	min, err := SortedSet.ParseScoreBorder(string(args[1]))
+	if err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	max, err := SortedSet.ParseScoreBorder(string(args[2]))
+	if err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(sortedSet.RangeCount(min, max))
+}
+
+// ZRANK key member [WITHSCORE]
+func execZRank(db *DB, args [][]byte) resp.Reply {
+	return zrank(db, args, false)
+}
+
+// ZREVRANK key member [WITHSCORE]
+func execZRevRank(db *DB, args [][]byte) resp.Reply {
+	return zrank(db, args, true)
+}
+
+func zrank(db *DB, args [][]byte, desc bool) resp.Reply {
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORE" {
+			return reply.NewSyntaxErrReply()
+		}
+		withScore = true
+	} else if len(args) > 3 {
+		return reply.NewSyntaxErrReply()
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewNullBulkReply()
+	}
+	member := string(args[1])
+	rank := sortedSet.GetRank(member, desc)
+	if rank < 0 {
+		return reply.NewNullBulkReply()
+	}
+	if withScore {
+		element, _ := sortedSet.Get(member)
+		return reply.NewMultiRawReply([]resp.Reply{
+			reply.NewIntReply(rank),
+			reply.NewBulkReply(formatScore(element.Score)),
+		})
+	}
+	return reply.NewIntReply(rank)
+}
+
+const (
+	rangeByRank = iota
+	rangeByScore
+	rangeByLex
+)
+
+// zrangeSpec ZRANGE 系列命令解析后的参数
+type zrangeSpec struct {
+	by         int
+	rev        bool
+	withScores bool
+	hasLimit   bool
+	offset     int64
+	count      int64
+}
+
+// parseZRangeOptions 解析 [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func parseZRangeOptions(options [][]byte, spec *zrangeSpec, allowBy bool) reply.ErrorReply {
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(string(options[i])) {
+		case "BYSCORE":
+			if !allowBy {
+				return reply.NewSyntaxErrReply()
+			}
+			spec.by = rangeByScore
+		case "BYLEX":
+			if !allowBy {
+				return reply.NewSyntaxErrReply()
+			}
+			spec.by = rangeByLex
+		case "REV":
+			if !allowBy {
+				return reply.NewSyntaxErrReply()
+			}
+			spec.rev = true
+		case "WITHSCORES":
+			spec.withScores = true
+		case "LIMIT":
+			if i+2 >= len(options) {
+				return reply.NewSyntaxErrReply()
+			}
+			offset, err := strconv.ParseInt(string(options[i+1]), 10, 64)
+			if err != nil {
+				return reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			count, err := strconv.ParseInt(string(options[i+2]), 10, 64)
+			if err != nil {
+				return reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			spec.hasLimit = true
+			spec.offset = offset
+			spec.count = count
+			i += 2
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+	if spec.hasLimit && spec.by == rangeByRank {
+		return reply.NewErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.by == rangeByLex {
+		return reply.NewErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return nil
+}
+
+// zrange start/stop 在REV时分别是max/min，与redis一致
+func zrange(db *DB, key string, start []byte, stop []byte, spec *zrangeSpec) resp.Reply {
+	if spec.by == rangeByRank {
+		start64, err := strconv.ParseInt(string(start), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		stop64, err := strconv.ParseInt(string(stop), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return errReply
+		}
+		if sortedSet == nil {
+			return reply.NewNullMultiBulkReply()
+		}
+		begin, end := utils.ConvertRange(start64, stop64, sortedSet.Len())
+		if begin < 0 || begin == end {
+			return reply.NewNullMultiBulkReply()
+		}
+		elements := sortedSet.RangeByRank(int64(begin), int64(end), spec.rev)
+		return elementsToReply(elements, spec.withScores)
+	}
+
+	minRaw, maxRaw := start, stop
+	if spec.rev {
+		minRaw, maxRaw = stop, start
+	}
+	parseBorder := SortedSet.ParseScoreBorder
+	if spec.by == rangeByLex {
+		parseBorder = SortedSet.ParseLexBorder
+	}
+	min, err := parseBorder(string(minRaw))
+	if err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	max, err := parseBorder(string(maxRaw))
+	if err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewNullMultiBulkReply()
+	}
+	offset, count := int64(0), int64(-1)
+	if spec.hasLimit {
+		offset, count = spec.offset, spec.count
+	}
+	elements := sortedSet.Range(min, max, offset, count, spec.rev)
+	return elementsToReply(elements, spec.withScores)
+}
+
+// ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func execZRange(db *DB, args [][]byte) resp.Reply {
+	spec := &zrangeSpec{}
+	if errReply := parseZRangeOptions(args[3:], spec, true); errReply != nil {
+		return errReply
+	}
+	return zrange(db, string(args[0]), args[1], args[2], spec)
+}
+
+// ZREVRANGE key start stop [WITHSCORES]
+func execZRevRange(db *DB, args [][]byte) resp.Reply {
+	spec := &zrangeSpec{rev: true}
+	if errReply := parseZRangeOptions(args[3:], spec, false); errReply != nil {
+		return errReply
+	}
+	return zrange(db, string(args[0]), args[1], args[2], spec)
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func execZRangeByScore(db *DB, args [][]byte) resp.Reply {
+	spec := &zrangeSpec{by: rangeByScore}
+	if errReply := parseZRangeOptions(args[3:], spec, false); errReply != nil {
+		return errReply
+	}
+	return zrange(db, string(args[0]), args[1], args[2], spec)
+}
+
+// ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+func execZRevRangeByScore(db *DB, args [][]byte) resp.Reply {
+	spec := &zrangeSpec{by: rangeByScore, rev: true}
+	if errReply := parseZRangeOptions(args[3:], spec, false); errReply != nil {
+		return errReply
+	}
+	return zrange(db, string(args[0]), args[1], args[2], spec)
+}
+
+// ZREMRANGEBYRANK key start stop
+func execZRemRangeByRank(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewIntReply(0)
+	}
+	begin, end := utils.ConvertRange(start, stop, sortedSet.Len())
+	if begin < 0 || begin == end {
+		return reply.NewIntReply(0)
+	}
+	removed := sortedSet.RemoveByRank(int64(begin), int64(end))
+	if sortedSet.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if len(removed) > 0 {
+		db.addAof(utils.ToCmdLine3("zremrangebyrank", args...))
+	}
+	return reply.NewIntReply(int64(len(removed)))
+}
+
+// ZREMRANGEBYSCORE key min max
+func execZRemRangeByScore(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	min, err := SortedSet.ParseScoreBorder(string(args[1]))
+	if err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	max, err := SortedSet.ParseScoreBorder(string(args[2]))
+	if err != nil {
+		return reply.NewErrReply(err.Error())
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.NewIntReply(0)
+	}
+	removed := sortedSet.RemoveRange(min, max)
+	if sortedSet.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if len(removed) > 0 {
+		db.addAof(utils.ToCmdLine3("zremrangebyscore", args...))
+	}
+	return reply.NewIntReply(int64(len(removed)))
+}
+
+// ZPOPMIN key [count]
+func execZPopMin(db *DB, args [][]byte) resp.Reply {
+	return zpop(db, args, false)
+}
+
+// ZPOPMAX key [count]
+func execZPopMax(db *DB, args [][]byte) resp.Reply {
+	return zpop(db, args, true)
+}
+
+func zpop(db *DB, args [][]byte, max bool) resp.Reply {
+	if len(args) > 2 {
+		return reply.NewSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := 1
+	if len(args) == 2 {
+		count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || count64 < 0 {
+			return reply.NewErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(count64)
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil || count == 0 {
+		return reply.NewNullMultiBulkReply()
+	}
+
+	var removed []*SortedSet.Element
+	if max {
+		removed = sortedSet.PopMax(count)
+	} else {
+		removed = sortedSet.PopMin(count)
+	}
+	if sortedSet.Len() == 0 {
+		db.RemoveEntity(key)
+	}
+	if len(removed) > 0 {
+		cmdLine := make([]string, 0, len(removed)+1)
+		cmdLine = append(cmdLine, key)
+		for _, element := range removed {
+			cmdLine = append(cmdLine, element.Member)
+		}
+		db.addAof(utils.ToCmdLine2("zrem", cmdLine...))
+	}
+	return elementsToReply(removed, true)
+}
+
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+func aggregate(mode int, a, b float64) float64 {
+	switch mode {
+	case aggregateMin:
+		return math.Min(a, b)
+	case aggregateMax:
+		return math.Max(a, b)
+	default:
+		sum := a + b
+		if math.IsNaN(sum) { // inf + -inf
+			return 0
+		}
+		return sum
+	}
+}
+
+// getAsZSetSource ZUNIONSTORE/ZINTERSTORE 的输入可以是有序集合或普通集合，普通集合的分数视为1
+func (db *DB) getAsZSetSource(key string) (map[string]float64, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	result := make(map[string]float64)
+	switch data := entity.Data.(type) {
+	case *SortedSet.SortedSet:
+		data.ForEachByRank(0, data.Len(), false, func(element *SortedSet.Element) bool {
+			result[element.Member] = element.Score
+			return true
+		})
+	case *HashSet.Set:
+		data.ForEach(func(member string) bool {
+			result[member] = 1
+			return true
+		})
+	default:
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return result, nil
+}
+
+// prepareZSetStore numkeys不合法时只声明destination，命令执行时会返回错误
+func prepareZSetStore(args [][]byte) ([]string, []string) {
+	dest := string(args[0])
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil || numKeys <= 0 || numKeys > len(args)-2 {
+		return []string{dest}, nil
+	}
+	_, readKeys := readAllKeys(args[2 : 2+numKeys])
+	return []string{dest}, readKeys
+}
+
+// ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func execZUnionStore(db *DB, args [][]byte) resp.Reply {
+	return zsetAlgebraStore(db, "zunionstore", args, false)
+}
+
+// ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func execZInterStore(db *DB, args [][]byte) resp.Reply {
+	return zsetAlgebraStore(db, "zinterstore", args, true)
+}
+
+func zsetAlgebraStore(db *DB, cmdName string, args [][]byte, inter bool) resp.Reply {
+	dest := string(args[0])
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return reply.NewErrReply("ERR at least 1 input key is needed for '" + cmdName + "' command")
+	}
+	// numKeys很大时2+numKeys会溢出，先用参数个数计算可用的key数量
+	if numKeys > len(args)-2 {
+		return reply.NewSyntaxErrReply()
+	}
+	keys := args[2 : 2+numKeys]
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	mode := aggregateSum
+	options := args[2+numKeys:]
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(string(options[i])) {
+		case "WEIGHTS":
+			if i+numKeys >= len(options) {
+				return reply.NewSyntaxErrReply()
+			}
+			for j := 0; j < numKeys; j++ {
+				weight, err := strconv.ParseFloat(string(options[i+1+j]), 64)
+				if err != nil || math.IsNaN(weight) {
+					return reply.NewErrReply("ERR weight value is not a float")
+				}
+				weights[j] = weight
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if i+1 >= len(options) {
+				return reply.NewSyntaxErrReply()
+			}
+			switch strings.ToUpper(string(options[i+1])) {
+			case "SUM":
+				mode = aggregateSum
+			case "MIN":
+				mode = aggregateMin
+			case "MAX":
+				mode = aggregateMax
+			default:
+				return reply.NewSyntaxErrReply()
+			}
+			i++
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	var result map[string]float64
+	for i, key := range keys {
+		source, errReply := db.getAsZSetSource(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		weighted := make(map[string]float64, len(source))
+		for member, score := range source {
+			weighted[member] = score * weights[i]
+			if math.IsNaN(weighted[member]) { // 0 * inf
+				weighted[member] = 0
+			}
+		}
+		if i == 0 {
+			result = weighted
+			continue
+		}
+		if inter {
+			next := make(map[string]float64)
+			for member, score := range result {
+				if other, ok := weighted[member]; ok {
+					next[member] = aggregate(mode, score, other)
+				}
+			}
+			result = next
+		} else {
+			for member, score := range weighted {
+				if current, ok := result[member]; ok {
+					result[member] = aggregate(mode, current, score)
+				} else {
+					result[member] = score
+				}
+			}
+		}
+	}
+
+	db.RemoveEntity(dest)
+	if len(result) > 0 {
+		sortedSet := SortedSet.Make()
+		for member, score := range result {
+			sortedSet.Add(member, score)
+		}
+		db.PutEntity(dest, &databaseface.DataEntity{
+			Data: sortedSet,
+		})
+	}
+	db.addAof(utils.ToCmdLine3(cmdName, args...))
+	return reply.NewIntReply(int64(len(result)))
+}
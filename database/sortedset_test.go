@@ -0,0 +1,171 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"strconv"
+	"testing"
+)
+
+func TestZAdd(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	size := 100
+	key := utils.RandString(10)
+	members := make([]string, size)
+	scores := make([]float64, size)
+	setArgs := []string{key}
+	for i := 0; i < size; i++ {
+		members[i] = utils.RandString(10)
+		scores[i] = float64(i)
+		setArgs = append(setArgs, strconv.FormatFloat(scores[i], 'f', -1, 64), members[i])
+	}
+	result := execZAdd(testDB, utils.ToCmdLine(setArgs...))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(size) {
+		t.Error(fmt.Sprintf("expected %d, actually %d", size, intResult.Code))
+	}
+
+	for i := 0; i < size; i++ {
+		result = execZScore(testDB, utils.ToCmdLine(key, members[i]))
+		expected := reply.NewBulkReply([]byte(strconv.Itoa(i)))
+		if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+			t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+		}
+		result = execZRank(testDB, utils.ToCmdLine(key, members[i]))
+		if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(i) {
+			t.Error(fmt.Sprintf("expected %d, actually %d", i, intResult.Code))
+		}
+		result = execZRevRank(testDB, utils.ToCmdLine(key, members[i]))
+		if intResult, _ := result.(*reply.IntReply); intResult.Code != int64(size-i-1) {
+			t.Error(fmt.Sprintf("expected %d, actually %d", size-i-1, intResult.Code))
+		}
+	}
+
+	// NX does not update, GT only raises scores, CH counts changes
+	result = execZAdd(testDB, utils.ToCmdLine(key, "NX", "1000", members[0]))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+	result = execZAdd(testDB, utils.ToCmdLine(key, "GT", "CH", "-1", members[1], "1000", members[2]))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execZAdd(testDB, utils.ToCmdLine(key, "INCR", "5", members[2]))
+	expected := reply.NewBulkReply([]byte("1005"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execZAdd(testDB, utils.ToCmdLine(key, "NX", "XX", "1", "a"))
+	if !reply.IsErrReply(result) {
+		t.Error("expected error for NX and XX")
+	}
+	result = execType(testDB, utils.ToCmdLine(key))
+	if status, _ := result.(*reply.StatusReply); status.Status != "zset" {
+		t.Error(fmt.Sprintf("expected zset, actually %s", status.Status))
+	}
+}
+
+func TestZRange(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execZAdd(testDB, utils.ToCmdLine(key, "1", "a", "2", "b", "3", "c", "4", "d", "5", "e"))
+
+	cases := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{key, "0", "-1"}, []string{"a", "b", "c", "d", "e"}},
+		{[]string{key, "-100", "1"}, []string{"a", "b"}},
+		{[]string{key, "0", "1", "REV", "WITHSCORES"}, []string{"e", "5", "d", "4"}},
+		{[]string{key, "(1", "3", "BYSCORE"}, []string{"b", "c"}},
+		{[]string{key, "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"}, []string{"d", "c"}},
+		{[]string{key, "[b", "(d", "BYLEX"}, []string{"b", "c"}},
+	}
+	for _, c := range cases {
+		result := execZRange(testDB, utils.ToCmdLine(c.args...))
+		expected := reply.NewMultiBulkReply(utils.ToCmdLine(c.expected...))
+		if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+			t.Error(fmt.Sprintf("%v: expected %s, actually %s", c.args, string(expected.ToBytes()), string(result.ToBytes())))
+		}
+	}
+	result := execZRange(testDB, utils.ToCmdLine(key, "0", "1", "LIMIT", "0", "1"))
+	if !reply.IsErrReply(result) {
+		t.Error("expected error for LIMIT without BYSCORE")
+	}
+
+	result = execZRangeByScore(testDB, utils.ToCmdLine(key, "2", "4", "WITHSCORES", "LIMIT", "1", "-1"))
+	expected := reply.NewMultiBulkReply(utils.ToCmdLine("c", "3", "d", "4"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execZCount(testDB, utils.ToCmdLine(key, "(1", "+inf"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 4 {
+		t.Error(fmt.Sprintf("expected 4, actually %d", intResult.Code))
+	}
+}
+
+func TestZRemAndPop(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execZAdd(testDB, utils.ToCmdLine(key, "1", "a", "2", "b", "3", "c", "4", "d", "5", "e", "6", "f"))
+
+	result := execZRemRangeByRank(testDB, utils.ToCmdLine(key, "0", "0"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execZRemRangeByScore(testDB, utils.ToCmdLine(key, "(4", "5"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execZPopMin(testDB, utils.ToCmdLine(key))
+	expected := reply.NewMultiBulkReply(utils.ToCmdLine("b", "2"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execZPopMax(testDB, utils.ToCmdLine(key, "2"))
+	expected = reply.NewMultiBulkReply(utils.ToCmdLine("f", "6", "d", "4"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	execZRem(testDB, utils.ToCmdLine(key, "c"))
+	result = execExist(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %d", intResult.Code))
+	}
+}
+
+func TestZStore(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	dest := utils.RandString(10)
+	execZAdd(testDB, utils.ToCmdLine(key1, "1", "a", "2", "b"))
+	execZAdd(testDB, utils.ToCmdLine(key2, "10", "b", "20", "c"))
+
+	result := execZUnionStore(testDB, utils.ToCmdLine(dest, "2", key1, key2, "WEIGHTS", "1", "2"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 3 {
+		t.Error(fmt.Sprintf("expected 3, actually %d", intResult.Code))
+	}
+	result = execZRange(testDB, utils.ToCmdLine(dest, "0", "-1", "WITHSCORES"))
+	expected := reply.NewMultiBulkReply(utils.ToCmdLine("a", "1", "b", "22", "c", "40"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	result = execZInterStore(testDB, utils.ToCmdLine(dest, "2", key1, key2, "AGGREGATE", "MAX"))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %d", intResult.Code))
+	}
+	result = execZScore(testDB, utils.ToCmdLine(dest, "b"))
+	expected2 := reply.NewBulkReply([]byte("10"))
+	if !utils.BytesEquals(result.ToBytes(), expected2.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected2.ToBytes()), string(result.ToBytes())))
+	}
+	// numkeys过大时不能溢出
+	for _, numKeys := range []string{"9223372036854775807", "9223372036854775806", "3"} {
+		result = testDB.Exec(nil, utils.ToCmdLine("zunionstore", dest, numKeys, key1, key2))
+		if !utils.BytesEquals(result.ToBytes(), reply.NewSyntaxErrReply().ToBytes()) {
+			t.Error(fmt.Sprintf("expected syntax error, actually %s", string(result.ToBytes())))
+		}
+	}
+}
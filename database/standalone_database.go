@@ -6,18 +6,44 @@ import (
 	databaseface "go-redis/interface/database"
 	"go-redis/interface/resp"
 	"go-redis/logger"
+	"go-redis/pubsub"
 	"go-redis/resp/reply"
+	"go-redis/tracking"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type StandaloneDatabase struct {
 	dbSet []*DB
 	aofHandler *aof.AofHandler
+	hub *pubsub.Hub // 发布订阅，与选择的db无关
+	tracking *tracking.Table // 客户端缓存，失效消息通过hub发给重定向的连接
+
+	// snapshotMu 保证同一时间只有一个快照(SAVE/BGSAVE/BGREWRITEAOF)
+	snapshotMu sync.Mutex
+	// dirty 上次保存RDB之后的修改次数
+	dirty atomic.Int64
+	saveMu sync.Mutex
+	saving bool
+	lastSave time.Time // 上次成功保存的时间
+	lastSaveTry time.Time
+	lastSaveOK bool
+	savePolicies []savePolicy
+	closing chan struct{}
+	closeOnce sync.Once
 }
 
 func NewStandaloneDatabase() *StandaloneDatabase {
-	database := &StandaloneDatabase{}
+	database := &StandaloneDatabase{
+		hub: pubsub.MakeHub(),
+		lastSave: time.Now(),
+		lastSaveOK: true,
+		closing: make(chan struct{}),
+	}
+	database.tracking = tracking.MakeTable(database.hub)
 	if config.Properties.Databases <= 0 {
 		config.Properties.Databases = 16
 	}
@@ -28,20 +54,53 @@ func NewStandaloneDatabase() *StandaloneDatabase {
 		database.dbSet[i] = db
 	}
 
+	notifyFlags, err := parseNotifyFlags(config.Properties.NotifyKeyspaceEvents)
+	if err != nil {
+		logger.Error(err)
+	}
+	for _, db := range database.dbSet {
+		db.tracking = database.tracking
+		db.getDB = func(index int) *DB {
+			if index < 0 || index >= len(database.dbSet) {
+				return nil
+			}
+			return database.dbSet[index]
+		}
+		db.notifyFlags = notifyFlags
+		db.publish = func(channel string, message []byte) {
+			database.hub.PublishChannel(channel, message)
+		}
+	}
+
+	// 写命令都会调用addAof，借此统计修改次数
+	for _, db := range database.dbSet {
+		idb := db
+		idb.addAof = func(lines ...CmdLine) {
+			database.dirty.Add(int64(len(lines)))
+			if database.aofHandler != nil {
+				database.aofHandler.AddAof(idb.index, lines...)
+			}
+		}
+	}
 	if config.Properties.AppendOnly {
 		aofHandler ,err := aof.NewAofHandler(database)
 		if err != nil {
 			panic(err)
 		}
 		database.aofHandler = aofHandler
-		for _, db := range database.dbSet {
-			idb := db
-			idb.addAof = func(line CmdLine) {
-				database.aofHandler.AddAof(idb.index, line)
-			}
-		}
+	} else if err := database.loadRDB(); err != nil {
+		panic(err)
 	}
+	// 加载数据产生的修改不需要再保存
+	database.dirty.Store(0)
 
+	database.savePolicies, err = parseSavePolicies(config.Properties.Save)
+	if err != nil {
+		logger.Error(err)
+	}
+	if len(database.savePolicies) > 0 {
+		go database.saveCron(database.savePolicies)
+	}
 	return database
 }
 
@@ -52,9 +111,58 @@ func (d *StandaloneDatabase) Exec(client resp.Connection, args databaseface.CmdL
 		}
 	}()
 	cmd := strings.ToLower(string(args[0]))
+	if errReply := pubsub.CheckSubscribedMode(client, cmd); errReply != nil {
+		return errReply
+	}
+	defer d.tracking.AfterCommand(client, cmd)
+	switch cmd {
+	case "subscribe":
+		return pubsub.Subscribe(d.hub, client, args[1:])
+	case "unsubscribe":
+		return pubsub.UnSubscribe(d.hub, client, args[1:])
+	case "psubscribe":
+		return pubsub.PSubscribe(d.hub, client, args[1:])
+	case "punsubscribe":
+		return pubsub.PUnSubscribe(d.hub, client, args[1:])
+	case "ssubscribe":
+		return pubsub.SSubscribe(d.hub, client, args[1:])
+	case "sunsubscribe":
+		return pubsub.SUnSubscribe(d.hub, client, args[1:])
+	case "publish":
+		return pubsub.Publish(d.hub, args[1:])
+	case "spublish":
+		return pubsub.SPublish(d.hub, args[1:])
+	case "pubsub":
+		return pubsub.PubSub(d.hub, args[1:])
+	case "client":
+		return execClient(d, client, args[1:])
+	case "save":
+		return execSave(d, args[1:])
+	case "bgsave":
+		return execBgSave(d, args[1:])
+	case "lastsave":
+		return execLastSave(d, args[1:])
+	case "bgrewriteaof":
+		return execBgRewriteAof(d, args[1:])
+	case "info":
+		return execInfo(d, args[1:])
+	case "ping":
+		if client.SubsCount() > 0 {
+			return pubsub.Ping(client, args[1:])
+		}
+	}
 	if cmd == "select" {
 		if len(args) != 2 {
-			return reply.NewArgNumErrReply("select")
+			errReply := reply.NewArgNumErrReply("select")
+			if client.InMultiState() {
+				client.AddTxError(errReply)
+			}
+			return errReply
+		}
+		// 事务中的SELECT进入队列，EXEC时切换之后命令所在的DB
+		if client.InMultiState() {
+			client.EnqueueCmd(args)
+			return reply.NewQueuedReply()
 		}
 		return execSelect(client, d, args[1:])
 	}
@@ -64,12 +172,28 @@ func (d *StandaloneDatabase) Exec(client resp.Connection, args databaseface.CmdL
 	return db.Exec(client, args)
 }
 
+// GetHub 集群模式下需要把其他节点转来的分片消息发给本地订阅者
+func (d *StandaloneDatabase) GetHub() *pubsub.Hub {
+	return d.hub
+}
+
+// Close 把aof缓冲中的数据写入磁盘，配置了自动保存时，关闭前再保存一次
 func (d *StandaloneDatabase) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closing)
+		if d.aofHandler != nil {
+			d.aofHandler.Close()
+		}
+		if len(d.savePolicies) > 0 && d.dirty.Load() > 0 {
+			_ = d.save()
+		}
+	})
 	return nil
-
 }
 
 func (d *StandaloneDatabase) AfterClientClose(c resp.Connection) error {
+	d.hub.UnsubscribeAll(c)
+	d.tracking.Forget(c)
 	return nil
 }
 
@@ -0,0 +1,1167 @@
+package database
+
+import (
+	"go-redis/aof"
+	"go-redis/datastruct/stream"
+	databaseface "go-redis/interface/database"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	stream中自动生成的ID、消费者组的投递时间都依赖执行时的状态，
+	写入aof时记录执行结果而不是原命令，保证重放得到相同的数据：
+	XADD记录实际使用的ID，XREADGROUP记录为 XCLAIM ... FORCE JUSTID LASTID
+ */
+
+func init() {
+	RegisterCommand("xadd", execXAdd, writeFirstKey, -5)
+	RegisterCommand("xlen", execXLen, readFirstKey, 2)
+	RegisterCommand("xrange", execXRange, readFirstKey, -4)
+	RegisterCommand("xrevrange", execXRevRange, readFirstKey, -4)
+	RegisterCommand("xdel", execXDel, writeFirstKey, -3)
+	RegisterCommand("xtrim", execXTrim, writeFirstKey, -4)
+	RegisterCommand("xread", execXRead, prepareXRead, -4)
+	RegisterCommand("xgroup", execXGroup, prepareXGroup, -2)
+	RegisterCommand("xreadgroup", execXReadGroup, prepareXReadGroup, -7)
+	RegisterCommand("xack", execXAck, writeFirstKey, -4)
+	RegisterCommand("xpending", execXPending, readFirstKey, -3)
+	RegisterCommand("xclaim", execXClaim, writeFirstKey, -6)
+	RegisterBlockingCommand("xread", blockingXRead)
+	RegisterBlockingCommand("xreadgroup", blockingXReadGroup)
+}
+
+func (db *DB) getAsStream(key string) (*stream.Stream, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*stream.Stream)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return s, nil
+}
+
+// getStreamGroup 返回key对应stream中的消费者组，key或组不存在时返回NOGROUP错误
+func (db *DB) getStreamGroup(key string, groupName string) (*stream.Stream, *stream.Group, reply.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if s != nil {
+		if group := s.Group(groupName); group != nil {
+			return s, group, nil
+		}
+	}
+	return nil, nil, reply.NewErrReply("NOGROUP No such key '" + key + "' or consumer group '" + groupName + "'")
+}
+
+func parseStreamID(raw []byte) (stream.ID, reply.ErrorReply) {
+	id, err := stream.ParseID(string(raw), 0)
+	if err != nil {
+		return id, reply.NewErrReply(err.Error())
+	}
+	return id, nil
+}
+
+// parseRangeID 解析区间端点：- + 表示最小和最大ID，只有毫秒时起点序号取0、终点序号取最大值，
+// 以 ( 开头表示不包含该ID
+func parseRangeID(raw string, isStart bool) (stream.ID, reply.ErrorReply) {
+	switch raw {
+	case "-":
+		return stream.MinID, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+	exclusive := strings.HasPrefix(raw, "(")
+	if exclusive {
+		raw = raw[1:]
+	}
+	var defaultSeq uint64
+	if !isStart {
+		defaultSeq = math.MaxUint64
+	}
+	id, err := stream.ParseID(raw, defaultSeq)
+	if err != nil {
+		return id, reply.NewErrReply(err.Error())
+	}
+	if !exclusive {
+		return id, nil
+	}
+	ok := false
+	if isStart {
+		id, ok = id.Next()
+		if !ok {
+			return id, reply.NewErrReply("ERR invalid start ID for the interval")
+		}
+	} else {
+		id, ok = id.Prev()
+		if !ok {
+			return id, reply.NewErrReply("ERR invalid end ID for the interval")
+		}
+	}
+	return id, nil
+}
+
+func streamEntryToReply(entry *stream.Entry) resp.Reply {
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(entry.ID.String())),
+		reply.NewMultiBulkReply(entry.Fields),
+	})
+}
+
+func streamEntriesToReply(entries []*stream.Entry) resp.Reply {
+	result := make([]resp.Reply, len(entries))
+	for i, entry := range entries {
+		result[i] = streamEntryToReply(entry)
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// streamReadToReply XREAD/XREADGROUP中每个key的结果：[key, [entry ...]]
+func streamReadToReply(key string, entries resp.Reply) resp.Reply {
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(key)),
+		entries,
+	})
+}
+
+/* ---- trim ---- */
+
+// streamTrim XADD和XTRIM的裁剪参数
+type streamTrim struct {
+	byMinID bool
+	maxLen  int
+	minID   stream.ID
+	limit   int // 最多删除的数量，0表示不限制
+}
+
+// parseStreamTrim 解析 MAXLEN|MINID [=|~] threshold [LIMIT count]，返回最后一个被解析参数的下标
+// 近似裁剪 ~ 也按精确裁剪处理，只是允许使用LIMIT
+func parseStreamTrim(args [][]byte, i int) (*streamTrim, int, reply.ErrorReply) {
+	trim := &streamTrim{
+		byMinID: strings.ToUpper(string(args[i])) == "MINID",
+	}
+	i++
+	approx := false
+	if i < len(args) {
+		switch string(args[i]) {
+		case "=":
+			i++
+		case "~":
+			approx = true
+			i++
+		}
+	}
+	if i >= len(args) {
+		return nil, i, reply.NewSyntaxErrReply()
+	}
+	if trim.byMinID {
+		minID, errReply := parseStreamID(args[i])
+		if errReply != nil {
+			return nil, i, errReply
+		}
+		trim.minID = minID
+	} else {
+		maxLen, err := strconv.ParseInt(string(args[i]), 10, 64)
+		if err != nil {
+			return nil, i, reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return nil, i, reply.NewErrReply("ERR The MAXLEN argument must be >= 0.")
+		}
+		trim.maxLen = int(maxLen)
+	}
+	if i+1 < len(args) && strings.ToUpper(string(args[i+1])) == "LIMIT" {
+		if i+2 >= len(args) {
+			return nil, i, reply.NewSyntaxErrReply()
+		}
+		limit, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+		if err != nil {
+			return nil, i, reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		if limit < 0 {
+			return nil, i, reply.NewErrReply("ERR The LIMIT argument must be >= 0.")
+		}
+		if !approx {
+			return nil, i, reply.NewErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		trim.limit = int(limit)
+		i += 2
+	}
+	return trim, i, nil
+}
+
+func (trim *streamTrim) apply(s *stream.Stream) int {
+	if trim.byMinID {
+		return s.TrimMinID(trim.minID, trim.limit)
+	}
+	return s.TrimMaxLen(trim.maxLen, trim.limit)
+}
+
+/* ---- commands ---- */
+
+// XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func execXAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	noMkStream := false
+	var trim *streamTrim
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "NOMKSTREAM" {
+			noMkStream = true
+			continue
+		}
+		if option == "MAXLEN" || option == "MINID" {
+			var errReply reply.ErrorReply
+			trim, i, errReply = parseStreamTrim(args, i)
+			if errReply != nil {
+				return errReply
+			}
+			continue
+		}
+		break
+	}
+	if i >= len(args) || len(args[i+1:]) == 0 || len(args[i+1:])%2 != 0 {
+		return reply.NewArgNumErrReply("xadd")
+	}
+	rawID := string(args[i])
+	fields := args[i+1:]
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	created := false
+	if s == nil {
+		s = stream.New()
+		created = true
+	}
+
+	var id stream.ID
+	ok := false
+	switch {
+	case rawID == "*":
+		id, ok = s.NextID(uint64(time.Now().UnixMilli()))
+		if !ok {
+			return reply.NewErrReply("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+	case strings.HasSuffix(rawID, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(rawID, "-*"), 10, 64)
+		if err != nil {
+			return reply.NewErrReply(stream.ErrInvalidID.Error())
+		}
+		id, ok = s.NextSeqID(ms)
+	default:
+		id, errReply = parseStreamID(args[i])
+		if errReply != nil {
+			return errReply
+		}
+		if id == stream.MinID {
+			return reply.NewErrReply("ERR The ID specified in XADD must be greater than 0-0")
+		}
+		ok = s.LastID().Less(id)
+	}
+	if created && noMkStream {
+		return reply.NewNullBulkReply()
+	}
+	if !ok {
+		return reply.NewErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	}
+
+	s.Add(id, fields)
+	if created {
+		db.PutEntity(key, &databaseface.DataEntity{
+			Data: s,
+		})
+	}
+	if trim != nil {
+		trim.apply(s)
+	}
+	// 裁剪的结果只取决于数据，原样记录即可，只需把ID换成实际使用的ID
+	cmdLine := utils.ToCmdLine3("xadd", args...)
+	cmdLine[i+1] = []byte(id.String())
+	db.addAof(cmdLine)
+	return reply.NewBulkReply([]byte(id.String()))
+}
+
+// XLEN key
+func execXLen(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.NewIntReply(0)
+	}
+	return reply.NewIntReply(int64(s.Len()))
+}
+
+func streamRange(db *DB, args [][]byte, reverse bool) resp.Reply {
+	// XREVRANGE的参数是 end start
+	rawStart, rawEnd := string(args[1]), string(args[2])
+	if reverse {
+		rawStart, rawEnd = rawEnd, rawStart
+	}
+	start, errReply := parseRangeID(rawStart, true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(rawEnd, false)
+	if errReply != nil {
+		return errReply
+	}
+	count := 0
+	if len(args) > 3 {
+		if len(args) != 5 || strings.ToUpper(string(args[3])) != "COUNT" {
+			return reply.NewSyntaxErrReply()
+		}
+		n, err := strconv.ParseInt(string(args[4]), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		if n <= 0 {
+			return reply.NewNullMultiBulkReply()
+		}
+		count = int(n)
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil || end.Less(start) {
+		return reply.NewNullMultiBulkReply()
+	}
+	if reverse {
+		return streamEntriesToReply(s.RevRange(start, end, count))
+	}
+	return streamEntriesToReply(s.Range(start, end, count))
+}
+
+// XRANGE key start end [COUNT count]
+func execXRange(db *DB, args [][]byte) resp.Reply {
+	return streamRange(db, args, false)
+}
+
+// XREVRANGE key end start [COUNT count]
+func execXRevRange(db *DB, args [][]byte) resp.Reply {
+	return streamRange(db, args, true)
+}
+
+// XDEL key id [id ...]
+func execXDel(db *DB, args [][]byte) resp.Reply {
+	ids := make([]stream.ID, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		id, errReply := parseStreamID(arg)
+		if errReply != nil {
+			return errReply
+		}
+		ids = append(ids, id)
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.NewIntReply(0)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine3("xdel", args...))
+	}
+	return reply.NewIntReply(int64(deleted))
+}
+
+// XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+func execXTrim(db *DB, args [][]byte) resp.Reply {
+	strategy := strings.ToUpper(string(args[1]))
+	if strategy != "MAXLEN" && strategy != "MINID" {
+		return reply.NewSyntaxErrReply()
+	}
+	trim, last, errReply := parseStreamTrim(args, 1)
+	if errReply != nil {
+		return errReply
+	}
+	if last != len(args)-1 {
+		return reply.NewSyntaxErrReply()
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.NewIntReply(0)
+	}
+	removed := trim.apply(s)
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("xtrim", args...))
+	}
+	return reply.NewIntReply(int64(removed))
+}
+
+/* ---- XREAD ---- */
+
+type xreadOptions struct {
+	group    string
+	consumer string
+	count    int // 0表示不限制
+	block    time.Duration
+	blocking bool
+	noAck    bool
+	keys     []string
+	ids      []string
+}
+
+// parseXReadArgs 解析XREAD和XREADGROUP的参数，withGroup为true时参数以 GROUP group consumer 开头
+func parseXReadArgs(args [][]byte, withGroup bool) (*xreadOptions, reply.ErrorReply) {
+	opts := &xreadOptions{}
+	i := 0
+	if withGroup {
+		if len(args) < 3 || strings.ToUpper(string(args[0])) != "GROUP" {
+			return nil, reply.NewSyntaxErrReply()
+		}
+		opts.group, opts.consumer = string(args[1]), string(args[2])
+		i = 3
+	}
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "COUNT" && i+1 < len(args):
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			if count > 0 {
+				opts.count = int(count)
+			}
+			i++
+		case option == "BLOCK" && i+1 < len(args):
+			ms, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, reply.NewErrReply("ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return nil, reply.NewErrReply("ERR timeout is negative")
+			}
+			opts.block = time.Duration(ms) * time.Millisecond
+			opts.blocking = true
+			i++
+		case option == "NOACK" && withGroup:
+			opts.noAck = true
+		case option == "STREAMS":
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				if withGroup {
+					return nil, reply.NewErrReply("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+				}
+				return nil, reply.NewErrReply("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+			}
+			n := len(rest) / 2
+			for j := 0; j < n; j++ {
+				opts.keys = append(opts.keys, string(rest[j]))
+				opts.ids = append(opts.ids, string(rest[n+j]))
+			}
+			return opts, nil
+		default:
+			return nil, reply.NewSyntaxErrReply()
+		}
+	}
+	return nil, reply.NewSyntaxErrReply()
+}
+
+func prepareXRead(args [][]byte) ([]string, []string) {
+	opts, errReply := parseXReadArgs(args, false)
+	if errReply != nil {
+		return nil, nil
+	}
+	return nil, opts.keys
+}
+
+func prepareXReadGroup(args [][]byte) ([]string, []string) {
+	opts, errReply := parseXReadArgs(args, true)
+	if errReply != nil {
+		return nil, nil
+	}
+	return opts.keys, nil
+}
+
+// resolveXReadIDs 解析XREAD的ID，$ 表示stream当前最后的ID
+// 阻塞读取时只在第一次尝试时解析，之后一直等待比它更新的消息
+func (db *DB) resolveXReadIDs(opts *xreadOptions) ([]stream.ID, reply.ErrorReply) {
+	ids := make([]stream.ID, len(opts.ids))
+	for i, raw := range opts.ids {
+		switch raw {
+		case "$":
+			s, errReply := db.getAsStream(opts.keys[i])
+			if errReply != nil {
+				return nil, errReply
+			}
+			if s != nil {
+				ids[i] = s.LastID()
+			}
+		case ">":
+			return nil, reply.NewErrReply("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		default:
+			id, errReply := parseStreamID([]byte(raw))
+			if errReply != nil {
+				return nil, errReply
+			}
+			ids[i] = id
+		}
+	}
+	return ids, nil
+}
+
+// xreadOnce 读取每个stream中ID大于ids[i]的消息，都没有消息时返回nil
+func (db *DB) xreadOnce(opts *xreadOptions, ids []stream.ID) resp.Reply {
+	result := make([]resp.Reply, 0)
+	for i, key := range opts.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			continue
+		}
+		start, ok := ids[i].Next()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, stream.MaxID, opts.count)
+		if len(entries) == 0 {
+			continue
+		}
+		result = append(result, streamReadToReply(key, streamEntriesToReply(entries)))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+// 在事务中执行时忽略BLOCK
+func execXRead(db *DB, args [][]byte) resp.Reply {
+	opts, errReply := parseXReadArgs(args, false)
+	if errReply != nil {
+		return errReply
+	}
+	ids, errReply := db.resolveXReadIDs(opts)
+	if errReply != nil {
+		return errReply
+	}
+	if result := db.xreadOnce(opts, ids); result != nil {
+		return result
+	}
+	return reply.NewNullArrayReply()
+}
+
+func blockingXRead(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	opts, errReply := parseXReadArgs(cmdLine[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	if !opts.blocking {
+		return db.execNormalCommand(c, cmdLine)
+	}
+	var ids []stream.ID
+	result := db.execBlocking(c, opts.keys, opts.block, func(*waiter) resp.Reply {
+		db.locker.RWLocks(nil, opts.keys)
+		defer db.locker.RWUnLocks(nil, opts.keys)
+		if ids == nil {
+			var errReply reply.ErrorReply
+			ids, errReply = db.resolveXReadIDs(opts)
+			if errReply != nil {
+				return errReply
+			}
+		}
+		result := db.xreadOnce(opts, ids)
+		if result != nil {
+			db.trackKeys(c, nil, opts.keys)
+		}
+		return result
+	})
+	if result == nil {
+		return reply.NewNullArrayReply()
+	}
+	return result
+}
+
+/* ---- consumer group ---- */
+
+// xreadGroupOnce 为消费者读取消息，> 表示读取从未投递给组内消费者的新消息，
+// 其他ID表示读取该消费者待确认列表中ID更大的消息。都没有消息时返回nil
+func (db *DB) xreadGroupOnce(opts *xreadOptions) resp.Reply {
+	// 先检查所有参数，避免修改了部分stream之后才发现错误
+	streams := make([]*stream.Stream, len(opts.keys))
+	groups := make([]*stream.Group, len(opts.keys))
+	historyIDs := make([]stream.ID, len(opts.keys))
+	for i, key := range opts.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s != nil {
+			groups[i] = s.Group(opts.group)
+		}
+		if groups[i] == nil {
+			return reply.NewErrReply("NOGROUP No such key '" + key + "' or consumer group '" + opts.group + "' in XREADGROUP with GROUP option")
+		}
+		streams[i] = s
+		switch opts.ids[i] {
+		case ">":
+		case "$":
+			return reply.NewErrReply("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+		default:
+			id, errReply := parseStreamID([]byte(opts.ids[i]))
+			if errReply != nil {
+				return errReply
+			}
+			historyIDs[i] = id
+		}
+	}
+
+	now := time.Now()
+	result := make([]resp.Reply, 0)
+	for i, key := range opts.keys {
+		s, group := streams[i], groups[i]
+		consumer, created := group.CreateConsumer(opts.consumer, now)
+		consumer.SeenTime = now
+		if created {
+			db.addAof(utils.ToCmdLine("xgroup", "createconsumer", key, group.Name, consumer.Name))
+		}
+
+		if opts.ids[i] != ">" {
+			// 读取历史消息不会改变投递状态，也不会阻塞
+			entries := make([]resp.Reply, 0)
+			start, ok := historyIDs[i].Next()
+			if ok {
+				for _, pending := range consumer.PendingAfter(start, opts.count) {
+					entry := s.Get(pending.ID)
+					if entry == nil {
+						// 消息已经被XDEL删除
+						entries = append(entries, reply.NewMultiRawReply([]resp.Reply{
+							reply.NewBulkReply([]byte(pending.ID.String())),
+							reply.NewNullArrayReply(),
+						}))
+						continue
+					}
+					entries = append(entries, streamEntryToReply(entry))
+				}
+			}
+			result = append(result, streamReadToReply(key, reply.NewMultiRawReply(entries)))
+			continue
+		}
+
+		start, ok := group.LastID.Next()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, stream.MaxID, opts.count)
+		if len(entries) == 0 {
+			continue
+		}
+		group.LastID = entries[len(entries)-1].ID
+		if opts.noAck {
+			db.addAof(utils.ToCmdLine("xgroup", "setid", key, group.Name, group.LastID.String()))
+		} else {
+			aofLines := make([]CmdLine, 0, len(entries))
+			for _, entry := range entries {
+				pending := group.AddPending(entry.ID, consumer, now, 1)
+				aofLines = append(aofLines, aof.XClaimCmdLine(key, group, pending))
+			}
+			db.addAof(aofLines...)
+		}
+		result = append(result, streamReadToReply(key, streamEntriesToReply(entries)))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+// 在事务中执行时忽略BLOCK
+func execXReadGroup(db *DB, args [][]byte) resp.Reply {
+	opts, errReply := parseXReadArgs(args, true)
+	if errReply != nil {
+		return errReply
+	}
+	if result := db.xreadGroupOnce(opts); result != nil {
+		return result
+	}
+	return reply.NewNullArrayReply()
+}
+
+func blockingXReadGroup(db *DB, c resp.Connection, cmdLine CmdLine) resp.Reply {
+	opts, errReply := parseXReadArgs(cmdLine[1:], true)
+	if errReply != nil {
+		return errReply
+	}
+	if !opts.blocking {
+		return db.execNormalCommand(c, cmdLine)
+	}
+	result := db.execBlocking(c, opts.keys, opts.block, func(*waiter) resp.Reply {
+		db.locker.RWLocks(opts.keys, nil)
+		defer db.locker.RWUnLocks(opts.keys, nil)
+		db.beforeWrite(opts.keys...)
+		result := db.xreadGroupOnce(opts)
+		if result != nil {
+			db.addVersion(opts.keys...)
+			db.trackKeys(c, opts.keys, nil)
+		}
+		return result
+	})
+	if result == nil {
+		return reply.NewNullArrayReply()
+	}
+	return result
+}
+
+func prepareXGroup(args [][]byte) ([]string, []string) {
+	if len(args) < 2 {
+		return nil, nil
+	}
+	return []string{string(args[1])}, nil
+}
+
+// XGROUP CREATE|SETID|DESTROY|CREATECONSUMER|DELCONSUMER key group ...
+func execXGroup(db *DB, args [][]byte) resp.Reply {
+	subCommand := strings.ToLower(string(args[0]))
+	switch subCommand {
+	case "create":
+		if len(args) < 4 {
+			return reply.NewArgNumErrReply("xgroup|create")
+		}
+		return execXGroupCreate(db, args[1:])
+	case "setid":
+		if len(args) < 4 {
+			return reply.NewArgNumErrReply("xgroup|setid")
+		}
+		return execXGroupSetID(db, args[1:])
+	case "destroy":
+		if len(args) != 3 {
+			return reply.NewArgNumErrReply("xgroup|destroy")
+		}
+		return execXGroupDestroy(db, args[1:])
+	case "createconsumer":
+		if len(args) != 4 {
+			return reply.NewArgNumErrReply("xgroup|createconsumer")
+		}
+		return execXGroupCreateConsumer(db, args[1:])
+	case "delconsumer":
+		if len(args) != 4 {
+			return reply.NewArgNumErrReply("xgroup|delconsumer")
+		}
+		return execXGroupDelConsumer(db, args[1:])
+	}
+	return reply.NewErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+}
+
+func newXGroupNoKeyErrReply() reply.ErrorReply {
+	return reply.NewErrReply("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+}
+
+// parseXGroupOptions 解析CREATE和SETID的可选参数；本实现不统计entries-read，ENTRIESREAD只做校验
+func parseXGroupOptions(args [][]byte, allowMkStream bool) (mkStream bool, errReply reply.ErrorReply) {
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "MKSTREAM" && allowMkStream:
+			mkStream = true
+		case option == "ENTRIESREAD" && i+1 < len(args):
+			if _, err := strconv.ParseInt(string(args[i+1]), 10, 64); err != nil {
+				return false, reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			i++
+		default:
+			return false, reply.NewSyntaxErrReply()
+		}
+	}
+	return mkStream, nil
+}
+
+// resolveGroupID 解析消费者组的ID，$ 表示stream当前最后的ID
+func resolveGroupID(s *stream.Stream, raw []byte) (stream.ID, reply.ErrorReply) {
+	if string(raw) == "$" {
+		return s.LastID(), nil
+	}
+	return parseStreamID(raw)
+}
+
+// XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD entries-read]
+func execXGroupCreate(db *DB, args [][]byte) resp.Reply {
+	key, groupName := string(args[0]), string(args[1])
+	mkStream, errReply := parseXGroupOptions(args[3:], true)
+	if errReply != nil {
+		return errReply
+	}
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	created := false
+	if s == nil {
+		if !mkStream {
+			return newXGroupNoKeyErrReply()
+		}
+		s = stream.New()
+		created = true
+	}
+	id, errReply := resolveGroupID(s, args[2])
+	if errReply != nil {
+		return errReply
+	}
+	if _, ok := s.CreateGroup(groupName, id); !ok {
+		return reply.NewErrReply("BUSYGROUP Consumer Group name already exists")
+	}
+	if created {
+		db.PutEntity(key, &databaseface.DataEntity{
+			Data: s,
+		})
+	}
+	// stream已存在时MKSTREAM不起作用，统一带上
+	db.addAof(utils.ToCmdLine("xgroup", "create", key, groupName, id.String(), "mkstream"))
+	return reply.NewOkReply()
+}
+
+// getXGroup XGROUP的子命令要求key存在，组不存在时返回NOGROUP
+func (db *DB) getXGroup(key string, groupName string) (*stream.Stream, *stream.Group, reply.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if s == nil {
+		return nil, nil, newXGroupNoKeyErrReply()
+	}
+	group := s.Group(groupName)
+	if group == nil {
+		return s, nil, reply.NewErrReply("NOGROUP No such consumer group '" + groupName + "' for key name '" + key + "'")
+	}
+	return s, group, nil
+}
+
+// XGROUP SETID key group id|$ [ENTRIESREAD entries-read]
+func execXGroupSetID(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	if _, errReply := parseXGroupOptions(args[3:], false); errReply != nil {
+		return errReply
+	}
+	s, group, errReply := db.getXGroup(key, string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	id, errReply := resolveGroupID(s, args[2])
+	if errReply != nil {
+		return errReply
+	}
+	group.LastID = id
+	db.addAof(utils.ToCmdLine("xgroup", "setid", key, group.Name, id.String()))
+	return reply.NewOkReply()
+}
+
+// XGROUP DESTROY key group
+func execXGroupDestroy(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return newXGroupNoKeyErrReply()
+	}
+	if !s.DestroyGroup(string(args[1])) {
+		return reply.NewIntReply(0)
+	}
+	db.addAof(utils.ToCmdLine3("xgroup", append([][]byte{[]byte("destroy")}, args...)...))
+	return reply.NewIntReply(1)
+}
+
+// XGROUP CREATECONSUMER key group consumer
+func execXGroupCreateConsumer(db *DB, args [][]byte) resp.Reply {
+	_, group, errReply := db.getXGroup(string(args[0]), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	if _, created := group.CreateConsumer(string(args[2]), time.Now()); !created {
+		return reply.NewIntReply(0)
+	}
+	db.addAof(utils.ToCmdLine3("xgroup", append([][]byte{[]byte("createconsumer")}, args...)...))
+	return reply.NewIntReply(1)
+}
+
+// XGROUP DELCONSUMER key group consumer
+// 返回被删除的消费者还有多少条待确认消息
+func execXGroupDelConsumer(db *DB, args [][]byte) resp.Reply {
+	_, group, errReply := db.getXGroup(string(args[0]), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	pending, ok := group.DeleteConsumer(string(args[2]))
+	if ok {
+		db.addAof(utils.ToCmdLine3("xgroup", append([][]byte{[]byte("delconsumer")}, args...)...))
+	}
+	return reply.NewIntReply(int64(pending))
+}
+
+// XACK key group id [id ...]
+func execXAck(db *DB, args [][]byte) resp.Reply {
+	ids := make([]stream.ID, 0, len(args)-2)
+	for _, arg := range args[2:] {
+		id, errReply := parseStreamID(arg)
+		if errReply != nil {
+			return errReply
+		}
+		ids = append(ids, id)
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.NewIntReply(0)
+	}
+	group := s.Group(string(args[1]))
+	if group == nil {
+		return reply.NewIntReply(0)
+	}
+	acked := 0
+	for _, id := range ids {
+		if group.Ack(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		db.addAof(utils.ToCmdLine3("xack", args...))
+	}
+	return reply.NewIntReply(int64(acked))
+}
+
+// XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func execXPending(db *DB, args [][]byte) resp.Reply {
+	_, group, errReply := db.getStreamGroup(string(args[0]), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 2 {
+		return xpendingSummary(group)
+	}
+
+	i := 2
+	var minIdle time.Duration
+	if strings.ToUpper(string(args[i])) == "IDLE" {
+		if len(args) < 4 {
+			return reply.NewSyntaxErrReply()
+		}
+		ms, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+		minIdle = time.Duration(ms) * time.Millisecond
+		i = 4
+	}
+	if rest := len(args) - i; rest != 3 && rest != 4 {
+		return reply.NewSyntaxErrReply()
+	}
+	start, errReply := parseRangeID(string(args[i]), true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(string(args[i+1]), false)
+	if errReply != nil {
+		return errReply
+	}
+	count, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	consumerName := ""
+	if len(args) > i+3 {
+		consumerName = string(args[i+3])
+	}
+	if count <= 0 || end.Less(start) {
+		return reply.NewNullMultiBulkReply()
+	}
+
+	now := time.Now()
+	pendingList := group.PendingRange(start, end, int(count), func(pending *stream.PendingEntry) bool {
+		if consumerName != "" && pending.Consumer.Name != consumerName {
+			return false
+		}
+		return now.Sub(pending.DeliveryTime) >= minIdle
+	})
+	result := make([]resp.Reply, len(pendingList))
+	for j, pending := range pendingList {
+		result[j] = reply.NewMultiRawReply([]resp.Reply{
+			reply.NewBulkReply([]byte(pending.ID.String())),
+			reply.NewBulkReply([]byte(pending.Consumer.Name)),
+			reply.NewIntReply(now.Sub(pending.DeliveryTime).Milliseconds()),
+			reply.NewIntReply(int64(pending.DeliveryCount)),
+		})
+	}
+	return reply.NewMultiRawReply(result)
+}
+
+// xpendingSummary [待确认数量, 最小ID, 最大ID, [[consumer, 数量] ...]]
+func xpendingSummary(group *stream.Group) resp.Reply {
+	pendingList := group.PendingRange(stream.MinID, stream.MaxID, 0, func(*stream.PendingEntry) bool {
+		return true
+	})
+	if len(pendingList) == 0 {
+		return reply.NewMultiRawReply([]resp.Reply{
+			reply.NewIntReply(0),
+			reply.NewNullBulkReply(),
+			reply.NewNullBulkReply(),
+			reply.NewNullArrayReply(),
+		})
+	}
+	consumers := make([]resp.Reply, 0)
+	for _, consumer := range group.Consumers() {
+		if consumer.PendingLen() == 0 {
+			continue
+		}
+		consumers = append(consumers, reply.NewMultiBulkReply([][]byte{
+			[]byte(consumer.Name),
+			[]byte(strconv.Itoa(consumer.PendingLen())),
+		}))
+	}
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewIntReply(int64(len(pendingList))),
+		reply.NewBulkReply([]byte(pendingList[0].ID.String())),
+		reply.NewBulkReply([]byte(pendingList[len(pendingList)-1].ID.String())),
+		reply.NewMultiRawReply(consumers),
+	})
+}
+
+// XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+// [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func execXClaim(db *DB, args [][]byte) resp.Reply {
+	key, groupName, consumerName := string(args[0]), string(args[1]), string(args[2])
+	minIdleMs, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	minIdle := time.Duration(minIdleMs) * time.Millisecond
+
+	firstID, errReply := parseStreamID(args[4])
+	if errReply != nil {
+		return errReply
+	}
+	ids := []stream.ID{firstID}
+	i := 5
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	now := time.Now()
+	deliveryTime := now
+	retryCount := int64(-1)
+	force, justID := false, false
+	var lastID *stream.ID
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "FORCE":
+			force = true
+		case option == "JUSTID":
+			justID = true
+		case (option == "IDLE" || option == "TIME" || option == "RETRYCOUNT") && i+1 < len(args):
+			value, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			switch option {
+			case "IDLE":
+				deliveryTime = now.Add(-time.Duration(value) * time.Millisecond)
+			case "TIME":
+				deliveryTime = time.UnixMilli(value)
+			case "RETRYCOUNT":
+				if value < 0 {
+					return reply.NewErrReply("ERR value is out of range, must be positive")
+				}
+				retryCount = value
+			}
+			i++
+		case option == "LASTID" && i+1 < len(args):
+			id, errReply := parseStreamID(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			lastID = &id
+			i++
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	s, group, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	aofLines := make([]CmdLine, 0)
+	if lastID != nil && group.LastID.Less(*lastID) {
+		group.LastID = *lastID
+		aofLines = append(aofLines, utils.ToCmdLine("xgroup", "setid", key, groupName, lastID.String()))
+	}
+	consumer, created := group.CreateConsumer(consumerName, now)
+	consumer.SeenTime = now
+	if created {
+		aofLines = append(aofLines, utils.ToCmdLine("xgroup", "createconsumer", key, groupName, consumerName))
+	}
+
+	result := make([]resp.Reply, 0, len(ids))
+	for _, id := range ids {
+		pending := group.Pending(id)
+		entry := s.Get(id)
+		var deliveryCount uint64
+		if pending == nil {
+			// 只有FORCE时才会认领不在待确认列表中的消息
+			if !force || entry == nil {
+				continue
+			}
+			deliveryCount = 1
+		} else {
+			if entry == nil {
+				// 消息已经被XDEL删除，从待确认列表中清除
+				group.Ack(id)
+				aofLines = append(aofLines, utils.ToCmdLine("xack", key, groupName, id.String()))
+				continue
+			}
+			if now.Sub(pending.DeliveryTime) < minIdle {
+				continue
+			}
+			deliveryCount = pending.DeliveryCount
+		}
+		if retryCount >= 0 {
+			deliveryCount = uint64(retryCount)
+		} else if !justID {
+			deliveryCount++
+		}
+		pending = group.AddPending(id, consumer, deliveryTime, deliveryCount)
+		aofLines = append(aofLines, aof.XClaimCmdLine(key, group, pending))
+		if justID {
+			result = append(result, reply.NewBulkReply([]byte(id.String())))
+		} else {
+			result = append(result, streamEntryToReply(entry))
+		}
+	}
+	if len(aofLines) > 0 {
+		db.addAof(aofLines...)
+	}
+	return reply.NewMultiRawReply(result)
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"testing"
+	"time"
+)
+
+func assertReply(t *testing.T, result resp.Reply, expected resp.Reply) {
+	t.Helper()
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+}
+
+func makeEntryReply(id string, fields ...string) resp.Reply {
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(id)),
+		reply.NewMultiBulkReply(utils.ToCmdLine(fields...)),
+	})
+}
+
+func TestXAddAndRange(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(key, "1-1", "a", "1")), reply.NewBulkReply([]byte("1-1")))
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(key, "1-*", "b", "2")), reply.NewBulkReply([]byte("1-2")))
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(key, "2", "c", "3")), reply.NewBulkReply([]byte("2-0")))
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(key, "2-0", "d", "4")),
+		reply.NewErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item"))
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(key, "0-0", "d", "4")),
+		reply.NewErrReply("ERR The ID specified in XADD must be greater than 0-0"))
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(key, "3-0", "d")), reply.NewArgNumErrReply("xadd"))
+	// 自动生成的ID大于已有的ID
+	id := execXAdd(testDB, utils.ToCmdLine(key, "*", "d", "4")).(*reply.BulkReply).Arg
+	assertIntReply(t, execXLen(testDB, utils.ToCmdLine(key)), 4)
+
+	assertReply(t, execXRange(testDB, utils.ToCmdLine(key, "-", "2")), reply.NewMultiRawReply([]resp.Reply{
+		makeEntryReply("1-1", "a", "1"),
+		makeEntryReply("1-2", "b", "2"),
+		makeEntryReply("2-0", "c", "3"),
+	}))
+	assertReply(t, execXRange(testDB, utils.ToCmdLine(key, "(1-1", "+", "COUNT", "1")), reply.NewMultiRawReply([]resp.Reply{
+		makeEntryReply("1-2", "b", "2"),
+	}))
+	assertReply(t, execXRevRange(testDB, utils.ToCmdLine(key, "+", "1-2", "COUNT", "2")), reply.NewMultiRawReply([]resp.Reply{
+		makeEntryReply(string(id), "d", "4"),
+		makeEntryReply("2-0", "c", "3"),
+	}))
+
+	assertIntReply(t, execXDel(testDB, utils.ToCmdLine(key, "1-2", "9-9")), 1)
+	assertIntReply(t, execXTrim(testDB, utils.ToCmdLine(key, "MAXLEN", "=", "2")), 1)
+	assertIntReply(t, execXLen(testDB, utils.ToCmdLine(key)), 2)
+	assertReply(t, execXTrim(testDB, utils.ToCmdLine(key, "MAXLEN", "0", "LIMIT", "1")),
+		reply.NewErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option"))
+
+	if _, ok := execXAdd(testDB, utils.ToCmdLine(key, "MAXLEN", "1", "*", "e", "5")).(*reply.BulkReply); !ok {
+		t.Error("xadd with MAXLEN failed")
+	}
+	assertIntReply(t, execXLen(testDB, utils.ToCmdLine(key)), 1)
+	assertReply(t, execType(testDB, utils.ToCmdLine(key)), reply.NewStatusReply("stream"))
+
+	missing := utils.RandString(10)
+	assertReply(t, execXAdd(testDB, utils.ToCmdLine(missing, "NOMKSTREAM", "*", "a", "1")), reply.NewNullBulkReply())
+	assertIntReply(t, execExist(testDB, utils.ToCmdLine(missing)), 0)
+}
+
+func TestXRead(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1, key2 := utils.RandString(10), utils.RandString(10)
+	execXAdd(testDB, utils.ToCmdLine(key1, "1-0", "a", "1"))
+	execXAdd(testDB, utils.ToCmdLine(key1, "2-0", "b", "2"))
+	execXAdd(testDB, utils.ToCmdLine(key2, "1-0", "c", "3"))
+	result := testDB.Exec(nil, utils.ToCmdLine("xread", "COUNT", "1", "STREAMS", key1, key2, "0", "1"))
+	assertReply(t, result, reply.NewMultiRawReply([]resp.Reply{
+		streamReadToReply(key1, reply.NewMultiRawReply([]resp.Reply{makeEntryReply("1-0", "a", "1")})),
+	}))
+	result = testDB.Exec(nil, utils.ToCmdLine("xread", "STREAMS", key1, "$"))
+	assertReply(t, result, reply.NewNullArrayReply())
+	result = testDB.Exec(nil, utils.ToCmdLine("xread", "BLOCK", "10", "STREAMS", key1, "$"))
+	assertReply(t, result, reply.NewNullArrayReply())
+
+	// BLOCK 0 一直等待，直到其他客户端写入
+	done := make(chan resp.Reply)
+	go func() {
+		done <- testDB.Exec(nil, utils.ToCmdLine("xread", "BLOCK", "0", "STREAMS", key1, "$"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	testDB.Exec(nil, utils.ToCmdLine("xadd", key1, "3-0", "d", "4"))
+	select {
+	case result = <-done:
+		assertReply(t, result, reply.NewMultiRawReply([]resp.Reply{
+			streamReadToReply(key1, reply.NewMultiRawReply([]resp.Reply{makeEntryReply("3-0", "d", "4")})),
+		}))
+	case <-time.After(time.Second):
+		t.Error("blocking xread was not woken up")
+	}
+}
+
+func TestStreamGroup(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	assertReply(t, execXGroup(testDB, utils.ToCmdLine("create", key, "g", "$")), newXGroupNoKeyErrReply())
+	assertReply(t, execXGroup(testDB, utils.ToCmdLine("create", key, "g", "$", "MKSTREAM")), reply.NewOkReply())
+	assertReply(t, execXGroup(testDB, utils.ToCmdLine("create", key, "g", "0")),
+		reply.NewErrReply("BUSYGROUP Consumer Group name already exists"))
+	execXAdd(testDB, utils.ToCmdLine(key, "1-0", "a", "1"))
+	execXAdd(testDB, utils.ToCmdLine(key, "2-0", "b", "2"))
+	execXAdd(testDB, utils.ToCmdLine(key, "3-0", "c", "3"))
+
+	result := execXReadGroup(testDB, utils.ToCmdLine("GROUP", "g", "alice", "COUNT", "2", "STREAMS", key, ">"))
+	assertReply(t, result, reply.NewMultiRawReply([]resp.Reply{
+		streamReadToReply(key, reply.NewMultiRawReply([]resp.Reply{
+			makeEntryReply("1-0", "a", "1"),
+			makeEntryReply("2-0", "b", "2"),
+		})),
+	}))
+	execXReadGroup(testDB, utils.ToCmdLine("GROUP", "g", "bob", "STREAMS", key, ">"))
+	result = execXReadGroup(testDB, utils.ToCmdLine("GROUP", "g", "bob", "STREAMS", key, ">"))
+	assertReply(t, result, reply.NewNullArrayReply())
+	// 读取自己的历史消息
+	result = execXReadGroup(testDB, utils.ToCmdLine("GROUP", "g", "alice", "STREAMS", key, "1"))
+	assertReply(t, result, reply.NewMultiRawReply([]resp.Reply{
+		streamReadToReply(key, reply.NewMultiRawReply([]resp.Reply{makeEntryReply("2-0", "b", "2")})),
+	}))
+
+	assertReply(t, execXPending(testDB, utils.ToCmdLine(key, "g")), reply.NewMultiRawReply([]resp.Reply{
+		reply.NewIntReply(3),
+		reply.NewBulkReply([]byte("1-0")),
+		reply.NewBulkReply([]byte("3-0")),
+		reply.NewMultiRawReply([]resp.Reply{
+			reply.NewMultiBulkReply(utils.ToCmdLine("alice", "2")),
+			reply.NewMultiBulkReply(utils.ToCmdLine("bob", "1")),
+		}),
+	}))
+	assertIntReply(t, execXAck(testDB, utils.ToCmdLine(key, "g", "1-0", "1-0")), 1)
+
+	// bob认领alice的消息
+	result = execXClaim(testDB, utils.ToCmdLine(key, "g", "bob", "0", "2-0", "JUSTID"))
+	assertReply(t, result, reply.NewMultiBulkReply(utils.ToCmdLine("2-0")))
+	result = execXClaim(testDB, utils.ToCmdLine(key, "g", "bob", "3600000", "3-0"))
+	assertReply(t, result, reply.NewMultiRawReply(nil))
+	pending := execXPending(testDB, utils.ToCmdLine(key, "g", "-", "+", "10", "bob")).(*reply.MultiRawReply)
+	if len(pending.Replies) != 2 {
+		t.Fatal(fmt.Sprintf("expected 2 pending entries, actually %s", string(pending.ToBytes())))
+	}
+	first := pending.Replies[0].(*reply.MultiRawReply)
+	// JUSTID不增加投递次数
+	assertReply(t, first.Replies[0], reply.NewBulkReply([]byte("2-0")))
+	assertReply(t, first.Replies[3], reply.NewIntReply(1))
+
+	assertIntReply(t, execXGroup(testDB, utils.ToCmdLine("delconsumer", key, "g", "bob")), 2)
+	assertIntReply(t, execXGroup(testDB, utils.ToCmdLine("destroy", key, "g")), 1)
+	assertReply(t, execXReadGroup(testDB, utils.ToCmdLine("GROUP", "g", "alice", "STREAMS", key, ">")),
+		reply.NewErrReply("NOGROUP No such key '"+key+"' or consumer group 'g' in XREADGROUP with GROUP option"))
+}
+
+// 消费者组的状态通过aof重放后应该保持一致
+func TestStreamGroupAof(t *testing.T) {
+	db := makeTestDB()
+	aofLines := make([]CmdLine, 0)
+	db.addAof = func(lines ...CmdLine) {
+		aofLines = append(aofLines, lines...)
+	}
+	key := utils.RandString(10)
+	db.Exec(nil, utils.ToCmdLine("xadd", key, "*", "a", "1"))
+	db.Exec(nil, utils.ToCmdLine("xadd", key, "*", "b", "2"))
+	db.Exec(nil, utils.ToCmdLine("xgroup", "create", key, "g", "0"))
+	db.Exec(nil, utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "COUNT", "1", "STREAMS", key, ">"))
+	db.Exec(nil, utils.ToCmdLine("xreadgroup", "GROUP", "g", "bob", "NOACK", "STREAMS", key, ">"))
+
+	replayed := makeTestDB()
+	for _, line := range aofLines {
+		replayed.Exec(nil, line)
+	}
+	for _, cmdLine := range []CmdLine{
+		utils.ToCmdLine("xrange", key, "-", "+"),
+		utils.ToCmdLine("xpending", key, "g"),
+		utils.ToCmdLine("xreadgroup", "GROUP", "g", "alice", "STREAMS", key, "0"),
+		utils.ToCmdLine("xreadgroup", "GROUP", "g", "carol", "STREAMS", key, ">"),
+	} {
+		assertReply(t, replayed.Exec(nil, cmdLine), db.Exec(nil, cmdLine))
+	}
+}
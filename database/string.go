@@ -5,33 +5,173 @@ import (
 	"go-redis/interface/resp"
 	"go-redis/lib/utils"
 	"go-redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func init() {
-	RegisterCommand("get", execGet, 2)
-	RegisterCommand("set", execSet, 3)
-	RegisterCommand("setnx", execSetNX, 3)
-	RegisterCommand("getset", execGetSet, 3)
-	RegisterCommand("getstrlen", execStrlen, 2)
+	RegisterCommand("get", execGet, readFirstKey, 2)
+	RegisterCommand("set", execSet, writeFirstKey, -3)
+	RegisterCommand("setnx", execSetNX, writeFirstKey, 3)
+	RegisterCommand("setex", execSetEX, writeFirstKey, 4)
+	RegisterCommand("psetex", execPSetEX, writeFirstKey, 4)
+	RegisterCommand("getset", execGetSet, writeFirstKey, 3)
+	RegisterCommand("getex", execGetEX, writeFirstKey, -2)
+	RegisterCommand("getdel", execGetDel, writeFirstKey, 2)
+	RegisterCommand("strlen", execStrlen, readFirstKey, 2)
+	RegisterCommand("getstrlen", execStrlen, readFirstKey, 2) // 兼容旧名称
+	RegisterCommand("append", execAppend, writeFirstKey, 3)
+	RegisterCommand("setrange", execSetRange, writeFirstKey, 4)
+	RegisterCommand("getrange", execGetRange, readFirstKey, 4)
+	RegisterCommand("mget", execMGet, readAllKeys, -2)
+	RegisterCommand("mset", execMSet, prepareMSet, -3)
+	RegisterCommand("msetnx", execMSetNX, prepareMSet, -3)
+	RegisterCommand("incr", execIncr, writeFirstKey, 2)
+	RegisterCommand("incrby", execIncrBy, writeFirstKey, 3)
+	RegisterCommand("decr", execDecr, writeFirstKey, 2)
+	RegisterCommand("decrby", execDecrBy, writeFirstKey, 3)
+	RegisterCommand("incrbyfloat", execIncrByFloat, writeFirstKey, 3)
+}
+
+func (db *DB) getAsString(key string) ([]byte, reply.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	bytes, ok := entity.Data.([]byte)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return bytes, nil
 }
 
 // GET
 func execGet(db *DB, args[][]byte) resp.Reply {
-	entity, ok := db.GetEntity(string(args[0]))
-	if !ok {
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
 		return reply.NewNullBulkReply()
 	}
-	return reply.NewBulkReply(entity.Data.([]byte))
+	return reply.NewBulkReply(bytes)
 }
 
-// SET key value
+const (
+	upsertPolicy = iota // default
+	insertPolicy        // set nx
+	updatePolicy        // set xx
+)
+
+// parseExpireOption 解析 EX/PX/EXAT/PXAT 后面的时间参数，时间必须为正数
+func parseExpireOption(cmdName string, option string, raw []byte) (time.Time, reply.ErrorReply) {
+	val, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if val <= 0 {
+		return time.Time{}, reply.NewErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	switch option {
+	case "EX":
+		return parseExpireTime(cmdName, raw, time.Second, false)
+	case "PX":
+		return parseExpireTime(cmdName, raw, time.Millisecond, false)
+	case "EXAT":
+		return parseExpireTime(cmdName, raw, time.Second, true)
+	default: // PXAT
+		return parseExpireTime(cmdName, raw, time.Millisecond, true)
+	}
+}
+
+// SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|EXAT unix-time-seconds|PXAT unix-time-milliseconds|KEEPTTL]
 func execSet(db *DB, args [][]byte) resp.Reply {
 	key, value := string(args[0]), args[1]
-	entity := &databaseface.DataEntity{
+	policy := upsertPolicy
+	returnOld := false
+	keepTTL := false
+	hasTTL := false
+	var expireTime time.Time
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "NX":
+			if policy == updatePolicy {
+				return reply.NewSyntaxErrReply()
+			}
+			policy = insertPolicy
+		case "XX":
+			if policy == insertPolicy {
+				return reply.NewSyntaxErrReply()
+			}
+			policy = updatePolicy
+		case "GET":
+			returnOld = true
+		case "KEEPTTL":
+			if hasTTL {
+				return reply.NewSyntaxErrReply()
+			}
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasTTL || keepTTL || i+1 >= len(args) {
+				return reply.NewSyntaxErrReply()
+			}
+			var errReply reply.ErrorReply
+			expireTime, errReply = parseExpireOption("set", option, args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			hasTTL = true
+			i++
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	var old []byte
+	if returnOld {
+		var errReply reply.ErrorReply
+		old, errReply = db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+	}
+	_, exists := db.GetEntity(key)
+	if policy == insertPolicy && exists || policy == updatePolicy && !exists {
+		if returnOld {
+			return reply.NewBulkReply(old)
+		}
+		return reply.NewNullBulkReply()
+	}
+
+	db.PutEntity(key, &databaseface.DataEntity{
 		Data: value,
+	})
+	if hasTTL {
+		db.Expire(key, expireTime)
+	} else if !keepTTL {
+		db.Persist(key)
+	}
+
+	// aof中只记录最终效果，条件与GET选项已经求值过了
+	if keepTTL {
+		db.addAof(utils.ToCmdLine3("set", args[0], value, []byte("keepttl")))
+	} else {
+		db.addAof(utils.ToCmdLine3("set", args[0], value))
+	}
+	if hasTTL {
+		db.addAof(makeExpireCmd(key, expireTime))
+	}
+	db.notify(notifyString, "set", key)
+	if hasTTL {
+		db.notify(notifyGeneric, "expire", key)
+	}
+
+	if returnOld {
+		return reply.NewBulkReply(old)
 	}
-	db.PutEntity(key, entity)
-	db.addAof(utils.ToCmdLine3("set", args...))
 	return reply.NewOkReply()
 }
 
@@ -43,30 +183,382 @@ func execSetNX(db *DB, args[][]byte) resp.Reply {
 	}
 	res := db.PutEntityIfAbsent(key, entity)
 	db.addAof(utils.ToCmdLine3("setnx", args...))
+	if res > 0 {
+		db.notify(notifyString, "set", key)
+	}
 	return reply.NewIntReply(int64(res))
 }
 
+// SETEX key seconds value
+func execSetEX(db *DB, args [][]byte) resp.Reply {
+	return setWithTTL(db, "setex", "EX", args)
+}
+
+// PSETEX key milliseconds value
+func execPSetEX(db *DB, args [][]byte) resp.Reply {
+	return setWithTTL(db, "psetex", "PX", args)
+}
+
+func setWithTTL(db *DB, cmdName string, option string, args [][]byte) resp.Reply {
+	key, value := string(args[0]), args[2]
+	expireTime, errReply := parseExpireOption(cmdName, option, args[1])
+	if errReply != nil {
+		return errReply
+	}
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: value,
+	})
+	db.Expire(key, expireTime)
+	db.addAof(utils.ToCmdLine3("set", args[0], value))
+	db.addAof(makeExpireCmd(key, expireTime))
+	db.notify(notifyString, "set", key)
+	db.notify(notifyGeneric, "expire", key)
+	return reply.NewOkReply()
+}
+
+// GETEX key [EX seconds|PX milliseconds|EXAT unix-time-seconds|PXAT unix-time-milliseconds|PERSIST]
+func execGetEX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	persist := false
+	hasTTL := false
+	var expireTime time.Time
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "PERSIST":
+			if hasTTL {
+				return reply.NewSyntaxErrReply()
+			}
+			persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasTTL || persist || i+1 >= len(args) {
+				return reply.NewSyntaxErrReply()
+			}
+			var errReply reply.ErrorReply
+			expireTime, errReply = parseExpireOption("getex", option, args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			hasTTL = true
+			i++
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return reply.NewNullBulkReply()
+	}
+	if hasTTL {
+		db.Expire(key, expireTime)
+		db.addAof(makeExpireCmd(key, expireTime))
+		db.notify(notifyGeneric, "expire", key)
+	} else if persist {
+		if _, ok := db.TTLOf(key); ok {
+			db.Persist(key)
+			db.addAof(utils.ToCmdLine("persist", key))
+			db.notify(notifyGeneric, "persist", key)
+		}
+	}
+	return reply.NewBulkReply(bytes)
+}
+
+// GETDEL key
+func execGetDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return reply.NewNullBulkReply()
+	}
+	db.RemoveEntity(key)
+	db.addAof(utils.ToCmdLine3("del", args[0]))
+	db.notify(notifyGeneric, "del", key)
+	return reply.NewBulkReply(bytes)
+}
+
 // GETSET key value
 func execGetSet(db *DB, args[][]byte) resp.Reply {
 	key, value := string(args[0]), args[1]
-	entity, ok := db.GetEntity(key)
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
 	db.PutEntity(key, &databaseface.DataEntity{
 		Data: value,
 	})
+	db.Persist(key)
 	db.addAof(utils.ToCmdLine3("getset", args...))
-	if !ok {
+	db.notify(notifyString, "set", key)
+	if old == nil {
 		return reply.NewNullBulkReply()
 	}
-	return reply.NewBulkReply(entity.Data.([]byte))
+	return reply.NewBulkReply(old)
 }
 
-// STRLEN
+// STRLEN key
 func execStrlen(db *DB, args[][]byte) resp.Reply {
-	entity, exist := db.GetEntity(string(args[0]))
-	if !exist {
-		return reply.NewNullBulkReply()
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.NewIntReply(int64(len(bytes)))
+}
+
+// APPEND key value
+func execAppend(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	// 不能直接在原切片上append，GET返回的切片可能仍在被使用
+	value := make([]byte, 0, len(bytes)+len(args[1]))
+	value = append(value, bytes...)
+	value = append(value, args[1]...)
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: value,
+	})
+	db.addAof(utils.ToCmdLine3("append", args...))
+	db.notify(notifyString, "append", key)
+	return reply.NewIntReply(int64(len(value)))
+}
+
+// maxStringLength 与Redis的proto-max-bulk-len默认值一致
+const maxStringLength = 512 * 1024 * 1024
+
+// SETRANGE key offset value
+func execSetRange(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	if offset < 0 {
+		return reply.NewErrReply("ERR offset is out of range")
+	}
+	value := args[2]
+	// 分两步比较，避免offset接近MaxInt64时相加溢出
+	if offset > maxStringLength || offset > maxStringLength-int64(len(value)) {
+		return reply.NewErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	// value为空时不修改，key不存在也不会创建
+	if len(value) == 0 {
+		return reply.NewIntReply(int64(len(bytes)))
+	}
+	size := len(bytes)
+	if end := int(offset) + len(value); end > size {
+		size = end
 	}
-	return reply.NewIntReply(int64(len(entity.Data.([]byte))))
+	// 超出原长度的部分用0填充
+	result := make([]byte, size)
+	copy(result, bytes)
+	copy(result[offset:], value)
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: result,
+	})
+	db.addAof(utils.ToCmdLine3("setrange", args...))
+	db.notify(notifyString, "setrange", key)
+	return reply.NewIntReply(int64(len(result)))
 }
 
+// GETRANGE key start end
+func execGetRange(db *DB, args [][]byte) resp.Reply {
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	end, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	bytes, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	size := int64(len(bytes))
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if size == 0 || start > end {
+		return reply.NewBulkReply([]byte{})
+	}
+	return reply.NewBulkReply(bytes[start : end+1])
+}
+
+// MGET key [key ...]
+// 不存在或者不是字符串的key返回nil
+func execMGet(db *DB, args [][]byte) resp.Reply {
+	result := make([][]byte, len(args))
+	for i, arg := range args {
+		bytes, errReply := db.getAsString(string(arg))
+		if errReply != nil {
+			continue
+		}
+		result[i] = bytes
+	}
+	return reply.NewMultiBulkReply(result)
+}
+
+func prepareMSet(args [][]byte) ([]string, []string) {
+	keys := make([]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, string(args[i]))
+	}
+	return keys, nil
+}
+
+// MSET key value [key value ...]
+func execMSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 0 {
+		return reply.NewArgNumErrReply("mset")
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.PutEntity(key, &databaseface.DataEntity{
+			Data: args[i+1],
+		})
+		db.Persist(key)
+		db.notify(notifyString, "set", key)
+	}
+	db.addAof(utils.ToCmdLine3("mset", args...))
+	return reply.NewOkReply()
+}
 
+// MSETNX key value [key value ...]
+// 只要有一个key已存在，所有key都不会被设置
+func execMSetNX(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 0 {
+		return reply.NewArgNumErrReply("msetnx")
+	}
+	for i := 0; i < len(args); i += 2 {
+		if _, exists := db.GetEntity(string(args[i])); exists {
+			return reply.NewIntReply(0)
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		db.PutEntity(string(args[i]), &databaseface.DataEntity{
+			Data: args[i+1],
+		})
+		db.notify(notifyString, "set", string(args[i]))
+	}
+	db.addAof(utils.ToCmdLine3("msetnx", args...))
+	return reply.NewIntReply(1)
+}
+
+// incrBy 给key上的整数加上delta，key不存在时视为0，保留原有的过期时间
+func incrBy(db *DB, key string, delta int64) resp.Reply {
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current int64
+	if bytes != nil {
+		var err error
+		current, err = strconv.ParseInt(string(bytes), 10, 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if delta > 0 && current > math.MaxInt64-delta || delta < 0 && current < math.MinInt64-delta {
+		return reply.NewErrReply("ERR increment or decrement would overflow")
+	}
+	result := current + delta
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: []byte(strconv.FormatInt(result, 10)),
+	})
+	db.addAof(utils.ToCmdLine("incrby", key, strconv.FormatInt(delta, 10)))
+	db.notify(notifyString, "incrby", key)
+	return reply.NewIntReply(result)
+}
+
+func parseDelta(raw []byte) (int64, reply.ErrorReply) {
+	delta, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, reply.NewErrReply("ERR value is not an integer or out of range")
+	}
+	return delta, nil
+}
+
+// INCR key
+func execIncr(db *DB, args [][]byte) resp.Reply {
+	return incrBy(db, string(args[0]), 1)
+}
+
+// INCRBY key increment
+func execIncrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseDelta(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	return incrBy(db, string(args[0]), delta)
+}
+
+// DECR key
+func execDecr(db *DB, args [][]byte) resp.Reply {
+	return incrBy(db, string(args[0]), -1)
+}
+
+// DECRBY key decrement
+func execDecrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseDelta(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	// -MinInt64 会溢出
+	if delta == math.MinInt64 {
+		return reply.NewErrReply("ERR decrement would overflow")
+	}
+	return incrBy(db, string(args[0]), -delta)
+}
+
+// INCRBYFLOAT key increment
+func execIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	delta, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return reply.NewErrReply("ERR value is not a valid float")
+	}
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current float64
+	if bytes != nil {
+		current, err = strconv.ParseFloat(string(bytes), 64)
+		if err != nil {
+			return reply.NewErrReply("ERR value is not a valid float")
+		}
+	}
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return reply.NewErrReply("ERR increment would produce NaN or Infinity")
+	}
+	value := []byte(strconv.FormatFloat(result, 'f', -1, 64))
+	db.PutEntity(key, &databaseface.DataEntity{
+		Data: value,
+	})
+	// 浮点运算结果可能因平台而异，aof中直接记录最终值，KEEPTTL保证重放时不清除过期时间
+	db.addAof(utils.ToCmdLine3("set", args[0], value, []byte("keepttl")))
+	db.notify(notifyString, "incrbyfloat", key)
+	return reply.NewBulkReply(value)
+}
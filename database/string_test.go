@@ -0,0 +1,304 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"testing"
+)
+
+func TestSetOptions(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+
+	// XX on missing key
+	result := execSet(testDB, utils.ToCmdLine(key, "a", "XX"))
+	if _, ok := result.(*reply.NullBulkReply); !ok {
+		t.Error(fmt.Sprintf("expected null, actually %s", string(result.ToBytes())))
+	}
+	// NX on missing key
+	result = execSet(testDB, utils.ToCmdLine(key, "a", "NX", "EX", "100"))
+	if _, ok := result.(*reply.OkReply); !ok {
+		t.Error(fmt.Sprintf("expected OK, actually %s", string(result.ToBytes())))
+	}
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 100 {
+		t.Error(fmt.Sprintf("expected 100, actually %d", intResult.Code))
+	}
+	// NX on existing key
+	result = execSet(testDB, utils.ToCmdLine(key, "b", "NX"))
+	if _, ok := result.(*reply.NullBulkReply); !ok {
+		t.Error(fmt.Sprintf("expected null, actually %s", string(result.ToBytes())))
+	}
+	// GET returns old value, KEEPTTL keeps the ttl
+	result = execSet(testDB, utils.ToCmdLine(key, "b", "XX", "GET", "KEEPTTL"))
+	expected := reply.NewBulkReply([]byte("a"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 100 {
+		t.Error(fmt.Sprintf("expected 100, actually %d", intResult.Code))
+	}
+	// plain SET clears the ttl
+	execSet(testDB, utils.ToCmdLine(key, "c"))
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -1 {
+		t.Error(fmt.Sprintf("expected -1, actually %d", intResult.Code))
+	}
+
+	// conflicting options
+	for _, cmd := range [][]string{
+		{key, "v", "NX", "XX"},
+		{key, "v", "EX", "10", "PX", "100"},
+		{key, "v", "EX", "10", "KEEPTTL"},
+		{key, "v", "EX"},
+		{key, "v", "FOO"},
+	} {
+		result = execSet(testDB, utils.ToCmdLine(cmd...))
+		if !utils.BytesEquals(result.ToBytes(), reply.NewSyntaxErrReply().ToBytes()) {
+			t.Error(fmt.Sprintf("expected syntax error, actually %s", string(result.ToBytes())))
+		}
+	}
+	result = execSet(testDB, utils.ToCmdLine(key, "v", "EX", "0"))
+	if !reply.IsErrReply(result) {
+		t.Error("expected invalid expire time error")
+	}
+
+	// GET against a list
+	listKey := utils.RandString(10)
+	RPush(testDB, utils.ToCmdLine(listKey, "a"))
+	result = execSet(testDB, utils.ToCmdLine(listKey, "v", "GET"))
+	if _, ok := result.(*reply.WrongTypeErrReply); !ok {
+		t.Error(fmt.Sprintf("expected wrong type error, actually %s", string(result.ToBytes())))
+	}
+}
+
+func TestGetEXAndGetDel(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSetEX(testDB, utils.ToCmdLine(key, "100", "v"))
+	result := execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 100 {
+		t.Error(fmt.Sprintf("expected 100, actually %d", intResult.Code))
+	}
+
+	result = execGetEX(testDB, utils.ToCmdLine(key, "PERSIST"))
+	expected := reply.NewBulkReply([]byte("v"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -1 {
+		t.Error(fmt.Sprintf("expected -1, actually %d", intResult.Code))
+	}
+	execGetEX(testDB, utils.ToCmdLine(key, "PX", "20000"))
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 20 {
+		t.Error(fmt.Sprintf("expected 20, actually %d", intResult.Code))
+	}
+
+	result = execGetDel(testDB, utils.ToCmdLine(key))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execGetDel(testDB, utils.ToCmdLine(key))
+	if _, ok := result.(*reply.NullBulkReply); !ok {
+		t.Error(fmt.Sprintf("expected null, actually %s", string(result.ToBytes())))
+	}
+}
+
+func TestIncr(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	for i := 1; i <= 3; i++ {
+		result := execIncr(testDB, utils.ToCmdLine(key))
+		if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != int64(i) {
+			t.Error(fmt.Sprintf("expected %d, actually %s", i, string(result.ToBytes())))
+		}
+	}
+	execDecrBy(testDB, utils.ToCmdLine(key, "10"))
+	execDecr(testDB, utils.ToCmdLine(key))
+	result := execIncrBy(testDB, utils.ToCmdLine(key, "5"))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != -3 {
+		t.Error(fmt.Sprintf("expected -3, actually %s", string(result.ToBytes())))
+	}
+	// GET返回字符串形式
+	result = execGet(testDB, utils.ToCmdLine(key))
+	expected := reply.NewBulkReply([]byte("-3"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	// 溢出
+	execSet(testDB, utils.ToCmdLine(key, "9223372036854775807"))
+	result = execIncr(testDB, utils.ToCmdLine(key))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected overflow error, actually %s", string(result.ToBytes())))
+	}
+	execSet(testDB, utils.ToCmdLine(key, "0"))
+	result = execDecrBy(testDB, utils.ToCmdLine(key, "-9223372036854775808"))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected overflow error, actually %s", string(result.ToBytes())))
+	}
+
+	// 非整数
+	execSet(testDB, utils.ToCmdLine(key, "abc"))
+	result = execIncr(testDB, utils.ToCmdLine(key))
+	notIntErr := reply.NewErrReply("ERR value is not an integer or out of range")
+	if !utils.BytesEquals(result.ToBytes(), notIntErr.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(notIntErr.ToBytes()), string(result.ToBytes())))
+	}
+	result = execIncrBy(testDB, utils.ToCmdLine(key, "1.5"))
+	if !utils.BytesEquals(result.ToBytes(), notIntErr.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(notIntErr.ToBytes()), string(result.ToBytes())))
+	}
+
+	// 保留过期时间
+	execSet(testDB, utils.ToCmdLine(key, "1", "EX", "100"))
+	execIncr(testDB, utils.ToCmdLine(key))
+	result = execTTL(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != 100 {
+		t.Error(fmt.Sprintf("expected 100, actually %d", intResult.Code))
+	}
+}
+
+func TestIncrByFloat(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+	execSet(testDB, utils.ToCmdLine(key, "10.5"))
+	result := execIncrByFloat(testDB, utils.ToCmdLine(key, "0.1"))
+	expected := reply.NewBulkReply([]byte("10.6"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execIncrByFloat(testDB, utils.ToCmdLine(key, "-5e3"))
+	expected = reply.NewBulkReply([]byte("-4989.4"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	result = execIncrByFloat(testDB, utils.ToCmdLine(key, "abc"))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected error, actually %s", string(result.ToBytes())))
+	}
+
+	// aof记录最终值
+	var aofLines []CmdLine
+	db := makeTestDB()
+	db.addAof = func(lines ...CmdLine) {
+		aofLines = append(aofLines, lines...)
+	}
+	execIncrByFloat(db, utils.ToCmdLine(key, "1.5"))
+	if len(aofLines) != 1 || string(aofLines[0][0]) != "set" || string(aofLines[0][2]) != "1.5" {
+		t.Error("incrbyfloat should be logged as set of the final value")
+	}
+}
+
+func TestAppendAndRange(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key := utils.RandString(10)
+
+	result := execAppend(testDB, utils.ToCmdLine(key, "Hello"))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != 5 {
+		t.Error(fmt.Sprintf("expected 5, actually %s", string(result.ToBytes())))
+	}
+	execAppend(testDB, utils.ToCmdLine(key, " World"))
+	result = execStrlen(testDB, utils.ToCmdLine(key))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != 11 {
+		t.Error(fmt.Sprintf("expected 11, actually %s", string(result.ToBytes())))
+	}
+
+	for _, c := range []struct {
+		start, end, expected string
+	}{
+		{"0", "4", "Hello"},
+		{"-5", "-1", "World"},
+		{"0", "-1", "Hello World"},
+		{"-100", "3", "Hell"},
+		{"6", "100", "World"},
+		{"5", "3", ""},
+		{"20", "30", ""},
+	} {
+		result = execGetRange(testDB, utils.ToCmdLine(key, c.start, c.end))
+		expected := reply.NewBulkReply([]byte(c.expected))
+		if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+			t.Error(fmt.Sprintf("getrange %s %s: expected %s, actually %s", c.start, c.end, string(expected.ToBytes()), string(result.ToBytes())))
+		}
+	}
+
+	result = execSetRange(testDB, utils.ToCmdLine(key, "6", "Redis"))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != 11 {
+		t.Error(fmt.Sprintf("expected 11, actually %s", string(result.ToBytes())))
+	}
+	result = execGet(testDB, utils.ToCmdLine(key))
+	expected := reply.NewBulkReply([]byte("Hello Redis"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	// 超出长度时用0填充
+	key2 := utils.RandString(10)
+	execSetRange(testDB, utils.ToCmdLine(key2, "3", "ab"))
+	result = execGet(testDB, utils.ToCmdLine(key2))
+	expected = reply.NewBulkReply([]byte{0, 0, 0, 'a', 'b'})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %q, actually %q", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	// 空value不会创建key
+	key3 := utils.RandString(10)
+	result = execSetRange(testDB, utils.ToCmdLine(key3, "3", ""))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %s", string(result.ToBytes())))
+	}
+	if _, exists := testDB.GetEntity(key3); exists {
+		t.Error("setrange with empty value should not create key")
+	}
+	result = execSetRange(testDB, utils.ToCmdLine(key3, "-1", "a"))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected error, actually %s", string(result.ToBytes())))
+	}
+	// offset加上value长度会溢出
+	for _, offset := range []string{"9223372036854775807", "536870911"} {
+		result = execSetRange(testDB, utils.ToCmdLine(key3, offset, "ab"))
+		assertReply(t, result, reply.NewErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)"))
+	}
+}
+
+func TestMSetAndMGet(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	key1, key2, missing := utils.RandString(10), utils.RandString(10), utils.RandString(10)
+	listKey := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", listKey, "a"))
+
+	execSet(testDB, utils.ToCmdLine(key1, "old", "EX", "100"))
+	result := execMSet(testDB, utils.ToCmdLine(key1, "v1", key2, ""))
+	if _, ok := result.(*reply.OkReply); !ok {
+		t.Error(fmt.Sprintf("expected OK, actually %s", string(result.ToBytes())))
+	}
+	// MSET会清除过期时间
+	result = execTTL(testDB, utils.ToCmdLine(key1))
+	if intResult, _ := result.(*reply.IntReply); intResult.Code != -1 {
+		t.Error(fmt.Sprintf("expected -1, actually %d", intResult.Code))
+	}
+	result = execMGet(testDB, utils.ToCmdLine(key1, missing, key2, listKey))
+	expected := reply.NewMultiBulkReply([][]byte{[]byte("v1"), nil, []byte(""), nil})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	result = execMSetNX(testDB, utils.ToCmdLine(missing, "v", key1, "v"))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != 0 {
+		t.Error(fmt.Sprintf("expected 0, actually %s", string(result.ToBytes())))
+	}
+	if _, exists := testDB.GetEntity(missing); exists {
+		t.Error("msetnx should not set any key when one exists")
+	}
+	result = execMSetNX(testDB, utils.ToCmdLine(missing, "v"))
+	if intResult, _ := result.(*reply.IntReply); intResult == nil || intResult.Code != 1 {
+		t.Error(fmt.Sprintf("expected 1, actually %s", string(result.ToBytes())))
+	}
+	result = execMSet(testDB, utils.ToCmdLine(key1, "v1", key2))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected arg num error, actually %s", string(result.ToBytes())))
+	}
+}
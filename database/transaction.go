@@ -0,0 +1,275 @@
+package database
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StartMulti MULTI: 之后的命令进入队列，等待EXEC
+func StartMulti(c resp.Connection) resp.Reply {
+	if c.InMultiState() {
+		return reply.NewErrReply("ERR MULTI calls can not be nested")
+	}
+	c.SetMultiState(true)
+	return reply.NewOkReply()
+}
+
+// DiscardMulti DISCARD: 放弃事务，同时取消所有WATCH
+func DiscardMulti(c resp.Connection) resp.Reply {
+	if !c.InMultiState() {
+		return reply.NewErrReply("ERR DISCARD without MULTI")
+	}
+	c.SetMultiState(false)
+	c.ClearWatching()
+	return reply.NewOkReply()
+}
+
+// EnqueueCmd 事务中的命令在入队时就检查命令是否存在以及参数个数
+// 检查失败会记录错误，EXEC时整个事务都会被放弃
+func EnqueueCmd(c resp.Connection, cmdLine CmdLine) resp.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		errReply := reply.NewErrReply("ERR unknown command '" + cmdName + "'")
+		c.AddTxError(errReply)
+		return errReply
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		errReply := reply.NewArgNumErrReply(cmdName)
+		c.AddTxError(errReply)
+		return errReply
+	}
+	c.EnqueueCmd(cmdLine)
+	return reply.NewQueuedReply()
+}
+
+// Watch WATCH key [key ...]: 记录key当前的版本号
+func Watch(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	if c.InMultiState() {
+		return reply.NewErrReply("ERR WATCH inside MULTI is not allowed")
+	}
+	watching := c.GetWatching()
+	for _, arg := range args {
+		key := string(arg)
+		watching[resp.WatchKey{DBIndex: db.index, Key: key}] = db.GetVersion(key)
+	}
+	return reply.NewOkReply()
+}
+
+// Unwatch UNWATCH
+func Unwatch(c resp.Connection) resp.Reply {
+	c.ClearWatching()
+	return reply.NewOkReply()
+}
+
+func (db *DB) execMulti(c resp.Connection) resp.Reply {
+	if !c.InMultiState() {
+		return reply.NewErrReply("ERR EXEC without MULTI")
+	}
+	defer func() {
+		c.SetMultiState(false)
+		c.ClearWatching()
+	}()
+	if len(c.GetTxErrors()) > 0 {
+		return reply.NewErrReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	return db.ExecMulti(c, c.GetWatching(), c.GetQueuedCmdLine())
+}
+
+// isWatchingChanged 调用方需要持有WATCH的key的锁，包括其他DB中的key
+func (db *DB) isWatchingChanged(watching map[resp.WatchKey]uint32) bool {
+	for watchKey, ver := range watching {
+		watchDB := db.dbAt(watchKey.DBIndex)
+		if watchDB == nil {
+			continue
+		}
+		// 检查过期，过期删除也算修改
+		watchDB.IsExpired(watchKey.Key)
+		if watchDB.GetVersion(watchKey.Key) != ver {
+			return true
+		}
+	}
+	return false
+}
+
+// dbAt 返回同一个服务器中序号为index的DB，单独创建的DB只能找到自己
+func (db *DB) dbAt(index int) *DB {
+	if index == db.index {
+		return db
+	}
+	if db.getDB == nil {
+		return nil
+	}
+	return db.getDB(index)
+}
+
+// multiKeys 事务在一个DB中涉及的key
+type multiKeys struct {
+	db *DB
+	writeKeys []string
+	readKeys []string
+	// 事务中有FLUSHDB、KEYS等命令时锁住整个DB
+	lockAll bool
+}
+
+// lockMulti 对事务涉及的所有DB中的key加锁
+// 多个DB按序号从小到大加锁，避免两个事务互相等待对方DB中的锁
+func lockMulti(keysOfDB map[int]*multiKeys) (unlock func()) {
+	indexes := make([]int, 0, len(keysOfDB))
+	for index := range keysOfDB {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	unlocks := make([]func(), 0, len(indexes))
+	for _, index := range indexes {
+		keys := keysOfDB[index]
+		if keys.lockAll {
+			keys.db.locker.LockAll()
+			unlocks = append(unlocks, keys.db.locker.UnLockAll)
+			continue
+		}
+		keys.db.locker.RWLocks(keys.writeKeys, keys.readKeys)
+		unlocks = append(unlocks, func() {
+			keys.db.locker.RWUnLocks(keys.writeKeys, keys.readKeys)
+		})
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+func isSelect(cmdLine CmdLine) bool {
+	return strings.ToLower(string(cmdLine[0])) == "select"
+}
+
+// selectInMulti 事务中的SELECT在EXEC时执行，之后的命令在选择的DB中执行
+func (db *DB) selectInMulti(cmdLine CmdLine) (*DB, reply.ErrorReply) {
+	if len(cmdLine) != 2 {
+		return nil, reply.NewArgNumErrReply("select")
+	}
+	index, err := strconv.Atoi(string(cmdLine[1]))
+	if err != nil {
+		return nil, reply.NewErrReply("ERR invalid DB index")
+	}
+	selected := db.dbAt(index)
+	if selected == nil {
+		return nil, reply.NewErrReply("ERR DB index is out of range")
+	}
+	return selected, nil
+}
+
+// ExecMulti 原子地执行事务中的命令
+// WATCH的key被修改时放弃执行并返回nil数组；单条命令执行出错不影响其他命令
+// 事务中所有写命令用 MULTI ... EXEC 包裹，作为一个整体写入aof
+// 与Redis相同，事务中的SELECT切换之后命令所在的DB，EXEC之后仍然有效
+func (db *DB) ExecMulti(c resp.Connection, watching map[resp.WatchKey]uint32, cmdLines []CmdLine) resp.Reply {
+	// 一次性锁住事务涉及的所有key以及WATCH的key，SELECT之后的key属于选择的DB
+	keysOfDB := make(map[int]*multiKeys)
+	keysOf := func(keyDB *DB) *multiKeys {
+		keys, ok := keysOfDB[keyDB.index]
+		if !ok {
+			keys = &multiKeys{db: keyDB}
+			keysOfDB[keyDB.index] = keys
+		}
+		return keys
+	}
+	keysOf(db)
+	current := db
+	for _, cmdLine := range cmdLines {
+		if isSelect(cmdLine) {
+			if selected, errReply := db.selectInMulti(cmdLine); errReply == nil {
+				current = selected
+			}
+			continue
+		}
+		cmd := cmdTable[strings.ToLower(string(cmdLine[0]))]
+		write, read := cmd.prepare(cmdLine[1:])
+		keys := keysOf(current)
+		keys.writeKeys = append(keys.writeKeys, write...)
+		keys.readKeys = append(keys.readKeys, read...)
+		keys.lockAll = keys.lockAll || cmd.lockMode != lockKeys
+	}
+	// WATCH之后可能SELECT了其他DB，WATCH的key属于WATCH时所在的DB
+	for watchKey := range watching {
+		if watchDB := db.dbAt(watchKey.DBIndex); watchDB != nil {
+			keys := keysOf(watchDB)
+			keys.readKeys = append(keys.readKeys, watchKey.Key)
+		}
+	}
+	defer func() {
+		for _, keys := range keysOfDB {
+			keys.db.waiters.wake(keys.writeKeys...)
+		}
+	}()
+	unlock := lockMulti(keysOfDB)
+	defer unlock()
+
+	if db.isWatchingChanged(watching) {
+		return reply.NewNullArrayReply()
+	}
+	for _, keys := range keysOfDB {
+		keys.db.beforeWrite(keys.writeKeys...)
+	}
+
+	aofLines := []CmdLine{utils.ToCmdLine("multi")}
+	// aof记录在db中，写入其他DB的命令之前先写入select
+	aofIndex := db.index
+	// 复制出的txDB与对应的DB共享数据，只是aof先记录到aofLines中
+	txDBs := make(map[int]*DB)
+	txDBOf := func(cmdDB *DB) *DB {
+		if txDB, ok := txDBs[cmdDB.index]; ok {
+			return txDB
+		}
+		txDB := *cmdDB
+		txDB.addAof = func(lines ...CmdLine) {
+			if cmdDB.index != aofIndex {
+				aofLines = append(aofLines, utils.ToCmdLine("select", strconv.Itoa(cmdDB.index)))
+				aofIndex = cmdDB.index
+			}
+			aofLines = append(aofLines, lines...)
+		}
+		txDBs[cmdDB.index] = &txDB
+		return &txDB
+	}
+	results := make([]resp.Reply, 0, len(cmdLines))
+	current = db
+	for _, cmdLine := range cmdLines {
+		if isSelect(cmdLine) {
+			selected, errReply := db.selectInMulti(cmdLine)
+			if errReply != nil {
+				results = append(results, errReply)
+				continue
+			}
+			current = selected
+			results = append(results, reply.NewOkReply())
+			continue
+		}
+		cmd := cmdTable[strings.ToLower(string(cmdLine[0]))]
+		aofLen := len(aofLines)
+		results = append(results, cmd.exector(txDBOf(current), cmdLine[1:]))
+		write, read := cmd.prepare(cmdLine[1:])
+		// 与execWrite相同，只有修改了数据的命令增加版本号
+		if len(aofLines) > aofLen {
+			current.addVersion(write...)
+		}
+		current.trackKeys(c, write, read)
+	}
+	if len(aofLines) > 1 {
+		aofLines = append(aofLines, utils.ToCmdLine("exec"))
+		// 重放时EXEC之后停留在aof中最后select的DB，需要切换回db
+		if aofIndex != db.index {
+			aofLines = append(aofLines, utils.ToCmdLine("select", strconv.Itoa(db.index)))
+		}
+		db.addAof(aofLines...)
+	}
+	if c != nil && current != db {
+		c.SelectDB(current.index)
+	}
+	return reply.NewMultiRawReply(results)
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"fmt"
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"testing"
+)
+
+func TestMulti(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	conn := &connection.Connection{}
+	key := utils.RandString(10)
+
+	result := testDB.Exec(conn, utils.ToCmdLine("multi"))
+	if _, ok := result.(*reply.OkReply); !ok {
+		t.Error(fmt.Sprintf("expected OK, actually %s", string(result.ToBytes())))
+	}
+	result = testDB.Exec(conn, utils.ToCmdLine("multi"))
+	if !reply.IsErrReply(result) {
+		t.Error("nested MULTI should fail")
+	}
+	result = testDB.Exec(conn, utils.ToCmdLine("set", key, "1"))
+	if _, ok := result.(*reply.QueuedReply); !ok {
+		t.Error(fmt.Sprintf("expected QUEUED, actually %s", string(result.ToBytes())))
+	}
+	testDB.Exec(conn, utils.ToCmdLine("get", key))
+	// 命令在EXEC之前不会执行
+	if _, exists := testDB.GetEntity(key); exists {
+		t.Error("queued command should not be executed before EXEC")
+	}
+
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	expected := reply.NewMultiRawReply([]resp.Reply{reply.NewOkReply(), reply.NewBulkReply([]byte("1"))})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	if conn.InMultiState() {
+		t.Error("connection should leave multi state after EXEC")
+	}
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if !reply.IsErrReply(result) {
+		t.Error("EXEC without MULTI should fail")
+	}
+}
+
+func TestMultiQueueError(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	conn := &connection.Connection{}
+	key := utils.RandString(10)
+
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "1"))
+	result := testDB.Exec(conn, utils.ToCmdLine("get"))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected arg num error, actually %s", string(result.ToBytes())))
+	}
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if !reply.IsErrReply(result) {
+		t.Error(fmt.Sprintf("expected EXECABORT, actually %s", string(result.ToBytes())))
+	}
+	if _, exists := testDB.GetEntity(key); exists {
+		t.Error("aborted transaction should not be executed")
+	}
+
+	// 执行时出错的命令不影响其他命令
+	testDB.Exec(conn, utils.ToCmdLine("sadd", key, "a"))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("get", key))
+	testDB.Exec(conn, utils.ToCmdLine("sadd", key, "b"))
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	expected := reply.NewMultiRawReply([]resp.Reply{&reply.WrongTypeErrReply{}, reply.NewIntReply(1)})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	conn := &connection.Connection{}
+	key := utils.RandString(10)
+
+	result := testDB.Exec(conn, utils.ToCmdLine("discard"))
+	if !reply.IsErrReply(result) {
+		t.Error("DISCARD without MULTI should fail")
+	}
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "1"))
+	result = testDB.Exec(conn, utils.ToCmdLine("discard"))
+	if _, ok := result.(*reply.OkReply); !ok {
+		t.Error(fmt.Sprintf("expected OK, actually %s", string(result.ToBytes())))
+	}
+	if _, exists := testDB.GetEntity(key); exists {
+		t.Error("discarded command should not be executed")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	conn := &connection.Connection{}
+	otherConn := &connection.Connection{}
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+
+	// 被其他客户端修改
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	testDB.Exec(otherConn, utils.ToCmdLine("set", key, "other"))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, value))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if _, ok := result.(*reply.NullArrayReply); !ok {
+		t.Error(fmt.Sprintf("expected nil array, actually %s", string(result.ToBytes())))
+	}
+	actual := testDB.Exec(conn, utils.ToCmdLine("get", key))
+	if !utils.BytesEquals(actual.ToBytes(), reply.NewBulkReply([]byte("other")).ToBytes()) {
+		t.Error(fmt.Sprintf("transaction should be aborted, actually %s", string(actual.ToBytes())))
+	}
+
+	// EXEC之后WATCH被清除，读操作不会让事务失败
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	testDB.Exec(otherConn, utils.ToCmdLine("get", key))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, value))
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	expected := reply.NewMultiRawReply([]resp.Reply{reply.NewOkReply()})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	// UNWATCH
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	testDB.Exec(otherConn, utils.ToCmdLine("del", key))
+	testDB.Exec(conn, utils.ToCmdLine("unwatch"))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, value))
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+}
+
+// TestWatchFailedWrite 出错或没有修改数据的写命令不会让事务失败
+func TestWatchFailedWrite(t *testing.T) {
+	execFlushDB(testDB, [][]byte{})
+	conn := &connection.Connection{}
+	otherConn := &connection.Connection{}
+	key := utils.RandString(10)
+	missing := utils.RandString(10)
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "v"))
+
+	testDB.Exec(conn, utils.ToCmdLine("watch", key, missing))
+	testDB.Exec(otherConn, utils.ToCmdLine("lpush", key, "a"))
+	testDB.Exec(otherConn, utils.ToCmdLine("del", missing))
+	testDB.Exec(otherConn, utils.ToCmdLine("multi"))
+	testDB.Exec(otherConn, utils.ToCmdLine("incr", key))
+	testDB.Exec(otherConn, utils.ToCmdLine("exec"))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "new"))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	expected := reply.NewMultiRawReply([]resp.Reply{reply.NewOkReply()})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+}
+
+// TestWatchOtherDB WATCH的key属于WATCH时所在的DB
+func TestWatchOtherDB(t *testing.T) {
+	d := makeRDBTestDatabase(t)
+	conn := connection.NewFakeConn()
+	otherConn := connection.NewFakeConn()
+	expected := reply.NewMultiRawReply([]resp.Reply{reply.NewOkReply()})
+
+	// 其他DB中的同名key被修改
+	d.Exec(conn, utils.ToCmdLine("watch", "k"))
+	d.Exec(otherConn, utils.ToCmdLine("select", "1"))
+	d.Exec(otherConn, utils.ToCmdLine("set", "k", "v"))
+	d.Exec(conn, utils.ToCmdLine("multi"))
+	d.Exec(conn, utils.ToCmdLine("set", "k", "v"))
+	result := d.Exec(conn, utils.ToCmdLine("exec"))
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+
+	// WATCH之后切换DB，原来DB中的key被修改
+	d.Exec(conn, utils.ToCmdLine("watch", "k"))
+	d.Exec(conn, utils.ToCmdLine("select", "2"))
+	d.Exec(otherConn, utils.ToCmdLine("select", "0"))
+	d.Exec(otherConn, utils.ToCmdLine("set", "k", "other"))
+	d.Exec(conn, utils.ToCmdLine("multi"))
+	d.Exec(conn, utils.ToCmdLine("set", "k", "v"))
+	result = d.Exec(conn, utils.ToCmdLine("exec"))
+	if _, ok := result.(*reply.NullArrayReply); !ok {
+		t.Error(fmt.Sprintf("expected nil array, actually %s", string(result.ToBytes())))
+	}
+}
+
+// TestSelectInMulti 事务中的SELECT切换之后命令所在的DB，EXEC之后仍然有效
+func TestSelectInMulti(t *testing.T) {
+	d := makeRDBTestDatabase(t)
+	conn := connection.NewFakeConn()
+	d.Exec(conn, utils.ToCmdLine("multi"))
+	for _, cmd := range [][]string{
+		{"set", "k", "v0"},
+		{"select", "1"},
+		{"set", "k", "v1"},
+		{"select", "100"},
+		{"get", "k"},
+	} {
+		result := d.Exec(conn, utils.ToCmdLine(cmd...))
+		if !utils.BytesEquals(result.ToBytes(), reply.NewQueuedReply().ToBytes()) {
+			t.Error(fmt.Sprintf("expected QUEUED, actually %s", string(result.ToBytes())))
+		}
+	}
+	result := d.Exec(conn, utils.ToCmdLine("exec"))
+	expected := reply.NewMultiRawReply([]resp.Reply{
+		reply.NewOkReply(),
+		reply.NewOkReply(),
+		reply.NewOkReply(),
+		reply.NewErrReply("ERR DB index is out of range"),
+		reply.NewBulkReply([]byte("v1")),
+	})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("expected %s, actually %s", string(expected.ToBytes()), string(result.ToBytes())))
+	}
+	if conn.GetDBIndex() != 1 {
+		t.Error(fmt.Sprintf("expected db 1 after exec, actually %d", conn.GetDBIndex()))
+	}
+	d.Exec(conn, utils.ToCmdLine("select", "0"))
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("get", "k")), reply.NewBulkReply([]byte("v0")))
+
+	// SELECT参数个数错误时放弃整个事务
+	d.Exec(conn, utils.ToCmdLine("multi"))
+	d.Exec(conn, utils.ToCmdLine("select"))
+	d.Exec(conn, utils.ToCmdLine("set", "k", "discarded"))
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("exec")),
+		reply.NewErrReply("EXECABORT Transaction discarded because of previous errors."))
+	assertReply(t, d.Exec(conn, utils.ToCmdLine("get", "k")), reply.NewBulkReply([]byte("v0")))
+}
+
+func TestExecMultiAof(t *testing.T) {
+	var aofLines []CmdLine
+	db := makeTestDB()
+	db.addAof = func(lines ...CmdLine) {
+		aofLines = append(aofLines, lines...)
+	}
+	key := utils.RandString(10)
+	db.ExecMulti(nil, nil, []CmdLine{
+		utils.ToCmdLine("set", key, "1"),
+		utils.ToCmdLine("get", key),
+		utils.ToCmdLine("del", key),
+	})
+	expected := []string{"multi", "set", "del", "exec"}
+	if len(aofLines) != len(expected) {
+		t.Fatal(fmt.Sprintf("expected %d aof lines, actually %d", len(expected), len(aofLines)))
+	}
+	for i, line := range aofLines {
+		if string(line[0]) != expected[i] {
+			t.Error(fmt.Sprintf("expected %s, actually %s", expected[i], string(line[0])))
+		}
+	}
+}
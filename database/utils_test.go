@@ -2,13 +2,19 @@ package database
 
 import (
 	"go-redis/datastruct/dict"
+	"sync/atomic"
 )
 
 func makeTestDB() *DB {
 	return &DB{
-		data:   dict.NewSyncDict(),
-		addAof: func(line CmdLine) {
+		data:       dict.NewSyncDict(),
+		ttlMap:     dict.NewSyncDict(),
+		versionMap: dict.NewSyncDict(),
+		locker:     newLockTable(defaultLockCount),
+		waiters:    newWaiterTable(),
+		addAof: func(lines ...CmdLine) {
 
 		},
+		snapshot: &atomic.Pointer[rdbSnapshot]{},
 	}
 }
@@ -0,0 +1,107 @@
+package bitmap
+
+import (
+	"math/bits"
+)
+
+// BitMap 位图，直接使用字符串的字节存储，非并发安全
+// 位序与Redis一致：第0位是第一个字节的最高位
+type BitMap []byte
+
+func FromBytes(bytes []byte) *BitMap {
+	b := BitMap(bytes)
+	return &b
+}
+
+func (b *BitMap) ToBytes() []byte {
+	return *b
+}
+
+// BitSize 返回位图的总位数
+func (b *BitMap) BitSize() int64 {
+	return int64(len(*b)) * 8
+}
+
+// grow 保证位图至少能容纳bitSize位，新增的位为0
+func (b *BitMap) grow(bitSize int64) {
+	byteSize := int((bitSize + 7) / 8)
+	if byteSize <= len(*b) {
+		return
+	}
+	*b = append(*b, make([]byte, byteSize-len(*b))...)
+}
+
+// GetBit 超出长度的位视为0
+func (b *BitMap) GetBit(offset int64) byte {
+	index := offset / 8
+	if index >= int64(len(*b)) {
+		return 0
+	}
+	return ((*b)[index] >> (7 - offset%8)) & 1
+}
+
+// SetBit 设置offset上的位，位图长度不足时自动扩展
+func (b *BitMap) SetBit(offset int64, val byte) {
+	b.grow(offset + 1)
+	index := offset / 8
+	mask := byte(1) << (7 - offset%8)
+	if val > 0 {
+		(*b)[index] |= mask
+	} else {
+		(*b)[index] &^= mask
+	}
+}
+
+// BitCount 统计[start, end]位区间内1的个数，start和end都是合法的位偏移
+func (b *BitMap) BitCount(start, end int64) int64 {
+	var count int64
+	for offset := start; offset <= end; {
+		// 按整字节统计
+		if offset%8 == 0 && offset+7 <= end {
+			count += int64(bits.OnesCount8((*b)[offset/8]))
+			offset += 8
+			continue
+		}
+		count += int64(b.GetBit(offset))
+		offset++
+	}
+	return count
+}
+
+// BitPos 返回[start, end]位区间内第一个值为bit的位，没有找到返回-1
+func (b *BitMap) BitPos(bit byte, start, end int64) int64 {
+	// 整字节全0或全1时可以整体跳过
+	skip := byte(0xff)
+	if bit == 1 {
+		skip = 0
+	}
+	for offset := start; offset <= end; {
+		if offset%8 == 0 && offset+7 <= end && (*b)[offset/8] == skip {
+			offset += 8
+			continue
+		}
+		if b.GetBit(offset) == bit {
+			return offset
+		}
+		offset++
+	}
+	return -1
+}
+
+// GetField 读取从offset开始的width位(width <= 64)，作为无符号整数返回
+func (b *BitMap) GetField(offset int64, width int) uint64 {
+	var value uint64
+	for i := 0; i < width; i++ {
+		value = value<<1 | uint64(b.GetBit(offset+int64(i)))
+	}
+	return value
+}
+
+// SetField 把value的低width位写入从offset开始的位置
+func (b *BitMap) SetField(offset int64, width int, value uint64) {
+	b.grow(offset + int64(width))
+	for i := 0; i < width; i++ {
+		bit := byte(value>>(width-1-i)) & 1
+		b.SetBit(offset+int64(i), bit)
+	}
+}
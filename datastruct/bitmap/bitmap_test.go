@@ -0,0 +1,66 @@
+package bitmap
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSetBit(t *testing.T) {
+	bm := FromBytes(nil)
+	size := int64(1000)
+	expected := make(map[int64]byte)
+	for i := 0; i < 300; i++ {
+		offset := rand.Int63n(size)
+		bit := byte(rand.Intn(2))
+		bm.SetBit(offset, bit)
+		expected[offset] = bit
+	}
+	for offset := int64(0); offset < size+100; offset++ {
+		if bm.GetBit(offset) != expected[offset] {
+			t.Errorf("wrong bit at %d", offset)
+		}
+	}
+
+	// 第0位是第一个字节的最高位
+	bm = FromBytes(nil)
+	bm.SetBit(0, 1)
+	bm.SetBit(15, 1)
+	if bytes := bm.ToBytes(); len(bytes) != 2 || bytes[0] != 0x80 || bytes[1] != 0x01 {
+		t.Errorf("wrong bit order: %v", bytes)
+	}
+}
+
+func TestBitCountAndPos(t *testing.T) {
+	bm := FromBytes([]byte{0xff, 0xf0, 0x00})
+	if count := bm.BitCount(0, bm.BitSize()-1); count != 12 {
+		t.Errorf("expected 12, actually %d", count)
+	}
+	if count := bm.BitCount(5, 9); count != 5 {
+		t.Errorf("expected 5, actually %d", count)
+	}
+	if pos := bm.BitPos(0, 0, bm.BitSize()-1); pos != 12 {
+		t.Errorf("expected 12, actually %d", pos)
+	}
+	if pos := bm.BitPos(1, 12, bm.BitSize()-1); pos != -1 {
+		t.Errorf("expected -1, actually %d", pos)
+	}
+	if pos := bm.BitPos(1, 3, 10); pos != 3 {
+		t.Errorf("expected 3, actually %d", pos)
+	}
+}
+
+func TestField(t *testing.T) {
+	bm := FromBytes(nil)
+	bm.SetField(3, 13, 0x1abc)
+	if value := bm.GetField(3, 13); value != 0x1abc {
+		t.Errorf("expected %x, actually %x", 0x1abc, value)
+	}
+	bm.SetField(100, 64, ^uint64(0)-1)
+	if value := bm.GetField(100, 64); value != ^uint64(0)-1 {
+		t.Errorf("expected %x, actually %x", ^uint64(0)-1, value)
+	}
+	// 写入不影响相邻的位
+	if value := bm.GetField(3, 13); value != 0x1abc {
+		t.Errorf("expected %x, actually %x", 0x1abc, value)
+	}
+}
@@ -0,0 +1,102 @@
+package dict
+
+// SimpleDict 基于普通map实现的Dict，非并发安全，
+// 用于hash等数据结构内部存储field，由上层保证并发安全
+type SimpleDict struct {
+	m map[string]interface{}
+}
+
+func NewSimpleDict() *SimpleDict {
+	return &SimpleDict{
+		m: make(map[string]interface{}),
+	}
+}
+
+func (d *SimpleDict) Get(key string) (val interface{}, exist bool) {
+	val, exist = d.m[key]
+	return
+}
+
+func (d *SimpleDict) Len() int {
+	return len(d.m)
+}
+
+// Put 插入新键值对返回1，修改已有键返回0
+func (d *SimpleDict) Put(key string, value interface{}) int {
+	_, exist := d.m[key]
+	d.m[key] = value
+	if exist {
+		return 0
+	}
+	return 1
+}
+
+func (d *SimpleDict) PutIfAbsent(key string, value interface{}) int {
+	if _, exist := d.m[key]; exist {
+		return 0
+	}
+	d.m[key] = value
+	return 1
+}
+
+func (d *SimpleDict) PutIfExist(key string, value interface{}) int {
+	if _, exist := d.m[key]; exist {
+		d.m[key] = value
+		return 1
+	}
+	return 0
+}
+
+func (d *SimpleDict) Remove(key string) int {
+	if _, exist := d.m[key]; exist {
+		delete(d.m, key)
+		return 1
+	}
+	return 0
+}
+
+func (d *SimpleDict) ForEach(consumer Consumer) {
+	for k, v := range d.m {
+		if !consumer(k, v) {
+			break
+		}
+	}
+}
+
+func (d *SimpleDict) Keys() []string {
+	keys := make([]string, 0, len(d.m))
+	for k := range d.m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (d *SimpleDict) RandomKeys(limit int) []string {
+	keys := make([]string, limit)
+	for i := 0; i < limit; i++ {
+		for k := range d.m { // map每次遍历的起点是随机的
+			keys[i] = k
+			break
+		}
+	}
+	return keys
+}
+
+func (d *SimpleDict) RandomDistinctKeys(limit int) []string {
+	size := limit
+	if size > len(d.m) {
+		size = len(d.m)
+	}
+	keys := make([]string, 0, size)
+	for k := range d.m {
+		if len(keys) == size {
+			break
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (d *SimpleDict) Clear() {
+	d.m = make(map[string]interface{})
+}
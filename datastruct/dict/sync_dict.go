@@ -31,8 +31,7 @@ func (d *SyncDict) Len() int {
 // Put 向SyncDict中插入一个新的键值对
 // 如果key不存在，插入后返回1，存在的话，修改成新值，返回0
 func (d *SyncDict) Put(key string, value interface{}) int {
-	_, exist := d.Get(key)
-	d.m.Store(key, value)
+	_, exist := d.m.Swap(key, value)
 	if exist {
 		return 0
 	}
@@ -40,9 +39,8 @@ func (d *SyncDict) Put(key string, value interface{}) int {
 }
 
 func (d *SyncDict) PutIfAbsent(key string, value interface{}) int {
-	_, exist := d.Get(key)
+	_, exist := d.m.LoadOrStore(key, value)
 	if !exist {
-		d.m.Store(key, value)
 		return 1
 	}
 	return 0
@@ -58,11 +56,10 @@ func (d *SyncDict) PutIfExist(key string, value interface{}) int {
 }
 
 func (d *SyncDict) Remove(key string) int {
-	_, exist := d.Get(key)
+	_, exist := d.m.LoadAndDelete(key)
 	if !exist {
 		return 0
 	}
-	d.m.Delete(key)
 	return 1
 }
 
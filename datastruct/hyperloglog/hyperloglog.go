@@ -0,0 +1,412 @@
+package hyperloglog
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+/*
+	HyperLogLog 与Redis使用相同的存储格式，因此可以作为普通字符串GET/SET
+	header(16字节): "HYLL" | 编码(1字节) | 未使用(3字节) | 基数缓存(8字节，小端，最高位为1表示缓存失效)
+	dense编码: 16384个6位寄存器紧密排列
+	sparse编码: 由以下操作码组成的游程编码
+		ZERO  00xxxxxx          连续 xxxxxx+1 个寄存器为0
+		XZERO 01xxxxxx yyyyyyyy 连续 xxxxxxyyyyyyyy+1 个寄存器为0
+		VAL   1vvvvvxx          连续 xx+1 个寄存器的值为 vvvvv+1
+*/
+
+const (
+	p              = 14 // 寄存器下标使用的位数
+	q              = 64 - p
+	Registers      = 1 << p
+	registerBits   = 6
+	registerMax    = 1<<registerBits - 1
+	headerSize     = 16
+	denseSize      = headerSize + (Registers*registerBits+7)/8
+	encodingDense  = 0
+	encodingSparse = 1
+
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+	// sparse编码超过这个长度时转为dense，与Redis的hll-sparse-max-bytes默认值一致
+	sparseMaxBytes = 3000
+
+	alphaInf = 0.721347520444481703680 // 0.5/ln(2)
+	hashSeed = 0xadc83b19
+)
+
+var magic = []byte("HYLL")
+
+// HLL 直接使用字符串的字节存储，非并发安全
+type HLL []byte
+
+// New 返回一个空的sparse编码HLL
+func New() *HLL {
+	h := HLL(newHeader(encodingSparse))
+	h = append(h, encodeSparse(make([]uint8, Registers))...)
+	return &h
+}
+
+func newHeader(encoding byte) []byte {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[4] = encoding
+	// 新建的HLL基数为0，缓存有效
+	return header
+}
+
+// FromBytes 检查bytes是否是合法的HLL，不合法时ok为false
+func FromBytes(bytes []byte) (h *HLL, ok bool) {
+	if len(bytes) < headerSize || string(bytes[:4]) != string(magic) {
+		return nil, false
+	}
+	switch bytes[4] {
+	case encodingDense:
+		if len(bytes) != denseSize {
+			return nil, false
+		}
+	case encodingSparse:
+		if _, ok := decodeSparse(bytes[headerSize:]); !ok {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	hll := HLL(bytes)
+	return &hll, true
+}
+
+func (h *HLL) ToBytes() []byte {
+	return *h
+}
+
+func (h *HLL) isDense() bool {
+	return (*h)[4] == encodingDense
+}
+
+func (h *HLL) invalidateCache() {
+	(*h)[15] |= 0x80
+}
+
+// CachedCount 返回缓存的基数，缓存失效时ok为false
+func (h *HLL) CachedCount() (count uint64, ok bool) {
+	if (*h)[15]&0x80 != 0 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64((*h)[8:16]), true
+}
+
+func (h *HLL) setCachedCount(count uint64) {
+	binary.LittleEndian.PutUint64((*h)[8:16], count)
+}
+
+/* ---- hash ---- */
+
+// murmurHash64A 与Redis使用的哈希函数一致
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+	tail := len(key) - len(key)%8
+	for i := 0; i < tail; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	rest := key[tail:]
+	if len(rest) > 0 {
+		for i := len(rest) - 1; i >= 0; i-- {
+			h ^= uint64(rest[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// patternLen 返回元素对应的寄存器下标，以及哈希剩余部分末尾连续0的个数+1
+func patternLen(element []byte) (index int, count uint8) {
+	hash := murmurHash64A(element, hashSeed)
+	index = int(hash & (Registers - 1))
+	hash >>= p
+	hash |= 1 << q // 保证循环能结束，count最大为q+1
+	count = 1
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+/* ---- dense ---- */
+
+func denseGet(registers []byte, index int) uint8 {
+	byteIndex := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	b0 := uint(registers[byteIndex])
+	var b1 uint
+	if byteIndex+1 < len(registers) {
+		b1 = uint(registers[byteIndex+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & registerMax)
+}
+
+func denseSet(registers []byte, index int, value uint8) {
+	byteIndex := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	v := uint(value)
+	registers[byteIndex] &^= byte(registerMax << fb)
+	registers[byteIndex] |= byte(v << fb)
+	if byteIndex+1 < len(registers) {
+		registers[byteIndex+1] &^= byte(registerMax >> (8 - fb))
+		registers[byteIndex+1] |= byte(v >> (8 - fb))
+	}
+}
+
+func decodeDense(registers []byte) []uint8 {
+	result := make([]uint8, Registers)
+	for i := range result {
+		result[i] = denseGet(registers, i)
+	}
+	return result
+}
+
+func encodeDense(regs []uint8) []byte {
+	registers := make([]byte, denseSize-headerSize)
+	for i, value := range regs {
+		denseSet(registers, i, value)
+	}
+	return registers
+}
+
+/* ---- sparse ---- */
+
+// decodeSparse 解码sparse编码，寄存器总数不等于Registers时ok为false
+func decodeSparse(data []byte) (regs []uint8, ok bool) {
+	regs = make([]uint8, Registers)
+	index := 0
+	for i := 0; i < len(data); i++ {
+		op := data[i]
+		var runLen int
+		var value uint8
+		switch {
+		case op&0xc0 == 0x00: // ZERO
+			runLen = int(op&0x3f) + 1
+		case op&0xc0 == 0x40: // XZERO
+			if i+1 >= len(data) {
+				return nil, false
+			}
+			runLen = (int(op&0x3f)<<8 | int(data[i+1])) + 1
+			i++
+		default: // VAL
+			value = (op>>2)&0x1f + 1
+			runLen = int(op&0x03) + 1
+		}
+		if index+runLen > Registers {
+			return nil, false
+		}
+		for j := 0; j < runLen; j++ {
+			regs[index+j] = value
+		}
+		index += runLen
+	}
+	return regs, index == Registers
+}
+
+// encodeSparse 对寄存器做游程编码，调用前需保证所有值都不超过sparseValMaxValue
+func encodeSparse(regs []uint8) []byte {
+	data := make([]byte, 0)
+	for i := 0; i < len(regs); {
+		value := regs[i]
+		runLen := 1
+		for i+runLen < len(regs) && regs[i+runLen] == value {
+			runLen++
+		}
+		i += runLen
+		for runLen > 0 {
+			if value == 0 {
+				if runLen > sparseZeroMaxLen {
+					n := runLen
+					if n > sparseXZeroMaxLen {
+						n = sparseXZeroMaxLen
+					}
+					data = append(data, byte(0x40|(n-1)>>8), byte((n-1)&0xff))
+					runLen -= n
+				} else {
+					data = append(data, byte(runLen-1))
+					runLen = 0
+				}
+				continue
+			}
+			n := runLen
+			if n > sparseValMaxLen {
+				n = sparseValMaxLen
+			}
+			data = append(data, byte(0x80|(value-1)<<2|uint8(n-1)))
+			runLen -= n
+		}
+	}
+	return data
+}
+
+/* ---- 对外接口 ---- */
+
+// registers 解码出全部寄存器的值
+func (h *HLL) registers() []uint8 {
+	if h.isDense() {
+		return decodeDense((*h)[headerSize:])
+	}
+	regs, _ := decodeSparse((*h)[headerSize:])
+	return regs
+}
+
+// setRegisters 写入全部寄存器；dense编码保持dense，sparse编码放不下时转为dense
+func (h *HLL) setRegisters(regs []uint8) {
+	header := make([]byte, headerSize)
+	copy(header, (*h)[:headerSize])
+	if !h.isDense() {
+		fitSparse := true
+		for _, value := range regs {
+			if value > sparseValMaxValue {
+				fitSparse = false
+				break
+			}
+		}
+		if fitSparse {
+			if data := encodeSparse(regs); len(data) <= sparseMaxBytes {
+				*h = append(header, data...)
+				h.invalidateCache()
+				return
+			}
+		}
+		header[4] = encodingDense
+	}
+	*h = append(header, encodeDense(regs)...)
+	h.invalidateCache()
+}
+
+// Add 添加元素，有寄存器被修改时返回true
+func (h *HLL) Add(elements ...[]byte) bool {
+	changed := false
+	if h.isDense() {
+		registers := (*h)[headerSize:]
+		for _, element := range elements {
+			index, count := patternLen(element)
+			if count > denseGet(registers, index) {
+				denseSet(registers, index, count)
+				changed = true
+			}
+		}
+		if changed {
+			h.invalidateCache()
+		}
+		return changed
+	}
+
+	regs := h.registers()
+	for _, element := range elements {
+		index, count := patternLen(element)
+		if count > regs[index] {
+			regs[index] = count
+			changed = true
+		}
+	}
+	if changed {
+		h.setRegisters(regs)
+	}
+	return changed
+}
+
+// Count 返回估计的基数，缓存失效时重新计算并更新缓存
+func (h *HLL) Count() uint64 {
+	if count, ok := h.CachedCount(); ok {
+		return count
+	}
+	count := countRegisters(h.registers())
+	h.setCachedCount(count)
+	return count
+}
+
+// Merge 把others合并到h中，每个寄存器取最大值
+func (h *HLL) Merge(others ...*HLL) {
+	regs := h.registers()
+	for _, other := range others {
+		mergeRegisters(regs, other.registers())
+	}
+	h.setRegisters(regs)
+}
+
+// Count 返回多个HLL并集的估计基数，不修改它们
+func Count(hlls ...*HLL) uint64 {
+	regs := make([]uint8, Registers)
+	for _, h := range hlls {
+		mergeRegisters(regs, h.registers())
+	}
+	return countRegisters(regs)
+}
+
+func mergeRegisters(dest []uint8, src []uint8) {
+	for i, value := range src {
+		if value > dest[i] {
+			dest[i] = value
+		}
+	}
+}
+
+/* ---- 基数估计，使用与Redis相同的Ertl改进算法 ---- */
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+func countRegisters(regs []uint8) uint64 {
+	m := float64(Registers)
+	// dense寄存器最大为63，这里按64分配，以容忍外部SET进来的数据
+	histogram := make([]int, registerMax+1)
+	for _, value := range regs {
+		histogram[value]++
+	}
+	z := m * tau((m-float64(histogram[q+1]))/m)
+	for j := q; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return uint64(math.Round(alphaInf * m * m / z))
+}
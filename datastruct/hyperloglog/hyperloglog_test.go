@@ -0,0 +1,119 @@
+package hyperloglog
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func assertApprox(t *testing.T, actual uint64, expected int) {
+	t.Helper()
+	// 标准误差约0.81%，这里放宽到3%
+	if math.Abs(float64(actual)-float64(expected)) > float64(expected)*0.03 {
+		t.Errorf("expected about %d, actually %d", expected, actual)
+	}
+}
+
+func TestAddAndCount(t *testing.T) {
+	h := New()
+	if count := h.Count(); count != 0 {
+		t.Errorf("expected 0, actually %d", count)
+	}
+	for i := 0; i < 7; i++ {
+		h.Add([]byte(strconv.Itoa(i)))
+	}
+	if count := h.Count(); count != 7 {
+		t.Errorf("expected 7, actually %d", count)
+	}
+	// 重复添加不会修改寄存器
+	if h.Add([]byte("1")) {
+		t.Error("adding an existing element should not change registers")
+	}
+
+	size := 100000
+	for i := 0; i < size; i++ {
+		h.Add([]byte("element" + strconv.Itoa(i)))
+		if i == 100 && h.isDense() {
+			t.Error("small hll should use sparse encoding")
+		}
+	}
+	if !h.isDense() {
+		t.Error("large hll should be promoted to dense encoding")
+	}
+	assertApprox(t, h.Count(), size+7)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{10, 50000} {
+		h := New()
+		for i := 0; i < size; i++ {
+			h.Add([]byte(strconv.Itoa(i)))
+		}
+		count := h.Count()
+		// 缓存被写入header后，重新解析得到相同的结果
+		h2, ok := FromBytes(append([]byte{}, h.ToBytes()...))
+		if !ok {
+			t.Fatal("valid hll rejected")
+		}
+		if c, ok := h2.CachedCount(); !ok || c != count {
+			t.Errorf("expected cached count %d, actually %d", count, c)
+		}
+		h2.invalidateCache()
+		if c := h2.Count(); c != count {
+			t.Errorf("expected %d, actually %d", count, c)
+		}
+	}
+
+	for _, bytes := range [][]byte{
+		[]byte("HYLL"),
+		[]byte("hello world, this is not a hll"),
+		append([]byte("HYLL\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), make([]byte, 10)...),
+		[]byte("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f"),
+	} {
+		if _, ok := FromBytes(bytes); ok {
+			t.Errorf("invalid hll accepted: %q", bytes)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	h1, h2 := New(), New()
+	for i := 0; i < 30000; i++ {
+		h1.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 20000; i < 50000; i++ {
+		h2.Add([]byte(strconv.Itoa(i)))
+	}
+	assertApprox(t, Count(h1, h2), 50000)
+	// Count不修改参数
+	assertApprox(t, h1.Count(), 30000)
+
+	dest := New()
+	dest.Merge(h1, h2)
+	assertApprox(t, dest.Count(), 50000)
+
+	sparse := New()
+	sparse.Add([]byte("a"), []byte("b"))
+	sparse.Merge(New())
+	if sparse.isDense() || sparse.Count() != 2 {
+		t.Error("merging small hlls should keep sparse encoding")
+	}
+}
+
+func TestSparseEncoding(t *testing.T) {
+	regs := make([]uint8, Registers)
+	regs[0] = 1
+	regs[100] = 32
+	for i := 200; i < 210; i++ {
+		regs[i] = 5
+	}
+	decoded, ok := decodeSparse(encodeSparse(regs))
+	if !ok {
+		t.Fatal("decode failed")
+	}
+	for i := range regs {
+		if regs[i] != decoded[i] {
+			t.Fatalf("register %d: expected %d, actually %d", i, regs[i], decoded[i])
+		}
+	}
+}
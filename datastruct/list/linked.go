@@ -226,6 +226,20 @@ func (l *LinkList) ForEach(consumer func(int, interface{}) bool) {
 	}
 }
 
+// ReverseForEach 从尾部向头部遍历，index仍是元素从头部开始的下标
+func (l *LinkList) ReverseForEach(consumer func(int, interface{}) bool) {
+	if l == nil {
+		panic("list is nil")
+	}
+	i := l.size - 1
+	for n := l.tail; n != nil; n = n.prev {
+		if !consumer(i, n.val) {
+			break
+		}
+		i --
+	}
+}
+
 func (l *LinkList) Contains(val interface{}) bool {
 	if l == nil {
 		panic("list is nil")
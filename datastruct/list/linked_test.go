@@ -223,3 +223,23 @@ func TestRange(t *testing.T) {
 		}
 	}
 }
+
+func TestReverseForEach(t *testing.T) {
+	list := New()
+	size := 10
+	for i := 0; i < size; i++ {
+		list.Add(i)
+	}
+	expected := size - 1
+	list.ReverseForEach(func(index int, val interface{}) bool {
+		intVal, _ := val.(int)
+		if index != expected || intVal != expected {
+			t.Error("expected " + strconv.Itoa(expected) + ", get: " + strconv.Itoa(intVal))
+		}
+		expected--
+		return expected >= 5
+	})
+	if expected != 4 {
+		t.Error("ReverseForEach should stop when consumer returns false")
+	}
+}
@@ -0,0 +1,20 @@
+package list
+
+// List 列表的公共接口，LinkList 和 QuickList 都实现了它
+// 下标从0开始，越界时panic，由调用方保证下标合法
+type List interface {
+	Add(val interface{})
+	Get(index int) (val interface{})
+	Set(index int, val interface{})
+	Insert(index int, val interface{})
+	Remove(index int) (val interface{})
+	RemoveLast() (val interface{})
+	RemoveAllByVal(val interface{}) int
+	RemoveByVal(val interface{}, count int) int
+	ReverseRemoveByVal(val interface{}, count int) int
+	Len() int
+	ForEach(consumer func(int, interface{}) bool)
+	ReverseForEach(consumer func(int, interface{}) bool)
+	Contains(val interface{}) bool
+	Range(start, stop int) []interface{}
+}
@@ -0,0 +1,401 @@
+package list
+
+import (
+	"go-redis/lib/utils"
+)
+
+// pageSize 每页最多存放的元素个数
+const pageSize = 1024
+
+/*
+	QuickList 由若干页组成的双向链表，每页是一个最多存放pageSize个元素的切片
+	相比每个元素一个节点的LinkList，指针开销小得多；按下标查找时按页跳过，
+	并根据下标离头部还是尾部更近决定从哪一端开始找
+ */
+type QuickList struct {
+	head *page
+	tail *page
+	size int
+}
+
+type page struct {
+	vals []interface{}
+	prev *page
+	next *page
+}
+
+// iterator 指向某一页中的某个元素，p为nil表示位于列表末尾之后
+type iterator struct {
+	p      *page
+	offset int
+	ql     *QuickList
+}
+
+func NewQuickList(vals ...interface{}) *QuickList {
+	ql := &QuickList{}
+	for _, v := range vals {
+		ql.Add(v)
+	}
+	return ql
+}
+
+// 在尾部插入一页
+func (ql *QuickList) pushPage(p *page) {
+	if ql.tail == nil {
+		ql.head = p
+		ql.tail = p
+		return
+	}
+	p.prev = ql.tail
+	ql.tail.next = p
+	ql.tail = p
+}
+
+// 在pivot之后插入一页
+func (ql *QuickList) insertPageAfter(pivot *page, p *page) {
+	p.prev = pivot
+	p.next = pivot.next
+	if pivot.next == nil {
+		ql.tail = p
+	} else {
+		pivot.next.prev = p
+	}
+	pivot.next = p
+}
+
+func (ql *QuickList) removePage(p *page) {
+	if p.prev == nil {
+		ql.head = p.next
+	} else {
+		p.prev.next = p.next
+	}
+	if p.next == nil {
+		ql.tail = p.prev
+	} else {
+		p.next.prev = p.prev
+	}
+	p.prev = nil
+	p.next = nil
+}
+
+// 向尾部增加元素
+func (ql *QuickList) Add(val interface{}) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if ql.tail == nil || len(ql.tail.vals) >= pageSize {
+		// 页的容量随append增长，元素很少的列表不会预先分配整页
+		ql.pushPage(&page{})
+	}
+	ql.tail.vals = append(ql.tail.vals, val)
+	ql.size++
+}
+
+// find 返回指向第index个元素的迭代器，调用方保证 0 <= index < size
+func (ql *QuickList) find(index int) *iterator {
+	var p *page
+	var pageBeg int
+	if index < ql.size/2 {
+		p = ql.head
+		pageBeg = 0
+		for index >= pageBeg+len(p.vals) {
+			pageBeg += len(p.vals)
+			p = p.next
+		}
+	} else {
+		p = ql.tail
+		pageBeg = ql.size - len(p.vals)
+		for index < pageBeg {
+			p = p.prev
+			pageBeg -= len(p.vals)
+		}
+	}
+	return &iterator{
+		p:      p,
+		offset: index - pageBeg,
+		ql:     ql,
+	}
+}
+
+func (iter *iterator) get() interface{} {
+	return iter.p.vals[iter.offset]
+}
+
+func (iter *iterator) set(val interface{}) {
+	iter.p.vals[iter.offset] = val
+}
+
+// next 移动到下一个元素，已经是最后一个元素时返回false
+func (iter *iterator) next() bool {
+	if iter.p == nil {
+		return false
+	}
+	iter.offset++
+	if iter.offset < len(iter.p.vals) {
+		return true
+	}
+	iter.p = iter.p.next
+	iter.offset = 0
+	return iter.p != nil
+}
+
+// prev 移动到上一个元素，已经是第一个元素时返回false
+func (iter *iterator) prev() bool {
+	if iter.p == nil {
+		// 位于末尾之后，移动到最后一个元素
+		if iter.ql.tail == nil {
+			return false
+		}
+		iter.p = iter.ql.tail
+		iter.offset = len(iter.p.vals) - 1
+		return true
+	}
+	if iter.offset > 0 {
+		iter.offset--
+		return true
+	}
+	if iter.p.prev == nil {
+		return false
+	}
+	iter.p = iter.p.prev
+	iter.offset = len(iter.p.vals) - 1
+	return true
+}
+
+// remove 删除当前元素，之后迭代器指向被删除元素的下一个元素
+func (iter *iterator) remove() interface{} {
+	vals := iter.p.vals
+	val := vals[iter.offset]
+	if iter.offset == 0 {
+		// 从页首弹出(LPOP)时不需要移动后面的元素
+		vals[0] = nil
+		vals = vals[1:]
+	} else {
+		copy(vals[iter.offset:], vals[iter.offset+1:])
+		vals[len(vals)-1] = nil
+		vals = vals[:len(vals)-1]
+	}
+	iter.ql.size--
+	if len(vals) == 0 {
+		next := iter.p.next
+		iter.ql.removePage(iter.p)
+		iter.p = next
+		iter.offset = 0
+		return val
+	}
+	iter.p.vals = vals
+	if iter.offset == len(vals) {
+		iter.p = iter.p.next
+		iter.offset = 0
+	}
+	return val
+}
+
+func (ql *QuickList) Get(index int) (val interface{}) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	return ql.find(index).get()
+}
+
+func (ql *QuickList) Set(index int, val interface{}) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	ql.find(index).set(val)
+}
+
+// Insert 插入元素使其下标为index，页满时把后一半元素拆分到新的一页
+func (ql *QuickList) Insert(index int, val interface{}) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if index < 0 || index > ql.size {
+		panic("index out of bound")
+	}
+	if index == ql.size {
+		ql.Add(val)
+		return
+	}
+	iter := ql.find(index)
+	p := iter.p
+	if len(p.vals) >= pageSize {
+		half := pageSize / 2
+		nextPage := &page{
+			vals: make([]interface{}, 0, len(p.vals)-half+1),
+		}
+		nextPage.vals = append(nextPage.vals, p.vals[half:]...)
+		for i := half; i < len(p.vals); i++ {
+			p.vals[i] = nil
+		}
+		p.vals = p.vals[:half]
+		ql.insertPageAfter(p, nextPage)
+		if iter.offset >= half {
+			p = nextPage
+			iter.offset -= half
+		}
+	}
+	p.vals = append(p.vals, nil)
+	copy(p.vals[iter.offset+1:], p.vals[iter.offset:])
+	p.vals[iter.offset] = val
+	ql.size++
+}
+
+func (ql *QuickList) Remove(index int) (val interface{}) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	return ql.find(index).remove()
+}
+
+func (ql *QuickList) RemoveLast() (val interface{}) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if ql.tail == nil {
+		return nil
+	}
+	iter := &iterator{
+		p:      ql.tail,
+		offset: len(ql.tail.vals) - 1,
+		ql:     ql,
+	}
+	return iter.remove()
+}
+
+func (ql *QuickList) RemoveAllByVal(val interface{}) int {
+	if ql == nil {
+		panic("list is nil")
+	}
+	return ql.RemoveByVal(val, 0)
+}
+
+// RemoveByVal 从头部开始删除最多count个等于val的元素，count为0时删除全部
+func (ql *QuickList) RemoveByVal(val interface{}, count int) int {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if ql.size == 0 {
+		return 0
+	}
+	removed := 0
+	iter := ql.find(0)
+	for iter.p != nil {
+		if !utils.Equals(val, iter.get()) {
+			iter.next()
+			continue
+		}
+		iter.remove()
+		removed++
+		if removed == count {
+			break
+		}
+	}
+	return removed
+}
+
+// ReverseRemoveByVal 从尾部开始删除最多count个等于val的元素
+func (ql *QuickList) ReverseRemoveByVal(val interface{}, count int) int {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if ql.size == 0 {
+		return 0
+	}
+	removed := 0
+	iter := ql.find(ql.size - 1)
+	for {
+		if utils.Equals(val, iter.get()) {
+			iter.remove()
+			removed++
+			if removed == count {
+				break
+			}
+		}
+		if !iter.prev() {
+			break
+		}
+	}
+	return removed
+}
+
+func (ql *QuickList) Len() int {
+	if ql == nil {
+		panic("list is nil")
+	}
+	return ql.size
+}
+
+func (ql *QuickList) ForEach(consumer func(int, interface{}) bool) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	i := 0
+	for p := ql.head; p != nil; p = p.next {
+		for _, v := range p.vals {
+			if !consumer(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// ReverseForEach 从尾部向头部遍历，index仍是元素从头部开始的下标
+func (ql *QuickList) ReverseForEach(consumer func(int, interface{}) bool) {
+	if ql == nil {
+		panic("list is nil")
+	}
+	i := ql.size - 1
+	for p := ql.tail; p != nil; p = p.prev {
+		for j := len(p.vals) - 1; j >= 0; j-- {
+			if !consumer(i, p.vals[j]) {
+				return
+			}
+			i--
+		}
+	}
+}
+
+func (ql *QuickList) Contains(val interface{}) bool {
+	if ql == nil {
+		panic("list is nil")
+	}
+	contains := false
+	ql.ForEach(func(index int, v interface{}) bool {
+		if utils.Equals(v, val) {
+			contains = true
+			return false
+		}
+		return true
+	})
+	return contains
+}
+
+func (ql *QuickList) Range(start, stop int) []interface{} {
+	if ql == nil {
+		panic("list is nil")
+	}
+	if start < 0 || start >= ql.size {
+		panic("`start` out of range")
+	}
+	if stop < start || stop > ql.size {
+		panic("`stop` out of range")
+	}
+	result := make([]interface{}, 0, stop-start)
+	iter := ql.find(start)
+	for i := start; i < stop; i++ {
+		result = append(result, iter.get())
+		iter.next()
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package list
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+var _ List = &LinkList{}
+var _ List = &QuickList{}
+
+// 检查list中的元素与expected一致
+func assertListEquals(t *testing.T, list List, expected []int) {
+	t.Helper()
+	if list.Len() != len(expected) {
+		t.Fatal("expected size " + strconv.Itoa(len(expected)) + ", actual: " + strconv.Itoa(list.Len()))
+	}
+	list.ForEach(func(i int, v interface{}) bool {
+		intVal, _ := v.(int)
+		if intVal != expected[i] {
+			t.Fatal("at " + strconv.Itoa(i) + " expected " + strconv.Itoa(expected[i]) + ", actual: " + strconv.Itoa(intVal))
+		}
+		return true
+	})
+	list.ReverseForEach(func(i int, v interface{}) bool {
+		intVal, _ := v.(int)
+		if intVal != expected[i] {
+			t.Fatal("reverse at " + strconv.Itoa(i) + " expected " + strconv.Itoa(expected[i]) + ", actual: " + strconv.Itoa(intVal))
+		}
+		return true
+	})
+}
+
+func TestQuickListGetSet(t *testing.T) {
+	size := pageSize*3 + 7
+	list := NewQuickList()
+	expected := make([]int, 0, size)
+	for i := 0; i < size; i++ {
+		list.Add(i)
+		expected = append(expected, i)
+	}
+	for i := 0; i < size; i++ {
+		if v, _ := list.Get(i).(int); v != i {
+			t.Error("get test fail: expected " + strconv.Itoa(i) + ", actual: " + strconv.Itoa(v))
+		}
+	}
+	for i := 0; i < size; i += 3 {
+		list.Set(i, -i)
+		expected[i] = -i
+	}
+	assertListEquals(t, list, expected)
+	slice := list.Range(pageSize-1, pageSize*2+1)
+	for i, v := range slice {
+		if intVal, _ := v.(int); intVal != expected[pageSize-1+i] {
+			t.Error("range test fail at " + strconv.Itoa(i))
+		}
+	}
+}
+
+func TestQuickListInsertRemove(t *testing.T) {
+	// 与切片的结果对比，元素数量跨越多页，覆盖页拆分和页删除
+	list := NewQuickList()
+	expected := make([]int, 0)
+	for i := 0; i < pageSize*4; i++ {
+		index := rand.Intn(len(expected) + 1)
+		list.Insert(index, i)
+		expected = append(expected, 0)
+		copy(expected[index+1:], expected[index:])
+		expected[index] = i
+	}
+	assertListEquals(t, list, expected)
+
+	for len(expected) > pageSize {
+		index := rand.Intn(len(expected))
+		val, _ := list.Remove(index).(int)
+		if val != expected[index] {
+			t.Fatal("remove test fail: expected " + strconv.Itoa(expected[index]) + ", actual: " + strconv.Itoa(val))
+		}
+		expected = append(expected[:index], expected[index+1:]...)
+	}
+	assertListEquals(t, list, expected)
+
+	for len(expected) > 0 {
+		val, _ := list.RemoveLast().(int)
+		if val != expected[len(expected)-1] {
+			t.Fatal("remove last test fail")
+		}
+		expected = expected[:len(expected)-1]
+	}
+	assertListEquals(t, list, expected)
+	if list.RemoveLast() != nil {
+		t.Error("remove last of empty list should return nil")
+	}
+}
+
+func TestQuickListRemoveVal(t *testing.T) {
+	size := pageSize * 3
+	list := NewQuickList()
+	for i := 0; i < size; i++ {
+		list.Add(i % 3)
+	}
+	if removed := list.RemoveByVal(0, 2); removed != 2 {
+		t.Error("expected 2, actual: " + strconv.Itoa(removed))
+	}
+	if v, _ := list.Get(0).(int); v != 1 {
+		t.Error("expected 1, actual: " + strconv.Itoa(v))
+	}
+	if removed := list.ReverseRemoveByVal(2, 2); removed != 2 {
+		t.Error("expected 2, actual: " + strconv.Itoa(removed))
+	}
+	if v, _ := list.Get(list.Len() - 1).(int); v != 1 {
+		t.Error("expected 1, actual: " + strconv.Itoa(v))
+	}
+	if removed := list.RemoveAllByVal(1); removed != pageSize {
+		t.Error("expected " + strconv.Itoa(pageSize) + ", actual: " + strconv.Itoa(removed))
+	}
+	if removed := list.ReverseRemoveByVal(2, 0); removed != pageSize-2 {
+		t.Error("expected " + strconv.Itoa(pageSize-2) + ", actual: " + strconv.Itoa(removed))
+	}
+	expected := make([]int, pageSize-2)
+	assertListEquals(t, list, expected)
+	if list.Contains(1) || !list.Contains(0) {
+		t.Error("contains test fail")
+	}
+}
+
+const benchmarkSize = 100000
+
+func benchmarkGet(b *testing.B, list List) {
+	for i := 0; i < benchmarkSize; i++ {
+		list.Add(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list.Get(rand.Intn(benchmarkSize))
+	}
+}
+
+func benchmarkInsert(b *testing.B, list List) {
+	for i := 0; i < benchmarkSize; i++ {
+		list.Add(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list.Insert(rand.Intn(list.Len()+1), i)
+	}
+}
+
+func benchmarkAddAndPop(b *testing.B, list List) {
+	for i := 0; i < b.N; i++ {
+		list.Add(i)
+	}
+	for i := 0; i < b.N; i++ {
+		list.Remove(0)
+	}
+}
+
+func BenchmarkLinkListGet(b *testing.B) {
+	benchmarkGet(b, New())
+}
+
+func BenchmarkQuickListGet(b *testing.B) {
+	benchmarkGet(b, NewQuickList())
+}
+
+func BenchmarkLinkListInsert(b *testing.B) {
+	benchmarkInsert(b, New())
+}
+
+func BenchmarkQuickListInsert(b *testing.B) {
+	benchmarkInsert(b, NewQuickList())
+}
+
+func BenchmarkLinkListAddAndPop(b *testing.B) {
+	benchmarkAddAndPop(b, New())
+}
+
+func BenchmarkQuickListAddAndPop(b *testing.B) {
+	benchmarkAddAndPop(b, NewQuickList())
+}
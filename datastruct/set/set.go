@@ -0,0 +1,101 @@
+package set
+
+import (
+	"go-redis/datastruct/dict"
+)
+
+// Set 无序集合，基于dict实现，非并发安全
+type Set struct {
+	dict dict.Dict
+}
+
+func New(members ...string) *Set {
+	s := &Set{
+		dict: dict.NewSimpleDict(),
+	}
+	for _, member := range members {
+		s.Add(member)
+	}
+	return s
+}
+
+// Add 添加成员，新成员返回1，已存在返回0
+func (s *Set) Add(val string) int {
+	return s.dict.Put(val, nil)
+}
+
+// Remove 删除成员，成功返回1，不存在返回0
+func (s *Set) Remove(val string) int {
+	return s.dict.Remove(val)
+}
+
+func (s *Set) Has(val string) bool {
+	_, exists := s.dict.Get(val)
+	return exists
+}
+
+func (s *Set) Len() int {
+	return s.dict.Len()
+}
+
+func (s *Set) ToSlice() []string {
+	return s.dict.Keys()
+}
+
+func (s *Set) ForEach(consumer func(member string) bool) {
+	s.dict.ForEach(func(key string, val interface{}) bool {
+		return consumer(key)
+	})
+}
+
+// Intersect 返回s与another的交集
+func (s *Set) Intersect(another *Set) *Set {
+	result := New()
+	small, large := s, another
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+	small.ForEach(func(member string) bool {
+		if large.Has(member) {
+			result.Add(member)
+		}
+		return true
+	})
+	return result
+}
+
+// Union 返回s与another的并集
+func (s *Set) Union(another *Set) *Set {
+	result := New()
+	s.ForEach(func(member string) bool {
+		result.Add(member)
+		return true
+	})
+	another.ForEach(func(member string) bool {
+		result.Add(member)
+		return true
+	})
+	return result
+}
+
+// Diff 返回属于s但不属于another的成员
+func (s *Set) Diff(another *Set) *Set {
+	result := New()
+	s.ForEach(func(member string) bool {
+		if !another.Has(member) {
+			result.Add(member)
+		}
+		return true
+	})
+	return result
+}
+
+// RandomMembers 随机返回limit个成员，可能重复
+func (s *Set) RandomMembers(limit int) []string {
+	return s.dict.RandomKeys(limit)
+}
+
+// RandomDistinctMembers 随机返回最多limit个不重复的成员
+func (s *Set) RandomDistinctMembers(limit int) []string {
+	return s.dict.RandomDistinctKeys(limit)
+}
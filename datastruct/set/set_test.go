@@ -0,0 +1,53 @@
+package set
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func sorted(members []string) []string {
+	sort.Strings(members)
+	return members
+}
+
+func TestSet(t *testing.T) {
+	s := New()
+	for i := 0; i < 10; i++ {
+		if s.Add(strconv.Itoa(i)) != 1 {
+			t.Error("add new member should return 1")
+		}
+	}
+	if s.Add("0") != 0 {
+		t.Error("add existing member should return 0")
+	}
+	if s.Len() != 10 {
+		t.Errorf("expected 10, actually %d", s.Len())
+	}
+	if !s.Has("5") || s.Has("10") {
+		t.Error("has error")
+	}
+	if s.Remove("5") != 1 || s.Remove("5") != 0 || s.Has("5") {
+		t.Error("remove error")
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a := New("a", "b", "c")
+	b := New("b", "c", "d")
+	if got := sorted(a.Intersect(b).ToSlice()); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("intersect error: %v", got)
+	}
+	if got := sorted(a.Union(b).ToSlice()); len(got) != 4 {
+		t.Errorf("union error: %v", got)
+	}
+	if got := a.Diff(b).ToSlice(); len(got) != 1 || got[0] != "a" {
+		t.Errorf("diff error: %v", got)
+	}
+	if got := a.RandomDistinctMembers(5); len(got) != 3 {
+		t.Errorf("expected 3 distinct members, actually %d", len(got))
+	}
+	if got := a.RandomMembers(5); len(got) != 5 {
+		t.Errorf("expected 5 members, actually %d", len(got))
+	}
+}
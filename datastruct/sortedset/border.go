@@ -0,0 +1,142 @@
+package sortedset
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+/*
+ * ScoreBorder 和 LexBorder 表示 ZRANGEBYSCORE / ZRANGEBYLEX 的区间端点
+ * 成员e在[min, max]区间内当且仅当 min.less(e) && max.greater(e)
+ */
+
+const (
+	negativeInf int8 = -1
+	positiveInf int8 = 1
+)
+
+// Border 区间端点
+type Border interface {
+	greater(element *Element) bool
+	less(element *Element) bool
+	isIntersected(max Border) bool // 作为min时，与max构成的区间是否为空
+}
+
+// ScoreBorder 分数端点，支持 <, <=, >, >=, +inf, -inf
+type ScoreBorder struct {
+	Value   float64
+	Exclude bool
+}
+
+// greater 端点是否大于(或等于)成员的分数
+func (border *ScoreBorder) greater(element *Element) bool {
+	if border.Exclude {
+		return border.Value > element.Score
+	}
+	return border.Value >= element.Score
+}
+
+// less 端点是否小于(或等于)成员的分数
+func (border *ScoreBorder) less(element *Element) bool {
+	if border.Exclude {
+		return border.Value < element.Score
+	}
+	return border.Value <= element.Score
+}
+
+func (border *ScoreBorder) isIntersected(max Border) bool {
+	maxBorder, ok := max.(*ScoreBorder)
+	if !ok {
+		return true
+	}
+	return border.Value > maxBorder.Value ||
+		border.Value == maxBorder.Value && (border.Exclude || maxBorder.Exclude)
+}
+
+var (
+	// PositiveInfBorder +inf
+	PositiveInfBorder = &ScoreBorder{Value: math.Inf(1)}
+	// NegativeInfBorder -inf
+	NegativeInfBorder = &ScoreBorder{Value: math.Inf(-1)}
+)
+
+// ParseScoreBorder 解析 "1.5", "(1.5", "+inf", "-inf"
+func ParseScoreBorder(s string) (Border, error) {
+	exclude := false
+	if len(s) > 0 && s[0] == '(' {
+		exclude = true
+		s = s[1:]
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, errors.New("ERR min or max is not a float")
+	}
+	return &ScoreBorder{
+		Value:   value,
+		Exclude: exclude,
+	}, nil
+}
+
+// LexBorder 字典序端点，用于分数相同的成员
+type LexBorder struct {
+	Inf     int8
+	Value   string
+	Exclude bool
+}
+
+func (border *LexBorder) greater(element *Element) bool {
+	if border.Inf == negativeInf {
+		return false
+	} else if border.Inf == positiveInf {
+		return true
+	}
+	if border.Exclude {
+		return border.Value > element.Member
+	}
+	return border.Value >= element.Member
+}
+
+func (border *LexBorder) less(element *Element) bool {
+	if border.Inf == negativeInf {
+		return true
+	} else if border.Inf == positiveInf {
+		return false
+	}
+	if border.Exclude {
+		return border.Value < element.Member
+	}
+	return border.Value <= element.Member
+}
+
+func (border *LexBorder) isIntersected(max Border) bool {
+	maxBorder, ok := max.(*LexBorder)
+	if !ok {
+		return true
+	}
+	if border.Inf == positiveInf || maxBorder.Inf == negativeInf {
+		return true
+	}
+	if border.Inf == negativeInf || maxBorder.Inf == positiveInf {
+		return false
+	}
+	return border.Value > maxBorder.Value ||
+		border.Value == maxBorder.Value && (border.Exclude || maxBorder.Exclude)
+}
+
+// ParseLexBorder 解析 "-", "+", "[a", "(a"
+func ParseLexBorder(s string) (Border, error) {
+	if s == "+" {
+		return &LexBorder{Inf: positiveInf}, nil
+	}
+	if s == "-" {
+		return &LexBorder{Inf: negativeInf}, nil
+	}
+	if len(s) > 0 && s[0] == '(' {
+		return &LexBorder{Value: s[1:], Exclude: true}, nil
+	}
+	if len(s) > 0 && s[0] == '[' {
+		return &LexBorder{Value: s[1:]}, nil
+	}
+	return nil, errors.New("ERR min or max not valid string range item")
+}
@@ -0,0 +1,303 @@
+package sortedset
+
+import (
+	"math/rand"
+)
+
+const (
+	maxLevel = 16
+)
+
+// Element 有序集合中的一个成员
+type Element struct {
+	Member string
+	Score  float64
+}
+
+// Level 节点的某一层，span为到下一个节点跨过的节点数，用于计算排名
+type Level struct {
+	forward *node
+	span    int64
+}
+
+type node struct {
+	Element
+	backward *node
+	level    []*Level // level[0] 是最底层
+}
+
+// skiplist 按 (score, member) 升序排列的跳表
+type skiplist struct {
+	header *node
+	tail   *node
+	length int64
+	level  int16
+}
+
+func makeNode(level int16, score float64, member string) *node {
+	n := &node{
+		Element: Element{
+			Score:  score,
+			Member: member,
+		},
+		level: make([]*Level, level),
+	}
+	for i := range n.level {
+		n.level[i] = new(Level)
+	}
+	return n
+}
+
+func makeSkiplist() *skiplist {
+	return &skiplist{
+		level:  1,
+		header: makeNode(maxLevel, 0, ""),
+	}
+}
+
+// randomLevel 每升高一层的概率为1/4
+func randomLevel() int16 {
+	level := int16(1)
+	for level < maxLevel && rand.Int31n(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// before 判断 (score, member) 是否排在n之后，即n应该继续向前走
+func (n *node) before(score float64, member string) bool {
+	return n.Score < score || (n.Score == score && n.Member < member)
+}
+
+func (sl *skiplist) insert(member string, score float64) *node {
+	update := make([]*node, maxLevel) // 每一层插入位置的前驱节点
+	rank := make([]int64, maxLevel)   // 每一层前驱节点的排名
+
+	n := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i == sl.level-1 {
+			rank[i] = 0
+		} else {
+			rank[i] = rank[i+1]
+		}
+		for n.level[i].forward != nil && n.level[i].forward.before(score, member) {
+			rank[i] += n.level[i].span
+			n = n.level[i].forward
+		}
+		update[i] = n
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	n = makeNode(level, score, member)
+	for i := int16(0); i < level; i++ {
+		n.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = n
+		n.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	// 新节点没有达到的层，跨度加一
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] == sl.header {
+		n.backward = nil
+	} else {
+		n.backward = update[0]
+	}
+	if n.level[0].forward != nil {
+		n.level[0].forward.backward = n
+	} else {
+		sl.tail = n
+	}
+	sl.length++
+	return n
+}
+
+// removeNode update为每一层n的前驱节点
+func (sl *skiplist) removeNode(n *node, update []*node) {
+	for i := int16(0); i < sl.level; i++ {
+		if update[i].level[i].forward == n {
+			update[i].level[i].span += n.level[i].span - 1
+			update[i].level[i].forward = n.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if n.level[0].forward != nil {
+		n.level[0].forward.backward = n.backward
+	} else {
+		sl.tail = n.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+func (sl *skiplist) remove(member string, score float64) bool {
+	update := make([]*node, maxLevel)
+	n := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.level[i].forward != nil && n.level[i].forward.before(score, member) {
+			n = n.level[i].forward
+		}
+		update[i] = n
+	}
+	n = n.level[0].forward
+	if n != nil && score == n.Score && n.Member == member {
+		sl.removeNode(n, update)
+		return true
+	}
+	return false
+}
+
+// getRank 返回从1开始的排名，不存在返回0
+func (sl *skiplist) getRank(member string, score float64) int64 {
+	var rank int64 = 0
+	n := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.level[i].forward != nil &&
+			(n.level[i].forward.before(score, member) ||
+				n.level[i].forward.Score == score && n.level[i].forward.Member == member) {
+			rank += n.level[i].span
+			n = n.level[i].forward
+		}
+		if n != sl.header && n.Member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// getByRank rank从1开始
+func (sl *skiplist) getByRank(rank int64) *node {
+	var i int64 = 0
+	n := sl.header
+	for level := sl.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && (i+n.level[level].span) <= rank {
+			i += n.level[level].span
+			n = n.level[level].forward
+		}
+		if i == rank {
+			return n
+		}
+	}
+	return nil
+}
+
+func (sl *skiplist) hasInRange(min Border, max Border) bool {
+	if min.isIntersected(max) {
+		return false
+	}
+	// min > tail
+	n := sl.tail
+	if n == nil || !min.less(&n.Element) {
+		return false
+	}
+	// max < head
+	n = sl.header.level[0].forward
+	if n == nil || !max.greater(&n.Element) {
+		return false
+	}
+	return true
+}
+
+func (sl *skiplist) getFirstInRange(min Border, max Border) *node {
+	if !sl.hasInRange(min, max) {
+		return nil
+	}
+	n := sl.header
+	for level := sl.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && !min.less(&n.level[level].forward.Element) {
+			n = n.level[level].forward
+		}
+	}
+	n = n.level[0].forward
+	if !max.greater(&n.Element) {
+		return nil
+	}
+	return n
+}
+
+func (sl *skiplist) getLastInRange(min Border, max Border) *node {
+	if !sl.hasInRange(min, max) {
+		return nil
+	}
+	n := sl.header
+	for level := sl.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && max.greater(&n.level[level].forward.Element) {
+			n = n.level[level].forward
+		}
+	}
+	if !min.less(&n.Element) {
+		return nil
+	}
+	return n
+}
+
+// RemoveRange 删除[min, max]内的成员，limit <= 0 表示不限制数量
+func (sl *skiplist) RemoveRange(min Border, max Border, limit int) (removed []*Element) {
+	update := make([]*node, maxLevel)
+	removed = make([]*Element, 0)
+	n := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for n.level[i].forward != nil && !min.less(&n.level[i].forward.Element) {
+			n = n.level[i].forward
+		}
+		update[i] = n
+	}
+
+	n = n.level[0].forward
+	for n != nil {
+		if !max.greater(&n.Element) {
+			break
+		}
+		next := n.level[0].forward
+		removedElement := n.Element
+		removed = append(removed, &removedElement)
+		sl.removeNode(n, update)
+		if limit > 0 && len(removed) == limit {
+			break
+		}
+		n = next
+	}
+	return removed
+}
+
+// RemoveRangeByRank 删除排名在[start, stop)内的成员，排名从1开始
+func (sl *skiplist) RemoveRangeByRank(start int64, stop int64) (removed []*Element) {
+	var i int64 = 0
+	update := make([]*node, maxLevel)
+	removed = make([]*Element, 0)
+
+	n := sl.header
+	for level := sl.level - 1; level >= 0; level-- {
+		for n.level[level].forward != nil && (i+n.level[level].span) < start {
+			i += n.level[level].span
+			n = n.level[level].forward
+		}
+		update[level] = n
+	}
+
+	i++
+	n = n.level[0].forward
+	for n != nil && i < stop {
+		next := n.level[0].forward
+		removedElement := n.Element
+		removed = append(removed, &removedElement)
+		sl.removeNode(n, update)
+		n = next
+		i++
+	}
+	return removed
+}
@@ -0,0 +1,214 @@
+package sortedset
+
+import (
+	"strconv"
+)
+
+// SortedSet 有序集合：dict保存成员到分数的映射，跳表维护顺序，非并发安全
+type SortedSet struct {
+	dict     map[string]*Element
+	skiplist *skiplist
+}
+
+func Make() *SortedSet {
+	return &SortedSet{
+		dict:     make(map[string]*Element),
+		skiplist: makeSkiplist(),
+	}
+}
+
+// Add 添加或更新成员，新成员返回true
+func (s *SortedSet) Add(member string, score float64) bool {
+	element, ok := s.dict[member]
+	s.dict[member] = &Element{
+		Member: member,
+		Score:  score,
+	}
+	if ok {
+		if score != element.Score {
+			s.skiplist.remove(member, element.Score)
+			s.skiplist.insert(member, score)
+		}
+		return false
+	}
+	s.skiplist.insert(member, score)
+	return true
+}
+
+func (s *SortedSet) Len() int64 {
+	return int64(len(s.dict))
+}
+
+func (s *SortedSet) Get(member string) (element *Element, ok bool) {
+	element, ok = s.dict[member]
+	return
+}
+
+func (s *SortedSet) Remove(member string) bool {
+	element, ok := s.dict[member]
+	if ok {
+		s.skiplist.remove(member, element.Score)
+		delete(s.dict, member)
+		return true
+	}
+	return false
+}
+
+// GetRank 返回从0开始的排名，desc为true时按分数从大到小，成员不存在返回-1
+func (s *SortedSet) GetRank(member string, desc bool) int64 {
+	element, ok := s.dict[member]
+	if !ok {
+		return -1
+	}
+	rank := s.skiplist.getRank(member, element.Score)
+	if desc {
+		return s.skiplist.length - rank
+	}
+	return rank - 1
+}
+
+// ForEachByRank 遍历排名在[start, stop)内的成员，排名从0开始
+func (s *SortedSet) ForEachByRank(start int64, stop int64, desc bool, consumer func(element *Element) bool) {
+	size := s.Len()
+	if start < 0 || start >= size {
+		panic("illegal start " + strconv.FormatInt(start, 10))
+	}
+	if stop < start || stop > size {
+		panic("illegal end " + strconv.FormatInt(stop, 10))
+	}
+
+	var n *node
+	if desc {
+		n = s.skiplist.tail
+		if start > 0 {
+			n = s.skiplist.getByRank(size - start)
+		}
+	} else {
+		n = s.skiplist.header.level[0].forward
+		if start > 0 {
+			n = s.skiplist.getByRank(start + 1)
+		}
+	}
+
+	for i := start; i < stop && n != nil; i++ {
+		if !consumer(&n.Element) {
+			break
+		}
+		if desc {
+			n = n.backward
+		} else {
+			n = n.level[0].forward
+		}
+	}
+}
+
+// RangeByRank 返回排名在[start, stop)内的成员
+func (s *SortedSet) RangeByRank(start int64, stop int64, desc bool) []*Element {
+	result := make([]*Element, 0, stop-start)
+	s.ForEachByRank(start, stop, desc, func(element *Element) bool {
+		result = append(result, element)
+		return true
+	})
+	return result
+}
+
+// RangeCount 返回[min, max]区间内的成员数量
+func (s *SortedSet) RangeCount(min Border, max Border) int64 {
+	first := s.skiplist.getFirstInRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := s.skiplist.getLastInRange(min, max)
+	return s.skiplist.getRank(last.Member, last.Score) - s.skiplist.getRank(first.Member, first.Score) + 1
+}
+
+// ForEach 遍历[min, max]区间内的成员，跳过前offset个，最多limit个，limit < 0 表示不限制
+func (s *SortedSet) ForEach(min Border, max Border, offset int64, limit int64, desc bool, consumer func(element *Element) bool) {
+	var n *node
+	if desc {
+		n = s.skiplist.getLastInRange(min, max)
+	} else {
+		n = s.skiplist.getFirstInRange(min, max)
+	}
+	next := func(n *node) *node {
+		if desc {
+			return n.backward
+		}
+		return n.level[0].forward
+	}
+
+	for n != nil && offset > 0 {
+		n = next(n)
+		offset--
+	}
+	for i := int64(0); (i < limit || limit < 0) && n != nil; i++ {
+		if !min.less(&n.Element) || !max.greater(&n.Element) {
+			break
+		}
+		if !consumer(&n.Element) {
+			break
+		}
+		n = next(n)
+	}
+}
+
+// Range 返回[min, max]区间内的成员
+func (s *SortedSet) Range(min Border, max Border, offset int64, limit int64, desc bool) []*Element {
+	if limit == 0 || offset < 0 {
+		return make([]*Element, 0)
+	}
+	result := make([]*Element, 0)
+	s.ForEach(min, max, offset, limit, desc, func(element *Element) bool {
+		result = append(result, element)
+		return true
+	})
+	return result
+}
+
+// RemoveRange 删除[min, max]区间内的成员
+func (s *SortedSet) RemoveRange(min Border, max Border) []*Element {
+	removed := s.skiplist.RemoveRange(min, max, 0)
+	for _, element := range removed {
+		delete(s.dict, element.Member)
+	}
+	return removed
+}
+
+// RemoveByRank 删除排名在[start, stop)内的成员，排名从0开始
+func (s *SortedSet) RemoveByRank(start int64, stop int64) []*Element {
+	removed := s.skiplist.RemoveRangeByRank(start+1, stop+1)
+	for _, element := range removed {
+		delete(s.dict, element.Member)
+	}
+	return removed
+}
+
+// PopMin 弹出分数最小的count个成员
+func (s *SortedSet) PopMin(count int) []*Element {
+	first := s.skiplist.getFirstInRange(NegativeInfBorder, PositiveInfBorder)
+	if first == nil {
+		return nil
+	}
+	border := &ScoreBorder{
+		Value: first.Score,
+	}
+	removed := s.skiplist.RemoveRange(border, PositiveInfBorder, count)
+	for _, element := range removed {
+		delete(s.dict, element.Member)
+	}
+	return removed
+}
+
+// PopMax 弹出分数最大的count个成员
+func (s *SortedSet) PopMax(count int) []*Element {
+	size := s.Len()
+	if int64(count) > size {
+		count = int(size)
+	}
+	removed := s.RemoveByRank(size-int64(count), size)
+	// 按分数从大到小返回
+	for i, j := 0, len(removed)-1; i < j; i, j = i+1, j-1 {
+		removed[i], removed[j] = removed[j], removed[i]
+	}
+	return removed
+}
@@ -0,0 +1,137 @@
+package sortedset
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func makeRandomSet(size int) (*SortedSet, []*Element) {
+	s := Make()
+	elements := make([]*Element, 0, size)
+	for i := 0; i < size; i++ {
+		member := "m" + strconv.Itoa(i)
+		score := float64(rand.Intn(size / 2)) // duplicate scores are ordered by member
+		s.Add(member, score)
+		elements = append(elements, &Element{Member: member, Score: score})
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].Score < elements[j].Score ||
+			elements[i].Score == elements[j].Score && elements[i].Member < elements[j].Member
+	})
+	return s, elements
+}
+
+func TestRank(t *testing.T) {
+	size := 1000
+	s, elements := makeRandomSet(size)
+	for i, element := range elements {
+		if rank := s.GetRank(element.Member, false); rank != int64(i) {
+			t.Errorf("%s: expected rank %d, actually %d", element.Member, i, rank)
+		}
+		if rank := s.GetRank(element.Member, true); rank != int64(size-i-1) {
+			t.Errorf("%s: expected rev rank %d, actually %d", element.Member, size-i-1, rank)
+		}
+	}
+	if s.GetRank("missing", false) != -1 {
+		t.Error("expected -1 for missing member")
+	}
+
+	result := s.RangeByRank(10, 20, false)
+	for i, element := range result {
+		if element.Member != elements[10+i].Member {
+			t.Errorf("expected %s, actually %s", elements[10+i].Member, element.Member)
+		}
+	}
+	result = s.RangeByRank(0, 5, true)
+	for i, element := range result {
+		if element.Member != elements[size-1-i].Member {
+			t.Errorf("expected %s, actually %s", elements[size-1-i].Member, element.Member)
+		}
+	}
+}
+
+func TestUpdateAndRemove(t *testing.T) {
+	s := Make()
+	s.Add("a", 1)
+	s.Add("b", 2)
+	s.Add("c", 3)
+	if s.Add("a", 4) {
+		t.Error("update should return false")
+	}
+	if rank := s.GetRank("a", false); rank != 2 {
+		t.Errorf("expected 2, actually %d", rank)
+	}
+	if !s.Remove("b") || s.Remove("b") {
+		t.Error("remove error")
+	}
+	if s.Len() != 2 {
+		t.Errorf("expected 2, actually %d", s.Len())
+	}
+	if rank := s.GetRank("a", false); rank != 1 {
+		t.Errorf("expected 1, actually %d", rank)
+	}
+}
+
+func TestRangeByScore(t *testing.T) {
+	s := Make()
+	for i := 0; i < 10; i++ {
+		s.Add(strconv.Itoa(i), float64(i))
+	}
+	min, _ := ParseScoreBorder("(2")
+	max, _ := ParseScoreBorder("5")
+	if count := s.RangeCount(min, max); count != 3 {
+		t.Errorf("expected 3, actually %d", count)
+	}
+	result := s.Range(min, max, 1, -1, false)
+	if len(result) != 2 || result[0].Member != "4" || result[1].Member != "5" {
+		t.Errorf("range error: %v", result)
+	}
+	result = s.Range(min, max, 0, 2, true)
+	if len(result) != 2 || result[0].Member != "5" || result[1].Member != "4" {
+		t.Errorf("rev range error: %v", result)
+	}
+	empty, _ := ParseScoreBorder("(5")
+	if count := s.RangeCount(empty, max); count != 0 {
+		t.Errorf("expected 0, actually %d", count)
+	}
+
+	removed := s.RemoveRange(min, max)
+	if len(removed) != 3 || s.Len() != 7 {
+		t.Errorf("remove range error: %d removed, %d left", len(removed), s.Len())
+	}
+	popped := s.PopMin(2)
+	if len(popped) != 2 || popped[0].Member != "0" || popped[1].Member != "1" {
+		t.Errorf("pop min error: %v", popped)
+	}
+	popped = s.PopMax(2)
+	if len(popped) != 2 || popped[0].Member != "9" || popped[1].Member != "8" {
+		t.Errorf("pop max error: %v", popped)
+	}
+	removed = s.RemoveByRank(0, 1)
+	if len(removed) != 1 || removed[0].Member != "2" {
+		t.Errorf("remove by rank error: %v", removed)
+	}
+}
+
+func TestRangeByLex(t *testing.T) {
+	s := Make()
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		s.Add(member, 0)
+	}
+	min, _ := ParseLexBorder("[b")
+	max, _ := ParseLexBorder("(d")
+	result := s.Range(min, max, 0, -1, false)
+	if len(result) != 2 || result[0].Member != "b" || result[1].Member != "c" {
+		t.Errorf("lex range error: %v", result)
+	}
+	min, _ = ParseLexBorder("-")
+	max, _ = ParseLexBorder("+")
+	if count := s.RangeCount(min, max); count != 5 {
+		t.Errorf("expected 5, actually %d", count)
+	}
+	if _, err := ParseLexBorder("b"); err == nil {
+		t.Error("expected error for invalid lex border")
+	}
+}
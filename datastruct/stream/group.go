@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"sort"
+	"time"
+)
+
+// Group 消费者组
+type Group struct {
+	Name      string
+	LastID    ID // 最后一条投递给组内消费者的消息ID
+	pending   map[ID]*PendingEntry
+	consumers map[string]*Consumer
+}
+
+// PendingEntry 已投递但还没有被确认(XACK)的消息
+type PendingEntry struct {
+	ID            ID
+	Consumer      *Consumer
+	DeliveryTime  time.Time
+	DeliveryCount uint64
+}
+
+type Consumer struct {
+	Name     string
+	SeenTime time.Time
+	pending  map[ID]*PendingEntry
+}
+
+func (c *Consumer) PendingLen() int {
+	return len(c.pending)
+}
+
+// PendingAfter 返回该消费者ID大于等于start的待确认消息，按ID排序
+func (c *Consumer) PendingAfter(start ID, count int) []*PendingEntry {
+	return sortPending(c.pending, start, MaxID, count, func(*PendingEntry) bool {
+		return true
+	})
+}
+
+func (g *Group) Consumer(name string) *Consumer {
+	return g.consumers[name]
+}
+
+// CreateConsumer 创建消费者，已存在时返回已有的消费者和false
+func (g *Group) CreateConsumer(name string, now time.Time) (*Consumer, bool) {
+	if consumer, ok := g.consumers[name]; ok {
+		return consumer, false
+	}
+	consumer := &Consumer{
+		Name:     name,
+		SeenTime: now,
+		pending:  make(map[ID]*PendingEntry),
+	}
+	g.consumers[name] = consumer
+	return consumer, true
+}
+
+// DeleteConsumer 删除消费者，它的待确认消息也一并删除，返回删除的待确认消息数量
+func (g *Group) DeleteConsumer(name string) (int, bool) {
+	consumer, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	for id := range consumer.pending {
+		delete(g.pending, id)
+	}
+	delete(g.consumers, name)
+	return len(consumer.pending), true
+}
+
+// Consumers 按名称排序返回所有消费者
+func (g *Group) Consumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.consumers))
+	for _, consumer := range g.consumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers
+}
+
+func (g *Group) PendingLen() int {
+	return len(g.pending)
+}
+
+func (g *Group) Pending(id ID) *PendingEntry {
+	return g.pending[id]
+}
+
+// AddPending 把消息记为consumer待确认，消息已经属于其他消费者时转移给consumer
+func (g *Group) AddPending(id ID, consumer *Consumer, deliveryTime time.Time, deliveryCount uint64) *PendingEntry {
+	entry, ok := g.pending[id]
+	if ok {
+		delete(entry.Consumer.pending, id)
+	} else {
+		entry = &PendingEntry{ID: id}
+		g.pending[id] = entry
+	}
+	entry.Consumer = consumer
+	entry.DeliveryTime = deliveryTime
+	entry.DeliveryCount = deliveryCount
+	consumer.pending[id] = entry
+	return entry
+}
+
+// Ack 确认消息，消息不在待确认列表中时返回false
+func (g *Group) Ack(id ID) bool {
+	entry, ok := g.pending[id]
+	if !ok {
+		return false
+	}
+	delete(entry.Consumer.pending, id)
+	delete(g.pending, id)
+	return true
+}
+
+// PendingRange 按ID排序返回[start, end]之间满足filter的待确认消息
+func (g *Group) PendingRange(start, end ID, count int, filter func(*PendingEntry) bool) []*PendingEntry {
+	return sortPending(g.pending, start, end, count, filter)
+}
+
+func sortPending(pending map[ID]*PendingEntry, start, end ID, count int, filter func(*PendingEntry) bool) []*PendingEntry {
+	result := make([]*PendingEntry, 0)
+	for id, entry := range pending {
+		if id.Less(start) || end.Less(id) || !filter(entry) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID.Less(result[j].ID)
+	})
+	if count > 0 && len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
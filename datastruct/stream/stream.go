@@ -0,0 +1,275 @@
+package stream
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ID 消息ID，形如 毫秒时间戳-序号
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	MinID = ID{0, 0}
+	MaxID = ID{math.MaxUint64, math.MaxUint64}
+
+	ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+)
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id ID) Compare(another ID) int {
+	switch {
+	case id.Ms < another.Ms:
+		return -1
+	case id.Ms > another.Ms:
+		return 1
+	case id.Seq < another.Seq:
+		return -1
+	case id.Seq > another.Seq:
+		return 1
+	}
+	return 0
+}
+
+func (id ID) Less(another ID) bool {
+	return id.Compare(another) < 0
+}
+
+// Next 返回比id大的最小ID，id已经是最大值时ok为false
+func (id ID) Next() (next ID, ok bool) {
+	if id.Seq < math.MaxUint64 {
+		return ID{id.Ms, id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return ID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// Prev 返回比id小的最大ID，id已经是最小值时ok为false
+func (id ID) Prev() (prev ID, ok bool) {
+	if id.Seq > 0 {
+		return ID{id.Ms, id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return ID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// ParseID 解析 ms-seq，只有ms时序号取defaultSeq
+func ParseID(str string, defaultSeq uint64) (ID, error) {
+	msStr, seqStr, hasSeq := strings.Cut(str, "-")
+	ms, err := strconv.ParseUint(msStr, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return ID{ms, defaultSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	return ID{ms, seq}, nil
+}
+
+// Entry 一条消息，Fields按 field value field value ... 排列
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// Stream 按ID有序存放消息，新消息总是追加在末尾，非并发安全
+type Stream struct {
+	entries []*Entry
+	lastID  ID // 曾经添加过的最大ID，删除消息后也不会变小
+	groups  map[string]*Group
+}
+
+func New() *Stream {
+	return &Stream{
+		groups: make(map[string]*Group),
+	}
+}
+
+func (s *Stream) Len() int {
+	return len(s.entries)
+}
+
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// SetLastID 只能设置为不小于最后一条消息的ID
+func (s *Stream) SetLastID(id ID) bool {
+	if len(s.entries) > 0 && id.Less(s.entries[len(s.entries)-1].ID) {
+		return false
+	}
+	s.lastID = id
+	return true
+}
+
+// NextID 自动生成ID：取当前时间，时间回拨或同一毫秒内时沿用lastID并增加序号
+func (s *Stream) NextID(nowMs uint64) (ID, bool) {
+	if nowMs > s.lastID.Ms {
+		return ID{nowMs, 0}, true
+	}
+	return s.lastID.Next()
+}
+
+// NextSeqID 生成 ms-* 形式的ID
+func (s *Stream) NextSeqID(ms uint64) (ID, bool) {
+	if ms > s.lastID.Ms {
+		return ID{ms, 0}, true
+	}
+	if ms < s.lastID.Ms || s.lastID.Seq == math.MaxUint64 {
+		return ID{}, false
+	}
+	return ID{ms, s.lastID.Seq + 1}, true
+}
+
+// Add 追加消息，id必须大于lastID
+func (s *Stream) Add(id ID, fields [][]byte) bool {
+	if !s.lastID.Less(id) {
+		return false
+	}
+	s.entries = append(s.entries, &Entry{
+		ID:     id,
+		Fields: fields,
+	})
+	s.lastID = id
+	return true
+}
+
+// search 返回第一个ID >= id的下标
+func (s *Stream) search(id ID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].ID.Less(id)
+	})
+}
+
+func (s *Stream) Get(id ID) *Entry {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i]
+	}
+	return nil
+}
+
+// Range 返回ID在[start, end]之间的消息，count <= 0表示不限制数量
+func (s *Stream) Range(start, end ID, count int) []*Entry {
+	result := make([]*Entry, 0)
+	for i := s.search(start); i < len(s.entries) && !end.Less(s.entries[i].ID); i++ {
+		if count > 0 && len(result) >= count {
+			break
+		}
+		result = append(result, s.entries[i])
+	}
+	return result
+}
+
+// RevRange 按ID从大到小返回[start, end]之间的消息
+func (s *Stream) RevRange(start, end ID, count int) []*Entry {
+	result := make([]*Entry, 0)
+	// 第一个ID > end的位置
+	i := sort.Search(len(s.entries), func(i int) bool {
+		return end.Less(s.entries[i].ID)
+	}) - 1
+	for ; i >= 0 && !s.entries[i].ID.Less(start); i-- {
+		if count > 0 && len(result) >= count {
+			break
+		}
+		result = append(result, s.entries[i])
+	}
+	return result
+}
+
+func (s *Stream) Delete(id ID) bool {
+	i := s.search(id)
+	if i >= len(s.entries) || s.entries[i].ID != id {
+		return false
+	}
+	s.entries = append(s.entries[:i], s.entries[i+1:]...)
+	return true
+}
+
+// removeHead 删除最前面的n条消息
+func (s *Stream) removeHead(n int) {
+	// 复制到新切片，避免被删除的消息一直被底层数组引用
+	s.entries = append(make([]*Entry, 0, len(s.entries)-n), s.entries[n:]...)
+}
+
+// TrimMaxLen 删除最旧的消息直到长度不超过maxLen，limit > 0时最多删除limit条，返回删除的数量
+func (s *Stream) TrimMaxLen(maxLen int, limit int) int {
+	n := len(s.entries) - maxLen
+	if n <= 0 {
+		return 0
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	s.removeHead(n)
+	return n
+}
+
+// TrimMinID 删除ID小于minID的消息，limit > 0时最多删除limit条，返回删除的数量
+func (s *Stream) TrimMinID(minID ID, limit int) int {
+	n := s.search(minID)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	if n > 0 {
+		s.removeHead(n)
+	}
+	return n
+}
+
+/* ---- consumer group ---- */
+
+// CreateGroup 创建消费者组，同名的组已存在时返回false
+func (s *Stream) CreateGroup(name string, lastID ID) (*Group, bool) {
+	if _, ok := s.groups[name]; ok {
+		return nil, false
+	}
+	group := &Group{
+		Name:      name,
+		LastID:    lastID,
+		pending:   make(map[ID]*PendingEntry),
+		consumers: make(map[string]*Consumer),
+	}
+	s.groups[name] = group
+	return group, true
+}
+
+func (s *Stream) Group(name string) *Group {
+	return s.groups[name]
+}
+
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups 按名称排序返回所有消费者组
+func (s *Stream) Groups() []*Group {
+	groups := make([]*Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
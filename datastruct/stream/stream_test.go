@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func makeFields(values ...string) [][]byte {
+	fields := make([][]byte, len(values))
+	for i, value := range values {
+		fields[i] = []byte(value)
+	}
+	return fields
+}
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("1526919030474-55", 0)
+	if err != nil || id != (ID{1526919030474, 55}) {
+		t.Errorf("expected 1526919030474-55, actually %s %v", id, err)
+	}
+	id, err = ParseID("5", 7)
+	if err != nil || id != (ID{5, 7}) {
+		t.Errorf("expected 5-7, actually %s %v", id, err)
+	}
+	for _, str := range []string{"", "-", "a-1", "1-b", "1-2-3", "-1"} {
+		if _, err := ParseID(str, 0); err != ErrInvalidID {
+			t.Errorf("expected error for %q", str)
+		}
+	}
+	if next, _ := (ID{1, MaxID.Seq}).Next(); next != (ID{2, 0}) {
+		t.Errorf("expected 2-0, actually %s", next)
+	}
+	if prev, _ := (ID{2, 0}).Prev(); prev != (ID{1, MaxID.Seq}) {
+		t.Errorf("expected 1-max, actually %s", prev)
+	}
+	if _, ok := MaxID.Next(); ok {
+		t.Error("max id should not have next")
+	}
+}
+
+func TestNextID(t *testing.T) {
+	s := New()
+	id, _ := s.NextID(100)
+	if id != (ID{100, 0}) {
+		t.Errorf("expected 100-0, actually %s", id)
+	}
+	s.Add(id, makeFields("a", "1"))
+	// 时间回拨时沿用lastID
+	id, _ = s.NextID(90)
+	if id != (ID{100, 1}) {
+		t.Errorf("expected 100-1, actually %s", id)
+	}
+	if _, ok := s.NextSeqID(99); ok {
+		t.Error("expected failure for smaller ms")
+	}
+	if id, _ = s.NextSeqID(100); id != (ID{100, 1}) {
+		t.Errorf("expected 100-1, actually %s", id)
+	}
+	if s.Add(ID{100, 0}, makeFields("a", "1")) {
+		t.Error("expected failure for equal id")
+	}
+}
+
+func TestRangeAndTrim(t *testing.T) {
+	s := New()
+	for i := uint64(1); i <= 10; i++ {
+		s.Add(ID{i, 0}, makeFields("i", "v"))
+	}
+	entries := s.Range(ID{3, 0}, ID{6, 0}, 0)
+	if len(entries) != 4 || entries[0].ID != (ID{3, 0}) || entries[3].ID != (ID{6, 0}) {
+		t.Errorf("wrong range result, size %d", len(entries))
+	}
+	entries = s.RevRange(ID{3, 0}, ID{6, 0}, 2)
+	if len(entries) != 2 || entries[0].ID != (ID{6, 0}) || entries[1].ID != (ID{5, 0}) {
+		t.Errorf("wrong rev range result, size %d", len(entries))
+	}
+	if !s.Delete(ID{5, 0}) || s.Delete(ID{5, 0}) || s.Get(ID{5, 0}) != nil {
+		t.Error("delete failed")
+	}
+	if n := s.TrimMaxLen(5, 2); n != 2 || s.Len() != 7 {
+		t.Errorf("expected 2 removed with limit, actually %d", n)
+	}
+	if n := s.TrimMinID(ID{8, 0}, 0); n != 4 || s.Len() != 3 {
+		t.Errorf("expected 4 removed, actually %d", n)
+	}
+	// 删除消息不影响lastID
+	s.TrimMaxLen(0, 0)
+	if s.Len() != 0 || s.LastID() != (ID{10, 0}) {
+		t.Errorf("expected empty stream with last id 10-0, actually %d %s", s.Len(), s.LastID())
+	}
+}
+
+func TestGroupPending(t *testing.T) {
+	s := New()
+	group, ok := s.CreateGroup("g", MinID)
+	if !ok {
+		t.Fatal("create group failed")
+	}
+	if _, ok := s.CreateGroup("g", MinID); ok {
+		t.Error("expected failure for existing group")
+	}
+	now := time.Now()
+	alice, _ := group.CreateConsumer("alice", now)
+	bob, _ := group.CreateConsumer("bob", now)
+	for i := uint64(1); i <= 3; i++ {
+		group.AddPending(ID{i, 0}, alice, now, 1)
+	}
+	// 转移给bob
+	group.AddPending(ID{2, 0}, bob, now, 2)
+	if alice.PendingLen() != 2 || bob.PendingLen() != 1 || group.PendingLen() != 3 {
+		t.Errorf("wrong pending size %d %d %d", alice.PendingLen(), bob.PendingLen(), group.PendingLen())
+	}
+	pending := alice.PendingAfter(ID{2, 0}, 0)
+	if len(pending) != 1 || pending[0].ID != (ID{3, 0}) {
+		t.Error("wrong pending after result")
+	}
+	if !group.Ack(ID{1, 0}) || group.Ack(ID{1, 0}) {
+		t.Error("ack failed")
+	}
+	if n, ok := group.DeleteConsumer("bob"); !ok || n != 1 || group.PendingLen() != 1 {
+		t.Errorf("expected 1 pending deleted, actually %d", n)
+	}
+}
@@ -2,6 +2,8 @@ package database
 
 import (
 	"go-redis/interface/resp"
+	"io"
+	"time"
 )
 
 type CmdLine = [][]byte
@@ -12,6 +14,17 @@ type Database interface {
 	AfterClientClose(c resp.Connection) error
 }
 
+// DBEngine 能够提供所有DB在同一时刻的快照，AOF重写时使用
+type DBEngine interface {
+	Database
+	// ForEachSnapshot 在快照开始时调用onStart，然后把快照中的key依次交给cb，expireAt为零值表示没有过期时间
+	ForEachSnapshot(onStart func(), cb func(dbIndex int, key string, data *DataEntity, expireAt time.Time) error) error
+	// WriteRDB 把快照以RDB格式写入w，用作aof文件的RDB前缀
+	WriteRDB(w io.Writer, onStart func()) error
+	// LoadRDB 加载aof文件开头的RDB数据
+	LoadRDB(r io.Reader) error
+}
+
 type DataEntity struct {
 	Data interface{}
 }
@@ -1,9 +1,41 @@
 package resp
 
+// WatchKey WATCH的key，不同DB中的同名key是不同的key
+type WatchKey struct {
+	DBIndex int
+	Key string
+}
 
 type Connection interface {
 	Write([]byte) error
+	// GetID 客户端id，CLIENT ID 的返回值
+	GetID() uint64
 	GetDBIndex() int
 	SelectDB(int)
+	// Done 连接关闭后返回的通道被关闭，阻塞中的命令据此放弃等待
+	Done() <-chan struct{}
+
+	// 事务(MULTI/EXEC)相关
+	InMultiState() bool
+	SetMultiState(bool)
+	GetQueuedCmdLine() [][][]byte
+	EnqueueCmd([][]byte)
+	AddTxError(err error)
+	GetTxErrors() []error
+	// GetWatching WATCH的key -> WATCH时的版本号
+	GetWatching() map[WatchKey]uint32
+	ClearWatching()
+
+	// 发布订阅相关，SubsCount大于0时连接处于订阅状态
+	Subscribe(channel string)
+	UnSubscribe(channel string)
+	PSubscribe(pattern string)
+	PUnSubscribe(pattern string)
+	SSubscribe(channel string)
+	SUnSubscribe(channel string)
+	SubsCount() int
+	GetChannels() []string
+	GetPatterns() []string
+	GetShardChannels() []string
 }
 
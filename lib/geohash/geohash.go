@@ -0,0 +1,207 @@
+package geohash
+
+import (
+	"math"
+)
+
+/*
+	与Redis相同的geohash编码：经纬度各量化为26位，交错成52位整数作为有序集合的score
+	经度位于奇数位、纬度位于偶数位，因此相邻的score在地理上也大致相邻
+	纬度范围与Web墨卡托投影一致，限制在±85.05112878度
+ */
+
+const (
+	MinLatitude  = -85.05112878
+	MaxLatitude  = 85.05112878
+	MinLongitude = -180.0
+	MaxLongitude = 180.0
+
+	Step = 26       // 每个坐标使用的位数
+	Bits = Step * 2 // score使用的位数
+
+	// EarthRadius 与Redis计算距离时使用的地球半径一致，单位为米
+	EarthRadius = 6372797.560856
+)
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Valid 检查经纬度是否可以被编码
+func Valid(longitude, latitude float64) bool {
+	return longitude >= MinLongitude && longitude <= MaxLongitude &&
+		latitude >= MinLatitude && latitude <= MaxLatitude
+}
+
+// quantize 把value在[min, max]中的位置量化为step位整数
+func quantize(value, min, max float64, step uint) uint32 {
+	cells := float64(uint64(1) << step)
+	offset := (value - min) / (max - min) * cells
+	if offset >= cells {
+		offset = cells - 1
+	}
+	return uint32(offset)
+}
+
+// spread 把x的低32位分散到偶数位上
+func spread(x uint32) uint64 {
+	v := uint64(x)
+	v = (v | v<<16) & 0x0000FFFF0000FFFF
+	v = (v | v<<8) & 0x00FF00FF00FF00FF
+	v = (v | v<<4) & 0x0F0F0F0F0F0F0F0F
+	v = (v | v<<2) & 0x3333333333333333
+	v = (v | v<<1) & 0x5555555555555555
+	return v
+}
+
+// squash spread的逆操作，取出偶数位
+func squash(v uint64) uint32 {
+	v &= 0x5555555555555555
+	v = (v | v>>1) & 0x3333333333333333
+	v = (v | v>>2) & 0x0F0F0F0F0F0F0F0F
+	v = (v | v>>4) & 0x00FF00FF00FF00FF
+	v = (v | v>>8) & 0x0000FFFF0000FFFF
+	v = (v | v>>16) & 0x00000000FFFFFFFF
+	return uint32(v)
+}
+
+func interleave(lonIndex, latIndex uint32) uint64 {
+	return spread(latIndex) | spread(lonIndex)<<1
+}
+
+func deinterleave(hash uint64) (lonIndex, latIndex uint32) {
+	return squash(hash >> 1), squash(hash)
+}
+
+// Encode 返回经纬度对应的52位geohash
+func Encode(longitude, latitude float64) uint64 {
+	return interleave(
+		quantize(longitude, MinLongitude, MaxLongitude, Step),
+		quantize(latitude, MinLatitude, MaxLatitude, Step),
+	)
+}
+
+// Decode 返回geohash所在网格中心的经纬度
+func Decode(hash uint64) (longitude, latitude float64) {
+	lonIndex, latIndex := deinterleave(hash)
+	cells := float64(uint64(1) << Step)
+	lonSize := (MaxLongitude - MinLongitude) / cells
+	latSize := (MaxLatitude - MinLatitude) / cells
+	longitude = MinLongitude + (float64(lonIndex)+0.5)*lonSize
+	latitude = MinLatitude + (float64(latIndex)+0.5)*latSize
+	longitude = math.Max(MinLongitude, math.Min(MaxLongitude, longitude))
+	latitude = math.Max(MinLatitude, math.Min(MaxLatitude, latitude))
+	return longitude, latitude
+}
+
+// ToString 返回标准的11位base32 geohash字符串
+// 标准geohash的纬度范围是±90度，需要先解码再按标准范围重新编码
+func ToString(hash uint64) string {
+	longitude, latitude := Decode(hash)
+	bits := interleave(
+		quantize(longitude, -180, 180, Step),
+		quantize(latitude, -90, 90, Step),
+	)
+	buf := make([]byte, 11)
+	for i := 0; i < 11; i++ {
+		index := 0
+		// 52位只够10个字符，最后一个字符与Redis一样补0
+		if i < 10 {
+			index = int(bits>>(Bits-(i+1)*5)) & 0x1f
+		}
+		buf[i] = base32[index]
+	}
+	return string(buf)
+}
+
+/* ---- distance ---- */
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// LatDistance 两个纬度之间沿经线的距离，单位为米
+func LatDistance(lat1, lat2 float64) float64 {
+	return EarthRadius * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+// Distance 使用haversine公式计算两点间的距离，单位为米
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	v := math.Sin((degToRad(lon2) - degToRad(lon1)) / 2)
+	if v == 0 {
+		return LatDistance(lat1, lat2)
+	}
+	lat1r, lat2r := degToRad(lat1), degToRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+/* ---- search ---- */
+
+// Range score区间[Min, Max)
+type Range struct {
+	Min uint64
+	Max uint64
+}
+
+// RadiusRanges 返回可能包含与中心距离不超过radius(米)的点的score区间，调用方需要再精确过滤
+func RadiusRanges(longitude, latitude, radius float64) []Range {
+	angle := radius / EarthRadius
+	// 以中心为圆心的球冠在经度方向的最大跨度，球冠包含极点时覆盖所有经度
+	lonRange := 180.0
+	if s := math.Sin(angle) / math.Cos(degToRad(latitude)); angle < math.Pi/2 && s < 1 {
+		lonRange = radToDeg(math.Asin(s))
+	}
+	return searchRanges(longitude, latitude, lonRange, radToDeg(angle))
+}
+
+// BoxRanges 返回可能包含以中心为中点、宽width高height(米)的矩形内的点的score区间
+func BoxRanges(longitude, latitude, width, height float64) []Range {
+	latRange := radToDeg(height / 2 / EarthRadius)
+	// 纬度越高，相同距离跨越的经度越大，按矩形内纬度绝对值最大处估计
+	maxLat := math.Min(math.Abs(latitude)+latRange, MaxLatitude)
+	lonRange := math.Min(radToDeg(width/2/EarthRadius/math.Cos(degToRad(maxLat))), 180)
+	return searchRanges(longitude, latitude, lonRange, latRange)
+}
+
+// searchRanges 选择网格边长不小于搜索范围的精度，返回中心所在网格及其周围8个网格的score区间
+// 距离中心经度差不超过lonRange、纬度差不超过latRange的点一定落在这9个网格中
+func searchRanges(longitude, latitude, lonRange, latRange float64) []Range {
+	step := uint(Step)
+	for ; step > 1; step-- {
+		cells := float64(uint64(1) << step)
+		if (MaxLongitude-MinLongitude)/cells >= lonRange && (MaxLatitude-MinLatitude)/cells >= latRange {
+			break
+		}
+	}
+	cells := int64(1) << step
+	lonIndex := int64(quantize(longitude, MinLongitude, MaxLongitude, step))
+	latIndex := int64(quantize(latitude, MinLatitude, MaxLatitude, step))
+	shift := Bits - 2*step
+
+	seen := make(map[uint64]struct{})
+	ranges := make([]Range, 0, 9)
+	for dLat := int64(-1); dLat <= 1; dLat++ {
+		lat := latIndex + dLat
+		if lat < 0 || lat >= cells {
+			continue
+		}
+		for dLon := int64(-1); dLon <= 1; dLon++ {
+			// 经度在±180度处首尾相接
+			lon := (lonIndex + dLon + cells) % cells
+			hash := interleave(uint32(lon), uint32(lat))
+			if _, ok := seen[hash]; ok {
+				continue
+			}
+			seen[hash] = struct{}{}
+			ranges = append(ranges, Range{
+				Min: hash << shift,
+				Max: (hash + 1) << shift,
+			})
+		}
+	}
+	return ranges
+}
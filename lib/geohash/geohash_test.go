@@ -0,0 +1,65 @@
+package geohash
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	hash := Encode(13.361389, 38.115556)
+	if hash != 3479099956230698 {
+		t.Errorf("expected 3479099956230698, actually %d", hash)
+	}
+	longitude, latitude := Decode(hash)
+	if math.Abs(longitude-13.361389) > 1e-5 || math.Abs(latitude-38.115556) > 1e-5 {
+		t.Errorf("wrong decode result %f,%f", longitude, latitude)
+	}
+	if str := ToString(hash); str != "sqc8b49rny0" {
+		t.Errorf("expected sqc8b49rny0, actually %s", str)
+	}
+	if hash := Encode(MaxLongitude, MaxLatitude); hash != 1<<Bits-1 {
+		t.Errorf("expected max hash, actually %d", hash)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	lon1, lat1 := Decode(Encode(13.361389, 38.115556))
+	lon2, lat2 := Decode(Encode(15.087269, 37.502669))
+	if dist := Distance(lon1, lat1, lon2, lat2); math.Abs(dist-166274.1516) > 0.001 {
+		t.Errorf("expected 166274.1516, actually %f", dist)
+	}
+}
+
+// 所有距离不超过半径的点都应该落在返回的区间中
+func TestRadiusRanges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		longitude := r.Float64()*360 - 180
+		latitude := r.Float64()*160 - 80
+		radius := math.Pow(10, r.Float64()*7)
+		ranges := RadiusRanges(longitude, latitude, radius)
+		for j := 0; j < 100; j++ {
+			lon := longitude + (r.Float64()*2-1)*10
+			lat := latitude + (r.Float64()*2-1)*10
+			if lon < MinLongitude || lon > MaxLongitude || lat < MinLatitude || lat > MaxLatitude {
+				continue
+			}
+			hash := Encode(lon, lat)
+			pLon, pLat := Decode(hash)
+			if Distance(longitude, latitude, pLon, pLat) > radius {
+				continue
+			}
+			found := false
+			for _, rg := range ranges {
+				if hash >= rg.Min && hash < rg.Max {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("point %f,%f within %f meters of %f,%f not covered", lon, lat, radius, longitude, latitude)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package pubsub
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/wildcard"
+	"go-redis/resp/reply"
+	"sync"
+)
+
+/*
+	Hub 记录频道和模式的订阅者
+	PUBLISH不直接写订阅者的连接，而是把消息放入订阅者的队列，由订阅者自己的协程写出，
+	这样慢的订阅者不会拖慢发布者；订阅和退订的确认在持有订阅者写锁时写出，不会与推送的消息乱序
+ */
+type Hub struct {
+	mu          sync.RWMutex
+	channels    map[string]map[resp.Connection]struct{}
+	patterns    map[string]*patternSubscribers
+	// 分片频道与普通频道相互独立，集群模式下只在频道所属的节点上发布
+	shardChannels map[string]map[resp.Connection]struct{}
+	subscribers   map[resp.Connection]*subscriber
+}
+
+type patternSubscribers struct {
+	pattern *wildcard.Pattern
+	conns   map[resp.Connection]struct{}
+}
+
+func MakeHub() *Hub {
+	return &Hub{
+		channels:      make(map[string]map[resp.Connection]struct{}),
+		patterns:      make(map[string]*patternSubscribers),
+		shardChannels: make(map[string]map[resp.Connection]struct{}),
+		subscribers:   make(map[resp.Connection]*subscriber),
+	}
+}
+
+// subscriber 一个处于订阅状态的连接
+// writeMu保证队列中的消息与订阅/退订的确认按顺序写出
+type subscriber struct {
+	conn    resp.Connection
+	writeMu sync.Mutex
+	queueMu sync.Mutex
+	queue   [][]byte
+	signal  chan struct{}
+	stop    chan struct{}
+}
+
+func newSubscriber(conn resp.Connection) *subscriber {
+	s := &subscriber{
+		conn:   conn,
+		signal: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.signal:
+			s.writeMu.Lock()
+			s.flush()
+			s.writeMu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// enqueue 放入一条待推送的消息，不会阻塞
+func (s *subscriber) enqueue(msg []byte) {
+	s.queueMu.Lock()
+	s.queue = append(s.queue, msg)
+	s.queueMu.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// flush 写出队列中的全部消息，调用方需要持有writeMu
+func (s *subscriber) flush() {
+	s.queueMu.Lock()
+	queue := s.queue
+	s.queue = nil
+	s.queueMu.Unlock()
+	for _, msg := range queue {
+		_ = s.conn.Write(msg)
+	}
+}
+
+// getOrMakeSubscriber 调用方需要持有hub.mu
+func (hub *Hub) getOrMakeSubscriber(c resp.Connection) *subscriber {
+	s, ok := hub.subscribers[c]
+	if !ok {
+		s = newSubscriber(c)
+		hub.subscribers[c] = s
+	}
+	return s
+}
+
+// lockSubscriber 返回c对应的订阅者并锁住它的写出，调用方负责解锁
+func (hub *Hub) lockSubscriber(c resp.Connection) *subscriber {
+	hub.mu.Lock()
+	s := hub.getOrMakeSubscriber(c)
+	hub.mu.Unlock()
+	s.writeMu.Lock()
+	return s
+}
+
+// releaseSubscriber 连接不再订阅任何频道和模式时停止它的写出协程
+func (hub *Hub) releaseSubscriber(c resp.Connection) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if c.SubsCount() > 0 {
+		return
+	}
+	if s, ok := hub.subscribers[c]; ok {
+		close(s.stop)
+		delete(hub.subscribers, c)
+	}
+}
+
+func addSubscriber(m map[string]map[resp.Connection]struct{}, channel string, c resp.Connection) {
+	conns, ok := m[channel]
+	if !ok {
+		conns = make(map[resp.Connection]struct{})
+		m[channel] = conns
+	}
+	conns[c] = struct{}{}
+}
+
+func removeSubscriber(m map[string]map[resp.Connection]struct{}, channel string, c resp.Connection) {
+	if conns, ok := m[channel]; ok {
+		delete(conns, c)
+		if len(conns) == 0 {
+			delete(m, channel)
+		}
+	}
+}
+
+func (hub *Hub) subscribe(c resp.Connection, channel string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	addSubscriber(hub.channels, channel, c)
+	c.Subscribe(channel)
+}
+
+func (hub *Hub) unsubscribe(c resp.Connection, channel string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	removeSubscriber(hub.channels, channel, c)
+	c.UnSubscribe(channel)
+}
+
+func (hub *Hub) ssubscribe(c resp.Connection, channel string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	addSubscriber(hub.shardChannels, channel, c)
+	c.SSubscribe(channel)
+}
+
+func (hub *Hub) sunsubscribe(c resp.Connection, channel string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	removeSubscriber(hub.shardChannels, channel, c)
+	c.SUnSubscribe(channel)
+}
+
+func (hub *Hub) psubscribe(c resp.Connection, pattern string, compiled *wildcard.Pattern) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	subs, ok := hub.patterns[pattern]
+	if !ok {
+		subs = &patternSubscribers{
+			pattern: compiled,
+			conns:   make(map[resp.Connection]struct{}),
+		}
+		hub.patterns[pattern] = subs
+	}
+	subs.conns[c] = struct{}{}
+	c.PSubscribe(pattern)
+}
+
+func (hub *Hub) punsubscribe(c resp.Connection, pattern string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if subs, ok := hub.patterns[pattern]; ok {
+		delete(subs.conns, c)
+		if len(subs.conns) == 0 {
+			delete(hub.patterns, pattern)
+		}
+	}
+	c.PUnSubscribe(pattern)
+}
+
+// PublishChannel 把消息放入订阅者的队列，返回收到消息的订阅者数量
+func (hub *Hub) PublishChannel(channel string, message []byte) int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	count := 0
+	if conns, ok := hub.channels[channel]; ok {
+		msg := makeMessage(channel, message)
+		for c := range conns {
+			hub.enqueue(c, msg)
+			count++
+		}
+	}
+	for pattern, subs := range hub.patterns {
+		if !subs.pattern.IsMatch(channel) {
+			continue
+		}
+		msg := makePMessage(pattern, channel, message)
+		for c := range subs.conns {
+			hub.enqueue(c, msg)
+			count++
+		}
+	}
+	return count
+}
+
+// PublishShard 把消息发给本节点上订阅了分片频道的连接，返回订阅者数量
+// 集群中其他节点转来的分片消息也通过它发给本地的订阅者
+func (hub *Hub) PublishShard(channel string, message []byte) int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	conns := hub.shardChannels[channel]
+	if len(conns) == 0 {
+		return 0
+	}
+	msg := makeSMessage(channel, message)
+	for c := range conns {
+		hub.enqueue(c, msg)
+	}
+	return len(conns)
+}
+
+// PublishTo 只把消息发给连接c，c没有订阅channel时返回false
+// message可以是任意回复，客户端缓存的失效消息是key的数组
+func (hub *Hub) PublishTo(c resp.Connection, channel string, message resp.Reply) bool {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	if _, ok := hub.channels[channel][c]; !ok {
+		return false
+	}
+	hub.enqueue(c, reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply(messageBytes),
+		reply.NewBulkReply([]byte(channel)),
+		message,
+	}).ToBytes())
+	return true
+}
+
+// ShardNumSub 本节点上订阅了分片频道的连接数
+func (hub *Hub) ShardNumSub(channel string) int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.shardChannels[channel])
+}
+
+// enqueue 调用方需要持有hub.mu
+func (hub *Hub) enqueue(c resp.Connection, msg []byte) {
+	// 连接关闭与订阅同时发生时订阅者可能已经被释放
+	if s, ok := hub.subscribers[c]; ok {
+		s.enqueue(msg)
+	}
+}
+
+// UnsubscribeAll 连接关闭时清理它的全部订阅
+func (hub *Hub) UnsubscribeAll(c resp.Connection) {
+	for _, channel := range c.GetChannels() {
+		hub.unsubscribe(c, channel)
+	}
+	for _, pattern := range c.GetPatterns() {
+		hub.punsubscribe(c, pattern)
+	}
+	for _, channel := range c.GetShardChannels() {
+		hub.sunsubscribe(c, channel)
+	}
+	hub.releaseSubscriber(c)
+}
@@ -0,0 +1,271 @@
+package pubsub
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/wildcard"
+	"go-redis/resp/reply"
+	"sort"
+	"strings"
+)
+
+const (
+	_subscribe    = "subscribe"
+	_unsubscribe  = "unsubscribe"
+	_psubscribe   = "psubscribe"
+	_punsubscribe = "punsubscribe"
+	_ssubscribe   = "ssubscribe"
+	_sunsubscribe = "sunsubscribe"
+)
+
+var (
+	messageBytes  = []byte("message")
+	pmessageBytes = []byte("pmessage")
+	smessageBytes = []byte("smessage")
+)
+
+// subscribedModeCommands 订阅状态下允许执行的命令
+var subscribedModeCommands = map[string]struct{}{
+	"subscribe":    {},
+	"unsubscribe":  {},
+	"psubscribe":   {},
+	"punsubscribe": {},
+	"ssubscribe":   {},
+	"sunsubscribe": {},
+	"ping":         {},
+	"quit":         {},
+}
+
+// CheckSubscribedMode 连接处于订阅状态时只能执行(P|S)(UN)SUBSCRIBE/PING/QUIT，否则返回错误
+func CheckSubscribedMode(c resp.Connection, cmdName string) resp.Reply {
+	if c == nil || c.SubsCount() == 0 {
+		return nil
+	}
+	if _, ok := subscribedModeCommands[cmdName]; ok {
+		return nil
+	}
+	return reply.NewErrReply("ERR Can't execute '" + cmdName +
+		"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+}
+
+// Ping 订阅状态下的PING返回 ["pong", message]
+func Ping(c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) > 1 {
+		return reply.NewArgNumErrReply("ping")
+	}
+	message := []byte("")
+	if len(args) == 1 {
+		message = args[0]
+	}
+	return reply.NewMultiBulkReply([][]byte{[]byte("pong"), message})
+}
+
+func makeMessage(channel string, message []byte) []byte {
+	return reply.NewMultiBulkReply([][]byte{messageBytes, []byte(channel), message}).ToBytes()
+}
+
+func makePMessage(pattern string, channel string, message []byte) []byte {
+	return reply.NewMultiBulkReply([][]byte{pmessageBytes, []byte(pattern), []byte(channel), message}).ToBytes()
+}
+
+func makeSMessage(channel string, message []byte) []byte {
+	return reply.NewMultiBulkReply([][]byte{smessageBytes, []byte(channel), message}).ToBytes()
+}
+
+// makeSubsReply 订阅/退订的确认：[kind, channel, 当前订阅数]，channel为nil时写空批量回复
+func makeSubsReply(kind string, channel *string, count int) []byte {
+	channelReply := resp.Reply(reply.NewNullBulkReply())
+	if channel != nil {
+		channelReply = reply.NewBulkReply([]byte(*channel))
+	}
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte(kind)),
+		channelReply,
+		reply.NewIntReply(int64(count)),
+	}).ToBytes()
+}
+
+// Subscribe SUBSCRIBE channel [channel ...]
+func Subscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.NewArgNumErrReply(_subscribe)
+	}
+	s := hub.lockSubscriber(c)
+	defer s.writeMu.Unlock()
+	for _, arg := range args {
+		channel := string(arg)
+		// 先写出订阅之前收到的消息，订阅之后的消息排在确认之后
+		s.flush()
+		hub.subscribe(c, channel)
+		_ = c.Write(makeSubsReply(_subscribe, &channel, c.SubsCount()))
+	}
+	return &reply.NoReply{}
+}
+
+// PSubscribe PSUBSCRIBE pattern [pattern ...]
+func PSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.NewArgNumErrReply(_psubscribe)
+	}
+	compiled := make([]*wildcard.Pattern, len(args))
+	for i, arg := range args {
+		p, err := wildcard.CompilePattern(string(arg))
+		if err != nil {
+			return reply.NewErrReply("ERR invalid pattern: " + string(arg))
+		}
+		compiled[i] = p
+	}
+	s := hub.lockSubscriber(c)
+	defer s.writeMu.Unlock()
+	for i, arg := range args {
+		pattern := string(arg)
+		s.flush()
+		hub.psubscribe(c, pattern, compiled[i])
+		_ = c.Write(makeSubsReply(_psubscribe, &pattern, c.SubsCount()))
+	}
+	return &reply.NoReply{}
+}
+
+// SSubscribe SSUBSCRIBE shardchannel [shardchannel ...]
+func SSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.NewArgNumErrReply(_ssubscribe)
+	}
+	s := hub.lockSubscriber(c)
+	defer s.writeMu.Unlock()
+	for _, arg := range args {
+		channel := string(arg)
+		s.flush()
+		hub.ssubscribe(c, channel)
+		_ = c.Write(makeSubsReply(_ssubscribe, &channel, c.SubsCount()))
+	}
+	return &reply.NoReply{}
+}
+
+// UnSubscribe UNSUBSCRIBE [channel ...]，没有参数时退订全部频道
+func UnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	channels := toStrings(args)
+	if len(channels) == 0 {
+		channels = c.GetChannels()
+	}
+	unsubscribe(hub, c, _unsubscribe, channels, hub.unsubscribe)
+	return &reply.NoReply{}
+}
+
+// PUnSubscribe PUNSUBSCRIBE [pattern ...]，没有参数时退订全部模式
+func PUnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	patterns := toStrings(args)
+	if len(patterns) == 0 {
+		patterns = c.GetPatterns()
+	}
+	unsubscribe(hub, c, _punsubscribe, patterns, hub.punsubscribe)
+	return &reply.NoReply{}
+}
+
+// SUnSubscribe SUNSUBSCRIBE [shardchannel ...]，没有参数时退订全部分片频道
+func SUnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	channels := toStrings(args)
+	if len(channels) == 0 {
+		channels = c.GetShardChannels()
+	}
+	unsubscribe(hub, c, _sunsubscribe, channels, hub.sunsubscribe)
+	return &reply.NoReply{}
+}
+
+func unsubscribe(hub *Hub, c resp.Connection, kind string, names []string, remove func(resp.Connection, string)) {
+	s := hub.lockSubscriber(c)
+	if len(names) == 0 {
+		_ = c.Write(makeSubsReply(kind, nil, c.SubsCount()))
+	}
+	for _, name := range names {
+		name := name
+		remove(c, name)
+		// 退订之前收到的消息排在确认之前
+		s.flush()
+		_ = c.Write(makeSubsReply(kind, &name, c.SubsCount()))
+	}
+	s.writeMu.Unlock()
+	hub.releaseSubscriber(c)
+}
+
+func toStrings(args [][]byte) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = string(arg)
+	}
+	return result
+}
+
+// Publish PUBLISH channel message，返回收到消息的订阅者数量
+func Publish(hub *Hub, args [][]byte) resp.Reply {
+	if len(args) != 2 {
+		return reply.NewArgNumErrReply("publish")
+	}
+	return reply.NewIntReply(int64(hub.PublishChannel(string(args[0]), args[1])))
+}
+
+// SPublish SPUBLISH shardchannel message，集群模式下由频道所属的节点执行
+func SPublish(hub *Hub, args [][]byte) resp.Reply {
+	if len(args) != 2 {
+		return reply.NewArgNumErrReply("spublish")
+	}
+	return reply.NewIntReply(int64(hub.PublishShard(string(args[0]), args[1])))
+}
+
+// PubSub PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+// | SHARDCHANNELS [pattern] | SHARDNUMSUB [shardchannel ...]
+func PubSub(hub *Hub, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.NewArgNumErrReply("pubsub")
+	}
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "channels", "shardchannels":
+		if len(args) > 2 {
+			return reply.NewArgNumErrReply("pubsub|" + subCmd)
+		}
+		source := hub.channels
+		if subCmd == "shardchannels" {
+			source = hub.shardChannels
+		}
+		var p *wildcard.Pattern
+		if len(args) == 2 {
+			var err error
+			p, err = wildcard.CompilePattern(string(args[1]))
+			if err != nil {
+				return reply.NewErrReply("ERR invalid pattern: " + string(args[1]))
+			}
+		}
+		channels := make([]string, 0, len(source))
+		for channel := range source {
+			if p == nil || p.IsMatch(channel) {
+				channels = append(channels, channel)
+			}
+		}
+		sort.Strings(channels)
+		result := make([][]byte, len(channels))
+		for i, channel := range channels {
+			result[i] = []byte(channel)
+		}
+		return reply.NewMultiBulkReply(result)
+	case "numsub", "shardnumsub":
+		source := hub.channels
+		if subCmd == "shardnumsub" {
+			source = hub.shardChannels
+		}
+		result := make([]resp.Reply, 0, 2*(len(args)-1))
+		for _, arg := range args[1:] {
+			result = append(result,
+				reply.NewBulkReply(arg),
+				reply.NewIntReply(int64(len(source[string(arg)]))))
+		}
+		return reply.NewMultiRawReply(result)
+	case "numpat":
+		if len(args) != 1 {
+			return reply.NewArgNumErrReply("pubsub|numpat")
+		}
+		return reply.NewIntReply(int64(len(hub.patterns)))
+	}
+	return reply.NewErrReply("ERR unknown subcommand '" + subCmd + "'. Try PUBSUB HELP.")
+}
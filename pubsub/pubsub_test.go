@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"testing"
+	"time"
+)
+
+// waitBytes 消息是异步写出的，等待一段时间直到收到expected
+func waitBytes(t *testing.T, conn *connection.FakeConn, expected []byte) {
+	t.Helper()
+	var actual []byte
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		actual = append(actual, conn.Bytes()...)
+		if len(actual) >= len(expected) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !utils.BytesEquals(actual, expected) {
+		t.Errorf("expected %q, actually %q", string(expected), string(actual))
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	hub := MakeHub()
+	conn1, conn2 := connection.NewFakeConn(), connection.NewFakeConn()
+	Subscribe(hub, conn1, utils.ToCmdLine("news", "sport"))
+	waitBytes(t, conn1, append(makeSubsReply("subscribe", strPtr("news"), 1), makeSubsReply("subscribe", strPtr("sport"), 2)...))
+	PSubscribe(hub, conn2, utils.ToCmdLine("n*"))
+	waitBytes(t, conn2, makeSubsReply("psubscribe", strPtr("n*"), 1))
+
+	result := Publish(hub, utils.ToCmdLine("news", "hello"))
+	if intReply, _ := result.(*reply.IntReply); intReply == nil || intReply.Code != 2 {
+		t.Errorf("expected 2 receivers, actually %s", string(result.ToBytes()))
+	}
+	waitBytes(t, conn1, makeMessage("news", []byte("hello")))
+	waitBytes(t, conn2, makePMessage("n*", "news", []byte("hello")))
+
+	result = PubSub(hub, utils.ToCmdLine("numsub", "news", "other"))
+	expected := reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte("news")), reply.NewIntReply(1),
+		reply.NewBulkReply([]byte("other")), reply.NewIntReply(0),
+	})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Errorf("expected %q, actually %q", string(expected.ToBytes()), string(result.ToBytes()))
+	}
+
+	// 订阅状态下只允许订阅相关的命令
+	if CheckSubscribedMode(conn1, "get") == nil {
+		t.Error("get should be rejected in subscribed mode")
+	}
+	if CheckSubscribedMode(conn1, "ping") != nil {
+		t.Error("ping should be allowed in subscribed mode")
+	}
+
+	UnSubscribe(hub, conn1, nil)
+	conn1Bytes := conn1.Bytes()
+	if conn1.SubsCount() != 0 || len(conn1Bytes) == 0 {
+		t.Error("unsubscribe all failed")
+	}
+	if CheckSubscribedMode(conn1, "get") != nil {
+		t.Error("get should be allowed after unsubscribe")
+	}
+	result = Publish(hub, utils.ToCmdLine("sport", "goal"))
+	if intReply, _ := result.(*reply.IntReply); intReply == nil || intReply.Code != 0 {
+		t.Errorf("expected 0 receivers, actually %s", string(result.ToBytes()))
+	}
+}
+
+func TestUnsubscribeAll(t *testing.T) {
+	hub := MakeHub()
+	conn := connection.NewFakeConn()
+	Subscribe(hub, conn, utils.ToCmdLine("a", "b"))
+	PSubscribe(hub, conn, utils.ToCmdLine("c*"))
+	hub.UnsubscribeAll(conn)
+	if conn.SubsCount() != 0 {
+		t.Error("subscriptions should be cleared")
+	}
+	result := PubSub(hub, utils.ToCmdLine("channels"))
+	if !utils.BytesEquals(result.ToBytes(), reply.NewMultiBulkReply([][]byte{}).ToBytes()) {
+		t.Errorf("expected no channels, actually %q", string(result.ToBytes()))
+	}
+	result = PubSub(hub, utils.ToCmdLine("numpat"))
+	if intReply, _ := result.(*reply.IntReply); intReply == nil || intReply.Code != 0 {
+		t.Errorf("expected 0 patterns, actually %s", string(result.ToBytes()))
+	}
+	if len(hub.subscribers) != 0 {
+		t.Error("subscriber should be released")
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestShardPubSub(t *testing.T) {
+	hub := MakeHub()
+	conn := connection.NewFakeConn()
+	SSubscribe(hub, conn, utils.ToCmdLine("orders"))
+	waitBytes(t, conn, makeSubsReply("ssubscribe", strPtr("orders"), 1))
+
+	// 分片频道与普通频道相互独立
+	result := Publish(hub, utils.ToCmdLine("orders", "x"))
+	if intReply, _ := result.(*reply.IntReply); intReply == nil || intReply.Code != 0 {
+		t.Errorf("expected 0 receivers, actually %s", string(result.ToBytes()))
+	}
+	result = SPublish(hub, utils.ToCmdLine("orders", "y"))
+	if intReply, _ := result.(*reply.IntReply); intReply == nil || intReply.Code != 1 {
+		t.Errorf("expected 1 receiver, actually %s", string(result.ToBytes()))
+	}
+	waitBytes(t, conn, makeSMessage("orders", []byte("y")))
+
+	result = PubSub(hub, utils.ToCmdLine("shardchannels", "ord*"))
+	if !utils.BytesEquals(result.ToBytes(), reply.NewMultiBulkReply(utils.ToCmdLine("orders")).ToBytes()) {
+		t.Errorf("unexpected shard channels %q", string(result.ToBytes()))
+	}
+	SUnSubscribe(hub, conn, nil)
+	waitBytes(t, conn, makeSubsReply("sunsubscribe", strPtr("orders"), 0))
+	if hub.ShardNumSub("orders") != 0 {
+		t.Error("shard channel should be unsubscribed")
+	}
+}
@@ -0,0 +1,11 @@
+package rdb
+
+import "hash/crc64"
+
+// redis使用Jones多项式的crc64，输入输出都按位反转，初始值为0，结果不取反
+// 标准库的crc64在计算前后都会取反，这里抵消掉
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+func crc64Update(crc uint64, p []byte) uint64 {
+	return ^crc64.Update(^crc, crcTable, p)
+}
@@ -0,0 +1,505 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"go-redis/datastruct/dict"
+	List "go-redis/datastruct/list"
+	HashSet "go-redis/datastruct/set"
+	SortedSet "go-redis/datastruct/sortedset"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Decoder 读取RDB文件，读到EOF标记和校验和后停止，不会多读后面的内容
+type Decoder struct {
+	r       *bufio.Reader
+	crc     uint64
+	version int
+}
+
+// NewDecoder r为*bufio.Reader时直接使用，解析结束后可以继续从r读取RDB之后的数据
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{
+		r: br,
+	}
+}
+
+func (dec *Decoder) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(dec.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	dec.crc = crc64Update(dec.crc, buf)
+	return buf, nil
+}
+
+func (dec *Decoder) readByte() (byte, error) {
+	b, err := dec.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	dec.crc = crc64Update(dec.crc, []byte{b})
+	return b, nil
+}
+
+// Parse 依次把读到的key交给consumer，consumer返回false时停止解析
+func (dec *Decoder) Parse(consumer func(obj *Object) bool) error {
+	header, err := dec.read(9)
+	if err != nil {
+		return err
+	}
+	if string(header[:5]) != magic {
+		return errors.New("rdb: invalid file header")
+	}
+	dec.version, err = strconv.Atoi(string(header[5:]))
+	if err != nil || dec.version < 1 || dec.version > maxVersion {
+		return errors.New("rdb: unsupported version " + string(header[5:]))
+	}
+	return dec.parseBody(consumer)
+}
+
+// DecodeObject 解析EncodeObject编码的一个key
+func DecodeObject(data []byte) (*Object, error) {
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.version = Version
+	var result *Object
+	err := dec.parseBody(func(obj *Object) bool {
+		result = obj
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (dec *Decoder) parseBody(consumer func(obj *Object) bool) error {
+	dbIndex := 0
+	var expireAt time.Time
+	for {
+		opcode, err := dec.readByte()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case opEOF:
+			return dec.checkSum()
+		case opSelectDB:
+			index, err := dec.readLength()
+			if err != nil {
+				return err
+			}
+			dbIndex = int(index)
+		case opResizeDB:
+			if _, err := dec.readLength(); err != nil {
+				return err
+			}
+			if _, err := dec.readLength(); err != nil {
+				return err
+			}
+		case opAux:
+			if _, err := dec.readString(); err != nil {
+				return err
+			}
+			if _, err := dec.readString(); err != nil {
+				return err
+			}
+		case opExpireTime:
+			raw, err := dec.read(4)
+			if err != nil {
+				return err
+			}
+			expireAt = time.Unix(int64(binary.LittleEndian.Uint32(raw)), 0)
+		case opExpireTimeMs:
+			ms, err := dec.readMillis()
+			if err != nil {
+				return err
+			}
+			expireAt = time.UnixMilli(ms)
+		case opIdle:
+			if _, err := dec.readLength(); err != nil {
+				return err
+			}
+		case opFreq:
+			if _, err := dec.readByte(); err != nil {
+				return err
+			}
+		case opSlotInfo:
+			// slot_id, slot_size, expires_slot_size
+			for i := 0; i < 3; i++ {
+				if _, err := dec.readLength(); err != nil {
+					return err
+				}
+			}
+		case opFunction2:
+			// 不支持函数，跳过函数库的代码
+			if _, err := dec.readString(); err != nil {
+				return err
+			}
+		case opModuleAux, opFunctionPreGA:
+			return errors.New("rdb: modules and functions are not supported")
+		default:
+			key, err := dec.readString()
+			if err != nil {
+				return err
+			}
+			value, err := dec.readObject(opcode)
+			if err != nil {
+				return err
+			}
+			obj := &Object{
+				DB:       dbIndex,
+				Key:      string(key),
+				Value:    value,
+				ExpireAt: expireAt,
+			}
+			expireAt = time.Time{}
+			if !consumer(obj) {
+				return nil
+			}
+		}
+	}
+}
+
+// checkSum 版本5之后文件末尾有8字节的校验和，为0表示写入时没有计算
+func (dec *Decoder) checkSum() error {
+	if dec.version < 5 {
+		return nil
+	}
+	expected := dec.crc
+	raw, err := dec.read(8)
+	if err != nil {
+		return err
+	}
+	actual := binary.LittleEndian.Uint64(raw)
+	if actual != 0 && actual != expected {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func (dec *Decoder) readMillis() (int64, error) {
+	raw, err := dec.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(raw)), nil
+}
+
+// readLengthOrEncoding encoded为true时n是特殊编码的类型(整数或LZF)
+func (dec *Decoder) readLengthOrEncoding() (n uint64, encoded bool, err error) {
+	b, err := dec.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b >> 6 {
+	case len6Bit:
+		return uint64(b & 0x3f), false, nil
+	case len14Bit:
+		next, err := dec.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3f)<<8 | uint64(next), false, nil
+	case lenEncVal:
+		return uint64(b & 0x3f), true, nil
+	}
+	switch b {
+	case len32Bit:
+		raw, err := dec.read(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(raw)), false, nil
+	case len64Bit:
+		raw, err := dec.read(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(raw), false, nil
+	}
+	return 0, false, ErrInvalidFormat
+}
+
+func (dec *Decoder) readLength() (uint64, error) {
+	n, encoded, err := dec.readLengthOrEncoding()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, ErrInvalidFormat
+	}
+	return n, nil
+}
+
+func (dec *Decoder) readString() ([]byte, error) {
+	n, encoded, err := dec.readLengthOrEncoding()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return dec.read(int(n))
+	}
+	switch n {
+	case encInt8, encInt16, encInt32:
+		raw, err := dec.read(1 << n)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(readIntLE(raw), 10)), nil
+	case encLZF:
+		compressedLen, err := dec.readLength()
+		if err != nil {
+			return nil, err
+		}
+		originLen, err := dec.readLength()
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := dec.read(int(compressedLen))
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(compressed, int(originLen))
+	}
+	return nil, ErrInvalidFormat
+}
+
+// readScore 旧的zset格式中分数以字符串存放，253/254/255分别表示NaN/+inf/-inf
+func (dec *Decoder) readScore() (float64, error) {
+	n, err := dec.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	raw, err := dec.read(int(n))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(raw), 64)
+}
+
+func (dec *Decoder) readBinaryScore() (float64, error) {
+	raw, err := dec.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(raw)), nil
+}
+
+// readStrings 读取由n个字符串组成的值
+func (dec *Decoder) readStrings(n uint64) ([][]byte, error) {
+	result := make([][]byte, 0, n)
+	for i := uint64(0); i < n; i++ {
+		s, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// readPacked 读取一个ziplist/listpack/intset编码的字符串并解码
+func (dec *Decoder) readPacked(decode func([]byte) ([][]byte, error)) ([][]byte, error) {
+	buf, err := dec.readString()
+	if err != nil {
+		return nil, err
+	}
+	return decode(buf)
+}
+
+func (dec *Decoder) readObject(objType byte) (interface{}, error) {
+	switch objType {
+	case typeString:
+		return dec.readString()
+	case typeList, typeSet, typeHash:
+		n, err := dec.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if objType == typeHash {
+			n *= 2
+		}
+		elements, err := dec.readStrings(n)
+		if err != nil {
+			return nil, err
+		}
+		return makeObject(objType, elements)
+	case typeListZiplist:
+		elements, err := dec.readPacked(decodeZiplist)
+		if err != nil {
+			return nil, err
+		}
+		return makeList(elements), nil
+	case typeListQuicklist, typeListQuicklist2:
+		return dec.readQuicklist(objType)
+	case typeSetIntset, typeSetListpack:
+		decode := decodeIntset
+		if objType == typeSetListpack {
+			decode = decodeListpack
+		}
+		elements, err := dec.readPacked(decode)
+		if err != nil {
+			return nil, err
+		}
+		return makeSet(elements), nil
+	case typeHashZiplist, typeHashListpack:
+		decode := decodeZiplist
+		if objType == typeHashListpack {
+			decode = decodeListpack
+		}
+		elements, err := dec.readPacked(decode)
+		if err != nil {
+			return nil, err
+		}
+		return makeObject(typeHash, elements)
+	case typeZSet, typeZSet2:
+		return dec.readZSet(objType)
+	case typeZSetZiplist, typeZSetListpack:
+		decode := decodeZiplist
+		if objType == typeZSetListpack {
+			decode = decodeListpack
+		}
+		elements, err := dec.readPacked(decode)
+		if err != nil {
+			return nil, err
+		}
+		return makeZSetFromPairs(elements)
+	case typeStreamListpacks, typeStreamListpacks2, typeStreamListpacks3:
+		return dec.readStream(objType)
+	}
+	return nil, errors.New("rdb: unsupported object type " + strconv.Itoa(int(objType)))
+}
+
+func (dec *Decoder) readQuicklist(objType byte) (List.List, error) {
+	nodeCount, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	list := List.NewQuickList()
+	for i := uint64(0); i < nodeCount; i++ {
+		container := uint64(quicklistNodePacked)
+		if objType == typeListQuicklist2 {
+			if container, err = dec.readLength(); err != nil {
+				return nil, err
+			}
+		}
+		buf, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		if container == quicklistNodePlain {
+			list.Add(buf)
+			continue
+		}
+		decode := decodeZiplist
+		if objType == typeListQuicklist2 {
+			decode = decodeListpack
+		}
+		elements, err := decode(buf)
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range elements {
+			list.Add(element)
+		}
+	}
+	return list, nil
+}
+
+func (dec *Decoder) readZSet(objType byte) (*SortedSet.SortedSet, error) {
+	n, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	zset := SortedSet.Make()
+	for i := uint64(0); i < n; i++ {
+		member, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if objType == typeZSet2 {
+			score, err = dec.readBinaryScore()
+		} else {
+			score, err = dec.readScore()
+		}
+		if err != nil {
+			return nil, err
+		}
+		zset.Add(string(member), score)
+	}
+	return zset, nil
+}
+
+func makeObject(objType byte, elements [][]byte) (interface{}, error) {
+	switch objType {
+	case typeList:
+		return makeList(elements), nil
+	case typeSet:
+		return makeSet(elements), nil
+	}
+	if len(elements)%2 != 0 {
+		return nil, ErrInvalidFormat
+	}
+	d := dict.NewSimpleDict()
+	for i := 0; i < len(elements); i += 2 {
+		d.Put(string(elements[i]), elements[i+1])
+	}
+	return d, nil
+}
+
+func makeList(elements [][]byte) List.List {
+	list := List.NewQuickList()
+	for _, element := range elements {
+		list.Add(element)
+	}
+	return list
+}
+
+func makeSet(elements [][]byte) *HashSet.Set {
+	set := HashSet.New()
+	for _, element := range elements {
+		set.Add(string(element))
+	}
+	return set
+}
+
+// makeZSetFromPairs 紧凑编码的zset按 成员 分数 成员 分数 ... 排列，分数为字符串或整数
+func makeZSetFromPairs(elements [][]byte) (*SortedSet.SortedSet, error) {
+	if len(elements)%2 != 0 {
+		return nil, ErrInvalidFormat
+	}
+	zset := SortedSet.Make()
+	for i := 0; i < len(elements); i += 2 {
+		score, err := strconv.ParseFloat(string(elements[i+1]), 64)
+		if err != nil {
+			return nil, ErrInvalidFormat
+		}
+		zset.Add(string(elements[i]), score)
+	}
+	return zset, nil
+}
@@ -0,0 +1,278 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"go-redis/datastruct/dict"
+	List "go-redis/datastruct/list"
+	HashSet "go-redis/datastruct/set"
+	SortedSet "go-redis/datastruct/sortedset"
+	"go-redis/datastruct/stream"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Encoder 按RDB格式写入数据，同时计算校验和，写完后需要调用WriteEnd
+type Encoder struct {
+	w   *bufio.Writer
+	crc uint64
+	buf [9]byte
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w: bufio.NewWriter(w),
+	}
+}
+
+func (enc *Encoder) write(p []byte) error {
+	enc.crc = crc64Update(enc.crc, p)
+	_, err := enc.w.Write(p)
+	return err
+}
+
+func (enc *Encoder) writeByte(b byte) error {
+	enc.buf[0] = b
+	return enc.write(enc.buf[:1])
+}
+
+// WriteHeader 写入文件头和辅助字段
+func (enc *Encoder) WriteHeader() error {
+	if err := enc.write([]byte(magic + "000" + strconv.Itoa(Version))); err != nil {
+		return err
+	}
+	aux := [][2]string{
+		{"redis-ver", "7.0.0"},
+		{"redis-bits", "64"},
+		{"ctime", strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	for _, field := range aux {
+		if err := enc.WriteAux(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (enc *Encoder) WriteAux(key string, value string) error {
+	if err := enc.writeByte(opAux); err != nil {
+		return err
+	}
+	if err := enc.writeString([]byte(key)); err != nil {
+		return err
+	}
+	return enc.writeString([]byte(value))
+}
+
+// WriteDBHeader 之后写入的key都属于数据库index，keyCount和ttlCount用于加载时预分配空间
+func (enc *Encoder) WriteDBHeader(index int, keyCount int, ttlCount int) error {
+	if err := enc.writeByte(opSelectDB); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(index)); err != nil {
+		return err
+	}
+	if err := enc.writeByte(opResizeDB); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(keyCount)); err != nil {
+		return err
+	}
+	return enc.writeLength(uint64(ttlCount))
+}
+
+// WriteObject 写入一个key，expireAt为零值表示没有过期时间
+func (enc *Encoder) WriteObject(key string, value interface{}, expireAt time.Time) error {
+	if !expireAt.IsZero() {
+		if err := enc.writeByte(opExpireTimeMs); err != nil {
+			return err
+		}
+		if err := enc.writeMillis(expireAt.UnixMilli()); err != nil {
+			return err
+		}
+	}
+	switch val := value.(type) {
+	case []byte:
+		return enc.writeObject(typeString, key, func() error {
+			return enc.writeString(val)
+		})
+	case List.List:
+		return enc.writeObject(typeList, key, func() error {
+			return enc.writeList(val)
+		})
+	case *HashSet.Set:
+		return enc.writeObject(typeSet, key, func() error {
+			return enc.writeSet(val)
+		})
+	case *SortedSet.SortedSet:
+		return enc.writeObject(typeZSet2, key, func() error {
+			return enc.writeZSet(val)
+		})
+	case dict.Dict:
+		return enc.writeObject(typeHash, key, func() error {
+			return enc.writeHash(val)
+		})
+	case *stream.Stream:
+		return enc.writeObject(typeStreamListpacks, key, func() error {
+			return enc.writeStream(val)
+		})
+	}
+	return errors.New("rdb: unsupported value type of key " + key)
+}
+
+// WriteEncoded 写入EncodeObject编码好的key
+func (enc *Encoder) WriteEncoded(data []byte) error {
+	return enc.write(data)
+}
+
+// WriteEnd 写入结束标记和校验和
+func (enc *Encoder) WriteEnd() error {
+	if err := enc.writeByte(opEOF); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(enc.buf[:8], enc.crc)
+	if _, err := enc.w.Write(enc.buf[:8]); err != nil {
+		return err
+	}
+	return enc.w.Flush()
+}
+
+// EncodeObject 把一个key编码到内存中，之后通过WriteEncoded写入文件
+func EncodeObject(key string, value interface{}, expireAt time.Time) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	if err := enc.WriteObject(key, value, expireAt); err != nil {
+		return nil, err
+	}
+	if err := enc.w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (enc *Encoder) writeObject(objType byte, key string, writeValue func() error) error {
+	if err := enc.writeByte(objType); err != nil {
+		return err
+	}
+	if err := enc.writeString([]byte(key)); err != nil {
+		return err
+	}
+	return writeValue()
+}
+
+// writeLength 长度编码：6位、14位、32位或64位，后两种为大端
+func (enc *Encoder) writeLength(n uint64) error {
+	switch {
+	case n < 1<<6:
+		return enc.writeByte(byte(n))
+	case n < 1<<14:
+		enc.buf[0] = len14Bit<<6 | byte(n>>8)
+		enc.buf[1] = byte(n)
+		return enc.write(enc.buf[:2])
+	case n <= math.MaxUint32:
+		enc.buf[0] = len32Bit
+		binary.BigEndian.PutUint32(enc.buf[1:], uint32(n))
+		return enc.write(enc.buf[:5])
+	}
+	enc.buf[0] = len64Bit
+	binary.BigEndian.PutUint64(enc.buf[1:], n)
+	return enc.write(enc.buf[:9])
+}
+
+// writeString 可以表示为32位整数的字符串按整数编码写入
+func (enc *Encoder) writeString(s []byte) error {
+	if len(s) > 0 && len(s) <= 11 {
+		if v, err := strconv.ParseInt(string(s), 10, 32); err == nil && strconv.FormatInt(v, 10) == string(s) {
+			return enc.writeInt(v)
+		}
+	}
+	if err := enc.writeLength(uint64(len(s))); err != nil {
+		return err
+	}
+	return enc.write(s)
+}
+
+func (enc *Encoder) writeInt(v int64) error {
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		enc.buf[0] = lenEncVal<<6 | encInt8
+		enc.buf[1] = byte(v)
+		return enc.write(enc.buf[:2])
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		enc.buf[0] = lenEncVal<<6 | encInt16
+		binary.LittleEndian.PutUint16(enc.buf[1:], uint16(v))
+		return enc.write(enc.buf[:3])
+	}
+	enc.buf[0] = lenEncVal<<6 | encInt32
+	binary.LittleEndian.PutUint32(enc.buf[1:], uint32(v))
+	return enc.write(enc.buf[:5])
+}
+
+func (enc *Encoder) writeMillis(ms int64) error {
+	binary.LittleEndian.PutUint64(enc.buf[:8], uint64(ms))
+	return enc.write(enc.buf[:8])
+}
+
+func (enc *Encoder) writeList(list List.List) error {
+	if err := enc.writeLength(uint64(list.Len())); err != nil {
+		return err
+	}
+	var err error
+	list.ForEach(func(i int, v interface{}) bool {
+		err = enc.writeString(v.([]byte))
+		return err == nil
+	})
+	return err
+}
+
+func (enc *Encoder) writeSet(set *HashSet.Set) error {
+	if err := enc.writeLength(uint64(set.Len())); err != nil {
+		return err
+	}
+	var err error
+	set.ForEach(func(member string) bool {
+		err = enc.writeString([]byte(member))
+		return err == nil
+	})
+	return err
+}
+
+// writeZSet 分数按小端的float64写入
+func (enc *Encoder) writeZSet(zset *SortedSet.SortedSet) error {
+	size := zset.Len()
+	if err := enc.writeLength(uint64(size)); err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+	var err error
+	zset.ForEachByRank(0, size, false, func(element *SortedSet.Element) bool {
+		if err = enc.writeString([]byte(element.Member)); err != nil {
+			return false
+		}
+		binary.LittleEndian.PutUint64(enc.buf[:8], math.Float64bits(element.Score))
+		err = enc.write(enc.buf[:8])
+		return err == nil
+	})
+	return err
+}
+
+func (enc *Encoder) writeHash(d dict.Dict) error {
+	if err := enc.writeLength(uint64(d.Len())); err != nil {
+		return err
+	}
+	var err error
+	d.ForEach(func(field string, value interface{}) bool {
+		if err = enc.writeString([]byte(field)); err != nil {
+			return false
+		}
+		err = enc.writeString(value.([]byte))
+		return err == nil
+	})
+	return err
+}
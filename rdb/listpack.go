@@ -0,0 +1,266 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+/*
+	listpack: <总字节数 uint32> <元素个数 uint16> <元素 ...> <0xFF>
+	每个元素为 <编码+数据> <backlen>，backlen记录前一部分的长度，用于从后向前遍历
+	写入stream时使用，读取时还用于redis 7以后的紧凑编码
+*/
+
+type listpackBuilder struct {
+	buf   []byte
+	count int
+}
+
+func newListpackBuilder() *listpackBuilder {
+	return &listpackBuilder{
+		buf: make([]byte, 6, 64),
+	}
+}
+
+func (b *listpackBuilder) appendInt(v int64) {
+	start := len(b.buf)
+	switch {
+	case v >= 0 && v <= 127:
+		b.buf = append(b.buf, byte(v))
+	case v >= -4096 && v <= 4095:
+		u := uint64(v) & 0x1fff
+		b.buf = append(b.buf, 0xc0|byte(u>>8), byte(u))
+	case v >= -32768 && v <= 32767:
+		b.buf = append(b.buf, 0xf1)
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(v))
+	case v >= -8388608 && v <= 8388607:
+		u := uint32(v)
+		b.buf = append(b.buf, 0xf2, byte(u), byte(u>>8), byte(u>>16))
+	case v >= -2147483648 && v <= 2147483647:
+		b.buf = append(b.buf, 0xf3)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v))
+	default:
+		b.buf = append(b.buf, 0xf4)
+		b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(v))
+	}
+	b.appendBacklen(len(b.buf) - start)
+}
+
+func (b *listpackBuilder) appendString(s []byte) {
+	start := len(b.buf)
+	switch {
+	case len(s) < 64:
+		b.buf = append(b.buf, 0x80|byte(len(s)))
+	case len(s) < 4096:
+		b.buf = append(b.buf, 0xe0|byte(len(s)>>8), byte(len(s)))
+	default:
+		b.buf = append(b.buf, 0xf0)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	}
+	b.buf = append(b.buf, s...)
+	b.appendBacklen(len(b.buf) - start)
+}
+
+// appendBacklen 长度按7位一组存放，高位在前，除第一个字节外最高位都是1
+func (b *listpackBuilder) appendBacklen(l int) {
+	size := backlenSize(l)
+	for i := size - 1; i >= 0; i-- {
+		v := byte(l>>(7*i)) & 127
+		if i != size-1 {
+			v |= 128
+		}
+		b.buf = append(b.buf, v)
+	}
+	b.count++
+}
+
+func backlenSize(l int) int {
+	switch {
+	case l <= 127:
+		return 1
+	case l < 16383:
+		return 2
+	case l < 2097151:
+		return 3
+	case l < 268435455:
+		return 4
+	}
+	return 5
+}
+
+func (b *listpackBuilder) build() []byte {
+	b.buf = append(b.buf, 0xff)
+	binary.LittleEndian.PutUint32(b.buf, uint32(len(b.buf)))
+	count := b.count
+	if count > 65535 {
+		count = 65535 // 元素个数未知
+	}
+	binary.LittleEndian.PutUint16(b.buf[4:], uint16(count))
+	return b.buf
+}
+
+// decodeListpack 返回listpack中的全部元素，整数转换为十进制字符串
+func decodeListpack(buf []byte) ([][]byte, error) {
+	if len(buf) < 7 {
+		return nil, ErrInvalidFormat
+	}
+	result := make([][]byte, 0)
+	pos := 6
+	for {
+		if pos >= len(buf) {
+			return nil, ErrInvalidFormat
+		}
+		b := buf[pos]
+		if b == 0xff {
+			return result, nil
+		}
+		var header, dataLen int
+		var intVal int64
+		isInt := true
+		switch {
+		case b&0x80 == 0:
+			header, intVal = 1, int64(b)
+		case b&0xc0 == 0x80:
+			header, dataLen, isInt = 1, int(b&0x3f), false
+		case b&0xe0 == 0xc0:
+			if pos+2 > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, intVal = 2, int64(b&0x1f)<<8|int64(buf[pos+1])
+			if intVal >= 1<<12 {
+				intVal -= 1 << 13
+			}
+		case b&0xf0 == 0xe0:
+			if pos+2 > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, dataLen, isInt = 2, int(b&0x0f)<<8|int(buf[pos+1]), false
+		case b == 0xf0:
+			if pos+5 > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, dataLen, isInt = 5, int(binary.LittleEndian.Uint32(buf[pos+1:])), false
+		case b >= 0xf1 && b <= 0xf4:
+			size := []int{2, 3, 4, 8}[b-0xf1]
+			if pos+1+size > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, intVal = 1+size, readIntLE(buf[pos+1:pos+1+size])
+		default:
+			return nil, ErrInvalidFormat
+		}
+		entryLen := header + dataLen
+		if pos+entryLen > len(buf) {
+			return nil, ErrInvalidFormat
+		}
+		if isInt {
+			result = append(result, []byte(strconv.FormatInt(intVal, 10)))
+		} else {
+			result = append(result, buf[pos+header:pos+entryLen])
+		}
+		pos += entryLen + backlenSize(entryLen)
+	}
+}
+
+// readIntLE 读取小端存放的有符号整数
+func readIntLE(buf []byte) int64 {
+	var u uint64
+	for i := len(buf) - 1; i >= 0; i-- {
+		u = u<<8 | uint64(buf[i])
+	}
+	shift := 64 - 8*uint(len(buf))
+	return int64(u<<shift) >> shift
+}
+
+/*
+ziplist: <总字节数 uint32> <最后一个元素的偏移 uint32> <元素个数 uint16> <元素 ...> <0xFF>
+每个元素为 <前一个元素的长度> <编码> <数据>，redis 7之前的紧凑编码
+*/
+func decodeZiplist(buf []byte) ([][]byte, error) {
+	if len(buf) < 11 {
+		return nil, ErrInvalidFormat
+	}
+	result := make([][]byte, 0)
+	pos := 10
+	for {
+		if pos >= len(buf) {
+			return nil, ErrInvalidFormat
+		}
+		if buf[pos] == 0xff {
+			return result, nil
+		}
+		// 前一个元素的长度
+		if buf[pos] < 254 {
+			pos++
+		} else {
+			pos += 5
+		}
+		if pos >= len(buf) {
+			return nil, ErrInvalidFormat
+		}
+		b := buf[pos]
+		var header, dataLen, intSize int
+		var intVal int64
+		switch {
+		case b>>6 == 0:
+			header, dataLen = 1, int(b&0x3f)
+		case b>>6 == 1:
+			if pos+2 > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, dataLen = 2, int(b&0x3f)<<8|int(buf[pos+1])
+		case b == 0x80:
+			if pos+5 > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, dataLen = 5, int(binary.BigEndian.Uint32(buf[pos+1:]))
+		case b == 0xc0:
+			intSize = 2
+		case b == 0xd0:
+			intSize = 4
+		case b == 0xe0:
+			intSize = 8
+		case b == 0xf0:
+			intSize = 3
+		case b == 0xfe:
+			intSize = 1
+		case b >= 0xf1 && b <= 0xfd:
+			header, intVal = 1, int64(b&0x0f)-1
+		default:
+			return nil, ErrInvalidFormat
+		}
+		if intSize > 0 {
+			if pos+1+intSize > len(buf) {
+				return nil, ErrInvalidFormat
+			}
+			header, intVal = 1+intSize, readIntLE(buf[pos+1:pos+1+intSize])
+		}
+		if pos+header+dataLen > len(buf) {
+			return nil, ErrInvalidFormat
+		}
+		if b>>6 == 3 { // 11开头的都是整数编码
+			result = append(result, []byte(strconv.FormatInt(intVal, 10)))
+		} else {
+			result = append(result, buf[pos+header:pos+header+dataLen])
+		}
+		pos += header + dataLen
+	}
+}
+
+// decodeIntset intset: <每个整数的字节数 uint32> <个数 uint32> <整数 ...>
+func decodeIntset(buf []byte) ([][]byte, error) {
+	if len(buf) < 8 {
+		return nil, ErrInvalidFormat
+	}
+	size := int(binary.LittleEndian.Uint32(buf))
+	count := int(binary.LittleEndian.Uint32(buf[4:]))
+	if (size != 2 && size != 4 && size != 8) || len(buf) < 8+size*count {
+		return nil, ErrInvalidFormat
+	}
+	result := make([][]byte, count)
+	for i := range result {
+		start := 8 + i*size
+		result[i] = []byte(strconv.FormatInt(readIntLE(buf[start:start+size]), 10))
+	}
+	return result, nil
+}
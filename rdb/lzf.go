@@ -0,0 +1,45 @@
+package rdb
+
+// lzfDecompress 解压redis用LZF压缩的字符串，expectedLen为压缩前的长度
+// 控制字节小于32时表示其后跟随ctrl+1字节的原文，否则为对已输出内容的回溯引用：
+// 高3位为长度(为7时长度还要加上下一个字节)，低5位与下一个字节组成回溯距离
+func lzfDecompress(in []byte, expectedLen int) ([]byte, error) {
+	out := make([]byte, 0, expectedLen)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 32 {
+			n := ctrl + 1
+			if i+n > len(in) {
+				return nil, ErrInvalidFormat
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(in) {
+				return nil, ErrInvalidFormat
+			}
+			length += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, ErrInvalidFormat
+		}
+		ref := len(out) - ((ctrl&0x1f)<<8 | int(in[i])) - 1
+		i++
+		if ref < 0 {
+			return nil, ErrInvalidFormat
+		}
+		// 引用的区域可能与正在写入的部分重叠，需要逐字节复制
+		for j := 0; j < length+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+	if len(out) != expectedLen {
+		return nil, ErrInvalidFormat
+	}
+	return out, nil
+}
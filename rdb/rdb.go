@@ -0,0 +1,99 @@
+package rdb
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+	与redis兼容的RDB文件格式
+	文件结构：REDIS<4位版本号> [AUX...] { SELECTDB [RESIZEDB] { [EXPIRETIME] <类型> key value } } EOF <crc64>
+
+	写入时只使用不依赖编码细节的基本类型(string/list/set/zset2/hash)，stream使用listpack，
+	版本号为9，redis 5.0及以后的版本都可以加载；
+	读取时还支持redis为节省空间使用的ziplist/listpack/intset/quicklist编码以及LZF压缩的字符串，
+	因此可以加载redis生成的RDB文件
+*/
+
+const (
+	magic = "REDIS"
+	// Version 写入的RDB版本
+	Version = 9
+	// maxVersion 能够读取的最高版本，对应redis 7.4
+	maxVersion = 12
+)
+
+// 值的类型
+const (
+	typeString           = 0
+	typeList             = 1
+	typeSet              = 2
+	typeZSet             = 3
+	typeHash             = 4
+	typeZSet2            = 5
+	typeModule           = 6
+	typeModule2          = 7
+	typeHashZipmap       = 9
+	typeListZiplist      = 10
+	typeSetIntset        = 11
+	typeZSetZiplist      = 12
+	typeHashZiplist      = 13
+	typeListQuicklist    = 14
+	typeStreamListpacks  = 15
+	typeHashListpack     = 16
+	typeZSetListpack     = 17
+	typeListQuicklist2   = 18
+	typeStreamListpacks2 = 19
+	typeSetListpack      = 20
+	typeStreamListpacks3 = 21
+)
+
+// 操作码
+const (
+	opSlotInfo      = 244
+	opFunction2     = 245
+	opFunctionPreGA = 246
+	opModuleAux     = 247
+	opIdle          = 248
+	opFreq          = 249
+	opAux           = 250
+	opResizeDB      = 251
+	opExpireTimeMs  = 252
+	opExpireTime    = 253
+	opSelectDB      = 254
+	opEOF           = 255
+)
+
+// 长度编码：前两位表示长度占用的位数，11表示后面是特殊编码的字符串
+const (
+	len6Bit   = 0
+	len14Bit  = 1
+	len32Bit  = 0x80
+	len64Bit  = 0x81
+	lenEncVal = 3
+
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+// quicklist2的节点类型
+const (
+	quicklistNodePlain  = 1
+	quicklistNodePacked = 2
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid rdb format")
+	ErrChecksum      = errors.New("rdb checksum mismatch")
+)
+
+// Object 从RDB文件中读取的一个key
+type Object struct {
+	DB    int
+	Key   string
+	Value interface{} // []byte, list.List, dict.Dict, *set.Set, *sortedset.SortedSet, *stream.Stream
+	// ExpireAt 过期时间，零值表示没有过期时间
+	ExpireAt time.Time
+}
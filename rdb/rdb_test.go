@@ -0,0 +1,298 @@
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"go-redis/datastruct/dict"
+	List "go-redis/datastruct/list"
+	HashSet "go-redis/datastruct/set"
+	SortedSet "go-redis/datastruct/sortedset"
+	"go-redis/datastruct/stream"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeAll(t *testing.T, data []byte) map[string]*Object {
+	objects := make(map[string]*Object)
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(obj *Object) bool {
+		objects[obj.Key] = obj
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
+
+func TestCRC64(t *testing.T) {
+	// redis源码crc64.c中的测试向量
+	if crc := crc64Update(0, []byte("123456789")); crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("unexpected crc %x", crc)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	list := List.NewQuickList()
+	for i := 0; i < 1000; i++ {
+		list.Add([]byte(strconv.Itoa(i)))
+	}
+	set := HashSet.New("a", "b", "100")
+	zset := SortedSet.Make()
+	zset.Add("a", 1.5)
+	zset.Add("b", -3)
+	zset.Add("c", math.Inf(1))
+	hash := dict.NewSimpleDict()
+	hash.Put("f1", []byte("v1"))
+	hash.Put("f2", []byte(strings.Repeat("x", 20000)))
+	expireAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]interface{}{
+		"str":    []byte("hello"),
+		"int":    []byte("-123456"),
+		"list":   list,
+		"set":    set,
+		"zset":   zset,
+		"hash":   hash,
+		"notint": []byte("0123"),
+	}
+	for key, value := range values {
+		var expire time.Time
+		if key == "str" {
+			expire = expireAt
+		}
+		if err := enc.WriteObject(key, value, expire); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteDBHeader(3, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := EncodeObject("db3", []byte("v"), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEncoded(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := decodeAll(t, buf.Bytes())
+	if len(objects) != len(values)+1 {
+		t.Fatalf("expected %d keys, actually %d", len(values)+1, len(objects))
+	}
+	for _, key := range []string{"str", "int", "notint"} {
+		if actual := objects[key].Value.([]byte); !bytes.Equal(actual, values[key].([]byte)) {
+			t.Errorf("%s: expected %s, actually %s", key, values[key], actual)
+		}
+	}
+	if !objects["str"].ExpireAt.Equal(expireAt) {
+		t.Errorf("expected expire at %v, actually %v", expireAt, objects["str"].ExpireAt)
+	}
+	if !objects["int"].ExpireAt.IsZero() {
+		t.Error("expected no expire time")
+	}
+	if obj := objects["db3"]; obj.DB != 3 || string(obj.Value.([]byte)) != "v" {
+		t.Errorf("unexpected db3 object %+v", obj)
+	}
+
+	decodedList := objects["list"].Value.(List.List)
+	if decodedList.Len() != 1000 {
+		t.Fatalf("expected list len 1000, actually %d", decodedList.Len())
+	}
+	decodedList.ForEach(func(i int, v interface{}) bool {
+		if string(v.([]byte)) != strconv.Itoa(i) {
+			t.Errorf("list[%d]: actually %s", i, v)
+			return false
+		}
+		return true
+	})
+
+	decodedSet := objects["set"].Value.(*HashSet.Set)
+	if decodedSet.Len() != 3 || !decodedSet.Has("100") || !decodedSet.Has("a") {
+		t.Errorf("unexpected set %v", decodedSet.ToSlice())
+	}
+
+	decodedZSet := objects["zset"].Value.(*SortedSet.SortedSet)
+	for _, member := range []string{"a", "b", "c"} {
+		expected, _ := zset.Get(member)
+		actual, ok := decodedZSet.Get(member)
+		if !ok || actual.Score != expected.Score {
+			t.Errorf("zset %s: expected %v, actually %v", member, expected.Score, actual)
+		}
+	}
+
+	decodedHash := objects["hash"].Value.(dict.Dict)
+	if decodedHash.Len() != 2 {
+		t.Errorf("expected hash len 2, actually %d", decodedHash.Len())
+	}
+	if v, _ := decodedHash.Get("f2"); len(v.([]byte)) != 20000 {
+		t.Error("unexpected hash value")
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	s := stream.New()
+	for i := 1; i <= 250; i++ {
+		fields := [][]byte{[]byte("name"), []byte("n" + strconv.Itoa(i))}
+		if i%7 == 0 {
+			fields = append(fields, []byte("extra"), []byte(strconv.Itoa(-i*1000)))
+		}
+		s.Add(stream.ID{Ms: uint64(1000 + i/3), Seq: uint64(i % 3)}, fields)
+	}
+	s.Delete(stream.ID{Ms: 1001, Seq: 0})
+	s.SetLastID(stream.ID{Ms: 5000, Seq: 1})
+	now := time.UnixMilli(time.Now().UnixMilli())
+	group, _ := s.CreateGroup("g1", stream.ID{Ms: 1002, Seq: 0})
+	consumer, _ := group.CreateConsumer("alice", now)
+	group.AddPending(stream.ID{Ms: 1001, Seq: 1}, consumer, now, 2)
+	group.AddPending(stream.ID{Ms: 1002, Seq: 0}, consumer, now, 1)
+	s.CreateGroup("g2", stream.ID{})
+
+	encoded, err := EncodeObject("s", s, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	_ = enc.WriteHeader()
+	_ = enc.WriteDBHeader(0, 1, 0)
+	_ = enc.WriteEncoded(encoded)
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := decodeAll(t, buf.Bytes())["s"].Value.(*stream.Stream)
+	if decoded.Len() != s.Len() || decoded.LastID() != s.LastID() {
+		t.Fatalf("expected len %d last %s, actually len %d last %s", s.Len(), s.LastID(), decoded.Len(), decoded.LastID())
+	}
+	expected := s.Range(stream.MinID, stream.MaxID, 0)
+	actual := decoded.Range(stream.MinID, stream.MaxID, 0)
+	for i := range expected {
+		if expected[i].ID != actual[i].ID || !bytes.Equal(bytes.Join(expected[i].Fields, nil), bytes.Join(actual[i].Fields, nil)) {
+			t.Fatalf("entry %d: expected %s, actually %s", i, expected[i].ID, actual[i].ID)
+		}
+	}
+	if len(decoded.Groups()) != 2 {
+		t.Fatalf("expected 2 groups, actually %d", len(decoded.Groups()))
+	}
+	decodedGroup := decoded.Group("g1")
+	if decodedGroup.LastID != group.LastID || decodedGroup.PendingLen() != 2 {
+		t.Fatalf("unexpected group %+v", decodedGroup)
+	}
+	pending := decodedGroup.Pending(stream.ID{Ms: 1001, Seq: 1})
+	if pending == nil || pending.Consumer.Name != "alice" || pending.DeliveryCount != 2 || !pending.DeliveryTime.Equal(now) {
+		t.Errorf("unexpected pending entry %+v", pending)
+	}
+	if c := decodedGroup.Consumer("alice"); c == nil || c.PendingLen() != 2 || !c.SeenTime.Equal(now) {
+		t.Errorf("unexpected consumer %+v", c)
+	}
+}
+
+func TestDecodeObject(t *testing.T) {
+	expireAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	encoded, err := EncodeObject("k", []byte("v"), expireAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := DecodeObject(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Key != "k" || string(obj.Value.([]byte)) != "v" || !obj.ExpireAt.Equal(expireAt) {
+		t.Errorf("unexpected object %+v", obj)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	encoded, _ := EncodeObject("k", []byte("v"), time.Time{})
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	_ = enc.WriteHeader()
+	_ = enc.WriteDBHeader(0, 1, 0)
+	_ = enc.WriteEncoded(encoded)
+	_ = enc.WriteEnd()
+	data := buf.Bytes()
+	data[len(data)-12] ^= 1 // 修改key
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(*Object) bool {
+		return true
+	})
+	if err != ErrChecksum {
+		t.Errorf("expected checksum error, actually %v", err)
+	}
+}
+
+// TestDecodeCompact 手工构造redis使用的紧凑编码
+func TestDecodeCompact(t *testing.T) {
+	ziplist := []byte{
+		17, 0, 0, 0, 14, 0, 0, 0, 2, 0,
+		0, 0x02, 'a', 'b', // prevlen=0, 长度为2的字符串
+		4, 0xf6, // prevlen=4, 立即数5
+		0xff,
+	}
+	intset := []byte{
+		2, 0, 0, 0, 2, 0, 0, 0,
+		0xfe, 0xff, 0x10, 0x00, // -2, 16
+	}
+	lzf := []byte{0x00, 'a', 0xe0, 0x00, 0x00} // 'a'后回溯复制9个字节
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("REDIS0009")
+	buf.Write([]byte{opSelectDB, 0})
+	buf.Write([]byte{typeListZiplist, 1, 'l', byte(len(ziplist))})
+	buf.Write(ziplist)
+	buf.Write([]byte{typeSetIntset, 1, 's', byte(len(intset))})
+	buf.Write(intset)
+	buf.Write([]byte{typeString, 1, 'z', lenEncVal<<6 | encLZF, byte(len(lzf)), 10})
+	buf.Write(lzf)
+	buf.Write([]byte{opExpireTime, 0x10, 0, 0, 0, typeString, 1, 'i', lenEncVal<<6 | encInt16, 0x39, 0x30})
+	buf.WriteByte(opEOF)
+	buf.Write(make([]byte, 8)) // 校验和为0表示不校验
+
+	objects := decodeAll(t, buf.Bytes())
+	var elements []string
+	objects["l"].Value.(List.List).ForEach(func(i int, v interface{}) bool {
+		elements = append(elements, string(v.([]byte)))
+		return true
+	})
+	if strings.Join(elements, ",") != "ab,5" {
+		t.Errorf("unexpected list %v", elements)
+	}
+	if set := objects["s"].Value.(*HashSet.Set); set.Len() != 2 || !set.Has("-2") || !set.Has("16") {
+		t.Errorf("unexpected set %v", set.ToSlice())
+	}
+	if s := string(objects["z"].Value.([]byte)); s != "aaaaaaaaaa" {
+		t.Errorf("unexpected lzf string %s", s)
+	}
+	if obj := objects["i"]; string(obj.Value.([]byte)) != "12345" || obj.ExpireAt.Unix() != 16 {
+		t.Errorf("unexpected object %+v", obj)
+	}
+}
+
+// TestDecoderStopsAtEnd 解析结束后可以继续从同一个reader读取后面的数据
+func TestDecoderStopsAtEnd(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	_ = enc.WriteHeader()
+	_ = enc.WriteEnd()
+	buf.WriteString("*1\r\n$4\r\nPING\r\n")
+	reader := bufio.NewReader(buf)
+	if err := NewDecoder(reader).Parse(func(*Object) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := reader.ReadString('\n'); line != "*1\r\n" {
+		t.Errorf("unexpected remaining %q", line)
+	}
+}
@@ -0,0 +1,403 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"go-redis/datastruct/stream"
+	"strconv"
+	"time"
+)
+
+/*
+	stream的消息按ID顺序分成若干节点，每个节点以第一条消息的ID(16字节大端)为key，内容为一个listpack：
+	主条目：<有效消息数> <已删除消息数> <字段数> <字段...> <0>
+	每条消息：<flags> <ms差值> <seq差值> [<字段数>] [<字段> <值>...|<值>...] <lp-count>
+	字段与主条目相同时设置SAMEFIELDS标记，只存放值
+	节点之后依次是消息数、lastID和消费者组
+*/
+
+const (
+	streamItemDeleted    = 1
+	streamItemSameFields = 2
+	// streamNodeMaxEntries 与redis的stream-node-max-entries默认值相同
+	streamNodeMaxEntries = 100
+)
+
+func appendStreamID(buf []byte, id stream.ID) []byte {
+	buf = binary.BigEndian.AppendUint64(buf, id.Ms)
+	return binary.BigEndian.AppendUint64(buf, id.Seq)
+}
+
+func parseStreamID(raw []byte) (stream.ID, error) {
+	if len(raw) != 16 {
+		return stream.ID{}, ErrInvalidFormat
+	}
+	return stream.ID{
+		Ms:  binary.BigEndian.Uint64(raw),
+		Seq: binary.BigEndian.Uint64(raw[8:]),
+	}, nil
+}
+
+func fieldNames(fields [][]byte) [][]byte {
+	names := make([][]byte, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		names = append(names, fields[i])
+	}
+	return names
+}
+
+func sameFieldNames(names [][]byte, fields [][]byte) bool {
+	if len(names)*2 != len(fields) {
+		return false
+	}
+	for i, name := range names {
+		if !bytes.Equal(name, fields[2*i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func buildStreamNode(entries []*stream.Entry) []byte {
+	master := entries[0].ID
+	masterFields := fieldNames(entries[0].Fields)
+	lp := newListpackBuilder()
+	lp.appendInt(int64(len(entries)))
+	lp.appendInt(0)
+	lp.appendInt(int64(len(masterFields)))
+	for _, field := range masterFields {
+		lp.appendString(field)
+	}
+	lp.appendInt(0)
+	for _, entry := range entries {
+		numFields := len(entry.Fields) / 2
+		same := sameFieldNames(masterFields, entry.Fields)
+		if same {
+			lp.appendInt(streamItemSameFields)
+		} else {
+			lp.appendInt(0)
+		}
+		lp.appendInt(int64(entry.ID.Ms - master.Ms))
+		lp.appendInt(int64(entry.ID.Seq - master.Seq))
+		lpCount := numFields + 3
+		if same {
+			for i := 1; i < len(entry.Fields); i += 2 {
+				lp.appendString(entry.Fields[i])
+			}
+		} else {
+			lp.appendInt(int64(numFields))
+			for _, field := range entry.Fields {
+				lp.appendString(field)
+			}
+			lpCount += numFields + 1
+		}
+		lp.appendInt(int64(lpCount))
+	}
+	return lp.build()
+}
+
+func (enc *Encoder) writeStreamID(id stream.ID) error {
+	if err := enc.writeLength(id.Ms); err != nil {
+		return err
+	}
+	return enc.writeLength(id.Seq)
+}
+
+func (enc *Encoder) writeStream(s *stream.Stream) error {
+	entries := s.Range(stream.MinID, stream.MaxID, 0)
+	nodeCount := (len(entries) + streamNodeMaxEntries - 1) / streamNodeMaxEntries
+	if err := enc.writeLength(uint64(nodeCount)); err != nil {
+		return err
+	}
+	for start := 0; start < len(entries); start += streamNodeMaxEntries {
+		end := start + streamNodeMaxEntries
+		if end > len(entries) {
+			end = len(entries)
+		}
+		if err := enc.writeString(appendStreamID(nil, entries[start].ID)); err != nil {
+			return err
+		}
+		if err := enc.writeString(buildStreamNode(entries[start:end])); err != nil {
+			return err
+		}
+	}
+	if err := enc.writeLength(uint64(len(entries))); err != nil {
+		return err
+	}
+	if err := enc.writeStreamID(s.LastID()); err != nil {
+		return err
+	}
+
+	groups := s.Groups()
+	if err := enc.writeLength(uint64(len(groups))); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := enc.writeString([]byte(group.Name)); err != nil {
+			return err
+		}
+		if err := enc.writeStreamID(group.LastID); err != nil {
+			return err
+		}
+		pending := group.PendingRange(stream.MinID, stream.MaxID, 0, func(*stream.PendingEntry) bool {
+			return true
+		})
+		if err := enc.writeLength(uint64(len(pending))); err != nil {
+			return err
+		}
+		for _, entry := range pending {
+			if err := enc.write(appendStreamID(nil, entry.ID)); err != nil {
+				return err
+			}
+			if err := enc.writeMillis(entry.DeliveryTime.UnixMilli()); err != nil {
+				return err
+			}
+			if err := enc.writeLength(entry.DeliveryCount); err != nil {
+				return err
+			}
+		}
+		consumers := group.Consumers()
+		if err := enc.writeLength(uint64(len(consumers))); err != nil {
+			return err
+		}
+		for _, consumer := range consumers {
+			if err := enc.writeString([]byte(consumer.Name)); err != nil {
+				return err
+			}
+			if err := enc.writeMillis(consumer.SeenTime.UnixMilli()); err != nil {
+				return err
+			}
+			consumerPending := consumer.PendingAfter(stream.MinID, 0)
+			if err := enc.writeLength(uint64(len(consumerPending))); err != nil {
+				return err
+			}
+			for _, entry := range consumerPending {
+				if err := enc.write(appendStreamID(nil, entry.ID)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/* ---- decode ---- */
+
+// parseStreamNode 解析一个节点中的消息，跳过已删除的消息
+func parseStreamNode(master stream.ID, elements [][]byte, s *stream.Stream) error {
+	ints := func(i int) (int64, error) {
+		if i >= len(elements) {
+			return 0, ErrInvalidFormat
+		}
+		return strconv.ParseInt(string(elements[i]), 10, 64)
+	}
+	if len(elements) < 4 {
+		return ErrInvalidFormat
+	}
+	numMaster, err := ints(2)
+	if err != nil || numMaster < 0 || int(numMaster)+4 > len(elements) {
+		return ErrInvalidFormat
+	}
+	masterFields := elements[3 : 3+numMaster]
+	for i := 4 + int(numMaster); i < len(elements); {
+		flags, err1 := ints(i)
+		msDiff, err2 := ints(i + 1)
+		seqDiff, err3 := ints(i + 2)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return ErrInvalidFormat
+		}
+		i += 3
+		var fields [][]byte
+		if flags&streamItemSameFields != 0 {
+			if i+int(numMaster) > len(elements) {
+				return ErrInvalidFormat
+			}
+			fields = make([][]byte, 0, 2*numMaster)
+			for j, name := range masterFields {
+				fields = append(fields, name, elements[i+j])
+			}
+			i += int(numMaster)
+		} else {
+			numFields, err := ints(i)
+			if err != nil || numFields < 0 || i+1+2*int(numFields) > len(elements) {
+				return ErrInvalidFormat
+			}
+			fields = elements[i+1 : i+1+2*int(numFields)]
+			i += 1 + 2*int(numFields)
+		}
+		i++ // lp-count
+		if flags&streamItemDeleted != 0 {
+			continue
+		}
+		id := stream.ID{
+			Ms:  master.Ms + uint64(msDiff),
+			Seq: master.Seq + uint64(seqDiff),
+		}
+		if !s.Add(id, fields) {
+			return ErrInvalidFormat
+		}
+	}
+	return nil
+}
+
+func (dec *Decoder) readStreamID() (stream.ID, error) {
+	ms, err := dec.readLength()
+	if err != nil {
+		return stream.ID{}, err
+	}
+	seq, err := dec.readLength()
+	if err != nil {
+		return stream.ID{}, err
+	}
+	return stream.ID{Ms: ms, Seq: seq}, nil
+}
+
+func (dec *Decoder) readRawStreamID() (stream.ID, error) {
+	raw, err := dec.read(16)
+	if err != nil {
+		return stream.ID{}, err
+	}
+	return parseStreamID(raw)
+}
+
+// readStream 读取15/19/21三种stream类型，后两种增加了redis 7的统计字段，这里读出后丢弃
+func (dec *Decoder) readStream(objType byte) (*stream.Stream, error) {
+	s := stream.New()
+	nodeCount, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < nodeCount; i++ {
+		rawMaster, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		master, err := parseStreamID(rawMaster)
+		if err != nil {
+			return nil, err
+		}
+		lp, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		elements, err := decodeListpack(lp)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseStreamNode(master, elements, s); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.readLength(); err != nil { // 消息数
+		return nil, err
+	}
+	lastID, err := dec.readStreamID()
+	if err != nil {
+		return nil, err
+	}
+	if !s.SetLastID(lastID) {
+		return nil, ErrInvalidFormat
+	}
+	if objType >= typeStreamListpacks2 {
+		// first_id, max_deleted_entry_id, entries_added
+		for j := 0; j < 5; j++ {
+			if _, err := dec.readLength(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	groupCount, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < groupCount; i++ {
+		if err := dec.readStreamGroup(objType, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (dec *Decoder) readStreamGroup(objType byte, s *stream.Stream) error {
+	name, err := dec.readString()
+	if err != nil {
+		return err
+	}
+	lastID, err := dec.readStreamID()
+	if err != nil {
+		return err
+	}
+	if objType >= typeStreamListpacks2 {
+		if _, err := dec.readLength(); err != nil { // entries_read
+			return err
+		}
+	}
+	group, ok := s.CreateGroup(string(name), lastID)
+	if !ok {
+		return ErrInvalidFormat
+	}
+
+	// 组的待确认列表记录投递时间和次数，所属的消费者在消费者的列表中给出
+	type delivery struct {
+		time  time.Time
+		count uint64
+	}
+	pendingCount, err := dec.readLength()
+	if err != nil {
+		return err
+	}
+	deliveries := make(map[stream.ID]delivery, pendingCount)
+	for i := uint64(0); i < pendingCount; i++ {
+		id, err := dec.readRawStreamID()
+		if err != nil {
+			return err
+		}
+		deliveryTime, err := dec.readMillis()
+		if err != nil {
+			return err
+		}
+		count, err := dec.readLength()
+		if err != nil {
+			return err
+		}
+		deliveries[id] = delivery{time.UnixMilli(deliveryTime), count}
+	}
+
+	consumerCount, err := dec.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < consumerCount; i++ {
+		consumerName, err := dec.readString()
+		if err != nil {
+			return err
+		}
+		seenTime, err := dec.readMillis()
+		if err != nil {
+			return err
+		}
+		if objType >= typeStreamListpacks3 {
+			if _, err := dec.readMillis(); err != nil { // active_time
+				return err
+			}
+		}
+		consumer, _ := group.CreateConsumer(string(consumerName), time.UnixMilli(seenTime))
+		count, err := dec.readLength()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < count; j++ {
+			id, err := dec.readRawStreamID()
+			if err != nil {
+				return err
+			}
+			d, ok := deliveries[id]
+			if !ok {
+				return ErrInvalidFormat
+			}
+			group.AddPending(id, consumer, d.time, d.count)
+		}
+	}
+	return nil
+}
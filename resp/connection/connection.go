@@ -1,35 +1,93 @@
 package connection
 
 import (
+	"go-redis/interface/resp"
 	"go-redis/lib/sync/wait"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	// 客户端id从1开始递增，0表示aof重放等内部使用的伪连接
+	nextID atomic.Uint64
+	// id -> resp.Connection，CLIENT TRACKING REDIRECT 据此找到接收失效消息的连接
+	clients sync.Map
+)
+
+// Lookup 根据id查找还没有关闭的连接
+func Lookup(id uint64) (resp.Connection, bool) {
+	c, ok := clients.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return c.(resp.Connection), true
+}
+
+func register(c resp.Connection) uint64 {
+	id := nextID.Add(1)
+	clients.Store(id, c)
+	return id
+}
+
 type Connection struct {
+	id uint64
 	conn net.Conn
 	waiting wait.Wait
 	mu sync.Mutex
 	selectedDB int
+	closed chan struct{} // Close时关闭
+	closeOnce sync.Once
+
+	// 事务状态
+	multiState bool
+	queue [][][]byte // MULTI之后排队等待EXEC的命令
+	txErrors []error // 排队时出现的错误，存在错误时EXEC直接放弃整个事务
+	watching map[resp.WatchKey]uint32 // WATCH的key -> WATCH时的版本号
+
+	// 订阅状态，连接关闭时可能在其他协程中读取，因此单独加锁
+	subsMu sync.Mutex
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	shardChannels map[string]struct{}
 }
 
 func NewConnection(conn net.Conn) *Connection {
-	return &Connection{
+	c := &Connection{
 		conn: conn,
+		closed: make(chan struct{}),
 	}
+	c.id = register(c)
+	return c
+}
+
+func (c *Connection) GetID() uint64 {
+	return c.id
 }
 
 func (c *Connection) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// Close 可以重复调用，只有第一次生效
 func (c *Connection) Close() error {
-	c.waiting.WaitWithTimeout(10 * time.Second)
-	_ = c.conn.Close()
+	c.closeOnce.Do(func() {
+		if c.closed != nil {
+			close(c.closed)
+		}
+		clients.Delete(c.id)
+		c.waiting.WaitWithTimeout(10 * time.Second)
+		_ = c.conn.Close()
+	})
 	return nil
 }
 
+// Done 返回连接关闭时被关闭的通道，aof重放使用的伪连接返回nil，永远不会关闭
+func (c *Connection) Done() <-chan struct{} {
+	return c.closed
+}
+
 func (c *Connection) Write(bytes []byte) error {
 	if len(bytes) == 0 {
 		return nil
@@ -52,5 +110,124 @@ func (c *Connection) SelectDB(id int) {
 	c.selectedDB = id
 }
 
+func (c *Connection) InMultiState() bool {
+	return c.multiState
+}
+
+// SetMultiState 进入或退出事务状态，退出时清空排队的命令和错误
+func (c *Connection) SetMultiState(state bool) {
+	if !state {
+		c.queue = nil
+		c.txErrors = nil
+	}
+	c.multiState = state
+}
+
+func (c *Connection) GetQueuedCmdLine() [][][]byte {
+	return c.queue
+}
+
+func (c *Connection) EnqueueCmd(cmdLine [][]byte) {
+	c.queue = append(c.queue, cmdLine)
+}
+
+func (c *Connection) AddTxError(err error) {
+	c.txErrors = append(c.txErrors, err)
+}
+
+func (c *Connection) GetTxErrors() []error {
+	return c.txErrors
+}
+
+func (c *Connection) GetWatching() map[resp.WatchKey]uint32 {
+	if c.watching == nil {
+		c.watching = make(map[resp.WatchKey]uint32)
+	}
+	return c.watching
+}
+
+func (c *Connection) ClearWatching() {
+	c.watching = nil
+}
+
+func (c *Connection) Subscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.channels == nil {
+		c.channels = make(map[string]struct{})
+	}
+	c.channels[channel] = struct{}{}
+}
+
+func (c *Connection) UnSubscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.channels, channel)
+}
 
+func (c *Connection) PSubscribe(pattern string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.patterns == nil {
+		c.patterns = make(map[string]struct{})
+	}
+	c.patterns[pattern] = struct{}{}
+}
 
+func (c *Connection) PUnSubscribe(pattern string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.patterns, pattern)
+}
+
+func (c *Connection) SSubscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.shardChannels == nil {
+		c.shardChannels = make(map[string]struct{})
+	}
+	c.shardChannels[channel] = struct{}{}
+}
+
+func (c *Connection) SUnSubscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.shardChannels, channel)
+}
+
+// SubsCount 订阅的频道、模式与分片频道数之和
+func (c *Connection) SubsCount() int {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	return len(c.channels) + len(c.patterns) + len(c.shardChannels)
+}
+
+func (c *Connection) GetChannels() []string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	channels := make([]string, 0, len(c.channels))
+	for channel := range c.channels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func (c *Connection) GetPatterns() []string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	patterns := make([]string, 0, len(c.patterns))
+	for pattern := range c.patterns {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func (c *Connection) GetShardChannels() []string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	channels := make([]string, 0, len(c.shardChannels))
+	for channel := range c.shardChannels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"sync"
+)
+
+// FakeConn 用于测试的连接，写入的数据保存在内存中
+type FakeConn struct {
+	Connection
+	bufMu sync.Mutex
+	buf   []byte
+}
+
+func NewFakeConn() *FakeConn {
+	c := &FakeConn{
+		Connection: Connection{
+			closed: make(chan struct{}),
+		},
+	}
+	c.id = register(c)
+	return c
+}
+
+func (c *FakeConn) Write(bytes []byte) error {
+	c.bufMu.Lock()
+	defer c.bufMu.Unlock()
+	c.buf = append(c.buf, bytes...)
+	return nil
+}
+
+// Bytes 取出目前写入的全部数据并清空
+func (c *FakeConn) Bytes() []byte {
+	c.bufMu.Lock()
+	defer c.bufMu.Unlock()
+	bytes := c.buf
+	c.buf = nil
+	return bytes
+}
+
+func (c *FakeConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		clients.Delete(c.id)
+	})
+	return nil
+}
@@ -22,6 +22,9 @@ var (
 	unknownErrReplyBytes = []byte("-ERR unknown\r\n")
 )
 
+// maxPipelineQueue 每个连接最多缓存的未执行请求数，超过后暂停读取，依靠TCP背压限制客户端
+const maxPipelineQueue = 1024
+
 type RespHandler struct {
 	activeConn sync.Map
 	closing atomic.Bool
@@ -42,10 +45,13 @@ func NewRespHandler() *RespHandler {
 	}
 }
 
+// closeClient 关闭连接并清理它在数据库中的状态，可以重复调用
 func (r *RespHandler) closeClient (client *connection.Connection) error {
+	if _, ok := r.activeConn.LoadAndDelete(client); !ok {
+		return nil
+	}
 	_ = client.Close()
 	_ = r.db.AfterClientClose(client)
-	r.activeConn.Delete(client)
 	return nil
 }
 
@@ -57,11 +63,13 @@ func (r *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 	client := connection.NewConnection(conn)
 	r.activeConn.Store(client, struct{}{})
 
-	ch := parser.ParseStream(conn)
+	stop := make(chan struct{})
+	defer close(stop)
+	ch := r.pipe(client, parser.ParseStream(conn), stop)
 
 	for payload := range ch {
 		if payload.Err != nil {
-			if payload.Err == io.EOF || errors.Is(payload.Err, io.ErrUnexpectedEOF) || strings.Contains(payload.Err.Error(), "use of closed network connection") {
+			if isClosedErr(payload.Err) {
 				_ = r.closeClient(client)
 				logger.Errorf("connection closed: %v\n", client.RemoteAddr().String())
 				return
@@ -84,6 +92,11 @@ func (r *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 			logger.Error("require multi bulk reply error")
 			continue
 		}
+		if len(rep.Args) > 0 && strings.ToLower(string(rep.Args[0])) == "quit" {
+			_ = client.Write(reply.NewOkReply().ToBytes())
+			_ = r.closeClient(client)
+			return
+		}
 		exec := r.db.Exec(client, rep.Args)
 		if exec != nil {
 			_ = client.Write(exec.ToBytes())
@@ -94,6 +107,58 @@ func (r *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 
 }
 
+func isClosedErr(err error) bool {
+	return err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// pipe 在执行命令期间继续读取客户端的请求并按顺序缓存，
+// 读到连接断开时立即关闭连接，使阻塞中的命令(如BLPOP)能及时放弃等待
+// 缓存满maxPipelineQueue个请求后暂停读取，直到有请求被执行，此时只能在缓存消耗后才能发现连接断开
+// handler退出时关闭stop，丢弃还没有执行的请求
+func (r *RespHandler) pipe(client *connection.Connection, in <-chan *parser.Payload, stop <-chan struct{}) <-chan *parser.Payload {
+	out := make(chan *parser.Payload)
+	go func() {
+		defer close(out)
+		queue := make([]*parser.Payload, 0)
+		for in != nil || len(queue) > 0 {
+			var sendCh chan<- *parser.Payload
+			var next *parser.Payload
+			if len(queue) > 0 {
+				sendCh = out
+				next = queue[0]
+			}
+			readCh := in
+			if len(queue) >= maxPipelineQueue {
+				readCh = nil
+			}
+			select {
+			case payload, ok := <-readCh:
+				if !ok {
+					in = nil
+					continue
+				}
+				if payload.Err != nil && isClosedErr(payload.Err) {
+					_ = r.closeClient(client)
+				}
+				queue = append(queue, payload)
+			case sendCh <- next:
+				queue[0] = nil
+				queue = queue[1:]
+			case <-stop:
+				// 让解析协程读到连接关闭后能够退出
+				if in != nil {
+					go func(in <-chan *parser.Payload) {
+						for range in {
+						}
+					}(in)
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
 func (r *RespHandler) Close() error {
 	logger.Info("handler shutting down!")
 	r.closing.Store(true)
@@ -26,6 +26,7 @@ type readState struct {
 	msgType             byte    // + - * $ :
 	args                [][]byte    // 已经读到的参数
 	bulkLen             int     // 如果是bulk string 还要记录它的长度
+	readingBulkBody     bool    // 下一行是bulk string的内容，需要按bulkLen读取
 }
 
 func (rs *readState) finished() bool {
@@ -136,7 +137,7 @@ func readLine(bufReader *bufio.Reader, state *readState) ([]byte, bool, error) {
 	var msg []byte
 	var err error
 
-	if state.bulkLen == 0 {
+	if !state.readingBulkBody {
 		msg, err = bufReader.ReadBytes('\n')
 		if err != nil { // io错误
 			return nil, true, err
@@ -153,7 +154,6 @@ func readLine(bufReader *bufio.Reader, state *readState) ([]byte, bool, error) {
 		if len(msg) == 0 || msg[len(msg) - 2] != '\r' || msg[len(msg) - 1] != '\n' { // 协议错误
 			return nil, false, errors.New("protocol error" + string(msg))
 		}
-		state.bulkLen = 0
 	}
 	return msg, false , nil
 }
@@ -193,9 +193,10 @@ func parseBulkHeader(msg []byte, state *readState) error {
 	}
 	if state.bulkLen == -1 {
 		return nil
-	} else if state.bulkLen > 0 {
+	} else if state.bulkLen >= 0 {
 		state.msgType = msg[0]
 		state.readingMultiLine = true
+		state.readingBulkBody = true
 		state.expectedArgsCount = 1
 		state.args = make([][]byte, 0, 1)
 		return nil
@@ -235,19 +236,27 @@ func parseSingleLineReply(msg []byte, state *readState) (resp.Reply, error) {
 // value\r\n
 // $0\r\n
 // \r\n
+// $-1\r\n 表示数组中的nil元素，解析为nil，与空字符串区分
 func readBody(msg []byte, state *readState) (err error) {
 	line := msg[:len(msg) - 2]
-	if line[0] == '$' {
+	if state.readingBulkBody {
+		// bulk string的内容可能以'$'开头，不能当作header解析
+		state.args = append(state.args, line)
+		state.readingBulkBody = false
+		state.bulkLen = 0
+		return nil
+	}
+	if len(line) > 0 && line[0] == '$' {
 		var code int64
 		code, err = strconv.ParseInt(string(line[1:]), 10, 64)
-		if err != nil {
+		if err != nil || code < -1 {
 			return errors.New("protocol error" + string(msg))
 		}
-		if code <= 0 {
-			state.bulkLen = 0
-			state.args = append(state.args, []byte{})
+		if code == -1 {
+			state.args = append(state.args, nil)
 		} else {
 			state.bulkLen = int(code)
+			state.readingBulkBody = true
 		}
 	} else {
 		state.args = append(state.args, line)
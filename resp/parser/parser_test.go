@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"bytes"
+	"go-redis/lib/utils"
+	"go-redis/resp/reply"
+	"testing"
+)
+
+func TestParseStream(t *testing.T) {
+	replies := []*reply.MultiBulkReply{
+		reply.NewMultiBulkReply(utils.ToCmdLine("set", "key", "")),
+		reply.NewMultiBulkReply([][]byte{[]byte("a"), nil, []byte("$1")}),
+		reply.NewMultiBulkReply(utils.ToCmdLine("", "\r\n")),
+	}
+	var data []byte
+	for _, r := range replies {
+		data = append(data, r.ToBytes()...)
+	}
+	data = append(data, reply.NewBulkReply([]byte("")).ToBytes()...)
+	data = append(data, reply.NewNullBulkReply().ToBytes()...)
+
+	ch := ParseStream(bytes.NewReader(data))
+	for i, expected := range replies {
+		payload := <-ch
+		if payload.Err != nil {
+			t.Fatal(payload.Err)
+		}
+		actual, ok := payload.Data.(*reply.MultiBulkReply)
+		if !ok {
+			t.Fatalf("payload %d: expected multi bulk reply, actually %s", i, string(payload.Data.ToBytes()))
+		}
+		if len(actual.Args) != len(expected.Args) {
+			t.Fatalf("payload %d: expected %d args, actually %d", i, len(expected.Args), len(actual.Args))
+		}
+		for j := range expected.Args {
+			if !utils.BytesEquals(expected.Args[j], actual.Args[j]) {
+				t.Errorf("payload %d arg %d: expected %q, actually %q", i, j, expected.Args[j], actual.Args[j])
+			}
+		}
+	}
+	payload := <-ch
+	if bulk, ok := payload.Data.(*reply.BulkReply); !ok || len(bulk.Arg) != 0 {
+		t.Errorf("expected empty bulk reply, actually %#v", payload)
+	}
+	payload = <-ch
+	if _, ok := payload.Data.(*reply.NullBulkReply); !ok {
+		t.Errorf("expected null bulk reply, actually %#v", payload)
+	}
+}
@@ -57,6 +57,31 @@ func (r *NullMultiBulkReply) ToBytes() []byte {
 	return nullMultiBulkBytes
 }
 
+// NullArrayReply nil数组回复 *-1，与空数组 *0 不同，如WATCH的key被修改时EXEC的回复
+type NullArrayReply struct{}
+var nullArrayReply = new(NullArrayReply)
+func NewNullArrayReply() *NullArrayReply {
+	return nullArrayReply
+}
+
+var nullArrayBytes = []byte("*-1\r\n")
+
+func (r *NullArrayReply) ToBytes() []byte {
+	return nullArrayBytes
+}
+
+// QueuedReply 事务中命令入队的回复
+type QueuedReply struct{}
+var queuedReply = new(QueuedReply)
+func NewQueuedReply() *QueuedReply {
+	return queuedReply
+}
+
+var queuedBytes = []byte("+QUEUED\r\n")
+
+func (r *QueuedReply) ToBytes() []byte {
+	return queuedBytes
+}
 
 // NoReply 空回复
 type NoReply struct {
@@ -51,6 +51,12 @@ func (r *MultiBulkReply) ToBytes() []byte {
 	reply = append(reply, strconv.Itoa(argLen)...)
 	reply = append(reply, CRLF...)
 	for i := 0; i < argLen; i ++ {
+		// nil元素表示不存在的值，如MGET中不存在的key
+		if r.Args[i] == nil {
+			reply = append(reply, "$-1"...)
+			reply = append(reply, CRLF...)
+			continue
+		}
 		reply = append(reply, '$')
 		reply = append(reply, strconv.Itoa(len(r.Args[i]))...)
 		reply = append(reply, CRLF...)
@@ -139,3 +145,29 @@ func IsErrReply(reply resp.Reply) bool {
 
 
 
+
+/* ---- Multi Raw Reply ---- */
+
+// MultiRawReply stores a list of replies, used for nested arrays such as SCAN
+type MultiRawReply struct {
+	Replies []resp.Reply
+}
+
+// NewMultiRawReply creates MultiRawReply
+func NewMultiRawReply(replies []resp.Reply) *MultiRawReply {
+	return &MultiRawReply{
+		Replies: replies,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *MultiRawReply) ToBytes() []byte {
+	buf := make([]byte, 0, 1024)
+	buf = append(buf, '*')
+	buf = append(buf, strconv.Itoa(len(r.Replies))...)
+	buf = append(buf, CRLF...)
+	for _, rep := range r.Replies {
+		buf = append(buf, rep.ToBytes()...)
+	}
+	return buf
+}
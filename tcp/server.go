@@ -21,7 +21,7 @@ func ListenAndServeWithSignal(cfg Config, handler tcp.Handler) error {
 		return err
 	}
 	closeChan := make(chan struct{})
-	sigChan := make(chan os.Signal)
+	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
 		switch <- sigChan {
@@ -0,0 +1,179 @@
+package tracking
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/pubsub"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// InvalidateChannel RESP2客户端通过订阅这个频道的连接接收失效消息
+const InvalidateChannel = "__redis__:invalidate"
+
+/*
+	Table 客户端缓存(CLIENT TRACKING)的跟踪表
+	默认模式下记录每个key被哪些客户端读取过，key被修改时通知这些客户端并删除记录，
+	之后客户端再次读取时重新记录；BCAST模式不记录读取，修改了匹配前缀的key就通知
+	与redis相同，key不区分数据库，FLUSHDB时通知所有客户端清空整个缓存
+
+	这里只支持RESP2，失效消息只能通过REDIRECT指定的、订阅了InvalidateChannel的连接接收，
+	没有指定REDIRECT时仍然记录读取的key，但没有办法把消息发给客户端
+ */
+type Table struct {
+	hub *pubsub.Hub
+
+	mu       sync.Mutex
+	keys     map[string]map[resp.Connection]struct{} // key -> 读取过它的客户端
+	prefixes map[string]map[resp.Connection]struct{} // BCAST前缀 -> 客户端，空前缀匹配所有key
+	clients  map[resp.Connection]*client
+	// 开启了跟踪的客户端数量，为0时读写命令不需要访问跟踪表
+	enabled atomic.Int32
+}
+
+// client 一个开启了跟踪的客户端的选项
+type client struct {
+	redirect uint64 // 接收失效消息的客户端id，0表示没有重定向
+	bcast    bool
+	optIn    bool
+	optOut   bool
+	noLoop   bool // 不接收自己修改的key的失效消息
+	prefixes []string
+	// OPTIN模式下CLIENT CACHING yes，或OPTOUT模式下CLIENT CACHING no，只对下一条命令生效
+	caching bool
+}
+
+func MakeTable(hub *pubsub.Hub) *Table {
+	return &Table{
+		hub:      hub,
+		keys:     make(map[string]map[resp.Connection]struct{}),
+		prefixes: make(map[string]map[resp.Connection]struct{}),
+		clients:  make(map[resp.Connection]*client),
+	}
+}
+
+// Remember 记录客户端c在只读命令中读取的key，调用方需要持有这些key的读锁
+func (t *Table) Remember(c resp.Connection, keys []string) {
+	if t.enabled.Load() == 0 || c == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cli, ok := t.clients[c]
+	if !ok || cli.bcast {
+		return
+	}
+	if (cli.optIn && !cli.caching) || (cli.optOut && cli.caching) {
+		return
+	}
+	for _, key := range keys {
+		conns, ok := t.keys[key]
+		if !ok {
+			conns = make(map[resp.Connection]struct{})
+			t.keys[key] = conns
+		}
+		conns[c] = struct{}{}
+	}
+}
+
+// Invalidate 通知缓存了keys的客户端，writer是修改key的客户端，过期删除时为nil
+func (t *Table) Invalidate(writer resp.Connection, keys []string) {
+	if t.enabled.Load() == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// 同一条命令修改的key合并为一条消息
+	pending := make(map[resp.Connection][][]byte)
+	collect := func(c resp.Connection, key string) {
+		cli, ok := t.clients[c]
+		if !ok || (cli.noLoop && c == writer) {
+			return
+		}
+		pending[c] = append(pending[c], []byte(key))
+	}
+	for _, key := range keys {
+		for c := range t.keys[key] {
+			collect(c, key)
+		}
+		delete(t.keys, key)
+		for prefix, conns := range t.prefixes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			for c := range conns {
+				collect(c, key)
+			}
+		}
+	}
+	for c, invalidated := range pending {
+		t.send(t.clients[c], reply.NewMultiBulkReply(invalidated))
+	}
+}
+
+// InvalidateAll FLUSHDB后通知所有客户端清空缓存，消息内容为nil
+func (t *Table) InvalidateAll() {
+	if t.enabled.Load() == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys = make(map[string]map[resp.Connection]struct{})
+	for _, cli := range t.clients {
+		t.send(cli, reply.NewNullBulkReply())
+	}
+}
+
+// send 调用方需要持有t.mu
+func (t *Table) send(cli *client, message resp.Reply) {
+	if cli.redirect == 0 {
+		return
+	}
+	target, ok := connection.Lookup(cli.redirect)
+	if !ok {
+		return
+	}
+	t.hub.PublishTo(target, InvalidateChannel, message)
+}
+
+// AfterCommand 命令执行完后清除CLIENT CACHING的标记
+// CLIENT命令本身和事务排队期间保留标记，使其对EXEC中的所有命令生效
+func (t *Table) AfterCommand(c resp.Connection, cmdName string) {
+	if t.enabled.Load() == 0 || cmdName == "client" || c.InMultiState() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cli, ok := t.clients[c]; ok {
+		cli.caching = false
+	}
+}
+
+// Forget 关闭客户端c的跟踪，它在keys中的记录在对应的key被修改时清除
+func (t *Table) Forget(c resp.Connection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disable(c)
+}
+
+// disable 调用方需要持有t.mu
+func (t *Table) disable(c resp.Connection) {
+	cli, ok := t.clients[c]
+	if !ok {
+		return
+	}
+	for _, prefix := range cli.prefixes {
+		if conns, ok := t.prefixes[prefix]; ok {
+			delete(conns, c)
+			if len(conns) == 0 {
+				delete(t.prefixes, prefix)
+			}
+		}
+	}
+	delete(t.clients, c)
+	if t.enabled.Add(-1) == 0 {
+		t.keys = make(map[string]map[resp.Connection]struct{})
+	}
+}
@@ -0,0 +1,170 @@
+package tracking
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+// Tracking CLIENT TRACKING ON|OFF [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]]
+// [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+func Tracking(t *Table, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return reply.NewArgNumErrReply("client|tracking")
+	}
+	opts := &client{}
+	for i := 1; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "redirect":
+			if i+1 >= len(args) {
+				return reply.NewSyntaxErrReply()
+			}
+			i++
+			id, err := strconv.ParseUint(string(args[i]), 10, 64)
+			if err != nil {
+				return reply.NewErrReply("ERR value is not an integer or out of range")
+			}
+			opts.redirect = id
+		case "prefix":
+			if i+1 >= len(args) {
+				return reply.NewSyntaxErrReply()
+			}
+			i++
+			opts.prefixes = append(opts.prefixes, string(args[i]))
+		case "bcast":
+			opts.bcast = true
+		case "optin":
+			opts.optIn = true
+		case "optout":
+			opts.optOut = true
+		case "noloop":
+			opts.noLoop = true
+		default:
+			return reply.NewSyntaxErrReply()
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch strings.ToLower(string(args[0])) {
+	case "on":
+	case "off":
+		t.disable(c)
+		return reply.NewOkReply()
+	default:
+		return reply.NewSyntaxErrReply()
+	}
+
+	old, enabled := t.clients[c]
+	if opts.bcast {
+		if errReply := checkPrefixCollisions(old, opts.prefixes); errReply != nil {
+			return errReply
+		}
+	}
+	if enabled && old.bcast != opts.bcast {
+		return reply.NewErrReply("ERR You can't switch BCAST mode on/off before disabling tracking " +
+			"for this client, and then re-enabling it with a different mode.")
+	}
+	if opts.bcast && (opts.optIn || opts.optOut) {
+		return reply.NewErrReply("ERR OPTIN and OPTOUT are not compatible with BCAST")
+	}
+	if opts.optIn && opts.optOut {
+		return reply.NewErrReply("ERR You can't use both OPTIN and OPTOUT")
+	}
+	if enabled && ((opts.optIn && old.optOut) || (opts.optOut && old.optIn)) {
+		return reply.NewErrReply("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking " +
+			"for this client, and then re-enabling it with a different mode.")
+	}
+	if len(opts.prefixes) > 0 && !opts.bcast {
+		return reply.NewErrReply("ERR PREFIX option requires BCAST mode to be enabled")
+	}
+	if opts.redirect != 0 {
+		if _, ok := connection.Lookup(opts.redirect); !ok {
+			return reply.NewErrReply("ERR The client ID you want redirect to does not exist")
+		}
+	}
+
+	if !enabled {
+		t.clients[c] = opts
+		t.enabled.Add(1)
+	} else {
+		// 再次开启时更新选项，BCAST的前缀在原有的基础上增加
+		opts.prefixes = append(old.prefixes, opts.prefixes...)
+		*old = *opts
+		opts = old
+	}
+	if opts.bcast && len(opts.prefixes) == 0 {
+		opts.prefixes = []string{""}
+	}
+	for _, prefix := range opts.prefixes {
+		conns, ok := t.prefixes[prefix]
+		if !ok {
+			conns = make(map[resp.Connection]struct{})
+			t.prefixes[prefix] = conns
+		}
+		conns[c] = struct{}{}
+	}
+	return reply.NewOkReply()
+}
+
+// checkPrefixCollisions 同一个客户端的前缀之间不能互为前缀，否则一个key会收到重复的消息
+func checkPrefixCollisions(old *client, prefixes []string) resp.Reply {
+	for i, prefix := range prefixes {
+		if old != nil {
+			for _, existing := range old.prefixes {
+				if strings.HasPrefix(existing, prefix) || strings.HasPrefix(prefix, existing) {
+					return reply.NewErrReply("ERR Prefix '" + prefix + "' overlaps with an existing prefix '" +
+						existing + "'. Prefixes for a single client must not overlap.")
+				}
+			}
+		}
+		for _, other := range prefixes[i+1:] {
+			if strings.HasPrefix(other, prefix) || strings.HasPrefix(prefix, other) {
+				return reply.NewErrReply("ERR Prefix '" + prefix + "' overlaps with another provided prefix '" +
+					other + "'. Prefixes for a single client must not overlap.")
+			}
+		}
+	}
+	return nil
+}
+
+// Caching CLIENT CACHING YES|NO，OPTIN/OPTOUT模式下决定是否跟踪下一条命令读取的key
+func Caching(t *Table, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) != 1 {
+		return reply.NewArgNumErrReply("client|caching")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cli, ok := t.clients[c]
+	if !ok || (!cli.optIn && !cli.optOut) {
+		return reply.NewErrReply("ERR CLIENT CACHING can be called only when the client is in " +
+			"tracking mode with OPTIN or OPTOUT mode enabled")
+	}
+	switch strings.ToLower(string(args[0])) {
+	case "yes":
+		if !cli.optIn {
+			return reply.NewErrReply("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+		}
+	case "no":
+		if !cli.optOut {
+			return reply.NewErrReply("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+		}
+	default:
+		return reply.NewSyntaxErrReply()
+	}
+	cli.caching = true
+	return reply.NewOkReply()
+}
+
+// GetRedir CLIENT GETREDIR，没有开启跟踪时返回-1，没有重定向时返回0
+func GetRedir(t *Table, c resp.Connection) resp.Reply {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cli, ok := t.clients[c]
+	if !ok {
+		return reply.NewIntReply(-1)
+	}
+	return reply.NewIntReply(int64(cli.redirect))
+}
@@ -0,0 +1,195 @@
+package tracking
+
+import (
+	"go-redis/interface/resp"
+	"go-redis/lib/utils"
+	"go-redis/pubsub"
+	"go-redis/resp/connection"
+	"go-redis/resp/reply"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// makeRedirect 返回订阅了失效消息频道的连接，以及订阅确认之后收到的数据
+func makeRedirect(t *testing.T, hub *pubsub.Hub) *connection.FakeConn {
+	conn := connection.NewFakeConn()
+	pubsub.Subscribe(hub, conn, utils.ToCmdLine(InvalidateChannel))
+	waitBytes(t, conn, reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte("subscribe")),
+		reply.NewBulkReply([]byte(InvalidateChannel)),
+		reply.NewIntReply(1),
+	}).ToBytes())
+	return conn
+}
+
+func makeInvalidation(message resp.Reply) []byte {
+	return reply.NewMultiRawReply([]resp.Reply{
+		reply.NewBulkReply([]byte("message")),
+		reply.NewBulkReply([]byte(InvalidateChannel)),
+		message,
+	}).ToBytes()
+}
+
+// waitBytes 消息是异步写出的，等待一段时间直到收到expected
+func waitBytes(t *testing.T, conn *connection.FakeConn, expected []byte) {
+	t.Helper()
+	var actual []byte
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		actual = append(actual, conn.Bytes()...)
+		if len(actual) >= len(expected) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !utils.BytesEquals(actual, expected) {
+		t.Errorf("expected %q, actually %q", string(expected), string(actual))
+	}
+}
+
+// assertNoBytes 等待一小段时间，确认没有收到任何消息
+func assertNoBytes(t *testing.T, conn *connection.FakeConn) {
+	t.Helper()
+	time.Sleep(20 * time.Millisecond)
+	if actual := conn.Bytes(); len(actual) > 0 {
+		t.Errorf("expected nothing, actually %q", string(actual))
+	}
+}
+
+func assertReply(t *testing.T, actual resp.Reply, expected string) {
+	t.Helper()
+	if string(actual.ToBytes()) != expected {
+		t.Errorf("expected %q, actually %q", expected, string(actual.ToBytes()))
+	}
+}
+
+func redirectArg(conn resp.Connection) string {
+	return strconv.FormatUint(conn.GetID(), 10)
+}
+
+func TestDefaultMode(t *testing.T) {
+	hub := pubsub.MakeHub()
+	table := MakeTable(hub)
+	redirect := makeRedirect(t, hub)
+	conn, writer := connection.NewFakeConn(), connection.NewFakeConn()
+
+	assertReply(t, GetRedir(table, conn), ":-1\r\n")
+	result := Tracking(table, conn, utils.ToCmdLine("on", "redirect", redirectArg(redirect)))
+	assertReply(t, result, "+OK\r\n")
+	assertReply(t, GetRedir(table, conn), ":"+redirectArg(redirect)+"\r\n")
+
+	table.Remember(conn, []string{"a", "b"})
+	table.Invalidate(writer, []string{"a", "c"})
+	waitBytes(t, redirect, makeInvalidation(reply.NewMultiBulkReply(utils.ToCmdLine("a"))))
+	// 通知之后不再跟踪，直到再次读取
+	table.Invalidate(writer, []string{"a"})
+	assertNoBytes(t, redirect)
+
+	table.InvalidateAll()
+	waitBytes(t, redirect, makeInvalidation(reply.NewNullBulkReply()))
+	table.Invalidate(writer, []string{"b"})
+	assertNoBytes(t, redirect)
+
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("off")), "+OK\r\n")
+	table.Remember(conn, []string{"a"})
+	table.Invalidate(writer, []string{"a"})
+	assertNoBytes(t, redirect)
+	if table.enabled.Load() != 0 || len(table.keys) != 0 {
+		t.Error("tracking table should be empty")
+	}
+}
+
+func TestNoLoop(t *testing.T) {
+	hub := pubsub.MakeHub()
+	table := MakeTable(hub)
+	redirect := makeRedirect(t, hub)
+	conn := connection.NewFakeConn()
+	Tracking(table, conn, utils.ToCmdLine("on", "redirect", redirectArg(redirect), "noloop"))
+
+	table.Remember(conn, []string{"a"})
+	table.Invalidate(conn, []string{"a"})
+	assertNoBytes(t, redirect)
+}
+
+func TestBroadcastMode(t *testing.T) {
+	hub := pubsub.MakeHub()
+	table := MakeTable(hub)
+	redirect := makeRedirect(t, hub)
+	conn := connection.NewFakeConn()
+	result := Tracking(table, conn, utils.ToCmdLine("on", "redirect", redirectArg(redirect),
+		"bcast", "prefix", "user:", "prefix", "order:"))
+	assertReply(t, result, "+OK\r\n")
+
+	// 不需要读取，匹配前缀的key被修改就会通知
+	table.Invalidate(nil, []string{"user:1", "item:1", "order:1"})
+	waitBytes(t, redirect, makeInvalidation(reply.NewMultiBulkReply(utils.ToCmdLine("user:1", "order:1"))))
+
+	result = Tracking(table, conn, utils.ToCmdLine("on", "bcast", "prefix", "user:vip"))
+	assertReply(t, result, "-ERR Prefix 'user:vip' overlaps with an existing prefix 'user:'. "+
+		"Prefixes for a single client must not overlap.\r\n")
+	result = Tracking(table, conn, utils.ToCmdLine("on"))
+	assertReply(t, result, "-ERR You can't switch BCAST mode on/off before disabling tracking "+
+		"for this client, and then re-enabling it with a different mode.\r\n")
+
+	Tracking(table, conn, utils.ToCmdLine("off"))
+	if len(table.prefixes) != 0 {
+		t.Error("prefixes should be removed")
+	}
+}
+
+func TestOptInOptOut(t *testing.T) {
+	hub := pubsub.MakeHub()
+	table := MakeTable(hub)
+	redirect := makeRedirect(t, hub)
+	conn := connection.NewFakeConn()
+
+	assertReply(t, Caching(table, conn, utils.ToCmdLine("yes")), "-ERR CLIENT CACHING can be called only "+
+		"when the client is in tracking mode with OPTIN or OPTOUT mode enabled\r\n")
+	Tracking(table, conn, utils.ToCmdLine("on", "redirect", redirectArg(redirect), "optin"))
+	assertReply(t, Caching(table, conn, utils.ToCmdLine("no")),
+		"-ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.\r\n")
+
+	// OPTIN模式下只跟踪CLIENT CACHING yes之后的一条命令
+	table.Remember(conn, []string{"a"})
+	assertReply(t, Caching(table, conn, utils.ToCmdLine("yes")), "+OK\r\n")
+	table.AfterCommand(conn, "client")
+	table.Remember(conn, []string{"b"})
+	table.AfterCommand(conn, "get")
+	table.Remember(conn, []string{"c"})
+	table.Invalidate(nil, []string{"a", "b", "c"})
+	waitBytes(t, redirect, makeInvalidation(reply.NewMultiBulkReply(utils.ToCmdLine("b"))))
+
+	Tracking(table, conn, utils.ToCmdLine("off"))
+	Tracking(table, conn, utils.ToCmdLine("on", "redirect", redirectArg(redirect), "optout"))
+	assertReply(t, Caching(table, conn, utils.ToCmdLine("no")), "+OK\r\n")
+	table.Remember(conn, []string{"a"})
+	table.AfterCommand(conn, "get")
+	table.Remember(conn, []string{"b"})
+	table.Invalidate(nil, []string{"a", "b"})
+	waitBytes(t, redirect, makeInvalidation(reply.NewMultiBulkReply(utils.ToCmdLine("b"))))
+}
+
+func TestTrackingErrors(t *testing.T) {
+	table := MakeTable(pubsub.MakeHub())
+	conn := connection.NewFakeConn()
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("on", "prefix", "a")),
+		"-ERR PREFIX option requires BCAST mode to be enabled\r\n")
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("on", "optin", "optout")),
+		"-ERR You can't use both OPTIN and OPTOUT\r\n")
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("on", "bcast", "optin")),
+		"-ERR OPTIN and OPTOUT are not compatible with BCAST\r\n")
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("on", "bcast", "prefix", "a", "prefix", "ab")),
+		"-ERR Prefix 'a' overlaps with another provided prefix 'ab'. Prefixes for a single client must not overlap.\r\n")
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("on", "redirect", "0x")),
+		"-ERR value is not an integer or out of range\r\n")
+
+	closed := connection.NewFakeConn()
+	_ = closed.Close()
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("on", "redirect", redirectArg(closed))),
+		"-ERR The client ID you want redirect to does not exist\r\n")
+	assertReply(t, Tracking(table, conn, utils.ToCmdLine("maybe")), "-Err syntax error\r\n")
+	if table.enabled.Load() != 0 {
+		t.Error("tracking should not be enabled")
+	}
+}